@@ -0,0 +1,227 @@
+// Package auth 는 정적 API 키, HTTP Basic(bcrypt 해시) 자격 증명, JWT bearer 토큰으로
+// 수신 REST 요청을 인증합니다.
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"servone/config"
+)
+
+// NoAuth는 경로 접두사 규칙이 있어도 엔드포인트의 인증을 끄는 인증 수단 이름입니다.
+const NoAuth = "none"
+
+// Policy.Authenticate 가 반환하는 실패 사유 (메트릭 레이블로도 사용)
+const (
+	ReasonMissing       = "missing_credentials"
+	ReasonInvalid       = "invalid_credentials"
+	ReasonMisconfigured = "misconfigured"
+)
+
+var (
+	// ErrNoCredentials는 요청에 해당 인증 수단의 자격 증명이 없을 때 반환됩니다.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials는 자격 증명이 있지만 틀렸을 때 반환됩니다.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Identity는 인증된 호출자입니다.
+type Identity struct {
+	Provider string                 `json:"provider"` // 설정된 인증 수단 이름
+	Type     string                 `json:"type"`     // api_key, basic, jwt
+	Subject  string                 `json:"subject"`  // 키 이름, 사용자 이름 또는 JWT sub
+	Claims   map[string]interface{} `json:"claims"`   // JWT 클레임 (그 외 유형은 {"sub": Subject})
+}
+
+// Provider는 한 가지 자격 증명 유형으로 요청을 인증합니다.
+type Provider interface {
+	// Authenticate는 호출자 신원을 반환합니다. 이 인증 수단의 자격 증명이 없으면
+	// ErrNoCredentials, 그 밖에는 다른 오류를 반환합니다.
+	Authenticate(r *http.Request) (*Identity, error)
+	// Challenge는 401 응답의 WWW-Authenticate 값을 반환합니다 (없으면 "").
+	Challenge() string
+}
+
+// NewProvider는 설정으로 Provider 를 생성합니다.
+func NewProvider(name string, cfg config.AuthProviderConfig) (Provider, error) {
+	switch strings.ToLower(cfg.Type) {
+	case "api_key", "apikey":
+		return newAPIKeyProvider(name, cfg)
+	case "basic":
+		return newBasicProvider(name, cfg)
+	case "jwt":
+		return newJWTProvider(name, cfg)
+	default:
+		return nil, fmt.Errorf("auth provider %s: unknown type %q", name, cfg.Type)
+	}
+}
+
+// CredentialHeaders는 설정된 인증 수단이 자격 증명을 읽는 요청 헤더를 반환합니다
+// (녹화와 업스트림 요청에서 제외하는 데 사용).
+func CredentialHeaders(cfg config.AuthConfig) []string {
+	seen := make(map[string]bool)
+	var headers []string
+	for _, p := range cfg.Providers {
+		header := "Authorization"
+		if t := strings.ToLower(p.Type); t == "api_key" || t == "apikey" {
+			header = p.Header
+			if header == "" && p.Query == "" {
+				header = defaultAPIKeyHeader
+			}
+		}
+		if header == "" {
+			continue
+		}
+		header = http.CanonicalHeaderKey(header)
+		if !seen[header] {
+			seen[header] = true
+			headers = append(headers, header)
+		}
+	}
+	sort.Strings(headers)
+	return headers
+}
+
+// CredentialQueryParams는 api_key 인증 수단이 키를 읽는 쿼리 파라미터를 반환합니다.
+func CredentialQueryParams(cfg config.AuthConfig) []string {
+	var params []string
+	for _, p := range cfg.Providers {
+		if t := strings.ToLower(p.Type); (t == "api_key" || t == "apikey") && p.Query != "" {
+			params = append(params, p.Query)
+		}
+	}
+	sort.Strings(params)
+	return params
+}
+
+// Authorizer는 컴파일된 인증 수단과 접두사 규칙을 담습니다.
+type Authorizer struct {
+	providers map[string]Provider
+	broken    map[string]bool // 컴파일에 실패한 인증 수단
+	rules     []config.AuthRule
+}
+
+// Compile은 Authorizer 를 생성합니다. 컴파일에 실패한 인증 수단은 오류로 보고하고,
+// 이를 참조하는 정책은 모든 요청을 거부합니다 (fail closed).
+func Compile(cfg config.AuthConfig) (*Authorizer, []error) {
+	a := &Authorizer{
+		providers: make(map[string]Provider, len(cfg.Providers)),
+		broken:    make(map[string]bool),
+	}
+
+	var errs []error
+	names := make([]string, 0, len(cfg.Providers))
+	for name := range cfg.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p, err := NewProvider(name, cfg.Providers[name])
+		if err != nil {
+			errs = append(errs, err)
+			a.broken[name] = true
+			continue
+		}
+		a.providers[name] = p
+	}
+
+	a.rules = append(a.rules, cfg.Rules...)
+	sort.SliceStable(a.rules, func(i, j int) bool { return len(a.rules[i].Prefix) > len(a.rules[j].Prefix) })
+	return a, errs
+}
+
+// matchesPrefix는 path 가 경로 단위로 prefix 아래에 있는지 확인합니다
+// ("/admin" 은 "/admin/users" 에 적용되지만 "/administrator" 에는 적용되지 않음).
+func matchesPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/")
+}
+
+// Policy는 라우트의 정책을 반환합니다. 엔드포인트 자체 인증 수단 목록이 있으면 그것을,
+// 없으면 가장 긴 일치 접두사 규칙을 사용하며, 보호하지 않는 라우트는 nil 을 반환합니다.
+func (a *Authorizer) Policy(endpointAuth []string, path string) *Policy {
+	if a == nil {
+		return nil
+	}
+
+	names := endpointAuth
+	if len(names) == 0 {
+		for _, rule := range a.rules {
+			if matchesPrefix(path, rule.Prefix) {
+				names = rule.Providers
+				break
+			}
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	policy := &Policy{}
+	for _, name := range names {
+		if name == NoAuth {
+			return nil
+		}
+		p, ok := a.providers[name]
+		if !ok {
+			problem := "is not configured"
+			if a.broken[name] {
+				problem = "failed to load"
+			}
+			policy.errs = append(policy.errs, fmt.Sprintf("provider %q %s", name, problem))
+			continue
+		}
+		policy.names = append(policy.names, name)
+		policy.providers = append(policy.providers, p)
+	}
+	return policy
+}
+
+// Policy는 한 라우트에서 허용하는 인증 수단 묶음입니다. 하나라도 성공하면 인증됩니다.
+type Policy struct {
+	names     []string
+	providers []Provider
+	errs      []string // 설정 문제 (인증 수단이 하나도 없는 정책은 모든 요청 거부)
+}
+
+// Providers는 사용 가능한 인증 수단 이름을 반환합니다.
+func (p *Policy) Providers() []string {
+	return p.names
+}
+
+// Problems는 정책 생성 중 발견한 설정 문제를 반환합니다.
+func (p *Policy) Problems() []string {
+	return p.errs
+}
+
+// Authenticate는 인증 수단을 순서대로 시도합니다. 실패하면 실패 사유(Reason 상수)와
+// 원인 오류를 반환합니다.
+func (p *Policy) Authenticate(r *http.Request) (*Identity, string, error) {
+	if len(p.providers) == 0 {
+		return nil, ReasonMisconfigured, fmt.Errorf("no usable auth provider: %s", strings.Join(p.errs, "; "))
+	}
+
+	reason, lastErr := ReasonMissing, error(ErrNoCredentials)
+	for _, provider := range p.providers {
+		id, err := provider.Authenticate(r)
+		if err == nil {
+			return id, "", nil
+		}
+		if !errors.Is(err, ErrNoCredentials) {
+			reason, lastErr = ReasonInvalid, err
+		}
+	}
+	return nil, reason, lastErr
+}
+
+// Challenge는 정책에 속한 인증 수단의 WWW-Authenticate 헤더를 추가합니다.
+func (p *Policy) Challenge(h http.Header) {
+	for _, provider := range p.providers {
+		if c := provider.Challenge(); c != "" {
+			h.Add("WWW-Authenticate", c)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"servone/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func testAuthConfig(t *testing.T) config.AuthConfig {
+	hash, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	return config.AuthConfig{
+		Providers: map[string]config.AuthProviderConfig{
+			"keys":   {Type: "api_key", Query: "api_key", Header: "X-API-Key", Keys: map[string]string{"partner-a": "key-a", "partner-b": "key-b"}},
+			"ops":    {Type: "basic", Realm: "ops", Users: map[string]string{"admin": string(hash)}},
+			"bad":    {Type: "basic", Users: map[string]string{"admin": "plaintext"}},
+			"tokens": {Type: "jwt", Secret: "hmac-secret"},
+		},
+		Rules: []config.AuthRule{
+			{Prefix: "/api/", Providers: []string{"keys"}},
+			{Prefix: "/api/snmp/", Providers: []string{"ops"}},
+			{Prefix: "/broken", Providers: []string{"bad"}},
+		},
+	}
+}
+
+func TestPolicyResolution(t *testing.T) {
+	a, errs := Compile(testAuthConfig(t))
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "bcrypt")
+
+	assert.Nil(t, a.Policy(nil, "/public"))
+	assert.Equal(t, []string{"keys"}, a.Policy(nil, "/api/users").Providers())
+	assert.Equal(t, []string{"ops"}, a.Policy(nil, "/api/snmp/get").Providers(), "longest prefix wins")
+	assert.Equal(t, []string{"tokens", "keys"}, a.Policy([]string{"tokens", "keys"}, "/api/snmp/get").Providers(), "endpoint overrides rules")
+	assert.Nil(t, a.Policy([]string{NoAuth}, "/api/users"))
+
+	t.Run("prefixes match on path segments", func(t *testing.T) {
+		assert.NotNil(t, a.Policy(nil, "/broken"))
+		assert.NotNil(t, a.Policy(nil, "/broken/sub"))
+		assert.Nil(t, a.Policy(nil, "/brokenness"))
+		assert.Equal(t, []string{"keys"}, a.Policy(nil, "/api/snmpx").Providers(), "/api/snmp/ does not cover /api/snmpx")
+	})
+
+	t.Run("broken providers fail closed", func(t *testing.T) {
+		p := a.Policy(nil, "/broken")
+		require.NotNil(t, p)
+		_, reason, err := p.Authenticate(httptest.NewRequest("GET", "/broken", nil))
+		assert.Error(t, err)
+		assert.Equal(t, ReasonMisconfigured, reason)
+
+		p = a.Policy([]string{"unknown"}, "/x")
+		assert.Equal(t, []string{`provider "unknown" is not configured`}, p.Problems())
+	})
+
+	var nilAuthorizer *Authorizer
+	assert.Nil(t, nilAuthorizer.Policy([]string{"keys"}, "/api"))
+}
+
+func TestAPIKeyAndBasic(t *testing.T) {
+	a, _ := Compile(testAuthConfig(t))
+	policy := a.Policy([]string{"keys", "ops"}, "/api/x")
+
+	t.Run("api key from header", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/x", nil)
+		req.Header.Set("X-API-Key", "key-b")
+		id, _, err := policy.Authenticate(req)
+		require.NoError(t, err)
+		assert.Equal(t, "partner-b", id.Subject)
+		assert.Equal(t, "api_key", id.Type)
+		assert.Equal(t, "partner-b", id.Claims["sub"])
+	})
+
+	t.Run("api key from query", func(t *testing.T) {
+		id, _, err := policy.Authenticate(httptest.NewRequest("GET", "/api/x?api_key=key-a", nil))
+		require.NoError(t, err)
+		assert.Equal(t, "partner-a", id.Subject)
+	})
+
+	t.Run("basic", func(t *testing.T) {
+		for i := 0; i < 2; i++ { // 두 번째 호출은 캐시 사용
+			req := httptest.NewRequest("GET", "/api/x", nil)
+			req.SetBasicAuth("admin", "s3cret")
+			id, _, err := policy.Authenticate(req)
+			require.NoError(t, err)
+			assert.Equal(t, "admin", id.Subject)
+			assert.Equal(t, "ops", id.Provider)
+		}
+
+		req := httptest.NewRequest("GET", "/api/x", nil)
+		req.SetBasicAuth("admin", "wrong")
+		_, reason, err := policy.Authenticate(req)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+		assert.Equal(t, ReasonInvalid, reason)
+	})
+
+	t.Run("missing and invalid", func(t *testing.T) {
+		_, reason, err := policy.Authenticate(httptest.NewRequest("GET", "/api/x", nil))
+		assert.ErrorIs(t, err, ErrNoCredentials)
+		assert.Equal(t, ReasonMissing, reason)
+
+		req := httptest.NewRequest("GET", "/api/x", nil)
+		req.Header.Set("X-API-Key", "nope")
+		_, reason, _ = policy.Authenticate(req)
+		assert.Equal(t, ReasonInvalid, reason)
+	})
+
+	t.Run("challenge", func(t *testing.T) {
+		h := http.Header{}
+		policy.Challenge(h)
+		assert.Equal(t, []string{`Basic realm="ops"`}, h.Values("WWW-Authenticate"))
+	})
+}
+
+func TestProviderConfigErrors(t *testing.T) {
+	cases := map[string]config.AuthProviderConfig{
+		"unknown type":    {Type: "oauth"},
+		"no keys":         {Type: "api_key"},
+		"empty key":       {Type: "api_key", Keys: map[string]string{"a": ""}},
+		"no users":        {Type: "basic"},
+		"jwt no key":      {Type: "jwt"},
+		"jwt both keys":   {Type: "jwt", Secret: "x", JWKSFile: "jwks.json"},
+		"jwt jwks absent": {Type: "jwt", JWKSFile: "/nonexistent/jwks.json"},
+	}
+	for name, cfg := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewProvider("p", cfg)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCredentialHeaders(t *testing.T) {
+	cfg := config.AuthConfig{Providers: map[string]config.AuthProviderConfig{
+		"keys":   {Type: "api_key"},
+		"device": {Type: "api_key", Header: "x-device-token", Query: "token"},
+		"query":  {Type: "api_key", Query: "api_key"},
+		"users":  {Type: "basic"},
+		"tokens": {Type: "jwt"},
+	}}
+	assert.Equal(t, []string{"Authorization", "X-Api-Key", "X-Device-Token"}, CredentialHeaders(cfg))
+	assert.Equal(t, []string{"api_key", "token"}, CredentialQueryParams(cfg))
+	assert.Empty(t, CredentialHeaders(config.AuthConfig{}))
+}
@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+
+	"servone/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	hmacAlgorithms = []string{"HS256", "HS384", "HS512"}
+	jwksAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+)
+
+// jwtProvider는 HMAC 비밀 키 또는 로컬 JWKS 파일의 키로 서명된 bearer 토큰을 검증합니다.
+type jwtProvider struct {
+	name   string
+	secret []byte
+	keys   map[string]interface{} // kid -> 검증 키 (JWKS)
+	parser *jwt.Parser
+}
+
+func newJWTProvider(name string, cfg config.AuthProviderConfig) (*jwtProvider, error) {
+	if (cfg.Secret == "") == (cfg.JWKSFile == "") {
+		return nil, fmt.Errorf("auth provider %s: jwt requires exactly one of secret or jwks_file", name)
+	}
+
+	p := &jwtProvider{name: name}
+	algorithms := cfg.Algorithms
+	if cfg.Secret != "" {
+		p.secret = []byte(cfg.Secret)
+		if len(algorithms) == 0 {
+			algorithms = hmacAlgorithms
+		}
+	} else {
+		keys, err := loadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, fmt.Errorf("auth provider %s: %w", name, err)
+		}
+		p.keys = keys
+		if len(algorithms) == 0 {
+			algorithms = jwksAlgorithms
+		}
+	}
+
+	opts := []jwt.ParserOption{jwt.WithValidMethods(algorithms)}
+	if cfg.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.Issuer))
+	}
+	if cfg.Audience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.Audience))
+	}
+	p.parser = jwt.NewParser(opts...)
+	return p, nil
+}
+
+func (p *jwtProvider) Authenticate(r *http.Request) (*Identity, error) {
+	header := r.Header.Get("Authorization")
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return nil, ErrNoCredentials
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := p.parser.ParseWithClaims(strings.TrimSpace(token), claims, p.keyFunc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	subject, _ := claims.GetSubject()
+	return &Identity{Provider: p.name, Type: "jwt", Subject: subject, Claims: claims}, nil
+}
+
+func (p *jwtProvider) Challenge() string {
+	return `Bearer realm="servone"`
+}
+
+// keyFunc는 토큰의 검증 키를 선택합니다.
+func (p *jwtProvider) keyFunc(token *jwt.Token) (interface{}, error) {
+	if p.secret != nil {
+		return p.secret, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// jwk는 RSA, EC, oct 키에 필요한 RFC 7517 필드입니다.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// loadJWKS는 JWK Set 파일을 읽어 kid 별 검증 키를 반환합니다.
+func loadJWKS(path string) (map[string]interface{}, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwks_file: %w", err)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse jwks_file %s: %w", path, err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwks_file %s: key %d (%s): %w", path, i, k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("jwks_file %s contains no signing keys", path)
+	}
+	return keys, nil
+}
+
+// publicKey는 JWK 를 jwt 에서 쓸 수 있는 키로 변환합니다.
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid n: %w", err)
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid e: %w", err)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x: %w", err)
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y: %w", err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return nil, fmt.Errorf("invalid k: %w", err)
+		}
+		return secret, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
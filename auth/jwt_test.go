@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"servone/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func authWithToken(t *testing.T, p Provider, token string) (*Identity, error) {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return p.Authenticate(req)
+}
+
+func TestJWTHMAC(t *testing.T) {
+	p, err := NewProvider("tokens", config.AuthProviderConfig{Type: "jwt", Secret: "hmac-secret", Issuer: "servone-test", Audience: "devices"})
+	require.NoError(t, err)
+
+	sign := func(claims jwt.MapClaims, secret string) string {
+		s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+		require.NoError(t, err)
+		return s
+	}
+	valid := jwt.MapClaims{"sub": "device-7", "iss": "servone-test", "aud": "devices", "tenant": "acme", "exp": time.Now().Add(time.Hour).Unix()}
+
+	t.Run("valid token exposes claims", func(t *testing.T) {
+		id, err := authWithToken(t, p, sign(valid, "hmac-secret"))
+		require.NoError(t, err)
+		assert.Equal(t, "device-7", id.Subject)
+		assert.Equal(t, "jwt", id.Type)
+		assert.Equal(t, "acme", id.Claims["tenant"])
+	})
+
+	t.Run("rejected tokens", func(t *testing.T) {
+		expired := jwt.MapClaims{"sub": "x", "iss": "servone-test", "aud": "devices", "exp": time.Now().Add(-time.Hour).Unix()}
+		wrongIssuer := jwt.MapClaims{"sub": "x", "iss": "other", "aud": "devices"}
+		for name, token := range map[string]string{
+			"wrong secret": sign(valid, "other-secret"),
+			"expired":      sign(expired, "hmac-secret"),
+			"wrong issuer": sign(wrongIssuer, "hmac-secret"),
+			"garbage":      "not.a.token",
+		} {
+			_, err := authWithToken(t, p, token)
+			assert.ErrorIs(t, err, ErrInvalidCredentials, name)
+		}
+	})
+
+	t.Run("alg none is rejected", func(t *testing.T) {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodNone, valid).SignedString(jwt.UnsafeAllowNoneSignatureType)
+		require.NoError(t, err)
+		_, err = authWithToken(t, p, token)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+
+	t.Run("no bearer token", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.SetBasicAuth("a", "b")
+		_, err := p.Authenticate(req)
+		assert.ErrorIs(t, err, ErrNoCredentials)
+	})
+}
+
+func TestJWTJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	enc := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	jwks := map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": enc(rsaKey.N.Bytes()), "e": enc(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": enc(ecKey.X.Bytes()), "y": enc(ecKey.Y.Bytes())},
+		{"kty": "RSA", "kid": "enc-1", "use": "enc", "n": "AQAB", "e": "AQAB"},
+	}}
+	raw, err := json.Marshal(jwks)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, raw, 0644))
+
+	p, err := NewProvider("idp", config.AuthProviderConfig{Type: "jwt", JWKSFile: path})
+	require.NoError(t, err)
+
+	claims := jwt.MapClaims{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()}
+
+	t.Run("RSA", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "rsa-1"
+		signed, err := token.SignedString(rsaKey)
+		require.NoError(t, err)
+
+		id, err := authWithToken(t, p, signed)
+		require.NoError(t, err)
+		assert.Equal(t, "user-1", id.Subject)
+	})
+
+	t.Run("EC", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+		token.Header["kid"] = "ec-1"
+		signed, err := token.SignedString(ecKey)
+		require.NoError(t, err)
+
+		_, err = authWithToken(t, p, signed)
+		assert.NoError(t, err)
+	})
+
+	t.Run("unknown kid", func(t *testing.T) {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "enc-1"
+		signed, err := token.SignedString(rsaKey)
+		require.NoError(t, err)
+
+		_, err = authWithToken(t, p, signed)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+
+	t.Run("HMAC not accepted for JWKS providers", func(t *testing.T) {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("x"))
+		require.NoError(t, err)
+		_, err = authWithToken(t, p, signed)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+}
@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"servone/config"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// defaultAPIKeyHeader는 api_key 인증 수단에 header 와 query 가 모두 없을 때 사용합니다.
+const defaultAPIKeyHeader = "X-API-Key"
+
+// maxBasicCache는 기억하는 Basic 로그인 성공 수의 상한입니다.
+const maxBasicCache = 1024
+
+// apiKeyProvider는 헤더 및/또는 쿼리 파라미터의 정적 키를 허용합니다.
+type apiKeyProvider struct {
+	name   string
+	header string
+	query  string
+	names  []string // 키 이름 (일정한 순서로 비교하도록 정렬)
+	keys   map[string]string
+}
+
+func newAPIKeyProvider(name string, cfg config.AuthProviderConfig) (*apiKeyProvider, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, fmt.Errorf("auth provider %s: api_key requires at least one key", name)
+	}
+	p := &apiKeyProvider{name: name, header: cfg.Header, query: cfg.Query, keys: cfg.Keys}
+	if p.header == "" && p.query == "" {
+		p.header = defaultAPIKeyHeader
+	}
+	for keyName, key := range cfg.Keys {
+		if key == "" {
+			return nil, fmt.Errorf("auth provider %s: key %q is empty", name, keyName)
+		}
+		p.names = append(p.names, keyName)
+	}
+	sort.Strings(p.names)
+	return p, nil
+}
+
+func (p *apiKeyProvider) Authenticate(r *http.Request) (*Identity, error) {
+	presented := ""
+	if p.header != "" {
+		presented = r.Header.Get(p.header)
+	}
+	if presented == "" && p.query != "" {
+		presented = r.URL.Query().Get(p.query)
+	}
+	if presented == "" {
+		return nil, ErrNoCredentials
+	}
+
+	// 어느 키와 일치했는지 시간 차로 드러나지 않도록 모든 키와 비교
+	matched := ""
+	for _, keyName := range p.names {
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(p.keys[keyName])) == 1 {
+			matched = keyName
+		}
+	}
+	if matched == "" {
+		return nil, ErrInvalidCredentials
+	}
+	return &Identity{Provider: p.name, Type: "api_key", Subject: matched, Claims: map[string]interface{}{"sub": matched}}, nil
+}
+
+func (p *apiKeyProvider) Challenge() string {
+	return ""
+}
+
+// basicProvider는 HTTP Basic 자격 증명을 bcrypt 해시와 비교합니다.
+type basicProvider struct {
+	name  string
+	realm string
+	users map[string][]byte
+
+	// bcrypt 는 일부러 느리므로 성공한 로그인을 사용자, 비밀번호, 해시의 다이제스트로 기억
+	// (해시가 바뀌면 항목이 무효화됨)
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]bool
+}
+
+func newBasicProvider(name string, cfg config.AuthProviderConfig) (*basicProvider, error) {
+	if len(cfg.Users) == 0 {
+		return nil, fmt.Errorf("auth provider %s: basic requires at least one user", name)
+	}
+	p := &basicProvider{
+		name:  name,
+		realm: cfg.Realm,
+		users: make(map[string][]byte, len(cfg.Users)),
+		cache: make(map[[sha256.Size]byte]bool),
+	}
+	if p.realm == "" {
+		p.realm = "servone"
+	}
+	for user, hash := range cfg.Users {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("auth provider %s: user %q: password must be a bcrypt hash: %w", name, user, err)
+		}
+		p.users[user] = []byte(hash)
+	}
+	return p, nil
+}
+
+func (p *basicProvider) Authenticate(r *http.Request) (*Identity, error) {
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return nil, ErrNoCredentials
+	}
+	hash, known := p.users[user]
+	if !known {
+		return nil, ErrInvalidCredentials
+	}
+
+	digest := sha256.Sum256([]byte(user + "\x00" + pass + "\x00" + string(hash)))
+	p.mu.Lock()
+	cached := p.cache[digest]
+	p.mu.Unlock()
+
+	if !cached {
+		if err := bcrypt.CompareHashAndPassword(hash, []byte(pass)); err != nil {
+			return nil, ErrInvalidCredentials
+		}
+		p.mu.Lock()
+		if len(p.cache) >= maxBasicCache {
+			p.cache = make(map[[sha256.Size]byte]bool)
+		}
+		p.cache[digest] = true
+		p.mu.Unlock()
+	}
+
+	return &Identity{Provider: p.name, Type: "basic", Subject: user, Claims: map[string]interface{}{"sub": user}}, nil
+}
+
+func (p *basicProvider) Challenge() string {
+	return fmt.Sprintf("Basic realm=%q", p.realm)
+}
@@ -0,0 +1,290 @@
+// Package certs provides a TLS server configuration whose certificate and
+// client CA bundle are reloaded from disk when the files change, so renewed
+// certificates are picked up by new handshakes without dropping connections.
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"servone/config"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDelay debounces bursts of file events (e.g. cert and key written separately).
+const reloadDelay = 500 * time.Millisecond
+
+// material is one consistent snapshot of the files on disk.
+type material struct {
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+}
+
+// Store holds the current certificate material and reloads it on demand or
+// when the watched files change.
+type Store struct {
+	mu      sync.Mutex // guards cfg, watcher and timer
+	cfg     config.TLSConfig
+	current atomic.Pointer[material]
+	watcher *fsnotify.Watcher
+	timer   *time.Timer
+}
+
+// NewStore loads the files referenced by cfg.
+func NewStore(cfg config.TLSConfig) (*Store, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, fmt.Errorf("tls: both cert_file and key_file are required")
+	}
+	switch strings.ToLower(cfg.ClientAuth) {
+	case "", "require", "optional":
+	default:
+		return nil, fmt.Errorf("tls: unknown client_auth %q (use require or optional)", cfg.ClientAuth)
+	}
+
+	s := &Store{cfg: cfg}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload re-reads the certificate, key and client CA bundle. On error the
+// previously loaded material stays in use.
+func (s *Store) Reload() error {
+	s.mu.Lock()
+	cfg := s.cfg
+	s.mu.Unlock()
+
+	m, err := load(cfg)
+	if err != nil {
+		return err
+	}
+	s.current.Store(m)
+	return nil
+}
+
+// Update switches to new file paths (e.g. after a config reload) and reloads.
+func (s *Store) Update(cfg config.TLSConfig) error {
+	s.mu.Lock()
+	if s.cfg == cfg {
+		s.mu.Unlock()
+		return nil
+	}
+	s.cfg = cfg
+	s.mu.Unlock()
+
+	if err := s.Reload(); err != nil {
+		return err
+	}
+	if s.watching() {
+		return s.watchFiles()
+	}
+	return nil
+}
+
+func load(cfg config.TLSConfig) (*material, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("tls: failed to load certificate: %w", err)
+	}
+	m := &material{cert: &cert}
+
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: failed to read client_ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls: no certificates found in client_ca_file %s", cfg.ClientCAFile)
+		}
+		m.clientCA = pool
+	}
+	return m, nil
+}
+
+// Certificate returns the currently loaded server certificate.
+func (s *Store) Certificate() *tls.Certificate {
+	return s.current.Load().cert
+}
+
+// ClientCAs returns the currently loaded client CA bundle, or nil when no
+// client_ca_file is configured.
+func (s *Store) ClientCAs() *x509.CertPool {
+	return s.current.Load().clientCA
+}
+
+// ServerConfig returns a tls.Config that always serves the latest loaded
+// certificate and verifies client certificates against the latest CA bundle.
+// ALPN is set here because the per-handshake config replaces the one
+// net/http prepares, so h2 must be advertised explicitly.
+func (s *Store) ServerConfig(http2 bool) *tls.Config {
+	if http2 {
+		return s.ServerConfigFor("h2", "http/1.1")
+	}
+	return s.ServerConfigFor("http/1.1")
+}
+
+// ServerConfigFor is ServerConfig for an arbitrary list of ALPN protocols
+// (e.g. "coap" for CoAP over TLS).
+func (s *Store) ServerConfigFor(nextProtos ...string) *tls.Config {
+	base := &tls.Config{MinVersion: tls.VersionTLS12, NextProtos: nextProtos}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		m := s.current.Load()
+
+		s.mu.Lock()
+		clientAuth := s.cfg.ClientAuth
+		s.mu.Unlock()
+
+		c := base.Clone()
+		c.GetConfigForClient = nil
+		c.Certificates = []tls.Certificate{*m.cert}
+		if m.clientCA != nil {
+			c.ClientCAs = m.clientCA
+			c.ClientAuth = tls.RequireAndVerifyClientCert
+			if strings.EqualFold(clientAuth, "optional") {
+				c.ClientAuth = tls.VerifyClientCertIfGiven
+			}
+		}
+		return c, nil
+	}
+	return base
+}
+
+// Watch reloads the material whenever one of the files (or the directory
+// holding it, to follow atomic symlink swaps) changes.
+func (s *Store) Watch() error {
+	s.mu.Lock()
+	if s.watcher == nil {
+		w, err := fsnotify.NewWatcher()
+		if err != nil {
+			s.mu.Unlock()
+			return err
+		}
+		s.watcher = w
+		go s.watchLoop(w)
+	}
+	s.mu.Unlock()
+	return s.watchFiles()
+}
+
+func (s *Store) watching() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.watcher != nil
+}
+
+// watchFiles registers the directories of the configured files.
+func (s *Store) watchFiles() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, dir := range s.watchedDirs() {
+		if err := s.watcher.Add(dir); err != nil {
+			return fmt.Errorf("tls: failed to watch %s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// watchedDirs returns the absolute directories of the configured files. Caller holds mu.
+func (s *Store) watchedDirs() []string {
+	seen := make(map[string]bool)
+	var dirs []string
+	for _, f := range []string{s.cfg.CertFile, s.cfg.KeyFile, s.cfg.ClientCAFile} {
+		if f == "" {
+			continue
+		}
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			continue
+		}
+		if dir := filepath.Dir(abs); !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// isRelevant reports whether an event path concerns the configured files.
+// Kubernetes-style secret mounts swap a "..data" symlink, so hidden ".."
+// entries in the watched directories also count.
+func (s *Store) isRelevant(path string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	abs, _ := filepath.Abs(path)
+	for _, f := range []string{s.cfg.CertFile, s.cfg.KeyFile, s.cfg.ClientCAFile} {
+		if f == "" {
+			continue
+		}
+		if fAbs, _ := filepath.Abs(f); fAbs == abs {
+			return true
+		}
+	}
+	return strings.HasPrefix(filepath.Base(abs), "..")
+}
+
+func (s *Store) watchLoop(w *fsnotify.Watcher) {
+	for {
+		select {
+		case event, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 || !s.isRelevant(event.Name) {
+				continue
+			}
+			s.scheduleReload()
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("TLS certificate watcher error: %v", err)
+		}
+	}
+}
+
+// scheduleReload reloads the material once events have settled.
+func (s *Store) scheduleReload() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	certFile := s.cfg.CertFile
+	s.timer = time.AfterFunc(reloadDelay, func() {
+		if err := s.Reload(); err != nil {
+			log.Printf("TLS certificate reload failed, keeping previous certificate: %v", err)
+			return
+		}
+		log.Printf("TLS certificate reloaded from %s", certFile)
+	})
+}
+
+// Close stops watching the files.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	if s.watcher == nil {
+		return nil
+	}
+	err := s.watcher.Close()
+	s.watcher = nil
+	return err
+}
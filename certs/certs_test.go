@@ -0,0 +1,182 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"servone/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// issue creates a certificate for cn signed by parent (self-signed when parent is nil).
+func issue(t *testing.T, cn string, serial int64, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if isCA {
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return cert, key,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+}
+
+func serialOf(t *testing.T, s *Store) int64 {
+	t.Helper()
+	leaf, err := x509.ParseCertificate(s.Certificate().Certificate[0])
+	require.NoError(t, err)
+	return leaf.SerialNumber.Int64()
+}
+
+func TestNewStore(t *testing.T) {
+	dir := t.TempDir()
+	_, _, certPEM, keyPEM := issue(t, "server", 1, false, nil, nil)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeFile(t, certFile, certPEM)
+	writeFile(t, keyFile, keyPEM)
+
+	t.Run("loads certificate", func(t *testing.T) {
+		s, err := NewStore(config.TLSConfig{CertFile: certFile, KeyFile: keyFile})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), serialOf(t, s))
+		assert.Nil(t, s.ClientCAs())
+	})
+
+	t.Run("requires cert and key", func(t *testing.T) {
+		_, err := NewStore(config.TLSConfig{CertFile: certFile})
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects unknown client_auth", func(t *testing.T) {
+		_, err := NewStore(config.TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientAuth: "sometimes"})
+		assert.ErrorContains(t, err, "client_auth")
+	})
+
+	t.Run("rejects CA file without certificates", func(t *testing.T) {
+		caFile := filepath.Join(dir, "empty-ca.pem")
+		writeFile(t, caFile, []byte("not a certificate"))
+		_, err := NewStore(config.TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile})
+		assert.ErrorContains(t, err, "no certificates")
+	})
+}
+
+func TestServerConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey, caPEM, _ := issue(t, "test-ca", 100, true, nil, nil)
+	_, _, certPEM, keyPEM := issue(t, "server", 1, false, ca, caKey)
+	certFile, keyFile, caFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key"), filepath.Join(dir, "ca.pem")
+	writeFile(t, certFile, certPEM)
+	writeFile(t, keyFile, keyPEM)
+	writeFile(t, caFile, caPEM)
+
+	forClient := func(cfg config.TLSConfig, http2 bool) *tls.Config {
+		s, err := NewStore(cfg)
+		require.NoError(t, err)
+		c, err := s.ServerConfig(http2).GetConfigForClient(&tls.ClientHelloInfo{})
+		require.NoError(t, err)
+		return c
+	}
+
+	t.Run("without client CA", func(t *testing.T) {
+		c := forClient(config.TLSConfig{CertFile: certFile, KeyFile: keyFile}, true)
+		assert.Equal(t, tls.NoClientCert, c.ClientAuth)
+		assert.Equal(t, []string{"h2", "http/1.1"}, c.NextProtos)
+		assert.Len(t, c.Certificates, 1)
+	})
+
+	t.Run("client certificates required by default", func(t *testing.T) {
+		c := forClient(config.TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile}, true)
+		assert.Equal(t, tls.RequireAndVerifyClientCert, c.ClientAuth)
+		assert.NotNil(t, c.ClientCAs)
+	})
+
+	t.Run("optional client certificates", func(t *testing.T) {
+		c := forClient(config.TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, ClientAuth: "optional"}, false)
+		assert.Equal(t, tls.VerifyClientCertIfGiven, c.ClientAuth)
+		assert.Equal(t, []string{"http/1.1"}, c.NextProtos)
+	})
+
+	t.Run("custom ALPN protocols", func(t *testing.T) {
+		s, err := NewStore(config.TLSConfig{CertFile: certFile, KeyFile: keyFile})
+		require.NoError(t, err)
+		c, err := s.ServerConfigFor("coap").GetConfigForClient(&tls.ClientHelloInfo{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"coap"}, c.NextProtos)
+	})
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	_, _, certPEM, keyPEM := issue(t, "server", 1, false, nil, nil)
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeFile(t, certFile, certPEM)
+	writeFile(t, keyFile, keyPEM)
+
+	s, err := NewStore(config.TLSConfig{CertFile: certFile, KeyFile: keyFile})
+	require.NoError(t, err)
+	require.NoError(t, s.Watch())
+	defer s.Close()
+
+	t.Run("keeps previous certificate when files are broken", func(t *testing.T) {
+		writeFile(t, certFile, []byte("garbage"))
+		assert.Error(t, s.Reload())
+		assert.Equal(t, int64(1), serialOf(t, s))
+	})
+
+	t.Run("picks up renewed certificate from disk", func(t *testing.T) {
+		_, _, renewedCert, renewedKey := issue(t, "server", 2, false, nil, nil)
+		writeFile(t, keyFile, renewedKey)
+		writeFile(t, certFile, renewedCert)
+
+		assert.Eventually(t, func() bool { return serialOf(t, s) == 2 }, 5*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("update switches files", func(t *testing.T) {
+		_, _, otherCert, otherKey := issue(t, "other", 3, false, nil, nil)
+		otherDir := t.TempDir()
+		otherCertFile, otherKeyFile := filepath.Join(otherDir, "tls.crt"), filepath.Join(otherDir, "tls.key")
+		writeFile(t, otherCertFile, otherCert)
+		writeFile(t, otherKeyFile, otherKey)
+
+		require.NoError(t, s.Update(config.TLSConfig{CertFile: otherCertFile, KeyFile: otherKeyFile}))
+		assert.Equal(t, int64(3), serialOf(t, s))
+	})
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// hash-password 명령어: rest.auth basic 사용자용 bcrypt 해시 생성
+func runHashPassword(args []string) error {
+	fs := flag.NewFlagSet("hash-password", flag.ExitOnError)
+	cost := fs.Int("cost", bcrypt.DefaultCost, "bcrypt cost")
+	fs.Parse(args)
+
+	// 셸 히스토리에 남지 않도록 비밀번호는 표준 입력으로 받는다
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return fmt.Errorf("failed to read password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return fmt.Errorf("password must not be empty")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), *cost)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(hash))
+	return nil
+}
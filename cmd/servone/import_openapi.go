@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"servone/config"
+	"servone/openapi"
+
+	"gopkg.in/yaml.v3"
+)
+
+// import-openapi 명령어: OpenAPI 명세를 rest.endpoints YAML 로 변환
+func runImportOpenAPI(args []string) error {
+	fs := flag.NewFlagSet("import-openapi", flag.ExitOnError)
+	spec := fs.String("spec", "", "OpenAPI 3.x 명세 파일 경로 (JSON 또는 YAML)")
+	out := fs.String("out", "", "출력 파일 경로 (기본: 표준 출력)")
+	fs.Parse(args)
+
+	if *spec == "" && fs.NArg() > 0 {
+		*spec = fs.Arg(0)
+	}
+	if *spec == "" {
+		fs.Usage()
+		return fmt.Errorf("-spec is required")
+	}
+
+	endpoints, err := openapi.ImportFile(*spec)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Generated by servone import-openapi from %s\n", *spec)
+	fmt.Fprintf(&buf, "# Paste under rest: in config.yaml, or reference the spec directly with rest.openapi.\n")
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(struct {
+		Endpoints []config.EndpointConfig `yaml:"endpoints"`
+	}{endpoints}); err != nil {
+		return fmt.Errorf("failed to encode endpoints: %w", err)
+	}
+	enc.Close()
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		if err := os.WriteFile(*out, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %d endpoint(s) to %s\n", len(endpoints), *out)
+		return nil
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
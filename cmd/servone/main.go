@@ -0,0 +1,48 @@
+// servone 운영 도구 명령어 진입점 (servone <command> [flags])
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+// 지원하는 하위 명령어 목록
+var commands = map[string]struct {
+	run   func(args []string) error
+	usage string
+}{
+	"replay":         {runReplay, "녹화된 HTTP 트래픽을 대상 서버로 재생"},
+	"import-openapi": {runImportOpenAPI, "OpenAPI 명세를 rest.endpoints 설정으로 변환"},
+	"hash-password":  {runHashPassword, "rest.auth basic 사용자용 bcrypt 해시 생성"},
+	"migrate":        {runMigrate, "DB 스키마 마이그레이션 적용/되돌리기/상태 확인 (up|down|status)"},
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: servone <command> [flags]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for name, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", name, cmd.usage)
+	}
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Run 'servone <command> -h' for command flags.")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+
+	if err := cmd.run(os.Args[2:]); err != nil {
+		log.Fatalf("servone %s: %v", os.Args[1], err)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"servone/config"
+	"servone/db"
+)
+
+// migrate 명령어: DB 스키마 마이그레이션 적용(up), 되돌리기(down), 상태 확인(status)
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	configPath := fs.String("config", "config.yaml", "설정 파일 경로 (database.connection_string 사용)")
+	steps := fs.Int("steps", 1, "down 시 되돌릴 마이그레이션 수")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: servone migrate [flags] up|down|status")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected one of up, down, status")
+	}
+	action := fs.Arg(0)
+	if action == "down" && *steps < 1 {
+		return fmt.Errorf("-steps must be at least 1")
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	database, err := db.NewDatabase(cfg.Database.ConnectionString)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	migrator, err := database.Migrator()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	switch action {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			fmt.Printf("Applied     %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("Schema is up to date")
+		}
+	case "down":
+		rolledBack, err := migrator.Down(ctx, *steps)
+		for _, m := range rolledBack {
+			fmt.Printf("Rolled back %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(rolledBack) == 0 {
+			fmt.Println("No applied migrations to roll back")
+		}
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, st := range statuses {
+			name, state := st.Name, "pending"
+			if name == "" {
+				name = "(unknown to this build)"
+			}
+			if st.AppliedAt != nil {
+				state = "applied " + st.AppliedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-28s %s\n", st.Version, name, state)
+		}
+	default:
+		fs.Usage()
+		return fmt.Errorf("unknown migrate action %q", action)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"servone/recording"
+)
+
+// replay 명령어: 녹화 파일을 읽어 대상 서버로 재생하고 녹화된 응답과 비교
+func runReplay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	file := fs.String("file", "recordings/servone.ndjson", "녹화 파일 경로 (NDJSON)")
+	target := fs.String("target", "http://localhost:8090", "재생 대상 기본 URL")
+	speed := fs.Float64("speed", 1, "재생 속도 배율 (1=원래 간격, 2=2배속, 0=대기 없음)")
+	compareBody := fs.Bool("compare-body", false, "상태 코드뿐 아니라 응답 본문도 비교")
+	endpoint := fs.String("endpoint", "", "지정 시 해당 엔드포인트 경로의 녹화만 재생")
+	fs.Parse(args)
+
+	entries, err := recording.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	if *endpoint != "" {
+		filtered := entries[:0]
+		for _, e := range entries {
+			if e.Endpoint == *endpoint {
+				filtered = append(filtered, e)
+			}
+		}
+		entries = filtered
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no recorded entries to replay in %s", *file)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	fmt.Printf("Replaying %d request(s) from %s to %s at %gx\n", len(entries), *file, *target, *speed)
+	report, err := recording.Replay(ctx, entries, recording.ReplayOptions{
+		Target:      *target,
+		Speed:       *speed,
+		CompareBody: *compareBody,
+	})
+	if report != nil {
+		for _, res := range report.Results {
+			status := "OK"
+			switch {
+			case res.Err != nil:
+				status = "ERROR " + res.Err.Error()
+			case res.Mismatch != "":
+				status = "MISMATCH " + res.Mismatch
+			}
+			fmt.Printf("%-7s %-40s %4d %8.1fms  %s\n", res.Entry.Request.Method, res.Entry.Request.URI, res.Status, res.DurationMs, status)
+		}
+		fmt.Printf("\nTotal: %d, matched: %d, mismatched: %d, failed: %d\n", report.Total, report.Matched, report.Mismatched, report.Failed)
+	}
+	if err != nil {
+		return err
+	}
+
+	if report.Mismatched > 0 || report.Failed > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
@@ -12,7 +12,10 @@ import (
 	"servone/config"
 	"servone/db"
 	"servone/kafka"
+	"servone/outbox"
+	"servone/retention"
 	"servone/server"
+	"servone/spool"
 )
 
 func main() {
@@ -24,27 +27,88 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	// 데이터베이스 초기화
-	if err := db.InitDB(cfg.Database.ConnectionString); err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+	// Kafka Publisher 생성
+	publisher, err := kafka.NewKafkaPublisher(cfg.Kafka.Brokers)
+	if err != nil {
+		log.Fatalf("Failed to create kafka publisher: %v", err)
+	}
+	defer publisher.Close()
+
+	// 로컬 스풀 (spool.dir 지정 시): DB/Kafka 장애 중 메시지를 디스크에 보관했다가 복구 후 순서대로 재전송
+	var sink kafka.KafkaPublisherInterface = publisher
+	var spooler *spool.Spool
+	if cfg.Spool.Dir != "" {
+		spooler, err = spool.Open(cfg.Spool)
+		if err != nil {
+			log.Fatalf("Failed to open spool: %v", err)
+		}
+		spooled, err := spool.NewPublisher(spooler, publisher)
+		if err != nil {
+			log.Fatalf("Failed to open kafka spool: %v", err)
+		}
+		sink = spooled
 	}
+
+	// 저장소 열기 (database.driver: postgres 기본, sqlite, file)
+	// file 저장소는 outbox가 없으므로 Kafka 이벤트를 sink로 바로 발행
+	store, err := db.OpenStore(cfg.Database, sink)
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	db.UseStore(store)
 	defer func() {
-		if err := db.CloseDB(); err != nil {
+		if err := store.Close(); err != nil {
 			log.Printf("Failed to close database: %v", err)
 		}
 	}()
 
-	db.SetupDatabase()
+	// PostgreSQL 전용 기능: DB 스풀, 일괄 저장, 파티션 관리, 조회 API, 인스턴스 간 공유 일일 할당량
+	var batch *db.BatchWriter
+	var partitions *retention.Manager
+	serverOpts := []server.Option{server.WithSpool(spooler)}
+	if _, ok := store.(*db.PostgresStore); ok {
+		if spooler != nil {
+			if err := db.UseSpool(spooler); err != nil {
+				log.Fatalf("Failed to open database spool: %v", err)
+			}
+		}
 
-	// Kafka Publisher 생성
-	publisher, err := kafka.NewKafkaPublisher(cfg.Kafka.Brokers)
-	if err != nil {
-		log.Fatalf("Failed to create kafka publisher: %v", err)
+		// 일괄 저장: 수집 행을 테이블별로 모아 COPY로 저장 (실패한 행은 하나씩 다시 저장)
+		if cfg.Database.Batch.Enabled {
+			batch, err = db.NewBatchWriter(db.DbPool, cfg.Database.Batch)
+			if err != nil {
+				log.Fatalf("Failed to create batch writer: %v", err)
+			}
+			db.UseBatchWriter(batch)
+			if spooler == nil {
+				log.Printf("Batch writer: spool is disabled, rows that fail to insert are dropped (at-most-once)")
+			}
+		}
+
+		// 수집 테이블 파티션 관리: 미래 파티션 생성, 보관 기간이 지난 파티션 삭제(SNMP 시간별 집계 포함)
+		partitions, err = retention.New(db.NewPartitionStore(db.DbPool), cfg.Retention)
+		if err != nil {
+			log.Fatalf("Failed to create partition manager: %v", err)
+		}
+		partitions.Start()
+
+		serverOpts = append(serverOpts, server.WithDataReader(db.NewDataReader(db.DbPool)))
+		serverOpts = append(serverOpts, server.WithQuotaCounter(db.NewQuotaStore(db.DbPool)))
+	}
+
+	// outbox 릴레이: 데이터 행과 같은 트랜잭션으로 기록된 Kafka 이벤트를 발행 (outbox가 있는 저장소만)
+	// (재시도는 outbox가 담당하므로 스풀을 거치지 않는 퍼블리셔 사용)
+	var relay *outbox.Relay
+	if outboxStore, ok := store.(outbox.Store); ok {
+		relay, err = outbox.New(outboxStore, publisher, cfg.Outbox)
+		if err != nil {
+			log.Fatalf("Failed to create outbox relay: %v", err)
+		}
+		relay.Start()
 	}
-	defer publisher.Close()
 
 	// HTTP REST API 서버만 생성
-	server := server.NewDynamicServer(cfg, publisher)
+	server := server.NewDynamicServer(cfg, sink, serverOpts...)
 
 	// 설정 파일 변경 감시를 위한 watcher 생성 (CoAP 서버는 nil로 전달)
 	watcher, err := config.NewConfigWatcher(configPath, server, nil)
@@ -83,5 +147,25 @@ func main() {
 		log.Printf("Server shutdown error: %v", err)
 	}
 
+	// 모아 둔 행 저장 (이후 들어오는 행은 하나씩 저장)
+	if batch != nil {
+		batch.Close()
+	}
+
+	// 저장이 끝난 뒤 릴레이 종료 (발행하지 못한 이벤트는 outbox에 남아 다음 실행 시 발행)
+	if relay != nil {
+		relay.Close()
+	}
+	if partitions != nil {
+		partitions.Close()
+	}
+
+	// 재전송하지 못한 메시지는 디스크에 남아 다음 실행 시 이어서 재전송
+	if spooler != nil {
+		if err := spooler.Close(); err != nil {
+			log.Printf("Spool close error: %v", err)
+		}
+	}
+
 	log.Println("REST API server stopped successfully")
 }
@@ -2,20 +2,27 @@ package coap
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io"
 	"log"
+	"net"
 	"sync"
+	"sync/atomic"
 
+	"servone/certs"
 	"servone/config"
 	"servone/db"
+	"servone/fault"
+	"servone/ingest"
 	"servone/kafka"
-	"strconv"
+	"servone/metrics"
+	"servone/ratelimit"
+	"servone/schema"
+	"servone/tmpl"
 	"strings"
-	"text/template"
 	"time"
 
-	"github.com/plgd-dev/go-coap/v3"
 	"github.com/plgd-dev/go-coap/v3/message"
 	"github.com/plgd-dev/go-coap/v3/message/codes"
 	"github.com/plgd-dev/go-coap/v3/mux"
@@ -23,78 +30,170 @@ import (
 
 // CoapServer 구조체는 CoAP 서버의 상태를 관리합니다.
 type CoapServer struct {
-	config    *config.Config
-	router    *mux.Router
-	publisher kafka.KafkaPublisherInterface
-	stopChan  chan struct{}
-	listening bool
-	mu        sync.Mutex
+	config     *config.Config
+	router     atomic.Pointer[mux.Router] // 현재 라우터 (리로드 시 통째로 교체)
+	publisher  kafka.KafkaPublisherInterface
+	renderer   *tmpl.Renderer
+	limits     *ratelimit.Registry
+	ingest     *ingest.Pipeline              // DB 저장/Kafka 발행 작업자 풀
+	ownsIngest bool                          // 자체 생성한 파이프라인이면 Shutdown 시 비우고 종료
+	observe    *ObserveHub                   // 관찰 가능한 엔드포인트의 마지막 값과 관찰자
+	ownsHub    bool                          // 자체 생성한 관찰 허브면 Shutdown 시 관찰자 정리
+	psk        pskKeyring                    // DTLS PSK (identity -> 키, 장치 ID)
+	certs      *certs.Store                  // DTLS 인증서 (인증서 모드)
+	tlsCerts   *certs.Store                  // CoAP over TLS 인증서
+	listeners  map[string]*transportListener // 전송 계층별 실행 중인 리스너
+	stopped    bool                          // Stop 이후에는 리로드해도 다시 바인딩하지 않음
+	mu         sync.Mutex                    // 설정과 리스너 변경 동기화
+}
+
+// Option은 CoapServer 생성 옵션입니다.
+type Option func(*CoapServer)
+
+// WithIngest는 외부에서 생성한 수집 파이프라인을 사용하게 합니다 (종료 시 비우는 것은 생성한 쪽의 책임).
+func WithIngest(p *ingest.Pipeline) Option {
+	return func(cs *CoapServer) {
+		cs.ingest = p
+	}
+}
+
+// WithObserveHub는 외부에서 생성한 관찰 허브를 사용하게 합니다 (MQTT/Kafka 값 갱신 연결용).
+func WithObserveHub(h *ObserveHub) Option {
+	return func(cs *CoapServer) {
+		cs.observe = h
+	}
+}
+
+// WithQuotaCounter는 일일 할당량을 c 에 기록하게 합니다 (기본은 메모리 카운터, 재시작 시 초기화).
+func WithQuotaCounter(c ratelimit.Counter) Option {
+	return func(cs *CoapServer) {
+		cs.limits = ratelimit.NewRegistry(c)
+	}
 }
 
 // NewCoapServer는 새로운 CoapServer 인스턴스를 생성하고 초기화합니다.
-func NewCoapServer(cfg *config.Config, publisher kafka.KafkaPublisherInterface) *CoapServer {
+func NewCoapServer(cfg *config.Config, publisher kafka.KafkaPublisherInterface, opts ...Option) *CoapServer {
 	cs := &CoapServer{
 		config:    cfg,
-		router:    mux.NewRouter(),
 		publisher: publisher,
-		stopChan:  make(chan struct{}),
+		renderer:  tmpl.NewRenderer(),
+		limits:    ratelimit.NewRegistry(ratelimit.NewMemoryCounter()),
+	}
+
+	for _, opt := range opts {
+		opt(cs)
+	}
+	if cs.ingest == nil {
+		// 공유 파이프라인이 없으면 설정으로 자체 생성 (설정 오류 시 기본값)
+		p, err := ingest.New(cfg.Ingest)
+		if err != nil {
+			log.Printf("Invalid ingest config, using defaults: %v", err)
+			p, _ = ingest.New(config.IngestConfig{})
+		}
+		cs.ingest = p
+		cs.ownsIngest = true
+	}
+	if cs.observe == nil {
+		cs.observe = NewObserveHub()
+		cs.ownsHub = true
 	}
 
-	cs.setupRoutes()
+	cs.router.Store(cs.buildRouter())
 	cs.start()
 
 	return cs
 }
 
-// start starts the CoAP server
+// start는 설정된 모든 리스너(UDP, DTLS, TCP, TLS)를 시작합니다.
 func (cs *CoapServer) start() {
-	addr := cs.config.Coap.Host + ":" + cs.config.Coap.Port
-
-	go func() {
-		log.Printf("Starting CoAP server on %s", addr)
-		cs.mu.Lock()
-		cs.listening = true
-		cs.mu.Unlock()
-
-		if err := coap.ListenAndServe("udp", addr, cs.router); err != nil {
-			// Check if server was stopped intentionally
-			select {
-			case <-cs.stopChan:
-				log.Println("CoAP server stopped")
-			default:
-				log.Printf("CoAP server error: %v", err)
-			}
-		}
-
-		cs.mu.Lock()
-		cs.listening = false
-		cs.mu.Unlock()
-	}()
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.syncListeners()
 }
 
-// setupRoutes는 설정 파일에 정의된 엔���포인트를 기반으로 CoAP 라우트를 설정합니다.
-func (cs *CoapServer) setupRoutes() {
+// buildRouter는 설정 파일에 정의된 엔드포인트로 새 CoAP 라우터를 만듭니다.
+func (cs *CoapServer) buildRouter() *mux.Router {
+	router := mux.NewRouter()
+	router.Use(cs.observe.middleware) // 알림에 대한 RST 로 관찰자 제거
+	cs.observe.configure(cs.config.Coap.Endpoints)
+	// 리소스 검색 (같은 경로의 엔드포인트가 설정되어 있으면 그 엔드포인트가 우선)
+	router.HandleFunc(wellKnownCore, discoveryHandler(cs.config.Coap.Endpoints))
+	literals := make(map[string]mux.HandlerFunc)
 	for _, endpoint := range cs.config.Coap.Endpoints {
-		cs.addRoute(endpoint)
+		cs.addRoute(router, endpoint, literals)
 	}
+	cs.limits.Prune() // 삭제된 엔드포인트의 제한 버킷 정리
+	return router
 }
 
 // addRoute는 단일 엔드포인트에 대한 CoAP 라우트를 추가합니다.
-func (cs *CoapServer) addRoute(endpoint config.EndpointConfig) {
+// go-coap 라우터는 가장 긴 패턴을 고르므로 /sensors/{id} 가 /sensors/all 보다 우선할 수 있어,
+// {변수} 경로의 핸들러는 요청 경로와 정확히 같은 고정 경로 엔드포인트가 있으면 그쪽으로 넘깁니다.
+func (cs *CoapServer) addRoute(router *mux.Router, endpoint config.EndpointConfig, literals map[string]mux.HandlerFunc) {
 	path := endpoint.Path
 	method := strings.ToUpper(endpoint.Method)
 
-	cs.router.HandleFunc(path, cs.createHandler(endpoint, method))
+	// 템플릿 예약 키(.path, .body 등)와 겹치는 경로 변수는 등록하지 않음
+	if err := tmpl.CheckPathVars(path); err != nil {
+		log.Printf("Invalid CoAP route %s %s: %v", method, path, err)
+		return
+	}
+
+	handler := cs.createHandler(endpoint, method)
+	if isPathTemplate(path) {
+		templated := handler
+		handler = func(w mux.ResponseWriter, r *mux.Message) {
+			if literal, ok := literals[requestPath(r)]; ok {
+				r.RouteParams.Vars = nil // 고정 경로에는 변수가 없음
+				literal(w, r)
+				return
+			}
+			templated(w, r)
+		}
+	} else {
+		literals[path] = handler
+	}
+
+	if err := router.Handle(path, handler); err != nil {
+		log.Printf("Invalid CoAP route %s %s: %v", method, path, err)
+		return
+	}
 	log.Printf("Added CoAP route: %s %s", method, path)
 }
 
 // createHandler는 CoAP 요청을 처리하는 핸들러 함수를 생성합니다.
 func (cs *CoapServer) createHandler(endpoint config.EndpointConfig, method string) mux.HandlerFunc {
+	// 요청 페이로드 스키마 컴파일 (실패 시 검증 없이 처리하지 않고 5.00 응답)
+	validator, schemaErr := schema.Compile(endpoint.Schema)
+	if schemaErr != nil {
+		log.Printf("Invalid schema for CoAP %s %s, rejecting requests: %v", method, endpoint.Path, schemaErr)
+	}
+
+	// 요청 제한 (서버 전체 coap.rate_limit 및 엔드포인트 rate_limit)
+	limiters, errs := cs.limits.ForEndpoint("coap", cs.config.Coap.RateLimit, endpoint)
+	for _, err := range errs {
+		log.Printf("Rate limit disabled for CoAP %s %s: %v", method, endpoint.Path, err)
+	}
+
 	return func(w mux.ResponseWriter, r *mux.Message) {
+		// 관찰 리소스와 저장/발행에 쓰는 경로 ({변수} 경로면 실제 요청 경로)
+		path := endpoint.Path
+		if isPathTemplate(endpoint.Path) {
+			path = requestPath(r)
+		}
+
+		// 관찰 가능한 엔드포인트의 GET 은 마지막 값 조회 및 관찰 등록/해제
+		res := cs.observe.resourceFor(endpoint.Path, path)
+		if res != nil && r.Code() == codes.GET {
+			cs.observe.serve(w, r, res)
+			return
+		}
+
 		if r.Code().String() != method {
 			w.SetResponse(codes.MethodNotAllowed, message.TextPlain, bytes.NewReader([]byte("Method Not Allowed")))
 			return
 		}
+		vars := pathVars(r) // {변수} 경로에서 추출한 값 (고정 경로면 nil)
 
 		// path, err := r.Options().Path()
 		// if err != nil {
@@ -115,6 +214,44 @@ func (cs *CoapServer) createHandler(endpoint config.EndpointConfig, method strin
 			return
 		}
 
+		// 요청 제한 (초과 시 Max-Age 와 함께 5.03, 저장/발행하지 않음)
+		if !allowRequest(w, r, limiters, endpoint.Path, bodyBytes) {
+			return
+		}
+
+		// 장애/지연 주입 (설정된 경우에만, 주입 시 저장/발행하지 않음)
+		decision := fault.Decide(endpoint.Fault, fault.CoAP)
+		decision.Apply(fault.CoAP, r.Code().String(), endpoint.Path)
+		switch decision.Kind {
+		case fault.Drop:
+			// 응답을 설정하지 않음: NON 요청은 무응답, CON 요청은 빈 ACK 만 전송되고 실제 응답은 오지 않는다
+			return
+		case fault.Error:
+			status := codes.InternalServerError
+			if endpoint.Fault.ErrorStatus != 0 {
+				status = codes.Code(endpoint.Fault.ErrorStatus)
+			}
+			w.SetResponse(status, message.TextPlain, bytes.NewReader([]byte(endpoint.Fault.ErrorBody)))
+			return
+		}
+
+		// 스키마를 컴파일하지 못한 엔드포인트는 검증 없이 받아들이지 않음
+		if schemaErr != nil {
+			log.Printf("CoAP %s %s - 5.00 | Schema unavailable: %v", r.Code(), endpoint.Path, schemaErr)
+			w.SetResponse(codes.InternalServerError, message.TextPlain, bytes.NewReader([]byte("schema unavailable")))
+			return
+		}
+
+		// 스키마가 설정된 경우 페이로드 검증 (실패 시 저장/발행하지 않고 4.00 반환)
+		if validator != nil && (len(bodyBytes) > 0 || r.Code() == codes.POST || r.Code() == codes.PUT) {
+			if violations := validator.Validate(bodyBytes); len(violations) > 0 {
+				metrics.RecordSchemaValidationFailure("coap", endpoint.Path)
+				log.Printf("CoAP %s %s - 4.00 | Schema validation failed: %d violation(s)", r.Code(), endpoint.Path, len(violations))
+				w.SetResponse(codes.BadRequest, message.AppJSON, bytes.NewReader(schema.ErrorBody(violations)))
+				return
+			}
+		}
+
 		if r.Code() == codes.POST && len(bodyBytes) > 0 {
 			var jsonData map[string]interface{}
 
@@ -126,27 +263,47 @@ func (cs *CoapServer) createHandler(endpoint config.EndpointConfig, method strin
 			// 	log.Printf("CoAP Request Log: %s", string(logBytes))
 			// }
 			log.Printf("CoAP Request Log: %s", jsonData)
-			// Save to database and publish to Kafka
-			go func() {
-				receivedTime := time.Now().UnixNano()
-				// Only save to database if DbPool is initialized
-				if db.DbPool != nil {
-					if err := db.SaveCoapMessage(endpoint.Path, string(bodyBytes), r.Code().String(), receivedTime); err != nil {
-						log.Printf("Failed to save CoAP message to database: %v", err)
-					}
-				}
-
-				// Publish to Kafka
+			// Save to database and publish to Kafka (수집 파이프라인에서 처리, 거절 시 5.03)
+			receivedTime := time.Now().UnixNano()
+			method := r.Code().String()
+			deviceID := cs.deviceID(w) // DTLS/TLS 로 인증된 장치 (평문 CoAP 는 빈 문자열)
+			job := ingest.Job{Source: "coap", Run: func() error {
 				kafkaPayload := map[string]interface{}{
-					"path":     endpoint.Path,
-					"method":   r.Code().String(),
+					"path":     path,
+					"method":   method,
 					"data":     jsonData,
 					"received": receivedTime,
 				}
-				if err := cs.publisher.Publish("coap"+endpoint.Path, kafkaPayload); err != nil {
+				if deviceID != "" {
+					kafkaPayload["device_id"] = deviceID
+				}
+				if len(vars) > 0 {
+					kafkaPayload["params"] = vars
+				}
+				event := &db.Event{Topic: kafka.SanitizeTopic("coap" + path), Data: kafkaPayload}
+
+				// DB가 있으면 메시지와 Kafka 이벤트(outbox)를 한 트랜잭션으로 저장, 발행은 outbox 릴레이가 담당
+				if db.DefaultStore() != nil {
+					if err := db.SaveCoapMessage(path, string(bodyBytes), method, deviceID, vars, receivedTime, event); err != nil {
+						log.Printf("Failed to save CoAP message to database: %v", err)
+						return err
+					}
+					return nil
+				}
+
+				// DB 미사용 시 Kafka로 직접 발행
+				if err := cs.publisher.Publish(event.Topic, event.Data); err != nil {
 					log.Printf("Failed to publish CoAP message to Kafka: %v", err)
+					return err
 				}
-			}()
+				return nil
+			}}
+			if err := cs.ingest.Submit(r.Context(), job); err != nil {
+				log.Printf("CoAP %s %s - 5.03 | Not accepted for ingestion: %v", r.Code(), endpoint.Path, err)
+				w.SetResponse(codes.ServiceUnavailable, message.TextPlain, bytes.NewReader([]byte("ingestion queue full")))
+				w.Message().SetOptionUint32(message.MaxAge, 1)
+				return
+			}
 
 		} else if len(bodyBytes) > 0 {
 			log.Printf("CoAP %s %s - %d | Request body: %s", r.Code(), endpoint.Path, endpoint.Response.Status, string(bodyBytes))
@@ -154,80 +311,160 @@ func (cs *CoapServer) createHandler(endpoint config.EndpointConfig, method strin
 			log.Printf("CoAP %s %s - %d", r.Code(), endpoint.Path, endpoint.Response.Status)
 		}
 
-		body := cs.processTemplate(endpoint.Response.Body, nil)
-
-		var mediaType message.MediaType = message.TextPlain
-		if contentType, ok := endpoint.Response.Headers["Content-Type"]; ok {
-			if strings.EqualFold(contentType, "application/json") {
-				mediaType = message.AppJSON
+		// 관찰 가능한 엔드포인트는 받은 값을 보관하고 관찰자에게 알림
+		if res != nil && len(bodyBytes) > 0 {
+			format, err := r.ContentFormat()
+			if err != nil {
+				format = message.TextPlain
 			}
+			res.update(bodyBytes, format)
 		}
 
-		w.SetResponse(codes.Code(endpoint.Response.Status), mediaType, bytes.NewReader([]byte(body)))
+		// 템플릿에서 사용할 요청 컨텍스트 구성
+		var parsedBody interface{}
+		if err := json.Unmarshal(bodyBytes, &parsedBody); err != nil {
+			parsedBody = nil
+		}
+		reqCtx := &tmpl.RequestContext{
+			Method:    r.Code().String(),
+			Path:      requestPath(r),
+			ClientIP:  remoteHost(w),
+			RequestID: tmpl.NewUUID(),
+			Vars:      vars,
+			Query:     queryValues(r),
+			Body:      parsedBody,
+		}
+		body := cs.processTemplate(endpoint.Response.Body, reqCtx.Data())
+
+		w.SetResponse(codes.Code(endpoint.Response.Status), responseMediaType(endpoint.Response), bytes.NewReader([]byte(body)))
 	}
 }
 
-// processTemplate은 응답 본문의 템플릿을 처리합니다.
-func (cs *CoapServer) processTemplate(body string, vars map[string]string) string {
-	if !strings.Contains(body, "{{") {
-		return body
+// responseMediaType은 응답 Content-Type 헤더에 맞는 CoAP Content-Format 을 반환합니다 (JSON 외에는 text/plain).
+func responseMediaType(resp config.ResponseConfig) message.MediaType {
+	if contentType, ok := resp.Headers["Content-Type"]; ok && strings.EqualFold(contentType, "application/json") {
+		return message.AppJSON
 	}
+	return message.TextPlain
+}
 
-	tmpl, err := template.New("response").Parse(body)
-	if err != nil {
-		log.Printf("Template parse error: %v", err)
-		return body
+// allowRequest는 요청 제한을 검사합니다. 초과 시 메트릭을 기록하고
+// 재시도 가능 시점까지의 초를 Max-Age 옵션에 담아 5.03 으로 응답한 뒤 false 를 반환합니다.
+func allowRequest(w mux.ResponseWriter, r *mux.Message, limiters []*ratelimit.Limiter, path string, body []byte) bool {
+	if len(limiters) == 0 {
+		return true
 	}
 
-	data := make(map[string]interface{})
-	for k, v := range vars {
-		if id, err := strconv.Atoi(v); err == nil {
-			data[k] = id
-		} else {
-			data[k] = v
-		}
+	client := ratelimit.Client{IP: remoteHost(w), Body: body}
+	decision, limiter, key := ratelimit.Check(r.Context(), limiters, client)
+	if decision.Allowed {
+		return true
+	}
+
+	metrics.RecordRateLimited("coap", path, decision.Reason)
+	log.Printf("CoAP %s %s - 5.03 | Throttled by %s (%s, key %s)", r.Code(), path, limiter.Scope(), decision.Reason, key)
+
+	reason := "rate limit exceeded"
+	if decision.Reason == ratelimit.ReasonQuota {
+		reason = "daily quota exceeded"
+	}
+	w.SetResponse(codes.ServiceUnavailable, message.TextPlain, bytes.NewReader([]byte(reason)))
+	w.Message().SetOptionUint32(message.MaxAge, uint32(decision.RetryAfterSeconds()))
+	return false
+}
+
+// processTemplate은 응답 본문의 템플릿을 REST 서버와 동일한 함수 집합으로 처리합니다.
+func (cs *CoapServer) processTemplate(body string, data map[string]interface{}) string {
+	return cs.renderer.Render(body, data)
+}
+
+// isPathTemplate은 경로에 {변수} 세그먼트가 있는지 확인합니다.
+func isPathTemplate(path string) bool {
+	return strings.Contains(path, "{")
+}
+
+// requestPath는 요청의 Uri-Path 를 반환합니다 (없으면 "/").
+func requestPath(r *mux.Message) string {
+	path, err := r.Options().Path()
+	if err != nil || path == "" {
+		return "/"
 	}
+	return path
+}
 
-	data["timestamp"] = time.Now().UTC().Format(time.RFC3339)
+// pathVars는 라우터가 {변수} 경로에서 추출한 값을 복사해 반환합니다.
+func pathVars(r *mux.Message) map[string]string {
+	if r.RouteParams == nil || len(r.RouteParams.Vars) == 0 {
+		return nil
+	}
+	vars := make(map[string]string, len(r.RouteParams.Vars))
+	for k, v := range r.RouteParams.Vars {
+		vars[k] = v
+	}
+	return vars
+}
 
-	var buf bytes.Buffer
-	err = tmpl.Execute(&buf, data)
+// queryValues는 CoAP Uri-Query 옵션을 이름별 값 목록으로 변환합니다.
+func queryValues(r *mux.Message) map[string][]string {
+	queries, err := r.Options().Queries()
 	if err != nil {
-		log.Printf("Template execute error: %v", err)
-		return body
+		return nil
+	}
+	values := make(map[string][]string, len(queries))
+	for _, q := range queries {
+		key, value, _ := strings.Cut(q, "=")
+		values[key] = append(values[key], value)
 	}
+	return values
+}
 
-	return buf.String()
+// remoteHost는 요청을 보낸 클라이언트의 IP 주소를 반환합니다.
+func remoteHost(w mux.ResponseWriter) string {
+	addr := w.Conn().RemoteAddr()
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
 }
 
-// Stop은 CoAP 서버를 중지합니다.
-// Stop gracefully stops the CoAP server
+// Stop은 모든 리스너를 닫고 Serve 가 끝날 때까지 기다립니다 (포트 해제).
 func (cs *CoapServer) Stop() {
 	log.Println("Stopping CoAP server...")
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
-	if cs.listening {
-		close(cs.stopChan)
-		// Note: go-coap v3 doesn't provide a way to stop a running server
-		// The server will stop when the main program exits
-		cs.listening = false
+	cs.stopped = true
+	cs.stopListeners()
+}
+
+// Shutdown은 CoAP 서버를 중지하고, 자체 생성한 수집 파이프라인의 대기 작업을 모두 처리합니다.
+func (cs *CoapServer) Shutdown(ctx context.Context) error {
+	cs.Stop()
+	if cs.ownsHub {
+		cs.observe.Close()
+	}
+	if !cs.ownsIngest {
+		return nil
 	}
+	return cs.ingest.Close(ctx)
 }
 
-// Reload는 새로운 설정으로 CoAP 서버를 재시작합니다.
+// Reload는 새 설정으로 라우터를 통째로 교체합니다.
+// 리스너는 주소가 바뀐 경우에만 다시 바인딩하므로 엔드포인트만 바뀌면 소켓과 관찰자가 그대로 유지됩니다.
 func (cs *CoapServer) Reload(newConfig *config.Config) {
 	log.Println("Reloading CoAP server...")
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
 
-	// Stop the current server
-	cs.Stop()
-
-	// Update configuration
 	cs.config = newConfig
-	cs.router = mux.NewRouter()
-	cs.setupRoutes()
-	cs.stopChan = make(chan struct{})
-
-	// Start the server again
-	cs.start()
+	cs.renderer.Reset()
+	cs.router.Store(cs.buildRouter())
+	if !cs.stopped {
+		cs.syncListeners()
+	}
+	log.Println("CoAP server reloaded")
 }
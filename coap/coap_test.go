@@ -4,13 +4,17 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
+	"net"
+	"os"
 	"servone/config"
 	"testing"
 	"time"
 
 	"github.com/plgd-dev/go-coap/v3/message"
 	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
 	"github.com/plgd-dev/go-coap/v3/udp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -103,7 +107,7 @@ func TestCoapServer(t *testing.T) {
 		time.Sleep(100 * time.Millisecond)
 
 		// Kafka에 메시지가 발행되었는지 확인
-		publishedData := mockPublisher.GetPublished("coap/test")
+		publishedData := mockPublisher.GetPublished("bz.coap.test")
 		if publishedData != nil {
 			assert.Equal(t, "/test", publishedData["path"])
 			// CoAP에서는 실제 메서드가 "POST"가 아닐 수 있으므로 확인
@@ -116,3 +120,387 @@ func TestCoapServer(t *testing.T) {
 		}
 	})
 }
+
+func TestCoapSchemaValidation(t *testing.T) {
+	cfg := &config.Config{
+		Coap: config.CoapConfig{
+			Host: "localhost",
+			Port: "5690",
+			Endpoints: []config.EndpointConfig{
+				{
+					Path:   "/tempo",
+					Method: "POST",
+					Response: config.ResponseConfig{
+						Status: 204,
+					},
+					Schema: &config.SchemaConfig{
+						Inline: map[string]interface{}{
+							"type":     "object",
+							"required": []interface{}{"device_id"},
+						},
+					},
+				},
+				{
+					Path:     "/broken",
+					Method:   "POST",
+					Response: config.ResponseConfig{Status: 204},
+					Schema:   &config.SchemaConfig{Inline: map[string]interface{}{"type": "objekt"}},
+				},
+			},
+		},
+	}
+
+	publishedChan := make(chan string, 1)
+	mockPublisher := NewMockKafkaPublisher()
+	mockPublisher.PublishFunc = func(topic string, data map[string]interface{}) error {
+		publishedChan <- topic
+		return nil
+	}
+
+	coapServer := NewCoapServer(cfg, mockPublisher)
+	defer coapServer.Stop()
+	time.Sleep(200 * time.Millisecond)
+
+	co, err := udp.Dial(cfg.Coap.Host + ":" + cfg.Coap.Port)
+	require.NoError(t, err)
+	defer co.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("invalid payload returns 4.00", func(t *testing.T) {
+		resp, err := co.Post(ctx, "/tempo", message.AppJSON, bytes.NewReader([]byte(`{"temp": 20}`)))
+		require.NoError(t, err)
+		assert.Equal(t, codes.BadRequest, resp.Code())
+
+		body, err := io.ReadAll(resp.Body())
+		require.NoError(t, err)
+		assert.Contains(t, string(body), "violations")
+
+		select {
+		case topic := <-publishedChan:
+			t.Fatalf("invalid payload must not be published, got topic %s", topic)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+
+	t.Run("valid payload is accepted", func(t *testing.T) {
+		resp, err := co.Post(ctx, "/tempo", message.AppJSON, bytes.NewReader([]byte(`{"device_id": "d1"}`)))
+		require.NoError(t, err)
+		assert.Equal(t, codes.Code(204), resp.Code())
+
+		select {
+		case topic := <-publishedChan:
+			assert.Equal(t, "bz.coap.tempo", topic)
+		case <-time.After(2 * time.Second):
+			t.Fatal("valid payload was not published")
+		}
+	})
+
+	t.Run("invalid schema returns 5.00", func(t *testing.T) {
+		resp, err := co.Post(ctx, "/broken", message.AppJSON, bytes.NewReader([]byte(`{"device_id": "d1"}`)))
+		require.NoError(t, err)
+		assert.Equal(t, codes.InternalServerError, resp.Code())
+
+		select {
+		case topic := <-publishedChan:
+			t.Fatalf("request to endpoint with invalid schema must not be published, got topic %s", topic)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+}
+
+func TestCoapFaultInjection(t *testing.T) {
+	cfg := &config.Config{
+		Coap: config.CoapConfig{
+			Host: "localhost",
+			Port: "5691",
+			Endpoints: []config.EndpointConfig{
+				{
+					Path:     "/flaky",
+					Method:   "POST",
+					Response: config.ResponseConfig{Status: 205, Body: "OK"},
+					Fault:    &config.FaultConfig{ErrorRate: 1, ErrorBody: "injected"},
+				},
+			},
+		},
+	}
+
+	coapServer := NewCoapServer(cfg, NewMockKafkaPublisher())
+	defer coapServer.Stop()
+	time.Sleep(200 * time.Millisecond)
+
+	co, err := udp.Dial(cfg.Coap.Host + ":" + cfg.Coap.Port)
+	require.NoError(t, err)
+	defer co.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := co.Post(ctx, "/flaky", message.AppJSON, bytes.NewReader([]byte(`{"key": "value"}`)))
+	require.NoError(t, err)
+	assert.Equal(t, codes.InternalServerError, resp.Code())
+
+	body, err := io.ReadAll(resp.Body())
+	require.NoError(t, err)
+	assert.Equal(t, "injected", string(body))
+}
+
+func TestCoapRateLimit(t *testing.T) {
+	cfg := &config.Config{
+		Coap: config.CoapConfig{
+			Host: "localhost",
+			Port: "5692",
+			Endpoints: []config.EndpointConfig{
+				{
+					Path:      "/tempo",
+					Method:    "POST",
+					Response:  config.ResponseConfig{Status: int(codes.Changed)},
+					RateLimit: &config.RateLimitConfig{Rate: 0.01, Burst: 1, Key: "body:device_id"},
+				},
+			},
+		},
+	}
+
+	mockPublisher := NewMockKafkaPublisher()
+	coapServer := NewCoapServer(cfg, mockPublisher)
+	defer coapServer.Stop()
+	time.Sleep(200 * time.Millisecond)
+
+	co, err := udp.Dial(cfg.Coap.Host + ":" + cfg.Coap.Port)
+	require.NoError(t, err)
+	defer co.Close()
+
+	post := func(body string) *pool.Message {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		resp, err := co.Post(ctx, "/tempo", message.AppJSON, bytes.NewReader([]byte(body)))
+		require.NoError(t, err)
+		return resp
+	}
+
+	resp := post(`{"device_id": "dev-1"}`)
+	assert.Equal(t, codes.Changed, resp.Code())
+
+	resp = post(`{"device_id": "dev-1"}`)
+	assert.Equal(t, codes.ServiceUnavailable, resp.Code())
+	maxAge, err := resp.Options().GetUint32(message.MaxAge)
+	require.NoError(t, err)
+	assert.Greater(t, maxAge, uint32(0))
+
+	// 다른 장치는 별도 버킷
+	resp = post(`{"device_id": "dev-2"}`)
+	assert.Equal(t, codes.Changed, resp.Code())
+}
+
+// reloadTestConfig는 path 엔드포인트 하나를 가진 설정을 만듭니다.
+func reloadTestConfig(port, path string) *config.Config {
+	return &config.Config{
+		Coap: config.CoapConfig{
+			Host: "localhost",
+			Port: port,
+			TCP:  config.CoapTCPConfig{Port: "5707"},
+			Endpoints: []config.EndpointConfig{
+				{Path: path, Method: "POST", Response: config.ResponseConfig{Status: int(codes.Content), Body: path}},
+			},
+		},
+	}
+}
+
+// postCode는 UDP 로 POST 를 보내고 응답 코드를 반환합니다.
+func postCode(t *testing.T, port, path string) codes.Code {
+	co, err := udp.Dial("localhost:" + port)
+	require.NoError(t, err)
+	defer co.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	resp, err := co.Post(ctx, path, message.AppJSON, bytes.NewReader([]byte(`{"v":1}`)))
+	require.NoError(t, err)
+	return resp.Code()
+}
+
+// portFree는 UDP 포트를 다시 바인딩할 수 있는지 (해제되었는지) 확인합니다.
+func portFree(port string) bool {
+	conn, err := net.ListenPacket("udp", "localhost:"+port)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+func TestCoapReload(t *testing.T) {
+	cs := NewCoapServer(reloadTestConfig("5705", "/v0"), NewMockKafkaPublisher())
+	t.Cleanup(func() { cs.Shutdown(context.Background()) })
+	udpListener := cs.listeners[transportUDP]
+	tcpListener := cs.listeners[transportTCP]
+	require.NotNil(t, udpListener)
+	require.NotNil(t, tcpListener)
+
+	t.Run("endpoint changes swap the router without rebinding", func(t *testing.T) {
+		for i := 1; i <= 10; i++ {
+			cs.Reload(reloadTestConfig("5705", fmt.Sprintf("/v%d", i)))
+		}
+		assert.Same(t, udpListener, cs.listeners[transportUDP])
+		assert.Same(t, tcpListener, cs.listeners[transportTCP])
+		assert.Equal(t, codes.Content, postCode(t, "5705", "/v10"))
+		assert.Equal(t, codes.NotFound, postCode(t, "5705", "/v9"))
+	})
+
+	t.Run("port changes rebind only the changed listener", func(t *testing.T) {
+		cs.Reload(reloadTestConfig("5708", "/moved"))
+		assert.True(t, portFree("5705"), "old port is released")
+		assert.Equal(t, codes.Content, postCode(t, "5708", "/moved"))
+		assert.Same(t, tcpListener, cs.listeners[transportTCP])
+	})
+
+	t.Run("repeated rebinding", func(t *testing.T) {
+		ports := []string{"5705", "5708"}
+		for i := 0; i < 10; i++ {
+			cs.Reload(reloadTestConfig(ports[i%2], "/loop"))
+			assert.Equal(t, codes.Content, postCode(t, ports[i%2], "/loop"))
+		}
+		assert.True(t, portFree("5705"))
+	})
+
+	t.Run("disabled listeners are stopped", func(t *testing.T) {
+		cfg := reloadTestConfig("5708", "/loop")
+		cfg.Coap.TCP.Port = ""
+		cs.Reload(cfg)
+		assert.NotContains(t, cs.listeners, transportTCP)
+	})
+
+	t.Run("stop releases the socket and later reloads do not rebind", func(t *testing.T) {
+		cs.Stop()
+		assert.True(t, portFree("5708"))
+		cs.Reload(reloadTestConfig("5708", "/after-stop"))
+		assert.Empty(t, cs.listeners)
+		assert.True(t, portFree("5708"))
+	})
+}
+
+func TestCoapPathVariables(t *testing.T) {
+	stored := useFileStore(t)
+	cfg := &config.Config{
+		Coap: config.CoapConfig{
+			Host: "localhost",
+			Port: "5710",
+			Endpoints: []config.EndpointConfig{
+				{Path: "/sensors/{id}", Method: "POST", Response: config.ResponseConfig{Status: int(codes.Changed), Body: "sensor {{.vars.id}}"}},
+				{Path: "/sensors/all", Method: "POST", Response: config.ResponseConfig{Status: int(codes.Changed), Body: "all"}},
+				{Path: "/sites/{site}/rooms/{room}", Method: "POST", Response: config.ResponseConfig{Status: int(codes.Changed), Body: "{{.site}}-{{.room}}"}},
+			},
+		},
+	}
+	cs := NewCoapServer(cfg, NewMockKafkaPublisher())
+	t.Cleanup(func() { cs.Shutdown(context.Background()) })
+
+	co, err := udp.Dial("localhost:5710")
+	require.NoError(t, err)
+	defer co.Close()
+	post := func(path string) (codes.Code, string) {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		resp, err := co.Post(ctx, path, message.AppJSON, bytes.NewReader([]byte(`{"v":1}`)))
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body())
+		require.NoError(t, err)
+		return resp.Code(), string(body)
+	}
+
+	t.Run("variables are available to templates", func(t *testing.T) {
+		code, body := post("/sensors/temp-1")
+		assert.Equal(t, codes.Changed, code)
+		assert.Equal(t, "sensor temp-1", body)
+
+		_, body = post("/sites/seoul/rooms/101")
+		assert.Equal(t, "seoul-101", body)
+	})
+
+	t.Run("literal path takes precedence over template", func(t *testing.T) {
+		_, body := post("/sensors/all")
+		assert.Equal(t, "all", body)
+	})
+
+	t.Run("variables are stored with the message", func(t *testing.T) {
+		var params []interface{}
+		assert.Eventually(t, func() bool {
+			params = storedParameters(t, stored)
+			return len(params) == 3
+		}, 2*time.Second, 20*time.Millisecond)
+		assert.ElementsMatch(t, []interface{}{
+			map[string]interface{}{"id": "temp-1"},
+			map[string]interface{}{"site": "seoul", "room": "101"},
+			nil, // 고정 경로는 변수 없음
+		}, params)
+	})
+
+	t.Run("the request path is stored", func(t *testing.T) {
+		assert.ElementsMatch(t, []interface{}{"/sensors/temp-1", "/sites/seoul/rooms/101", "/sensors/all"}, storedColumn(t, stored, "path"))
+	})
+}
+
+func TestCoapPathVariablesPublished(t *testing.T) {
+	// DB 없이 Kafka 로 직접 발행
+	type event struct {
+		topic string
+		data  map[string]interface{}
+	}
+	published := make(chan event, 1)
+	publisher := NewMockKafkaPublisher()
+	publisher.PublishFunc = func(topic string, data map[string]interface{}) error {
+		published <- event{topic, data}
+		return nil
+	}
+	cfg := &config.Config{
+		Coap: config.CoapConfig{
+			Host: "localhost",
+			Port: "5712",
+			Endpoints: []config.EndpointConfig{
+				{Path: "/sensors/{id}", Method: "POST", Response: config.ResponseConfig{Status: int(codes.Changed)}},
+			},
+		},
+	}
+	cs := NewCoapServer(cfg, publisher)
+	t.Cleanup(func() { cs.Shutdown(context.Background()) })
+
+	co, err := udp.Dial("localhost:5712")
+	require.NoError(t, err)
+	defer co.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	_, err = co.Post(ctx, "/sensors/temp-2", message.AppJSON, bytes.NewReader([]byte(`{"v":1}`)))
+	require.NoError(t, err)
+
+	select {
+	case ev := <-published:
+		assert.Equal(t, "bz.coap.sensors.temp-2", ev.topic, "the topic follows the request path")
+		assert.Equal(t, "/sensors/temp-2", ev.data["path"])
+		assert.Equal(t, map[string]string{"id": "temp-2"}, ev.data["params"])
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event published")
+	}
+}
+
+// storedParameters는 저장된 coap_messages 행의 parameters 목록을 반환합니다.
+func storedParameters(t *testing.T, path string) []interface{} {
+	return storedColumn(t, path, "parameters")
+}
+
+// storedColumn은 저장된 coap_messages 행의 column 값 목록을 반환합니다.
+func storedColumn(t *testing.T, path, column string) []interface{} {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var values []interface{}
+	for _, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var row map[string]interface{}
+		require.NoError(t, json.Unmarshal(line, &row))
+		values = append(values, row[column])
+	}
+	return values
+}
@@ -0,0 +1,143 @@
+package coap
+
+import (
+	"bytes"
+	"log"
+	"strconv"
+	"strings"
+
+	"servone/config"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/mux"
+)
+
+// CoRE 리소스 검색 경로 (RFC 6690)
+const wellKnownCore = "/.well-known/core"
+
+// coreLink는 /.well-known/core 응답의 링크 하나입니다.
+type coreLink struct {
+	path          string
+	resourceType  string
+	iface         string
+	contentFormat int
+	observable    bool
+}
+
+// coreLinks는 엔드포인트 설정으로 링크 목록을 만듭니다.
+// 같은 경로는 먼저 정의된 엔드포인트의 속성으로 한 번만 나오며, {변수} 경로와 hidden 링크는 제외합니다.
+func coreLinks(endpoints []config.EndpointConfig) []coreLink {
+	var links []coreLink
+	seen := make(map[string]bool)
+	for _, endpoint := range endpoints {
+		if seen[endpoint.Path] || endpoint.Path == wellKnownCore || isPathTemplate(endpoint.Path) {
+			continue
+		}
+		seen[endpoint.Path] = true
+
+		link := coreLink{
+			path:          endpoint.Path,
+			contentFormat: int(responseMediaType(endpoint.Response)),
+			observable:    endpoint.Observable,
+		}
+		if cfg := endpoint.Link; cfg != nil {
+			if cfg.Hidden {
+				continue
+			}
+			link.resourceType = cfg.ResourceType
+			link.iface = cfg.Interface
+			if cfg.ContentFormat != nil {
+				link.contentFormat = *cfg.ContentFormat
+			}
+			if cfg.Observable != nil {
+				link.observable = *cfg.Observable
+			}
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
+// String은 CoRE Link Format 으로 링크를 표현합니다 (예: </sensors/temp>;rt="temperature";if="sensor";ct=50;obs).
+func (l coreLink) String() string {
+	var b strings.Builder
+	b.WriteString("<" + l.path + ">")
+	if l.resourceType != "" {
+		b.WriteString(`;rt="` + l.resourceType + `"`)
+	}
+	if l.iface != "" {
+		b.WriteString(`;if="` + l.iface + `"`)
+	}
+	b.WriteString(";ct=" + strconv.Itoa(l.contentFormat))
+	if l.observable {
+		b.WriteString(";obs")
+	}
+	return b.String()
+}
+
+// matches는 RFC 6690 4.1 의 검색 필터(href, rt, if, ct, obs)를 검사합니다.
+// 값 끝의 * 는 접두사 일치이며, rt/if 는 공백으로 구분된 값 중 하나만 일치하면 됩니다. 모르는 속성은 일치하지 않습니다.
+func (l coreLink) matches(name, value string) bool {
+	switch name {
+	case "href":
+		return filterMatches(value, l.path)
+	case "rt":
+		return anyFilterMatches(value, l.resourceType)
+	case "if":
+		return anyFilterMatches(value, l.iface)
+	case "ct":
+		return filterMatches(value, strconv.Itoa(l.contentFormat))
+	case "obs":
+		return l.observable
+	}
+	return false
+}
+
+func anyFilterMatches(filter, values string) bool {
+	for _, v := range strings.Fields(values) {
+		if filterMatches(filter, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func filterMatches(filter, value string) bool {
+	if prefix, ok := strings.CutSuffix(filter, "*"); ok {
+		return strings.HasPrefix(value, prefix)
+	}
+	return filter == value
+}
+
+// discoveryHandler는 /.well-known/core 요청에 링크 목록을 application/link-format 으로 응답합니다.
+// Uri-Query 필터(예: ?rt=temperature, ?href=/sensors/*)가 있으면 모두 만족하는 링크만 반환합니다.
+func discoveryHandler(endpoints []config.EndpointConfig) mux.HandlerFunc {
+	links := coreLinks(endpoints)
+	return func(w mux.ResponseWriter, r *mux.Message) {
+		if r.Code() != codes.GET {
+			w.SetResponse(codes.MethodNotAllowed, message.TextPlain, bytes.NewReader([]byte("Method Not Allowed")))
+			return
+		}
+
+		filters, _ := r.Options().Queries()
+		var matched []string
+		for _, link := range links {
+			if linkMatches(link, filters) {
+				matched = append(matched, link.String())
+			}
+		}
+		log.Printf("CoAP GET %s - 2.05 | %d link(s)", wellKnownCore, len(matched))
+		w.SetResponse(codes.Content, message.AppLinkFormat, bytes.NewReader([]byte(strings.Join(matched, ","))))
+	}
+}
+
+func linkMatches(link coreLink, filters []string) bool {
+	for _, f := range filters {
+		name, value, _ := strings.Cut(f, "=")
+		if !link.matches(name, value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,116 @@
+package coap
+
+import (
+	"context"
+	"io"
+	"servone/config"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/udp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func discoveryEndpoints() []config.EndpointConfig {
+	ct := 60
+	obs := false
+	return []config.EndpointConfig{
+		{
+			Path: "/sensors/temp", Method: "POST", Observable: true,
+			Response: config.ResponseConfig{Headers: map[string]string{"Content-Type": "application/json"}},
+			Link:     &config.LinkConfig{ResourceType: "temperature-c", Interface: "sensor"},
+		},
+		{Path: "/sensors/temp", Method: "PUT"}, // 같은 경로는 한 번만
+		{Path: "/sensors/humidity", Method: "POST", Link: &config.LinkConfig{ResourceType: "humidity", Interface: "sensor core.s"}},
+		{Path: "/actuators/fan", Method: "PUT", Observable: true, Link: &config.LinkConfig{ContentFormat: &ct, Observable: &obs}},
+		{Path: "/sensors/{id}", Method: "POST"},                                     // 템플릿 경로는 제외
+		{Path: "/internal", Method: "POST", Link: &config.LinkConfig{Hidden: true}}, // 숨김
+	}
+}
+
+func TestCoreLinks(t *testing.T) {
+	links := coreLinks(discoveryEndpoints())
+	var formatted []string
+	for _, l := range links {
+		formatted = append(formatted, l.String())
+	}
+	assert.Equal(t, []string{
+		`</sensors/temp>;rt="temperature-c";if="sensor";ct=50;obs`,
+		`</sensors/humidity>;rt="humidity";if="sensor core.s";ct=0`,
+		`</actuators/fan>;ct=60`,
+	}, formatted)
+
+	tests := []struct {
+		filters []string
+		want    int
+	}{
+		{nil, 3},
+		{[]string{"rt=temperature-c"}, 1},
+		{[]string{"rt=temp*"}, 1},
+		{[]string{"if=core.s"}, 1},
+		{[]string{"if=sensor", "rt=humidity"}, 1},
+		{[]string{"href=/sensors/*"}, 2},
+		{[]string{"ct=60"}, 1},
+		{[]string{"obs"}, 1},
+		{[]string{"title=x"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.filters, "&"), func(t *testing.T) {
+			n := 0
+			for _, l := range links {
+				if linkMatches(l, tt.filters) {
+					n++
+				}
+			}
+			assert.Equal(t, tt.want, n)
+		})
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	cfg := &config.Config{Coap: config.CoapConfig{Host: "localhost", Port: "5709", Endpoints: discoveryEndpoints()}}
+	cs := NewCoapServer(cfg, NewMockKafkaPublisher())
+	t.Cleanup(func() { cs.Shutdown(context.Background()) })
+
+	co, err := udp.Dial("localhost:5709")
+	require.NoError(t, err)
+	defer co.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	t.Run("lists configured resources in link format", func(t *testing.T) {
+		resp, err := co.Get(ctx, wellKnownCore)
+		require.NoError(t, err)
+		assert.Equal(t, codes.Content, resp.Code())
+		format, err := resp.ContentFormat()
+		require.NoError(t, err)
+		assert.Equal(t, message.AppLinkFormat, format)
+		body, err := io.ReadAll(resp.Body())
+		require.NoError(t, err)
+		assert.Equal(t, `</sensors/temp>;rt="temperature-c";if="sensor";ct=50;obs,</sensors/humidity>;rt="humidity";if="sensor core.s";ct=0,</actuators/fan>;ct=60`, string(body))
+	})
+
+	t.Run("query filters links", func(t *testing.T) {
+		resp, err := co.Get(ctx, wellKnownCore, message.Option{ID: message.URIQuery, Value: []byte("rt=humidity")})
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body())
+		require.NoError(t, err)
+		assert.Equal(t, `</sensors/humidity>;rt="humidity";if="sensor core.s";ct=0`, string(body))
+	})
+
+	t.Run("reload regenerates links", func(t *testing.T) {
+		newCfg := *cfg
+		newCfg.Coap.Endpoints = []config.EndpointConfig{{Path: "/only", Method: "POST"}}
+		cs.Reload(&newCfg)
+
+		resp, err := co.Get(ctx, wellKnownCore)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body())
+		require.NoError(t, err)
+		assert.Equal(t, `</only>;ct=0`, string(body))
+	})
+}
@@ -0,0 +1,275 @@
+package coap
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"servone/certs"
+	"servone/config"
+	"servone/db"
+
+	piondtls "github.com/pion/dtls/v3"
+	coapdtls "github.com/plgd-dev/go-coap/v3/dtls"
+	"github.com/plgd-dev/go-coap/v3/mux"
+	coapnet "github.com/plgd-dev/go-coap/v3/net"
+	"github.com/plgd-dev/go-coap/v3/options"
+	"gopkg.in/yaml.v3"
+)
+
+// PSK 를 DB 에서 읽을 때의 제한 시간
+const pskLoadTimeout = 5 * time.Second
+
+// PSK 모드 암호 스위트 (RFC 7252 9.1.3.1 필수 스위트 우선)
+var pskCipherSuites = []piondtls.CipherSuiteID{
+	piondtls.TLS_PSK_WITH_AES_128_CCM_8,
+	piondtls.TLS_PSK_WITH_AES_128_CCM,
+	piondtls.TLS_PSK_WITH_AES_128_GCM_SHA256,
+}
+
+// 인증서 모드 암호 스위트 (RFC 7252 9.1.3.3 필수 스위트 우선)
+var certCipherSuites = []piondtls.CipherSuiteID{
+	piondtls.TLS_ECDHE_ECDSA_WITH_AES_128_CCM_8,
+	piondtls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	piondtls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	piondtls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	piondtls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// PSK 파일 항목 (key 는 hex 인코딩)
+type pskFileEntry struct {
+	Identity string `yaml:"identity"`
+	Key      string `yaml:"key"`
+	DeviceID string `yaml:"device_id"`
+}
+
+// pskKeyring은 identity 별 PSK 와 장치 ID 를 보관합니다. 리로드 시 통째로 교체됩니다.
+type pskKeyring struct {
+	mu   sync.RWMutex
+	keys map[string]db.PSK
+}
+
+func (k *pskKeyring) lookup(identity string) (db.PSK, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	psk, ok := k.keys[identity]
+	return psk, ok
+}
+
+func (k *pskKeyring) replace(keys map[string]db.PSK) {
+	k.mu.Lock()
+	k.keys = keys
+	k.mu.Unlock()
+}
+
+// loaded는 한 번이라도 키를 읽었는지 반환합니다.
+func (k *pskKeyring) loaded() bool {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.keys != nil
+}
+
+// loadPSKs는 psk_file 과 coap_psk 테이블의 PSK 를 읽습니다. 같은 identity 는 DB 값이 우선합니다.
+func loadPSKs(cfg config.DTLSConfig) (map[string]db.PSK, error) {
+	keys := make(map[string]db.PSK)
+	if cfg.PSKFile != "" {
+		fileKeys, err := loadPSKFile(cfg.PSKFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range fileKeys {
+			keys[k.Identity] = k
+		}
+	}
+	if cfg.PSKFromDB {
+		source, ok := db.DefaultStore().(db.PSKSource)
+		if !ok {
+			return nil, errors.New("psk_from_db needs a postgres or sqlite database")
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), pskLoadTimeout)
+		defer cancel()
+		dbKeys, err := source.LoadPSKs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range dbKeys {
+			keys[k.Identity] = k
+		}
+	}
+	return keys, nil
+}
+
+// loadPSKFile은 identity, key(hex), device_id 목록 YAML 파일을 읽습니다.
+func loadPSKFile(path string) ([]db.PSK, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read psk_file: %w", err)
+	}
+	var entries []pskFileEntry
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse psk_file %s: %w", path, err)
+	}
+
+	keys := make([]db.PSK, 0, len(entries))
+	for i, e := range entries {
+		if e.Identity == "" || e.Key == "" {
+			return nil, fmt.Errorf("psk_file %s: entry %d needs identity and key", path, i+1)
+		}
+		key, err := hex.DecodeString(e.Key)
+		if err != nil {
+			return nil, fmt.Errorf("psk_file %s: key of %q is not hex encoded: %w", path, e.Identity, err)
+		}
+		deviceID := e.DeviceID
+		if deviceID == "" {
+			deviceID = e.Identity
+		}
+		keys = append(keys, db.PSK{Identity: e.Identity, Key: key, DeviceID: deviceID})
+	}
+	return keys, nil
+}
+
+// startDTLS는 설정된 경우 coaps 리스너를 시작합니다.
+// PSK 와 인증서는 핸드셰이크마다 현재 값을 조회하므로 리로드 시 다시 바인딩하지 않고 refreshDTLS 로 다시 읽습니다.
+func (cs *CoapServer) startDTLS() {
+	cfg := cs.config.Coap.DTLS
+	if !cfg.Enabled() {
+		return
+	}
+
+	dtlsConfig, err := cs.dtlsConfig(cfg)
+	if err != nil {
+		log.Printf("CoAP DTLS listener disabled: %v", err)
+		return
+	}
+
+	addr := cs.config.Coap.Host + ":" + cfg.Port
+	l, err := coapnet.NewDTLSListener("udp", addr, dtlsConfig)
+	if err != nil {
+		log.Printf("CoAP DTLS listener error: %v", err)
+		return
+	}
+
+	server := coapdtls.NewServer(options.WithMux(cs.transportHandler(transportDTLS)), withoutBlockwise())
+	cs.serveListener(transportDTLS, addr, cfg, l, server.Stop, func() error { return server.Serve(l) })
+}
+
+// refreshDTLS는 실행 중인 DTLS 리스너의 PSK 와 인증서를 다시 읽습니다.
+func (cs *CoapServer) refreshDTLS() {
+	if err := cs.loadDTLSKeys(cs.config.Coap.DTLS); err != nil {
+		log.Printf("Failed to reload CoAP DTLS keys: %v", err)
+	}
+}
+
+// loadDTLSKeys는 PSK 와 인증서를 (다시) 읽습니다. 다시 읽지 못하면 이전에 읽은 값을 계속 사용합니다.
+func (cs *CoapServer) loadDTLSKeys(cfg config.DTLSConfig) error {
+	if cfg.PSKEnabled() {
+		keys, err := loadPSKs(cfg)
+		switch {
+		case err == nil:
+			cs.psk.replace(keys)
+			log.Printf("Loaded %d CoAP DTLS pre-shared key(s)", len(keys))
+		case cs.psk.loaded():
+			log.Printf("Failed to reload CoAP DTLS pre-shared keys, keeping previous keys: %v", err)
+		default:
+			return err
+		}
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		if cs.certs == nil {
+			store, err := certs.NewStore(cfg.TLS())
+			if err != nil {
+				return err
+			}
+			cs.certs = store
+		} else if err := cs.certs.Update(cfg.TLS()); err != nil {
+			log.Printf("Failed to reload CoAP DTLS certificate, keeping previous certificate: %v", err)
+		}
+	}
+	return nil
+}
+
+// dtlsConfig는 PSK 와 인증서 설정으로 DTLS 설정을 만듭니다.
+func (cs *CoapServer) dtlsConfig(cfg config.DTLSConfig) (*piondtls.Config, error) {
+	certMode := cfg.CertFile != "" || cfg.KeyFile != ""
+	if !cfg.PSKEnabled() && !certMode {
+		return nil, errors.New("set psk_file, psk_from_db or cert_file/key_file")
+	}
+	if err := cs.loadDTLSKeys(cfg); err != nil {
+		return nil, err
+	}
+
+	dtlsConfig := &piondtls.Config{
+		ExtendedMasterSecret: piondtls.RequireExtendedMasterSecret,
+	}
+
+	if cfg.PSKEnabled() {
+		dtlsConfig.CipherSuites = append(dtlsConfig.CipherSuites, pskCipherSuites...)
+		dtlsConfig.PSK = func(identity []byte) ([]byte, error) {
+			psk, ok := cs.psk.lookup(string(identity))
+			if !ok {
+				log.Printf("CoAP DTLS handshake rejected: unknown PSK identity %q", identity)
+				return nil, fmt.Errorf("unknown PSK identity %q", identity)
+			}
+			return psk.Key, nil
+		}
+	}
+
+	if certMode {
+		store := cs.certs
+		dtlsConfig.CipherSuites = append(dtlsConfig.CipherSuites, certCipherSuites...)
+		dtlsConfig.GetCertificate = func(*piondtls.ClientHelloInfo) (*tls.Certificate, error) {
+			return store.Certificate(), nil
+		}
+		// 클라이언트 CA 묶음은 바인딩할 때 고정됨 (client_ca_file 변경 시 다시 바인딩)
+		if pool := store.ClientCAs(); pool != nil {
+			dtlsConfig.ClientCAs = pool
+			dtlsConfig.ClientAuth = piondtls.RequireAndVerifyClientCert
+			if strings.EqualFold(cfg.ClientAuth, "optional") {
+				dtlsConfig.ClientAuth = piondtls.VerifyClientCertIfGiven
+			}
+		}
+	}
+	return dtlsConfig, nil
+}
+
+// deviceID는 DTLS 또는 TLS 로 인증된 장치 ID 를 반환합니다.
+// PSK 는 identity 에 매핑된 device_id, 인증서는 클라이언트 인증서의 CN 이며 평문 CoAP 이면 빈 문자열입니다.
+func (cs *CoapServer) deviceID(w mux.ResponseWriter) string {
+	nc, ok := w.Conn().(interface{ NetConn() net.Conn })
+	if !ok {
+		return ""
+	}
+	switch conn := nc.NetConn().(type) {
+	case *tls.Conn:
+		if state := conn.ConnectionState(); len(state.PeerCertificates) > 0 {
+			return state.PeerCertificates[0].Subject.CommonName
+		}
+	case *piondtls.Conn:
+		state, ok := conn.ConnectionState()
+		if !ok {
+			return ""
+		}
+		if identity := string(state.IdentityHint); identity != "" {
+			if psk, ok := cs.psk.lookup(identity); ok {
+				return psk.DeviceID
+			}
+			return identity
+		}
+		if len(state.PeerCertificates) > 0 {
+			if cert, err := x509.ParseCertificate(state.PeerCertificates[0]); err == nil {
+				return cert.Subject.CommonName
+			}
+		}
+	}
+	return ""
+}
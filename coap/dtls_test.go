@@ -0,0 +1,215 @@
+package coap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"servone/config"
+	"servone/db"
+	"servone/internal/testcert"
+	"testing"
+	"time"
+
+	piondtls "github.com/pion/dtls/v3"
+	coapdtls "github.com/plgd-dev/go-coap/v3/dtls"
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPSKFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "psk.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+		return path
+	}
+
+	t.Run("reads identities, keys and device IDs", func(t *testing.T) {
+		keys, err := loadPSKFile(write("- identity: sensor-01\n  key: \"0a0b\"\n  device_id: dev-01\n- identity: sensor-02\n  key: \"ff\"\n"))
+		require.NoError(t, err)
+		assert.Equal(t, []db.PSK{
+			{Identity: "sensor-01", Key: []byte{0x0a, 0x0b}, DeviceID: "dev-01"},
+			{Identity: "sensor-02", Key: []byte{0xff}, DeviceID: "sensor-02"}, // device_id 가 없으면 identity
+		}, keys)
+	})
+
+	t.Run("rejects invalid entries", func(t *testing.T) {
+		_, err := loadPSKFile(write("- identity: sensor-01\n"))
+		assert.ErrorContains(t, err, "needs identity and key")
+
+		_, err = loadPSKFile(write("- identity: sensor-01\n  key: secret\n"))
+		assert.ErrorContains(t, err, "not hex encoded")
+
+		_, err = loadPSKFile(filepath.Join(dir, "missing.yaml"))
+		assert.Error(t, err)
+	})
+}
+
+// DTLS 테스트 서버 설정 (평문 포트와 coaps 포트)
+func dtlsTestConfig(port, securePort string, dtls config.DTLSConfig) *config.Config {
+	dtls.Port = securePort
+	return &config.Config{
+		Coap: config.CoapConfig{
+			Host: "localhost",
+			Port: port,
+			DTLS: dtls,
+			Endpoints: []config.EndpointConfig{
+				{
+					Path:     "/secure",
+					Method:   "POST",
+					Response: config.ResponseConfig{Status: int(codes.Created), Body: "OK"},
+				},
+			},
+		},
+	}
+}
+
+// useFileStore는 저장된 coap_messages 행을 확인하기 위해 JSONL 저장소를 기본 저장소로 사용합니다.
+func useFileStore(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "servone.jsonl")
+	store, err := db.OpenFileStore(path, nil)
+	require.NoError(t, err)
+	db.UseStore(store)
+	t.Cleanup(func() {
+		db.UseStore(nil)
+		store.Close()
+	})
+	return path
+}
+
+// storedDevices는 저장된 coap_messages 행의 device_id 목록을 반환합니다.
+func storedDevices(t *testing.T, path string) []interface{} {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var devices []interface{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var row map[string]interface{}
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &row))
+		devices = append(devices, row["device_id"])
+	}
+	return devices
+}
+
+// postSecure는 DTLS 로 /secure 에 POST 합니다.
+func postSecure(t *testing.T, port string, cfg *piondtls.Config) error {
+	co, err := coapdtls.Dial("localhost:"+port, cfg)
+	if err != nil {
+		return err
+	}
+	defer co.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	resp, err := co.Post(ctx, "/secure", message.AppJSON, bytes.NewReader([]byte(`{"v":1}`)))
+	if err != nil {
+		return err
+	}
+	assert.Equal(t, codes.Created, resp.Code())
+	return nil
+}
+
+func pskClient(identity string, key []byte) *piondtls.Config {
+	return &piondtls.Config{
+		PSK:             func([]byte) ([]byte, error) { return key, nil },
+		PSKIdentityHint: []byte(identity),
+		CipherSuites:    []piondtls.CipherSuiteID{piondtls.TLS_PSK_WITH_AES_128_CCM_8},
+	}
+}
+
+func TestDTLSPSK(t *testing.T) {
+	stored := useFileStore(t)
+	pskFile := filepath.Join(t.TempDir(), "psk.yaml")
+	require.NoError(t, os.WriteFile(pskFile, []byte("- identity: sensor-01\n  key: \"01020304\"\n  device_id: dev-01\n"), 0600))
+
+	cfg := dtlsTestConfig("5695", "5696", config.DTLSConfig{PSKFile: pskFile})
+	cs := NewCoapServer(cfg, NewMockKafkaPublisher())
+	t.Cleanup(func() { cs.Shutdown(context.Background()) })
+
+	t.Run("known identity is stored with its device ID", func(t *testing.T) {
+		require.NoError(t, postSecure(t, "5696", pskClient("sensor-01", []byte{1, 2, 3, 4})))
+		assert.Eventually(t, func() bool {
+			devices := storedDevices(t, stored)
+			return len(devices) == 1 && devices[0] == "dev-01"
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("unknown identity and wrong key are rejected", func(t *testing.T) {
+		assert.Error(t, postSecure(t, "5696", pskClient("sensor-99", []byte{1, 2, 3, 4})))
+		assert.Error(t, postSecure(t, "5696", pskClient("sensor-01", []byte{9, 9, 9, 9})))
+	})
+
+	t.Run("reload picks up changed keys", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(pskFile, []byte("- identity: sensor-02\n  key: \"0506\"\n  device_id: dev-02\n"), 0600))
+		cs.Reload(dtlsTestConfig("5695", "5696", config.DTLSConfig{PSKFile: pskFile}))
+
+		assert.Error(t, postSecure(t, "5696", pskClient("sensor-01", []byte{1, 2, 3, 4})))
+		require.NoError(t, postSecure(t, "5696", pskClient("sensor-02", []byte{5, 6})))
+		assert.Eventually(t, func() bool {
+			devices := storedDevices(t, stored)
+			return len(devices) == 2 && devices[1] == "dev-02"
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("failed reload keeps previous keys", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(pskFile, []byte("- identity: broken\n"), 0600))
+		cs.Reload(dtlsTestConfig("5695", "5696", config.DTLSConfig{PSKFile: pskFile}))
+		require.NoError(t, postSecure(t, "5696", pskClient("sensor-02", []byte{5, 6})))
+	})
+}
+
+func TestDTLSCertificates(t *testing.T) {
+	stored := useFileStore(t)
+	dir := t.TempDir()
+
+	ca, caKey, _ := testcert.Issue(t, "servone-test-ca", nil, nil)
+	caFile := filepath.Join(dir, "ca.pem")
+	testcert.Write(t, caFile, ca)
+	serverCert, serverKey, _ := testcert.Issue(t, "localhost", ca, caKey)
+	certFile, keyFile := testcert.WriteKeyPair(t, dir, "server", serverCert, serverKey)
+	_, _, clientCert := testcert.Issue(t, "device-42", ca, caKey)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	cfg := dtlsTestConfig("5697", "5698", config.DTLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile})
+	cs := NewCoapServer(cfg, NewMockKafkaPublisher())
+	t.Cleanup(func() { cs.Shutdown(context.Background()) })
+
+	t.Run("client certificate CN is the device ID", func(t *testing.T) {
+		require.NoError(t, postSecure(t, "5698", &piondtls.Config{
+			Certificates: []tls.Certificate{clientCert},
+			RootCAs:      roots,
+			ServerName:   "localhost",
+		}))
+		assert.Eventually(t, func() bool {
+			devices := storedDevices(t, stored)
+			return len(devices) == 1 && devices[0] == "device-42"
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("client certificate is required", func(t *testing.T) {
+		assert.Error(t, postSecure(t, "5698", &piondtls.Config{RootCAs: roots, ServerName: "localhost"}))
+	})
+}
+
+func TestDTLSDisabled(t *testing.T) {
+	cs := &CoapServer{config: dtlsTestConfig("0", "5699", config.DTLSConfig{})}
+	cs.startDTLS()
+	assert.Empty(t, cs.listeners, "DTLS needs PSKs or a certificate")
+}
+
+func TestLoadPSKsFromDB(t *testing.T) {
+	db.UseStore(nil)
+	_, err := loadPSKs(config.DTLSConfig{PSKFromDB: true})
+	assert.ErrorContains(t, err, "needs a postgres or sqlite database")
+}
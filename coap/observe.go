@@ -0,0 +1,560 @@
+package coap
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"servone/config"
+	"servone/kafka"
+	"servone/metrics"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/mux"
+)
+
+// 관찰 리소스 기본값
+const (
+	defaultObserveMaxAge        = 60 * time.Second
+	defaultObserveNotifyTimeout = 30 * time.Second
+	defaultMaxObserversPerPeer  = 16   // 원격 주소 하나가 모든 리소스에 걸쳐 등록할 수 있는 관찰자 수
+	maxResourcesPerEndpoint     = 1024 // {변수} 경로 엔드포인트 하나에 만들어지는 요청 경로별 리소스 수
+)
+
+// Observe 옵션 값은 24비트 (RFC 7641 4.4)
+const observeSequenceMask = 1<<24 - 1
+
+// ObserveHub는 관찰 가능한 엔드포인트(RFC 7641)의 마지막 값과 관찰자를 관리합니다.
+// 값은 엔드포인트로 들어온 요청, 매핑된 MQTT 토픽 메시지, 매핑된 Kafka 토픽 이벤트로 갱신되며
+// 갱신될 때마다 모든 관찰자에게 CON 알림을 보냅니다.
+// RST 를 받거나 알림 확인 시간이 지나거나 연결이 닫힌 관찰자는 제거됩니다.
+// 원격 주소별 관찰자 수는 maxPerPeer 로 제한되며, 초과한 등록 요청은 Observe 옵션 없는 응답을 받습니다.
+// {변수} 경로 엔드포인트는 요청 경로(/sensors/1, /sensors/2 ...)마다 값과 관찰자가 따로 관리됩니다.
+type ObserveHub struct {
+	mu         sync.RWMutex
+	endpoints  map[string]*observeEndpoint // 설정 경로별 관찰 가능한 엔드포인트
+	resources  map[string]*resource        // 요청 경로별 관찰 리소스
+	maxPerPeer int
+}
+
+// 관찰 가능한 엔드포인트
+type observeEndpoint struct {
+	path      string // 설정 경로 ({변수} 포함 가능)
+	cfg       *config.ObserveConfig
+	resources int // 이 엔드포인트에 속한 리소스 수
+}
+
+// 관찰 리소스
+type resource struct {
+	path     string // 요청 경로
+	endpoint string // 리소스가 속한 엔드포인트의 설정 경로 (메트릭 레이블)
+
+	mu            sync.Mutex
+	maxAge        time.Duration
+	notifyTimeout time.Duration
+	mqttTopic     string
+	kafkaTopic    string // SanitizeTopic 적용 후 토픽
+	value         []byte
+	format        message.MediaType
+	sequence      uint32
+	observers     map[string]*observer // 원격 주소와 토큰으로 구분
+}
+
+// 등록된 관찰자 (알림은 관찰자별 고루틴이 순서대로 전송)
+type observer struct {
+	key   string
+	conn  mux.Conn
+	token message.Token
+
+	// 보내지 못한 알림이 있음을 표시 (연속 갱신은 최신 값 한 번으로 합쳐짐)
+	wake chan struct{}
+	done chan struct{}
+	stop sync.Once
+
+	// 마지막으로 보낸 알림의 메시지 ID (RST 매칭용, 보낸 적 없으면 -1)
+	mid atomic.Int32
+}
+
+// NewObserveHub는 빈 관찰 허브를 생성합니다.
+func NewObserveHub() *ObserveHub {
+	return &ObserveHub{
+		endpoints:  make(map[string]*observeEndpoint),
+		resources:  make(map[string]*resource),
+		maxPerPeer: defaultMaxObserversPerPeer,
+	}
+}
+
+// configure는 설정의 observable 엔드포인트로 리소스 목록을 맞춥니다.
+// 고정 경로의 리소스는 바로 만들고 {변수} 경로의 리소스는 요청 경로가 처음 요청될 때 만듭니다.
+// 남아 있는 리소스의 값과 관찰자는 유지되고, 사라진 리소스의 관찰자에게는 4.04 알림을 보낸 뒤 제거합니다.
+func (h *ObserveHub) configure(endpoints []config.EndpointConfig) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	configured := make(map[string]*observeEndpoint)
+	for _, endpoint := range endpoints {
+		if !endpoint.Observable {
+			continue
+		}
+		ep, ok := configured[endpoint.Path]
+		if !ok {
+			if ep = h.endpoints[endpoint.Path]; ep == nil {
+				ep = &observeEndpoint{path: endpoint.Path}
+				log.Printf("CoAP resource %s is observable", endpoint.Path)
+			}
+			configured[endpoint.Path] = ep
+		}
+		ep.cfg = endpoint.Observe
+	}
+	for path := range h.endpoints {
+		if configured[path] == nil {
+			log.Printf("CoAP resource %s is no longer observable", path)
+		}
+	}
+
+	// 엔드포인트가 사라졌거나, 요청 경로가 이제 다른 고정 경로 엔드포인트로 가는 리소스는 제거
+	for path, res := range h.resources {
+		ep := configured[res.endpoint]
+		if ep != nil && (res.endpoint == path || configured[path] == nil) {
+			res.apply(ep.cfg)
+			continue
+		}
+		delete(h.resources, path)
+		if ep := h.endpoints[res.endpoint]; ep != nil {
+			ep.resources--
+		}
+		res.remove()
+	}
+	h.endpoints = configured
+
+	for path, ep := range configured {
+		if _, ok := h.resources[path]; !ok && !isPathTemplate(path) {
+			h.addLocked(ep, path)
+		}
+	}
+}
+
+// addLocked는 ep 에 속한 요청 경로 path 의 리소스를 만듭니다 (h.mu 를 잡은 상태에서 호출).
+func (h *ObserveHub) addLocked(ep *observeEndpoint, path string) *resource {
+	res := &resource{path: path, endpoint: ep.path, format: message.TextPlain, sequence: 1, observers: make(map[string]*observer)}
+	res.apply(ep.cfg)
+	h.resources[path] = res
+	ep.resources++
+	return res
+}
+
+// apply는 리소스 세부 설정을 반영합니다.
+func (res *resource) apply(cfg *config.ObserveConfig) {
+	res.mu.Lock()
+	defer res.mu.Unlock()
+
+	res.maxAge = defaultObserveMaxAge
+	res.notifyTimeout = defaultObserveNotifyTimeout
+	res.mqttTopic = ""
+	res.kafkaTopic = ""
+	if cfg == nil {
+		return
+	}
+	if cfg.MaxAge > 0 {
+		res.maxAge = time.Duration(cfg.MaxAge) * time.Second
+	}
+	if cfg.NotifyTimeoutSeconds > 0 {
+		res.notifyTimeout = time.Duration(cfg.NotifyTimeoutSeconds) * time.Second
+	}
+	res.mqttTopic = cfg.MQTTTopic
+	if cfg.KafkaTopic != "" {
+		res.kafkaTopic = kafka.SanitizeTopic(cfg.KafkaTopic)
+	}
+}
+
+// resource는 요청 경로의 관찰 리소스를 반환합니다 (관찰 불가 경로면 nil).
+func (h *ObserveHub) resource(path string) *resource {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.resources[path]
+}
+
+// resourceFor는 endpoint 로 들어온 요청 경로 path 의 관찰 리소스를 반환합니다.
+// {변수} 경로 엔드포인트의 리소스는 여기서 처음 만들어지며, 관찰 불가 엔드포인트거나
+// 엔드포인트의 리소스 수가 maxResourcesPerEndpoint 에 이르면 nil 을 반환합니다.
+func (h *ObserveHub) resourceFor(endpoint, path string) *resource {
+	if res := h.resource(path); res != nil && res.endpoint == endpoint {
+		return res
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ep := h.endpoints[endpoint]
+	if ep == nil {
+		return nil
+	}
+	if res, ok := h.resources[path]; ok {
+		return res
+	}
+	if ep.resources >= maxResourcesPerEndpoint {
+		log.Printf("CoAP resource %s not observable: %s already has %d resources", path, endpoint, ep.resources)
+		return nil
+	}
+	return h.addLocked(ep, path)
+}
+
+// Update는 경로의 값을 갱신하고 관찰자에게 알립니다. 관찰 가능한 경로가 아니면 false 를 반환합니다.
+func (h *ObserveHub) Update(path string, payload []byte, format message.MediaType) bool {
+	res := h.resource(path)
+	if res == nil {
+		return false
+	}
+	res.update(payload, format)
+	return true
+}
+
+// HandleMQTT는 MQTT 메시지를 mqtt_topic 이 일치하는 리소스에 반영합니다.
+func (h *ObserveHub) HandleMQTT(topic string, payload []byte) {
+	for _, res := range h.matching(func(res *resource) bool {
+		return res.mqttTopic != "" && mqttTopicMatches(res.mqttTopic, topic)
+	}) {
+		res.update(payload, message.TextPlain)
+	}
+}
+
+// handleKafka는 발행된 Kafka 이벤트를 kafka_topic 이 일치하는 리소스에 JSON 으로 반영합니다.
+func (h *ObserveHub) handleKafka(topic string, data map[string]interface{}) {
+	topic = kafka.SanitizeTopic(topic)
+	resources := h.matching(func(res *resource) bool { return res.kafkaTopic == topic })
+	if len(resources) == 0 {
+		return
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Cannot encode Kafka event for CoAP observers: %v", err)
+		return
+	}
+	for _, res := range resources {
+		res.update(payload, message.AppJSON)
+	}
+}
+
+// matching은 조건에 맞는 리소스 목록을 반환합니다.
+func (h *ObserveHub) matching(match func(*resource) bool) []*resource {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var matched []*resource
+	for _, res := range h.resources {
+		res.mu.Lock()
+		ok := match(res)
+		res.mu.Unlock()
+		if ok {
+			matched = append(matched, res)
+		}
+	}
+	return matched
+}
+
+// Publisher는 p 로 발행에 성공한 이벤트를 kafka_topic 이 일치하는 리소스에도 반영하는 퍼블리셔를 반환합니다.
+func (h *ObserveHub) Publisher(p kafka.KafkaPublisherInterface) kafka.KafkaPublisherInterface {
+	return &observedPublisher{next: p, hub: h}
+}
+
+// Kafka 발행을 가로채 관찰 리소스를 갱신하는 퍼블리셔
+type observedPublisher struct {
+	next kafka.KafkaPublisherInterface
+	hub  *ObserveHub
+}
+
+func (p *observedPublisher) Publish(topic string, data map[string]interface{}) error {
+	if err := p.next.Publish(topic, data); err != nil {
+		return err
+	}
+	p.hub.handleKafka(topic, data)
+	return nil
+}
+
+func (p *observedPublisher) Close() {
+	p.next.Close()
+}
+
+// Close는 모든 관찰자를 알림 없이 제거합니다.
+func (h *ObserveHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, res := range h.resources {
+		res.mu.Lock()
+		for key, o := range res.observers {
+			delete(res.observers, key)
+			o.close()
+			metrics.AddCoapObservers(res.endpoint, -1)
+		}
+		res.mu.Unlock()
+	}
+}
+
+// middleware는 알림에 대한 RST 를 받으면 해당 관찰자를 제거합니다.
+func (h *ObserveHub) middleware(next mux.Handler) mux.Handler {
+	return mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		if r.Type() == message.Reset {
+			h.reset(w.Conn(), r.MessageID())
+			return
+		}
+		next.ServeCOAP(w, r)
+	})
+}
+
+// reset은 conn 의 원격 주소에서 메시지 ID 가 mid 인 알림을 받은 관찰자를 제거합니다.
+func (h *ObserveHub) reset(conn mux.Conn, mid int32) {
+	addr := remoteAddr(conn)
+	for _, res := range h.matching(func(*resource) bool { return true }) {
+		res.mu.Lock()
+		for key, o := range res.observers {
+			if o.mid.Load() == mid && remoteAddr(o.conn) == addr {
+				res.evictLocked(key, o, "reset")
+				res.mu.Unlock()
+				return
+			}
+		}
+		res.mu.Unlock()
+	}
+}
+
+// serve는 관찰 가능한 경로의 GET 요청을 처리합니다.
+// Observe 0 은 관찰 등록, 1 은 등록 해제이며 응답 본문은 마지막 값입니다.
+func (h *ObserveHub) serve(w mux.ResponseWriter, r *mux.Message, res *resource) {
+	observe, err := r.Options().Observe()
+	registered := false
+	if err == nil {
+		key := observerKey(w.Conn(), r.Token())
+		switch observe {
+		case 0:
+			registered = h.register(res, key, w.Conn(), r.Token())
+		case 1:
+			res.deregister(key)
+		}
+	}
+
+	res.mu.Lock()
+	value, format, sequence, maxAge := res.value, res.format, res.sequence, res.maxAge
+	res.mu.Unlock()
+
+	w.SetResponse(codes.Content, format, bytes.NewReader(value))
+	if registered {
+		w.Message().SetObserve(sequence)
+	}
+	w.Message().SetOptionUint32(message.MaxAge, uint32(maxAge/time.Second))
+	log.Printf("CoAP GET %s - 2.05 | Observe %v, %d observer(s)", res.path, registered, res.observerCount())
+}
+
+// register는 원격 주소의 관찰자 수가 제한 안이면 res 에 관찰자를 등록합니다.
+// 같은 클라이언트와 토큰으로 다시 등록하면 기존 등록을 유지합니다.
+func (h *ObserveHub) register(res *resource, key string, conn mux.Conn, token message.Token) bool {
+	// 개수 확인과 등록 사이에 다른 등록이 끼어들지 않도록 허브 전체를 잠급니다
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	addr := remoteAddr(conn)
+	count := 0
+	for _, r := range h.resources {
+		r.mu.Lock()
+		if _, ok := r.observers[key]; ok && r == res { // 재등록
+			r.mu.Unlock()
+			return true
+		}
+		for _, o := range r.observers {
+			if remoteAddr(o.conn) == addr {
+				count++
+			}
+		}
+		r.mu.Unlock()
+	}
+	if count >= h.maxPerPeer {
+		log.Printf("CoAP observer %s of %s rejected: %d observer(s) already registered from %s", key, res.path, count, addr)
+		return false
+	}
+	res.register(key, conn, token)
+	return true
+}
+
+// register는 관찰자를 등록합니다.
+func (res *resource) register(key string, conn mux.Conn, token message.Token) {
+	res.mu.Lock()
+	defer res.mu.Unlock()
+	if _, ok := res.observers[key]; ok {
+		return
+	}
+
+	o := &observer{
+		key:   key,
+		conn:  conn,
+		token: append(message.Token(nil), token...), // 요청 메시지는 풀로 반환되므로 복사
+		wake:  make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	o.mid.Store(-1)
+	res.observers[key] = o
+	metrics.AddCoapObservers(res.endpoint, 1)
+	go o.run(res)
+}
+
+// deregister는 관찰 등록을 해제합니다.
+func (res *resource) deregister(key string) {
+	res.mu.Lock()
+	defer res.mu.Unlock()
+	if o, ok := res.observers[key]; ok {
+		res.evictLocked(key, o, "deregistered")
+	}
+}
+
+// evict는 관찰자가 아직 등록되어 있으면 제거합니다.
+func (res *resource) evict(o *observer, reason string) {
+	res.mu.Lock()
+	defer res.mu.Unlock()
+	if res.observers[o.key] == o {
+		res.evictLocked(o.key, o, reason)
+	}
+}
+
+func (res *resource) evictLocked(key string, o *observer, reason string) {
+	delete(res.observers, key)
+	o.close()
+	metrics.RecordCoapObserverEviction(res.endpoint, reason)
+	metrics.AddCoapObservers(res.endpoint, -1)
+	if reason != "deregistered" {
+		log.Printf("Removed CoAP observer %s of %s (%s)", key, res.path, reason)
+	}
+}
+
+// update는 값을 바꾸고 시퀀스 번호를 올린 뒤 모든 관찰자를 깨웁니다.
+func (res *resource) update(payload []byte, format message.MediaType) {
+	res.mu.Lock()
+	defer res.mu.Unlock()
+
+	res.value = append([]byte(nil), payload...)
+	res.format = format
+	res.sequence = (res.sequence + 1) & observeSequenceMask
+	for _, o := range res.observers {
+		select {
+		case o.wake <- struct{}{}:
+		default: // 이미 알림 대기 중이면 전송 시점의 최신 값이 나감
+		}
+	}
+}
+
+// remove는 리소스가 설정에서 빠졌을 때 관찰자에게 4.04 알림을 보내고 모두 제거합니다.
+func (res *resource) remove() {
+	res.mu.Lock()
+	defer res.mu.Unlock()
+	for key, o := range res.observers {
+		go o.notFound()
+		res.evictLocked(key, o, "removed")
+	}
+}
+
+func (res *resource) observerCount() int {
+	res.mu.Lock()
+	defer res.mu.Unlock()
+	return len(res.observers)
+}
+
+// run은 깨어날 때마다 최신 값을 알림으로 보냅니다.
+func (o *observer) run(res *resource) {
+	for {
+		select {
+		case <-o.done:
+			return
+		case <-o.conn.Done():
+			res.evict(o, "closed")
+			return
+		case <-o.wake:
+			res.mu.Lock()
+			value, format, sequence := res.value, res.format, res.sequence
+			maxAge, timeout := res.maxAge, res.notifyTimeout
+			res.mu.Unlock()
+
+			if err := o.notify(value, format, sequence, maxAge, timeout); err != nil {
+				metrics.RecordCoapNotification(res.endpoint, "failed")
+				log.Printf("CoAP notification of %s to %s failed: %v", res.path, o.key, err)
+				res.evict(o, "timeout")
+				return
+			}
+			metrics.RecordCoapNotification(res.endpoint, "sent")
+		}
+	}
+}
+
+// notify는 CON 알림을 보내고 ACK 또는 RST 를 기다립니다. timeout 안에 응답이 없으면 오류를 반환합니다.
+// RST 를 이 알림과 매칭할 수 있도록 메시지 ID 를 직접 부여합니다.
+func (o *observer) notify(value []byte, format message.MediaType, sequence uint32, maxAge, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(o.conn.Context(), timeout)
+	defer cancel()
+
+	m := o.conn.AcquireMessage(ctx)
+	defer o.conn.ReleaseMessage(m)
+	m.SetCode(codes.Content)
+	m.SetToken(o.token)
+	m.SetObserve(sequence)
+	m.SetContentFormat(format)
+	m.SetOptionUint32(message.MaxAge, uint32(maxAge/time.Second))
+	m.SetBody(bytes.NewReader(value))
+	m.SetType(message.Confirmable)
+	if c, ok := o.conn.(interface{ GetMessageID() int32 }); ok {
+		mid := c.GetMessageID()
+		m.SetMessageID(mid)
+		o.mid.Store(mid)
+	}
+	return o.conn.WriteMessage(m)
+}
+
+// notFound는 리소스가 사라졌음을 Observe 옵션 없는 4.04 로 알립니다 (RFC 7641 3.2).
+func (o *observer) notFound() {
+	ctx, cancel := context.WithTimeout(o.conn.Context(), defaultObserveNotifyTimeout)
+	defer cancel()
+
+	m := o.conn.AcquireMessage(ctx)
+	defer o.conn.ReleaseMessage(m)
+	m.SetCode(codes.NotFound)
+	m.SetToken(o.token)
+	m.SetType(message.NonConfirmable)
+	if err := o.conn.WriteMessage(m); err != nil {
+		log.Printf("Cannot notify CoAP observer %s of removal: %v", o.key, err)
+	}
+}
+
+func (o *observer) close() {
+	o.stop.Do(func() { close(o.done) })
+}
+
+// observerKey는 관찰자를 구분하는 키 (원격 주소와 토큰)
+func observerKey(conn mux.Conn, token message.Token) string {
+	return remoteAddr(conn) + "/" + hex.EncodeToString(token)
+}
+
+func remoteAddr(conn mux.Conn) string {
+	if addr := conn.RemoteAddr(); addr != nil {
+		return addr.String()
+	}
+	return ""
+}
+
+// mqttTopicMatches는 MQTT 토픽 필터(+ 는 한 단계, # 는 나머지 전체)가 토픽과 일치하는지 검사합니다.
+func mqttTopicMatches(filter, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		if level == "#" {
+			return true
+		}
+		if i >= len(topicLevels) {
+			return false
+		}
+		if level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
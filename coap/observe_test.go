@@ -0,0 +1,429 @@
+package coap
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"servone/config"
+	"testing"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/message/pool"
+	"github.com/plgd-dev/go-coap/v3/udp"
+	"github.com/plgd-dev/go-coap/v3/udp/coder"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 관찰 가능한 /obs 엔드포인트를 가진 테스트 서버
+func newObserveServer(t *testing.T, port string, observe *config.ObserveConfig) (*CoapServer, *config.Config) {
+	cfg := &config.Config{
+		Coap: config.CoapConfig{
+			Host: "localhost",
+			Port: port,
+			Endpoints: []config.EndpointConfig{
+				{
+					Path:       "/obs",
+					Method:     "PUT",
+					Observable: true,
+					Observe:    observe,
+					Response:   config.ResponseConfig{Status: int(codes.Changed), Body: "OK"},
+				},
+			},
+		},
+	}
+	cs := NewCoapServer(cfg, NewMockKafkaPublisher())
+	t.Cleanup(func() { cs.Shutdown(context.Background()) })
+	time.Sleep(200 * time.Millisecond)
+	return cs, cfg
+}
+
+func TestMQTTTopicMatches(t *testing.T) {
+	tests := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"sensors/temp", "sensors/temp", true},
+		{"sensors/temp", "sensors/humidity", false},
+		{"sensors/+/temp", "sensors/a/temp", true},
+		{"sensors/+/temp", "sensors/a/b/temp", false},
+		{"sensors/#", "sensors/a/b", true},
+		{"sensors/#", "sensors", true},
+		{"#", "anything/at/all", true},
+		{"sensors/temp", "sensors/temp/extra", false},
+		{"sensors/temp/extra", "sensors/temp", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter+" "+tt.topic, func(t *testing.T) {
+			assert.Equal(t, tt.want, mqttTopicMatches(tt.filter, tt.topic))
+		})
+	}
+}
+
+func TestObserveHubConfigure(t *testing.T) {
+	hub := NewObserveHub()
+	hub.configure([]config.EndpointConfig{
+		{Path: "/a", Observable: true, Observe: &config.ObserveConfig{MaxAge: 5, MQTTTopic: "s/+", KafkaTopic: "/events/a"}},
+		{Path: "/b"},
+	})
+
+	require.NotNil(t, hub.resource("/a"))
+	assert.Nil(t, hub.resource("/b"))
+	assert.False(t, hub.Update("/b", []byte("x"), message.TextPlain))
+
+	t.Run("MQTT and Kafka topics update the value", func(t *testing.T) {
+		hub.HandleMQTT("s/1", []byte("21.5"))
+		res := hub.resource("/a")
+		assert.Equal(t, "21.5", string(res.value))
+		assert.Equal(t, uint32(2), res.sequence)
+
+		hub.HandleMQTT("other/1", []byte("x"))
+		assert.Equal(t, "21.5", string(res.value))
+
+		pub := NewMockKafkaPublisher()
+		require.NoError(t, hub.Publisher(pub).Publish("/events/a", map[string]interface{}{"v": 1}))
+		assert.JSONEq(t, `{"v":1}`, string(res.value))
+		assert.Equal(t, message.AppJSON, res.format)
+		assert.NotNil(t, pub.GetPublished("/events/a"))
+	})
+
+	t.Run("reconfigure keeps the value", func(t *testing.T) {
+		hub.configure([]config.EndpointConfig{{Path: "/a", Observable: true}})
+		res := hub.resource("/a")
+		require.NotNil(t, res)
+		assert.JSONEq(t, `{"v":1}`, string(res.value))
+		assert.Equal(t, defaultObserveMaxAge, res.maxAge)
+		assert.Empty(t, res.mqttTopic)
+	})
+
+	t.Run("reconfigure removes resources", func(t *testing.T) {
+		hub.configure(nil)
+		assert.Nil(t, hub.resource("/a"))
+	})
+
+	t.Run("templated endpoints get a resource per request path", func(t *testing.T) {
+		hub.configure([]config.EndpointConfig{
+			{Path: "/s/{id}", Observable: true, Observe: &config.ObserveConfig{MaxAge: 5}},
+			{Path: "/s/all"},
+		})
+		assert.Nil(t, hub.resource("/s/{id}"), "created on first request")
+
+		one, two := hub.resourceFor("/s/{id}", "/s/1"), hub.resourceFor("/s/{id}", "/s/2")
+		require.NotNil(t, one)
+		require.NotNil(t, two)
+		assert.NotSame(t, one, two)
+		assert.Same(t, one, hub.resourceFor("/s/{id}", "/s/1"))
+		assert.Equal(t, 5*time.Second, one.maxAge)
+		assert.Nil(t, hub.resourceFor("/s/all", "/s/all"))
+
+		one.update([]byte("1"), message.TextPlain)
+		assert.Empty(t, two.value)
+
+		hub.configure([]config.EndpointConfig{{Path: "/s/{id}", Observable: true}, {Path: "/s/1", Observable: true}})
+		assert.NotSame(t, one, hub.resource("/s/1"), "the literal endpoint takes the path over")
+		assert.Same(t, two, hub.resource("/s/2"))
+		assert.Equal(t, 1, hub.endpoints["/s/{id}"].resources)
+
+		hub.configure(nil)
+		assert.Nil(t, hub.resource("/s/2"))
+	})
+}
+
+func TestObserve(t *testing.T) {
+	cs, cfg := newObserveServer(t, "5693", &config.ObserveConfig{MaxAge: 30})
+
+	co, err := udp.Dial(cfg.Coap.Host + ":" + cfg.Coap.Port)
+	require.NoError(t, err)
+	defer co.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	notifications := make(chan string, 10)
+	sequences := make(chan uint32, 10)
+	obs, err := co.Observe(ctx, "/obs", func(n *pool.Message) {
+		body, _ := n.ReadBody()
+		seq, _ := n.Observe()
+		notifications <- string(body)
+		sequences <- seq
+	})
+	require.NoError(t, err)
+
+	// 등록 응답 (값이 없으면 빈 본문)
+	assert.Equal(t, "", <-notifications)
+	first := <-sequences
+	res := cs.observe.resource("/obs")
+	require.Eventually(t, func() bool { return res.observerCount() == 1 }, time.Second, 10*time.Millisecond)
+
+	t.Run("PUT notifies observers", func(t *testing.T) {
+		resp, err := co.Put(ctx, "/obs", message.TextPlain, bytes.NewReader([]byte("42")))
+		require.NoError(t, err)
+		assert.Equal(t, codes.Changed, resp.Code())
+
+		select {
+		case v := <-notifications:
+			assert.Equal(t, "42", v)
+			assert.Greater(t, <-sequences, first)
+		case <-time.After(2 * time.Second):
+			t.Fatal("no notification received")
+		}
+	})
+
+	t.Run("hub updates notify observers", func(t *testing.T) {
+		cs.observe.HandleMQTT("unmapped/topic", []byte("ignored"))
+		require.True(t, cs.observe.Update("/obs", []byte("43"), message.TextPlain))
+		select {
+		case v := <-notifications:
+			assert.Equal(t, "43", v)
+		case <-time.After(2 * time.Second):
+			t.Fatal("no notification received")
+		}
+	})
+
+	t.Run("GET returns the latest value", func(t *testing.T) {
+		resp, err := co.Get(ctx, "/obs")
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body())
+		require.NoError(t, err)
+		assert.Equal(t, "43", string(body))
+		maxAge, err := resp.Options().GetUint32(message.MaxAge)
+		require.NoError(t, err)
+		assert.Equal(t, uint32(30), maxAge)
+	})
+
+	t.Run("cancel deregisters", func(t *testing.T) {
+		require.NoError(t, obs.Cancel(ctx))
+		assert.Eventually(t, func() bool { return res.observerCount() == 0 }, time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestObserveTemplatedPath(t *testing.T) {
+	cfg := &config.Config{
+		Coap: config.CoapConfig{
+			Host: "localhost",
+			Port: "5711",
+			Endpoints: []config.EndpointConfig{
+				{Path: "/sensors/{id}", Method: "PUT", Observable: true, Response: config.ResponseConfig{Status: int(codes.Changed)}},
+			},
+		},
+	}
+	cs := NewCoapServer(cfg, NewMockKafkaPublisher())
+	t.Cleanup(func() { cs.Shutdown(context.Background()) })
+	time.Sleep(200 * time.Millisecond)
+
+	co, err := udp.Dial(cfg.Coap.Host + ":" + cfg.Coap.Port)
+	require.NoError(t, err)
+	defer co.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	notifications := make(chan string, 10)
+	obs, err := co.Observe(ctx, "/sensors/1", func(n *pool.Message) {
+		body, _ := n.ReadBody()
+		notifications <- string(body)
+	})
+	require.NoError(t, err)
+	defer obs.Cancel(ctx)
+	assert.Equal(t, "", <-notifications)
+
+	put := func(path, value string) {
+		resp, err := co.Put(ctx, path, message.TextPlain, bytes.NewReader([]byte(value)))
+		require.NoError(t, err)
+		require.Equal(t, codes.Changed, resp.Code())
+	}
+	put("/sensors/2", "20")
+	put("/sensors/1", "10")
+
+	select {
+	case v := <-notifications:
+		assert.Equal(t, "10", v, "updates of /sensors/2 do not reach observers of /sensors/1")
+	case <-time.After(2 * time.Second):
+		t.Fatal("no notification received")
+	}
+
+	resp, err := co.Get(ctx, "/sensors/2")
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body())
+	require.NoError(t, err)
+	assert.Equal(t, "20", string(body))
+
+	assert.Equal(t, 1, cs.observe.resource("/sensors/1").observerCount())
+	assert.Equal(t, 0, cs.observe.resource("/sensors/2").observerCount())
+	assert.Nil(t, cs.observe.resource("/sensors/{id}"))
+}
+
+// rawClient는 ACK/RST 를 직접 제어하기 위한 UDP 클라이언트입니다.
+type rawClient struct {
+	t    *testing.T
+	conn net.Conn
+	mid  int32
+}
+
+func dialRaw(t *testing.T, port string) *rawClient {
+	conn, err := net.Dial("udp", "localhost:"+port)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return &rawClient{t: t, conn: conn, mid: 100}
+}
+
+func (c *rawClient) send(setup func(m *pool.Message)) {
+	m := pool.NewMessage(context.Background())
+	setup(m)
+	data, err := m.MarshalWithEncoder(coder.DefaultCoder)
+	require.NoError(c.t, err)
+	_, err = c.conn.Write(data)
+	require.NoError(c.t, err)
+}
+
+func (c *rawClient) receive() *pool.Message {
+	buf := make([]byte, 1500)
+	require.NoError(c.t, c.conn.SetReadDeadline(time.Now().Add(3*time.Second)))
+	n, err := c.conn.Read(buf)
+	require.NoError(c.t, err)
+	m := pool.NewMessage(context.Background())
+	_, err = m.UnmarshalWithDecoder(coder.DefaultCoder, buf[:n])
+	require.NoError(c.t, err)
+	return m
+}
+
+// register는 Observe 0 으로 GET 을 보내고 등록 응답을 받습니다.
+func (c *rawClient) register(token string) {
+	resp := c.observe(token)
+	_, err := resp.Observe()
+	require.NoError(c.t, err)
+}
+
+// observe는 Observe 0 으로 GET 을 보내고 응답을 반환합니다.
+func (c *rawClient) observe(token string) *pool.Message {
+	c.mid++
+	c.send(func(m *pool.Message) {
+		m.SetCode(codes.GET)
+		m.SetType(message.Confirmable)
+		m.SetMessageID(c.mid)
+		m.SetToken(message.Token(token))
+		require.NoError(c.t, m.SetPath("/obs"))
+		m.SetObserve(0)
+	})
+	resp := c.receive()
+	require.Equal(c.t, codes.Content, resp.Code())
+	return resp
+}
+
+// reply는 알림에 ACK 또는 RST 로 응답합니다.
+func (c *rawClient) reply(n *pool.Message, typ message.Type) {
+	c.send(func(m *pool.Message) {
+		m.SetCode(codes.Empty)
+		m.SetType(typ)
+		m.SetMessageID(n.MessageID())
+	})
+}
+
+func TestObserverEviction(t *testing.T) {
+	cs, cfg := newObserveServer(t, "5694", &config.ObserveConfig{NotifyTimeoutSeconds: 1})
+	res := cs.observe.resource("/obs")
+
+	t.Run("RST removes the observer", func(t *testing.T) {
+		c := dialRaw(t, cfg.Coap.Port)
+		c.register("rst")
+		require.Equal(t, 1, res.observerCount())
+
+		cs.observe.Update("/obs", []byte("1"), message.TextPlain)
+		n := c.receive()
+		assert.Equal(t, message.Confirmable, n.Type())
+		assert.Equal(t, "1", string(mustBody(t, n)))
+
+		c.reply(n, message.Reset)
+		assert.Eventually(t, func() bool { return res.observerCount() == 0 }, 2*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("RST removes only the observer of that notification", func(t *testing.T) {
+		c := dialRaw(t, cfg.Coap.Port)
+		c.register("first")
+		c.register("second")
+		require.Equal(t, 2, res.observerCount())
+
+		cs.observe.Update("/obs", []byte("1"), message.TextPlain)
+		notifications := map[string]*pool.Message{}
+		for range 2 {
+			n := c.receive()
+			notifications[string(n.Token())] = n
+		}
+		require.Len(t, notifications, 2)
+		require.NotEqual(t, notifications["first"].MessageID(), notifications["second"].MessageID())
+
+		c.reply(notifications["second"], message.Acknowledgement)
+		c.reply(notifications["first"], message.Reset)
+		require.Eventually(t, func() bool { return res.observerCount() == 1 }, 2*time.Second, 10*time.Millisecond)
+
+		cs.observe.Update("/obs", []byte("2"), message.TextPlain)
+		n := c.receive()
+		assert.Equal(t, message.Token("second"), n.Token())
+		c.reply(n, message.Reset)
+		assert.Eventually(t, func() bool { return res.observerCount() == 0 }, 2*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("observers per client are limited", func(t *testing.T) {
+		setMaxPerPeer := func(n int) {
+			cs.observe.mu.Lock()
+			defer cs.observe.mu.Unlock()
+			cs.observe.maxPerPeer = n
+		}
+		setMaxPerPeer(2)
+		defer setMaxPerPeer(defaultMaxObserversPerPeer)
+
+		c := dialRaw(t, cfg.Coap.Port)
+		c.register("one")
+		c.register("two")
+		c.register("one") // 재등록은 제한에 걸리지 않음
+
+		resp := c.observe("three")
+		_, err := resp.Observe()
+		assert.Error(t, err, "rejected registrations are answered without Observe")
+		assert.Equal(t, 2, res.observerCount())
+
+		other := dialRaw(t, cfg.Coap.Port)
+		other.register("three")
+		assert.Equal(t, 3, res.observerCount())
+
+		res.mu.Lock()
+		for key, o := range res.observers {
+			res.evictLocked(key, o, "deregistered")
+		}
+		res.mu.Unlock()
+	})
+
+	t.Run("unacknowledged notification removes the observer", func(t *testing.T) {
+		c := dialRaw(t, cfg.Coap.Port)
+		c.register("timeout")
+		require.Equal(t, 1, res.observerCount())
+
+		cs.observe.Update("/obs", []byte("2"), message.TextPlain)
+		c.receive() // ACK 하지 않음
+		assert.Eventually(t, func() bool { return res.observerCount() == 0 }, 5*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("removed resource sends 4.04", func(t *testing.T) {
+		c := dialRaw(t, cfg.Coap.Port)
+		c.register("removed")
+
+		newCfg := *cfg
+		newCfg.Coap.Endpoints = nil
+		cs.observe.configure(newCfg.Coap.Endpoints)
+
+		n := c.receive()
+		assert.Equal(t, codes.NotFound, n.Code())
+		assert.Equal(t, message.Token("removed"), n.Token())
+		_, err := n.Observe()
+		assert.Error(t, err)
+	})
+}
+
+func mustBody(t *testing.T, m *pool.Message) []byte {
+	body, err := m.ReadBody()
+	require.NoError(t, err)
+	return body
+}
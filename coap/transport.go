@@ -0,0 +1,208 @@
+package coap
+
+import (
+	"io"
+	"log"
+	"time"
+
+	"servone/certs"
+	"servone/config"
+	"servone/metrics"
+
+	"github.com/plgd-dev/go-coap/v3/mux"
+	coapnet "github.com/plgd-dev/go-coap/v3/net"
+	"github.com/plgd-dev/go-coap/v3/net/blockwise"
+	"github.com/plgd-dev/go-coap/v3/options"
+	"github.com/plgd-dev/go-coap/v3/tcp"
+	tcpclient "github.com/plgd-dev/go-coap/v3/tcp/client"
+	"github.com/plgd-dev/go-coap/v3/udp"
+)
+
+// 전송 계층 이름 (메트릭 레이블)
+const (
+	transportUDP  = "udp"
+	transportDTLS = "dtls"
+	transportTCP  = "tcp"
+	transportTLS  = "tls"
+)
+
+// transportListener는 실행 중인 리스너(coap, coaps, coap+tcp, coaps+tcp)입니다.
+type transportListener struct {
+	addr       string      // 바인딩한 주소
+	settings   interface{} // 바인딩 당시의 설정 (바뀌면 다시 바인딩)
+	stopServer func()
+	done       chan struct{}
+}
+
+// stop은 리스너를 닫고 Serve 가 끝날 때까지 기다립니다 (포트 해제).
+func (l *transportListener) stop() {
+	l.stopServer()
+	<-l.done
+}
+
+// serveListener는 serve 를 고루틴에서 실행하고 리스너를 등록합니다. cs.mu 를 잡은 상태에서 호출합니다.
+// Serve 가 리스너를 등록하기 전에 중지되면 서버의 Stop 이 소켓을 닫지 못하므로 끝날 때 직접 닫습니다.
+func (cs *CoapServer) serveListener(name, addr string, settings interface{}, socket io.Closer, stop func(), serve func() error) {
+	l := &transportListener{addr: addr, settings: settings, stopServer: stop, done: make(chan struct{})}
+	if cs.listeners == nil {
+		cs.listeners = make(map[string]*transportListener)
+	}
+	cs.listeners[name] = l
+	log.Printf("Starting CoAP %s server on %s", name, addr)
+
+	go func() {
+		defer close(l.done)
+		defer socket.Close()
+		if err := serve(); err != nil {
+			log.Printf("CoAP %s server error: %v", name, err)
+		}
+		log.Printf("CoAP %s server stopped", name)
+	}()
+}
+
+// listenerSpec은 설정에서 얻은 리스너 하나의 바인딩 조건입니다.
+type listenerSpec struct {
+	name     string
+	enabled  bool
+	addr     string
+	settings interface{} // 바뀌면 다시 바인딩해야 하는 설정 (nil 이면 주소만 비교)
+	start    func()
+	refresh  func() // 다시 바인딩하지 않을 때 키/인증서를 다시 읽음 (nil 이면 없음)
+}
+
+func (cs *CoapServer) listenerSpecs() []listenerSpec {
+	c := cs.config.Coap
+	return []listenerSpec{
+		{name: transportUDP, enabled: true, addr: c.Host + ":" + c.Port, start: cs.startUDP},
+		{name: transportDTLS, enabled: c.DTLS.Enabled(), addr: c.Host + ":" + c.DTLS.Port, settings: c.DTLS, start: cs.startDTLS, refresh: cs.refreshDTLS},
+		{name: transportTCP, enabled: c.TCP.Enabled(), addr: c.Host + ":" + c.TCP.Port, start: cs.startTCP},
+		{name: transportTLS, enabled: c.TLS.Enabled(), addr: c.Host + ":" + c.TLS.Port, start: cs.startTLS, refresh: cs.refreshTLS},
+	}
+}
+
+// syncListeners는 현재 설정에 맞게 리스너를 시작하거나 중지합니다. cs.mu 를 잡은 상태에서 호출합니다.
+// 주소(DTLS 는 dtls 설정 포함)가 그대로인 리스너는 다시 바인딩하지 않고 키와 인증서만 다시 읽습니다.
+func (cs *CoapServer) syncListeners() {
+	for _, spec := range cs.listenerSpecs() {
+		l, running := cs.listeners[spec.name]
+		if running && spec.enabled && l.addr == spec.addr && l.settings == spec.settings {
+			if spec.refresh != nil {
+				spec.refresh()
+			}
+			continue
+		}
+		if running {
+			l.stop()
+			delete(cs.listeners, spec.name)
+		}
+		spec.start()
+	}
+}
+
+// stopListeners는 모든 리스너를 중지합니다.
+func (cs *CoapServer) stopListeners() {
+	for name, l := range cs.listeners {
+		l.stop()
+		delete(cs.listeners, name)
+	}
+}
+
+// transportHandler는 전송 계층별 요청 수를 기록한 뒤 현재 라우터로 넘기는 핸들러를 반환합니다.
+// 모든 전송 계층이 같은 라우터(엔드포인트)를 공유하며, 리로드 시 라우터만 바뀝니다.
+func (cs *CoapServer) transportHandler(transport string) mux.Handler {
+	return mux.HandlerFunc(func(w mux.ResponseWriter, r *mux.Message) {
+		metrics.RecordCoapTransportRequest(transport)
+		cs.router.Load().ServeCOAP(w, r)
+	})
+}
+
+// startUDP는 평문 CoAP(UDP) 리스너를 시작합니다.
+func (cs *CoapServer) startUDP() {
+	addr := cs.config.Coap.Host + ":" + cs.config.Coap.Port
+	l, err := coapnet.NewListenUDP("udp", addr)
+	if err != nil {
+		log.Printf("CoAP server error: %v", err)
+		return
+	}
+
+	server := udp.NewServer(options.WithMux(cs.transportHandler(transportUDP)), withoutBlockwise())
+	cs.serveListener(transportUDP, addr, nil, l, server.Stop, func() error { return server.Serve(l) })
+}
+
+// withoutBlockwise는 UDP/DTLS 서버의 block-wise 전송을 끕니다.
+// block-wise 계층은 보내는 메시지마다 메시지 ID 를 새로 부여하므로, 켜 두면 관찰 알림에 대한 RST 를 알림과 매칭할 수 없습니다.
+func withoutBlockwise() options.BlockwiseOpt {
+	return options.WithBlockwise(false, blockwise.SZX1024, time.Minute)
+}
+
+// countConnections는 TCP/TLS 연결 수를 메트릭에 반영합니다.
+func countConnections(transport string) options.OnNewConnOpt[func(*tcpclient.Conn)] {
+	return options.WithOnNewConn(func(cc *tcpclient.Conn) {
+		metrics.AddCoapTransportConnections(transport, 1)
+		cc.AddOnClose(func() {
+			metrics.AddCoapTransportConnections(transport, -1)
+		})
+	})
+}
+
+// startTCP는 설정된 경우 CoAP over TCP(RFC 8323) 리스너를 시작합니다.
+func (cs *CoapServer) startTCP() {
+	cfg := cs.config.Coap.TCP
+	if !cfg.Enabled() {
+		return
+	}
+
+	addr := cs.config.Coap.Host + ":" + cfg.Port
+	l, err := coapnet.NewTCPListener("tcp", addr)
+	if err != nil {
+		log.Printf("CoAP TCP listener error: %v", err)
+		return
+	}
+
+	server := tcp.NewServer(options.WithMux(cs.transportHandler(transportTCP)), countConnections(transportTCP))
+	cs.serveListener(transportTCP, addr, nil, l, server.Stop, func() error { return server.Serve(l) })
+}
+
+// startTLS는 설정된 경우 CoAP over TLS(RFC 8323, ALPN "coap") 리스너를 시작합니다.
+// 핸드셰이크마다 인증서 저장소의 최신 인증서를 사용하므로 리로드 시 다시 바인딩할 필요가 없습니다.
+func (cs *CoapServer) startTLS() {
+	cfg := cs.config.Coap.TLS
+	if !cfg.Enabled() {
+		return
+	}
+	if err := cs.loadTLSCerts(cfg); err != nil {
+		log.Printf("CoAP TLS listener disabled: %v", err)
+		return
+	}
+
+	addr := cs.config.Coap.Host + ":" + cfg.Port
+	l, err := coapnet.NewTLSListener("tcp", addr, cs.tlsCerts.ServerConfigFor("coap"))
+	if err != nil {
+		log.Printf("CoAP TLS listener error: %v", err)
+		return
+	}
+
+	server := tcp.NewServer(options.WithMux(cs.transportHandler(transportTLS)), countConnections(transportTLS))
+	cs.serveListener(transportTLS, addr, nil, l, server.Stop, func() error { return server.Serve(l) })
+}
+
+// refreshTLS는 실행 중인 TLS 리스너의 인증서를 다시 읽습니다.
+func (cs *CoapServer) refreshTLS() {
+	if err := cs.loadTLSCerts(cs.config.Coap.TLS); err != nil {
+		log.Printf("Failed to reload CoAP TLS certificate: %v", err)
+	}
+}
+
+// loadTLSCerts는 인증서를 (다시) 읽습니다. 다시 읽지 못하면 이전 인증서를 계속 사용합니다.
+func (cs *CoapServer) loadTLSCerts(cfg config.CoapTLSConfig) error {
+	if cs.tlsCerts == nil {
+		store, err := certs.NewStore(cfg.TLS())
+		if err != nil {
+			return err
+		}
+		cs.tlsCerts = store
+	} else if err := cs.tlsCerts.Update(cfg.TLS()); err != nil {
+		log.Printf("Failed to reload CoAP TLS certificate, keeping previous certificate: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package coap
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"path/filepath"
+	"servone/config"
+	"servone/internal/testcert"
+	"servone/metrics"
+	"testing"
+	"time"
+
+	"github.com/plgd-dev/go-coap/v3/message"
+	"github.com/plgd-dev/go-coap/v3/message/codes"
+	"github.com/plgd-dev/go-coap/v3/options"
+	"github.com/plgd-dev/go-coap/v3/tcp"
+	"github.com/plgd-dev/go-coap/v3/udp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 여러 전송 계층을 켠 테스트 서버 설정 (/secure 엔드포인트 공유)
+func transportTestConfig(port string, tcpCfg config.CoapTCPConfig, tlsCfg config.CoapTLSConfig) *config.Config {
+	cfg := dtlsTestConfig(port, "", config.DTLSConfig{})
+	cfg.Coap.TCP = tcpCfg
+	cfg.Coap.TLS = tlsCfg
+	return cfg
+}
+
+// postTCP는 CoAP over TCP(tlsCfg 지정 시 TLS) 로 /secure 에 POST 합니다.
+func postTCP(t *testing.T, port string, tlsCfg *tls.Config) error {
+	var opts []tcp.Option
+	if tlsCfg != nil {
+		opts = append(opts, options.WithTLS(tlsCfg))
+	}
+	co, err := tcp.Dial("localhost:"+port, opts...)
+	if err != nil {
+		return err
+	}
+	defer co.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	resp, err := co.Post(ctx, "/secure", message.AppJSON, bytes.NewReader([]byte(`{"v":1}`)))
+	if err != nil {
+		return err
+	}
+	assert.Equal(t, codes.Created, resp.Code())
+	return nil
+}
+
+func transportRequests(transport string) float64 {
+	return testutil.ToFloat64(metrics.CoapTransportRequestsTotal.WithLabelValues(transport))
+}
+
+func TestCoapOverTCP(t *testing.T) {
+	cfg := transportTestConfig("5700", config.CoapTCPConfig{Port: "5701"}, config.CoapTLSConfig{})
+	cs := NewCoapServer(cfg, NewMockKafkaPublisher())
+	t.Cleanup(func() { cs.Shutdown(context.Background()) })
+	time.Sleep(200 * time.Millisecond)
+
+	t.Run("TCP shares the endpoints", func(t *testing.T) {
+		before := transportRequests(transportTCP)
+		require.NoError(t, postTCP(t, "5701", nil))
+		assert.Equal(t, before+1, transportRequests(transportTCP))
+		assert.Eventually(t, func() bool {
+			return testutil.ToFloat64(metrics.CoapTransportConnections.WithLabelValues(transportTCP)) == 0
+		}, 2*time.Second, 20*time.Millisecond, "closed connections are no longer counted")
+	})
+
+	t.Run("UDP keeps working and is metered separately", func(t *testing.T) {
+		before := transportRequests(transportUDP)
+		co, err := udp.Dial("localhost:5700")
+		require.NoError(t, err)
+		defer co.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		resp, err := co.Post(ctx, "/secure", message.AppJSON, bytes.NewReader([]byte(`{"v":1}`)))
+		require.NoError(t, err)
+		assert.Equal(t, codes.Created, resp.Code())
+		assert.Equal(t, before+1, transportRequests(transportUDP))
+	})
+
+	t.Run("stop releases the port", func(t *testing.T) {
+		cs.Stop()
+		assert.Error(t, postTCP(t, "5701", nil))
+	})
+}
+
+func TestCoapOverTLS(t *testing.T) {
+	stored := useFileStore(t)
+	dir := t.TempDir()
+
+	ca, caKey, _ := testcert.Issue(t, "servone-test-ca", nil, nil)
+	caFile := filepath.Join(dir, "ca.pem")
+	testcert.Write(t, caFile, ca)
+	serverCert, serverKey, _ := testcert.Issue(t, "localhost", ca, caKey)
+	certFile, keyFile := testcert.WriteKeyPair(t, dir, "server", serverCert, serverKey)
+	_, _, clientCert := testcert.Issue(t, "gateway-7", ca, caKey)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	cfg := transportTestConfig("5702", config.CoapTCPConfig{}, config.CoapTLSConfig{
+		Port: "5703", CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile,
+	})
+	cs := NewCoapServer(cfg, NewMockKafkaPublisher())
+	t.Cleanup(func() { cs.Shutdown(context.Background()) })
+
+	t.Run("client certificate CN is the device ID", func(t *testing.T) {
+		before := transportRequests(transportTLS)
+		require.NoError(t, postTCP(t, "5703", &tls.Config{
+			Certificates: []tls.Certificate{clientCert},
+			RootCAs:      roots,
+			ServerName:   "localhost",
+			NextProtos:   []string{"coap"},
+		}))
+		assert.Equal(t, before+1, transportRequests(transportTLS))
+		assert.Eventually(t, func() bool {
+			devices := storedDevices(t, stored)
+			return len(devices) == 1 && devices[0] == "gateway-7"
+		}, 2*time.Second, 20*time.Millisecond)
+	})
+
+	t.Run("client certificate is required", func(t *testing.T) {
+		assert.Error(t, postTCP(t, "5703", &tls.Config{RootCAs: roots, ServerName: "localhost"}))
+	})
+}
+
+func TestTransportsDisabled(t *testing.T) {
+	cs := &CoapServer{config: transportTestConfig("0", config.CoapTCPConfig{}, config.CoapTLSConfig{Port: "5704"})}
+	cs.startTCP()
+	cs.startTLS()
+	assert.Empty(t, cs.listeners, "TCP needs a port and TLS needs a certificate")
+}
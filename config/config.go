@@ -10,13 +10,52 @@ import (
 
 // 전체 설정을 담는 최상위 구조체
 type Config struct {
-	Database DatabaseConfig `yaml:"database"` // 데이터베이스 관련 설정
-	Rest     RestConfig     `yaml:"rest"`     // REST 서버 관련 설정
-	Coap     CoapConfig     `yaml:"coap"`
-	Kafka    KafkaConfig    `yaml:"kafka"`
-	MQTT     MQTTConfig     `yaml:"mqtt"`
-	SNMP     SNMPConfig     `yaml:"snmp"`
-	SNMPTrap SNMPTrapConfig `yaml:"snmptrap"`
+	Database  DatabaseConfig  `yaml:"database"` // 데이터베이스 관련 설정
+	Rest      RestConfig      `yaml:"rest"`     // REST 서버 관련 설정
+	Coap      CoapConfig      `yaml:"coap"`
+	Kafka     KafkaConfig     `yaml:"kafka"`
+	MQTT      MQTTConfig      `yaml:"mqtt"`
+	SNMP      SNMPConfig      `yaml:"snmp"`
+	SNMPTrap  SNMPTrapConfig  `yaml:"snmptrap"`
+	Ingest    IngestConfig    `yaml:"ingest"`    // DB 저장/Kafka 발행 작업자 풀 설정
+	Spool     SpoolConfig     `yaml:"spool"`     // DB/Kafka 장애 시 메시지를 보관하는 로컬 스풀 설정
+	Outbox    OutboxConfig    `yaml:"outbox"`    // DB outbox 테이블의 Kafka 이벤트 발행 설정
+	Retention RetentionConfig `yaml:"retention"` // 수집 테이블 파티션 생성/보관 기간 설정
+}
+
+// 수집 파이프라인 설정 구조체 (DB 저장/Kafka 발행 작업자 풀, 변경 시 재시작 필요)
+type IngestConfig struct {
+	Workers   int    `yaml:"workers"`    // 작업자 수 (기본 8)
+	QueueSize int    `yaml:"queue_size"` // 대기열 크기 (기본 1000)
+	Overflow  string `yaml:"overflow"`   // 대기열이 가득 찼을 때: block(기본), reject(503), drop_oldest
+}
+
+// 로컬 스풀 설정 구조체 (dir 미지정 시 비활성, 변경 시 재시작 필요)
+// 싱크(PostgreSQL, Kafka) 쓰기가 실패하면 메시지를 세그먼트 파일에 기록했다가 복구 후 순서대로 재전송한다
+type SpoolConfig struct {
+	Dir             string `yaml:"dir"`               // 세그먼트 파일 저장 디렉터리
+	SegmentBytes    int64  `yaml:"segment_bytes"`     // 세그먼트 파일 최대 크기 (기본 16MiB)
+	RetryIntervalMs int    `yaml:"retry_interval_ms"` // 재전송 시도 간격 (기본 5000ms)
+}
+
+// outbox 릴레이 설정 구조체 (변경 시 재시작 필요)
+// 수집한 메시지의 Kafka 이벤트는 데이터 행과 같은 트랜잭션으로 outbox 테이블에 기록되고,
+// 릴레이가 이를 Kafka로 발행한 뒤 발행 완료로 표시한다 (최소 한 번 전달)
+type OutboxConfig struct {
+	PollIntervalMs int `yaml:"poll_interval_ms"` // 미발행 이벤트 조회 간격 (기본 1000ms)
+	BatchSize      int `yaml:"batch_size"`       // 한 번에 가져오는 최대 이벤트 수 (기본 100)
+	MinBackoffMs   int `yaml:"min_backoff_ms"`   // 발행 실패 후 첫 재시도 대기 시간 (기본 1000ms, 실패할 때마다 2배)
+	MaxBackoffMs   int `yaml:"max_backoff_ms"`   // 재시도 대기 시간 상한 (기본 60000ms)
+	RetentionHours int `yaml:"retention_hours"`  // 발행 완료 이벤트 보관 시간 (기본 24시간)
+}
+
+// 수집 테이블(client_data, mqtt_messages, coap_messages, snmp_data) 파티션 관리 설정 (변경 시 재시작 필요)
+// 테이블은 created_at 기준 일(UTC) 단위로 파티션되며, 미래 파티션을 미리 만들고 보관 기간이 지난 파티션을 삭제한다
+type RetentionConfig struct {
+	PremakeDays          int            `yaml:"premake_days"`           // 오늘 이후 미리 만들어 둘 파티션 일수 (기본 7)
+	CheckIntervalMinutes int            `yaml:"check_interval_minutes"` // 파티션 점검 주기 (기본 60분)
+	Days                 map[string]int `yaml:"days"`                   // 테이블별 보관 일수 (미지정 또는 0이면 삭제하지 않음)
+	SNMPRollup           bool           `yaml:"snmp_rollup"`            // snmp_data 파티션 삭제 전 숫자 값을 시간별 min/max/avg로 snmp_rollup_hourly에 집계
 }
 
 // RestConfig 구조체 추가
@@ -24,6 +63,72 @@ type RestConfig struct {
 	Host      string           `yaml:"host"`
 	Port      string           `yaml:"port"`
 	Endpoints []EndpointConfig `yaml:"endpoints"`
+	Scenarios []ScenarioConfig `yaml:"scenarios"`  // 목(mock) 시나리오 상태 머신 정의
+	Recording RecordingConfig  `yaml:"recording"`  // 트래픽 녹화 설정
+	OpenAPI   []string         `yaml:"openapi"`    // 엔드포인트를 가져올 OpenAPI 3.x 명세 파일 목록 (변경 시 자동 리로드)
+	Auth      AuthConfig       `yaml:"auth"`       // 인증 설정 (인증 수단 및 경로 접두사별 규칙)
+	TLS       TLSConfig        `yaml:"tls"`        // TLS/mTLS 설정 (cert_file 지정 시 HTTPS)
+	H2C       bool             `yaml:"h2c"`        // TLS 미사용 시 평문 HTTP/2(h2c) 허용
+	RateLimit *RateLimitConfig `yaml:"rate_limit"` // 모든 엔드포인트에 걸친 클라이언트별 요청 제한 (선택)
+	// X-Forwarded-For 를 신뢰할 프록시 (IP 또는 CIDR). 요청 제한 ip 키는 이 프록시를 거친 요청만 헤더의 주소를 사용
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	// 저장된 메시지 조회 API(/api/_data) 사용. /_admin/data 를 덮는 인증 규칙이 있어야 등록된다
+	DataAPI bool `yaml:"data_api"`
+}
+
+// TLS 설정 구조체
+// 인증서 파일이 변경되면 연결을 끊지 않고 새 핸드셰이크부터 자동으로 새 인증서를 사용한다
+type TLSConfig struct {
+	CertFile     string `yaml:"cert_file"`      // 서버 인증서 (PEM, 체인 포함 가능)
+	KeyFile      string `yaml:"key_file"`       // 서버 개인키 (PEM)
+	ClientCAFile string `yaml:"client_ca_file"` // 지정 시 이 CA 번들로 클라이언트 인증서 검증 (mTLS)
+	ClientAuth   string `yaml:"client_auth"`    // require(기본, 인증서 필수) 또는 optional(제시된 경우만 검증)
+	DisableHTTP2 bool   `yaml:"disable_http2"`  // HTTP/2 비활성화 (기본: TLS 에서 HTTP/2 사용)
+}
+
+// TLS 사용 여부
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+// 인증 설정 구조체
+type AuthConfig struct {
+	Providers map[string]AuthProviderConfig `yaml:"providers"` // 이름 -> 인증 수단
+	Rules     []AuthRule                    `yaml:"rules"`     // 경로 접두사별 인증 규칙 (가장 긴 접두사 우선)
+}
+
+// 인증 수단 설정 구조체
+// type 에 따라 api_key(header/query/keys), basic(realm/users), jwt(secret 또는 jwks_file) 항목을 사용한다
+type AuthProviderConfig struct {
+	Type       string            `yaml:"type"`       // api_key, basic, jwt
+	Header     string            `yaml:"header"`     // api_key: 키를 읽을 헤더 (기본 X-API-Key)
+	Query      string            `yaml:"query"`      // api_key: 키를 읽을 쿼리 파라미터 (선택)
+	Keys       map[string]string `yaml:"keys"`       // api_key: 키 이름 -> 키 값
+	Realm      string            `yaml:"realm"`      // basic: WWW-Authenticate realm (기본 servone)
+	Users      map[string]string `yaml:"users"`      // basic: 사용자 -> bcrypt 해시
+	Secret     string            `yaml:"secret"`     // jwt: HMAC 서명 비밀키
+	JWKSFile   string            `yaml:"jwks_file"`  // jwt: 공개키 JWKS 파일 경로
+	Issuer     string            `yaml:"issuer"`     // jwt: 기대하는 iss (선택)
+	Audience   string            `yaml:"audience"`   // jwt: 기대하는 aud (선택)
+	Algorithms []string          `yaml:"algorithms"` // jwt: 허용 알고리즘 (기본: secret 이면 HS*, jwks 면 RS*/PS*/ES*)
+}
+
+// 경로 접두사별 인증 규칙 구조체
+type AuthRule struct {
+	Prefix    string   `yaml:"prefix"`    // 경로 접두사 (예: /api/snmp/, /metrics)
+	Providers []string `yaml:"providers"` // 허용 인증 수단 이름 (하나라도 성공하면 통과)
+}
+
+// 트래픽 녹화 설정 구조체 (엔드포인트별 record: true 로 활성화)
+type RecordingConfig struct {
+	File            string `yaml:"file"`             // 녹화 파일 경로 (NDJSON, 기본 recordings/servone.ndjson)
+	KeepCredentials bool   `yaml:"keep_credentials"` // 인증 헤더/쿠키/API 키를 가리지 않고 그대로 기록 (기본 false)
+}
+
+// 시나리오(상태 머신) 정의 구조체
+type ScenarioConfig struct {
+	Name         string `yaml:"name"`          // 시나리오 이름
+	InitialState string `yaml:"initial_state"` // 초기 상태 (리셋 시 복귀)
 }
 
 // CoAP 관련 설정 구조체
@@ -31,6 +136,69 @@ type CoapConfig struct {
 	Port      string           `yaml:"port"` // CoAP 서버 포트 번호
 	Host      string           `yaml:"host"` // CoAP 서버 호스트 주소
 	Endpoints []EndpointConfig `yaml:"endpoints"`
+	RateLimit *RateLimitConfig `yaml:"rate_limit"` // 모든 엔드포인트에 걸친 클라이언트별 요청 제한 (선택)
+	DTLS      DTLSConfig       `yaml:"dtls"`       // DTLS(coaps) 리스너 설정 (port 지정 시 사용)
+	TCP       CoapTCPConfig    `yaml:"tcp"`        // CoAP over TCP(coap+tcp) 리스너 설정 (port 지정 시 사용)
+	TLS       CoapTLSConfig    `yaml:"tls"`        // CoAP over TLS(coaps+tcp) 리스너 설정 (port 지정 시 사용)
+}
+
+// CoAP over TCP(RFC 8323) 리스너 설정 구조체
+// UDP 가 막힌 NAT/방화벽 뒤의 게이트웨이용으로, 평문 CoAP 와 같은 엔드포인트를 제공한다
+type CoapTCPConfig struct {
+	Port string `yaml:"port"` // coap+tcp 포트 (보통 5683, 비우면 비활성)
+}
+
+// TCP 리스너 사용 여부
+func (t CoapTCPConfig) Enabled() bool {
+	return t.Port != ""
+}
+
+// CoAP over TLS(RFC 8323) 리스너 설정 구조체
+// 인증서와 클라이언트 인증서 검증은 rest.tls 와 같은 방식이며, 클라이언트 인증서 CN 이 장치 ID 가 된다
+type CoapTLSConfig struct {
+	Port         string `yaml:"port"`           // coaps+tcp 포트 (보통 5684, 비우면 비활성)
+	CertFile     string `yaml:"cert_file"`      // 서버 인증서 (PEM)
+	KeyFile      string `yaml:"key_file"`       // 서버 개인키 (PEM)
+	ClientCAFile string `yaml:"client_ca_file"` // 지정 시 이 CA 번들로 클라이언트 인증서 검증
+	ClientAuth   string `yaml:"client_auth"`    // require(기본, 인증서 필수) 또는 optional(제시된 경우만 검증)
+}
+
+// TLS 리스너 사용 여부
+func (t CoapTLSConfig) Enabled() bool {
+	return t.Port != ""
+}
+
+// 인증서 설정 (certs 패키지에서 로드)
+func (t CoapTLSConfig) TLS() TLSConfig {
+	return TLSConfig{CertFile: t.CertFile, KeyFile: t.KeyFile, ClientCAFile: t.ClientCAFile, ClientAuth: t.ClientAuth}
+}
+
+// CoAP DTLS(coaps) 리스너 설정 구조체
+// 평문 CoAP 와 같은 엔드포인트를 별도 포트에서 DTLS 로 제공하며, PSK 와 X.509 인증서 모드를 함께 사용할 수 있다
+// PSK 는 설정 리로드 시 다시 읽는다 (psk_file 변경도 리로드를 일으킴)
+type DTLSConfig struct {
+	Port         string `yaml:"port"`           // coaps 포트 (보통 5684, 비우면 비활성)
+	PSKFile      string `yaml:"psk_file"`       // PSK 목록 YAML 파일 (identity, key(hex), device_id)
+	PSKFromDB    bool   `yaml:"psk_from_db"`    // 데이터베이스 coap_psk 테이블의 PSK 사용
+	CertFile     string `yaml:"cert_file"`      // 서버 인증서 (PEM, 인증서 모드)
+	KeyFile      string `yaml:"key_file"`       // 서버 개인키 (PEM)
+	ClientCAFile string `yaml:"client_ca_file"` // 지정 시 이 CA 번들로 클라이언트 인증서 검증 (인증서 CN 이 장치 ID)
+	ClientAuth   string `yaml:"client_auth"`    // require(기본, 인증서 필수) 또는 optional(제시된 경우만 검증)
+}
+
+// DTLS 리스너 사용 여부
+func (d DTLSConfig) Enabled() bool {
+	return d.Port != ""
+}
+
+// PSK 모드 사용 여부
+func (d DTLSConfig) PSKEnabled() bool {
+	return d.PSKFile != "" || d.PSKFromDB
+}
+
+// 인증서 설정 (certs 패키지에서 로드)
+func (d DTLSConfig) TLS() TLSConfig {
+	return TLSConfig{CertFile: d.CertFile, KeyFile: d.KeyFile, ClientCAFile: d.ClientCAFile, ClientAuth: d.ClientAuth}
 }
 
 // MQTT 관련 설정 구조체
@@ -41,7 +209,18 @@ type MQTTConfig struct {
 
 // 데이터베이스 관련 설정 구조체
 type DatabaseConfig struct {
-	ConnectionString string `yaml:"connection_string"` // 데이터베이스 연결 문자열
+	Driver           string      `yaml:"driver"`            // 저장소 종류: postgres(기본), sqlite, file(JSONL)
+	ConnectionString string      `yaml:"connection_string"` // 데이터베이스 연결 문자열 (postgres)
+	Path             string      `yaml:"path"`              // 저장 파일 경로 (sqlite, file)
+	Batch            BatchConfig `yaml:"batch"`             // 수집 데이터 일괄 저장(COPY) 설정 (postgres)
+}
+
+// 수집 데이터 일괄 저장 설정 구조체 (변경 시 재시작 필요)
+// 활성화하면 REST/CoAP/MQTT/SNMP 행을 테이블별로 모아 COPY 한 번으로 저장한다
+type BatchConfig struct {
+	Enabled         bool `yaml:"enabled"`           // 일괄 저장 사용 여부 (기본 false: 메시지마다 INSERT)
+	MaxRows         int  `yaml:"max_rows"`          // 이 행 수만큼 모이면 즉시 저장 (기본 500)
+	FlushIntervalMs int  `yaml:"flush_interval_ms"` // 행 수와 관계없이 저장하는 주기 (기본 200ms)
 }
 
 // Kafka 관련 설정 구조체
@@ -78,16 +257,130 @@ type SNMPTrapConfig struct {
 
 // 각 엔드포인트(라우트)별 설정 구조체
 type EndpointConfig struct {
-	Path     string         `yaml:"path"`     // 엔드포인트 경로
-	Method   string         `yaml:"method"`   // HTTP 메서드(GET, POST 등)
+	Path       string            `yaml:"path"`                 // 엔드포인트 경로
+	Method     string            `yaml:"method"`               // HTTP 메서드(GET, POST 등)
+	Response   ResponseConfig    `yaml:"response"`             // 기본 응답 설정 (일치하는 변형이 없을 때 사용)
+	Responses  []ResponseVariant `yaml:"responses,omitempty"`  // 조건부 응답 변형 목록 (순서대로 평가)
+	Schema     *SchemaConfig     `yaml:"schema,omitempty"`     // 요청 페이로드 JSON Schema (선택)
+	Sequence   *SequenceConfig   `yaml:"sequence,omitempty"`   // 순차 응답 설정 (선택)
+	Scenario   *EndpointScenario `yaml:"scenario,omitempty"`   // 시나리오 상태별 응답 및 전이 (선택)
+	Fault      *FaultConfig      `yaml:"fault,omitempty"`      // 장애/지연 주입 설정 (선택)
+	Record     bool              `yaml:"record,omitempty"`     // 요청/응답 녹화 여부
+	Auth       []string          `yaml:"auth,omitempty"`       // 허용 인증 수단 이름 (경로 규칙보다 우선, [none] 이면 인증 없음)
+	RateLimit  *RateLimitConfig  `yaml:"rate_limit,omitempty"` // 엔드포인트별 요청 제한 및 일일 할당량 (선택)
+	Observable bool              `yaml:"observable,omitempty"` // CoAP Observe(RFC 7641) 관찰 허용 (CoAP 전용)
+	Observe    *ObserveConfig    `yaml:"observe,omitempty"`    // 관찰 리소스 세부 설정 (observable 일 때만 사용, 선택)
+	Link       *LinkConfig       `yaml:"link,omitempty"`       // /.well-known/core 링크 속성 (CoAP 전용, 선택)
+}
+
+// CoAP 리소스 검색(/.well-known/core, RFC 6690) 링크 속성 설정 구조체
+// 지정하지 않은 ct 는 응답 Content-Type, obs 는 observable 값을 따른다
+type LinkConfig struct {
+	ResourceType  string `yaml:"rt"`     // 리소스 유형 (rt)
+	Interface     string `yaml:"if"`     // 인터페이스 설명 (if)
+	ContentFormat *int   `yaml:"ct"`     // CoAP Content-Format 번호 (ct, 예: 50 = application/json)
+	Observable    *bool  `yaml:"obs"`    // 관찰 가능 여부 표시 (obs)
+	Hidden        bool   `yaml:"hidden"` // true 면 /.well-known/core 에 노출하지 않음
+}
+
+// CoAP Observe 리소스 설정 구조체
+// 관찰 가능한 엔드포인트는 마지막으로 받은 값을 보관하고, 값이 바뀔 때마다 등록된 관찰자에게 알림을 보낸다
+type ObserveConfig struct {
+	MaxAge               int    `yaml:"max_age"`                // 응답/알림의 Max-Age 옵션 (초, 기본 60)
+	NotifyTimeoutSeconds int    `yaml:"notify_timeout_seconds"` // 알림(CON) 확인 대기 시간, 초과 시 관찰자 제거 (초, 기본 30)
+	MQTTTopic            string `yaml:"mqtt_topic"`             // 이 MQTT 토픽(+, # 와일드카드 허용)으로 받은 메시지로도 값 갱신
+	KafkaTopic           string `yaml:"kafka_topic"`            // servone 이 이 Kafka 토픽으로 발행한 이벤트로도 값 갱신
+}
+
+// 요청 제한 설정 구조체
+// 토큰 버킷(rate/burst)과 UTC 하루 단위 할당량(daily_quota)을 클라이언트 키별로 적용한다
+type RateLimitConfig struct {
+	Rate       float64 `yaml:"rate"`                  // 초당 허용 요청 수 (0 이면 속도 제한 없음)
+	Burst      int     `yaml:"burst"`                 // 순간 허용 요청 수 (기본: rate 올림, 최소 1)
+	Key        string  `yaml:"key,omitempty"`         // global(기본), ip, api_key, header:<이름>, body:<필드 경로>
+	DailyQuota int64   `yaml:"daily_quota,omitempty"` // 클라이언트 키별 하루 허용 요청 수 (0 이면 무제한, PostgreSQL 에 저장)
+}
+
+// 장애 및 지연 주입 설정 구조체
+// 확률 값은 0~1 사이이며 reset, empty_reply/drop, error 순서로 한 번만 판정한다
+type FaultConfig struct {
+	DelayMs        int     `yaml:"delay_ms"`         // 고정 지연 (밀리초)
+	DelayMaxMs     int     `yaml:"delay_max_ms"`     // 지정 시 delay_ms~delay_max_ms 사이 랜덤 지연
+	ErrorRate      float64 `yaml:"error_rate"`       // 설정된 에러 응답을 반환할 확률
+	ErrorStatus    int     `yaml:"error_status"`     // 에러 응답 상태 코드 (기본 HTTP 500 / CoAP 5.00)
+	ErrorBody      string  `yaml:"error_body"`       // 에러 응답 본문
+	ResetRate      float64 `yaml:"reset_rate"`       // HTTP: 연결을 RST 로 끊을 확률
+	EmptyReplyRate float64 `yaml:"empty_reply_rate"` // HTTP: 응답 없이 연결을 닫을 확률
+	DropRate       float64 `yaml:"drop_rate"`        // CoAP: 응답하지 않을 확률
+}
+
+// 순차 응답 설정 구조체
+type SequenceConfig struct {
+	Mode  string         `yaml:"mode"`  // cycle(순환), stop(마지막 응답 유지), random(가중치 랜덤)
+	Steps []SequenceStep `yaml:"steps"` // 응답 목록
+}
+
+// 순차 응답의 한 단계
+type SequenceStep struct {
+	Weight   int            `yaml:"weight"`   // random 모드 가중치 (기본 1)
 	Response ResponseConfig `yaml:"response"` // 응답 설정
 }
 
+// 엔드포인트의 시나리오 연동 설정 구조체
+type EndpointScenario struct {
+	Name        string                    `yaml:"name"`        // 참조하는 시나리오 이름
+	States      map[string]ResponseConfig `yaml:"states"`      // 상태별 응답
+	Transitions []ScenarioTransition      `yaml:"transitions"` // 요청에 따른 상태 전이 (순서대로 평가)
+}
+
+// 시나리오 상태 전이 설정 구조체
+type ScenarioTransition struct {
+	From  string      `yaml:"from"`  // 전이 전 상태 (빈 값이면 모든 상태)
+	To    string      `yaml:"to"`    // 전이 후 상태
+	Match MatchConfig `yaml:"match"` // 전이 조건 (빈 값이면 모든 요청)
+}
+
+// 요청 페이로드 검증용 JSON Schema 설정 구조체
+// file 과 inline 중 하나만 지정한다
+type SchemaConfig struct {
+	File   string                 `yaml:"file,omitempty"`   // 스키마 파일 경로 (JSON)
+	Inline map[string]interface{} `yaml:"inline,omitempty"` // 인라인 스키마 (YAML 또는 JSON 형태)
+}
+
+// 조건부 응답 변형 설정 구조체
+type ResponseVariant struct {
+	Name     string         `yaml:"name"`     // 변형 이름 (로그 출력용)
+	Match    MatchConfig    `yaml:"match"`    // 요청 매칭 조건
+	Response ResponseConfig `yaml:"response"` // 조건 일치 시 응답
+}
+
+// 요청 매칭 조건 설정 구조체
+// 값 규칙: "*" 는 존재, "!" 는 부재, "regex:" 접두사는 정규표현식, 그 외는 정확히 일치
+type MatchConfig struct {
+	Query    map[string]string `yaml:"query"`     // 쿼리 파라미터 조건
+	Headers  map[string]string `yaml:"headers"`   // 요청 헤더 조건
+	PathVars map[string]string `yaml:"path_vars"` // 경로 변수 조건
+	Body     map[string]string `yaml:"body"`      // JSONPath 표현식 -> 기대값
+}
+
 // 엔드포인트 응답 설정 구조체
 type ResponseConfig struct {
-	Status  int               `yaml:"status"`  // HTTP 상태 코드
-	Body    string            `yaml:"body"`    // 응답 본문
-	Headers map[string]string `yaml:"headers"` // 응답 헤더
+	Status  int               `yaml:"status"`            // HTTP 상태 코드
+	Body    string            `yaml:"body,omitempty"`    // 응답 본문
+	Headers map[string]string `yaml:"headers,omitempty"` // 응답 헤더
+	Proxy   *ProxyConfig      `yaml:"proxy,omitempty"`   // 지정 시 프록시 응답 유형 (위 값들은 fallback 응답으로 사용)
+}
+
+// 업스트림 프록시(패스스루) 응답 설정 구조체
+type ProxyConfig struct {
+	URL       string            `yaml:"url"`        // 업스트림 URL ({id} 형태의 경로 변수 치환 지원)
+	TimeoutMs int               `yaml:"timeout_ms"` // 업스트림 요청 타임아웃 (기본 10000)
+	Fallback  bool              `yaml:"fallback"`   // 업스트림 실패 시 정적 응답으로 대체
+	Headers   map[string]string `yaml:"headers"`    // 업스트림 요청에 추가할 헤더
+	// fallback 과 함께 쓰면 업스트림의 5xx 응답도 실패로 보고 정적 응답으로 대체 (기본 false)
+	FallbackOn5xx bool `yaml:"fallback_on_5xx"`
+	// 인증 수단이 읽은 헤더/쿼리(Authorization, X-API-Key 등)를 업스트림에 그대로 전달 (기본 false)
+	ForwardCredentials bool `yaml:"forward_credentials"`
 }
 
 // 설정 파일을 읽어 Config 구조체로 반환하는 함수
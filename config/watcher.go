@@ -21,6 +21,8 @@ type ConfigWatcher struct {
 	watcher    *fsnotify.Watcher
 	mu         sync.Mutex
 	debouncer  *time.Timer
+	files      map[string]bool // absolute paths that trigger a reload (config, rest.openapi specs, coap.dtls.psk_file)
+	dirs       map[string]bool // directories registered with fsnotify
 }
 
 // NewConfigWatcher creates a new ConfigWatcher.
@@ -39,18 +41,21 @@ func NewConfigWatcher(configPath string, servers ...Reloadable) (*ConfigWatcher,
 		configPath: absPath,
 		servers:    servers,
 		watcher:    watcher,
+		files:      map[string]bool{absPath: true},
+		dirs:       make(map[string]bool),
 	}
 
 	return cw, nil
 }
 
-// Start begins watching the configuration file.
+// Start begins watching the configuration file and the files it references.
 func (cw *ConfigWatcher) Start() error {
-	configDir := filepath.Dir(cw.configPath)
-	err := cw.watcher.Add(configDir)
-	if err != nil {
+	if err := cw.watchDir(filepath.Dir(cw.configPath)); err != nil {
 		return err
 	}
+	if cfg, err := LoadConfig(cw.configPath); err == nil {
+		cw.watchSpecs(cfg)
+	}
 
 	go cw.watchLoop()
 	log.Printf("Started watching config file: %s", cw.configPath)
@@ -68,7 +73,7 @@ func (cw *ConfigWatcher) watchLoop() {
 			}
 
 			eventPath, _ := filepath.Abs(event.Name)
-			if eventPath == cw.configPath && (event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create) {
+			if cw.isWatched(eventPath) && (event.Op&fsnotify.Write == fsnotify.Write || event.Op&fsnotify.Create == fsnotify.Create) {
 				log.Printf("Config file event detected: %s %s", event.Op, event.Name)
 				cw.scheduleReload()
 			}
@@ -105,6 +110,7 @@ func (cw *ConfigWatcher) reloadConfig() {
 		log.Printf("Failed to reload config: %v", err)
 		return
 	}
+	cw.watchSpecs(newConfig)
 
 	for _, s := range cw.servers {
 		if s != nil {
@@ -113,6 +119,56 @@ func (cw *ConfigWatcher) reloadConfig() {
 	}
 }
 
+// watchDir registers dir with fsnotify once.
+func (cw *ConfigWatcher) watchDir(dir string) error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	if cw.dirs[dir] {
+		return nil
+	}
+	if err := cw.watcher.Add(dir); err != nil {
+		return err
+	}
+	cw.dirs[dir] = true
+	return nil
+}
+
+// watchSpecs replaces the watched files with the OpenAPI specs and the CoAP
+// PSK file referenced by cfg.
+func (cw *ConfigWatcher) watchSpecs(cfg *Config) {
+	files := map[string]bool{cw.configPath: true}
+	watch := func(kind, path string) {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			log.Printf("Config watcher: cannot resolve %s %s: %v", kind, path, err)
+			return
+		}
+		if err := cw.watchDir(filepath.Dir(absPath)); err != nil {
+			log.Printf("Config watcher: cannot watch %s %s: %v", kind, path, err)
+			return
+		}
+		files[absPath] = true
+	}
+	for _, spec := range cfg.Rest.OpenAPI {
+		watch("OpenAPI spec", spec)
+	}
+	if cfg.Coap.DTLS.PSKFile != "" {
+		watch("PSK file", cfg.Coap.DTLS.PSKFile)
+	}
+
+	cw.mu.Lock()
+	cw.files = files
+	cw.mu.Unlock()
+}
+
+// isWatched reports whether changes to path should trigger a reload.
+func (cw *ConfigWatcher) isWatched(path string) bool {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	return cw.files[path]
+}
+
 // Stop stops the watcher.
 func (cw *ConfigWatcher) Stop() {
 	cw.mu.Lock()
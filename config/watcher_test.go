@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"path/filepath"
 
 	"testing"
 	"time"
@@ -94,3 +95,103 @@ endpoints:
 		t.Error("Expected coap server to be reloaded, but it wasn't")
 	}
 }
+
+// TestConfigWatcherOpenAPISpecs tests that edits to referenced OpenAPI specs trigger a reload.
+func TestConfigWatcherOpenAPISpecs(t *testing.T) {
+	dir := t.TempDir()
+	specDir := filepath.Join(dir, "specs")
+	if err := os.Mkdir(specDir, 0755); err != nil {
+		t.Fatalf("Failed to create spec dir: %v", err)
+	}
+	specPath := filepath.Join(specDir, "partner.yaml")
+	if err := os.WriteFile(specPath, []byte("openapi: 3.1.0\n"), 0644); err != nil {
+		t.Fatalf("Failed to write spec: %v", err)
+	}
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("rest:\n  openapi:\n    - "+specPath+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	reloaded := make(chan *Config, 1)
+	mockServer := &MockReloadable{
+		ReloadFunc: func(config *Config) {
+			reloaded <- config
+		},
+	}
+
+	watcher, err := NewConfigWatcher(configPath, mockServer)
+	if err != nil {
+		t.Fatalf("Failed to create config watcher: %v", err)
+	}
+	if err := watcher.Start(); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	defer watcher.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	if err := os.WriteFile(specPath, []byte("openapi: 3.1.0\ninfo: {title: changed}\n"), 0644); err != nil {
+		t.Fatalf("Failed to update spec: %v", err)
+	}
+
+	select {
+	case cfg := <-reloaded:
+		if len(cfg.Rest.OpenAPI) != 1 || cfg.Rest.OpenAPI[0] != specPath {
+			t.Errorf("Unexpected openapi specs after reload: %v", cfg.Rest.OpenAPI)
+		}
+	case <-time.After(3 * time.Second):
+		t.Error("Expected spec change to trigger a reload, but it didn't")
+	}
+
+	// Changes to unrelated files in the spec directory are ignored
+	if err := os.WriteFile(filepath.Join(specDir, "other.yaml"), []byte("x: 1\n"), 0644); err != nil {
+		t.Fatalf("Failed to write unrelated file: %v", err)
+	}
+	select {
+	case <-reloaded:
+		t.Error("Unrelated file change should not trigger a reload")
+	case <-time.After(1 * time.Second):
+	}
+}
+
+// TestConfigWatcherPSKFile tests that edits to the CoAP DTLS PSK file trigger a reload.
+func TestConfigWatcherPSKFile(t *testing.T) {
+	dir := t.TempDir()
+	pskPath := filepath.Join(dir, "psk.yaml")
+	if err := os.WriteFile(pskPath, []byte("[]\n"), 0600); err != nil {
+		t.Fatalf("Failed to write PSK file: %v", err)
+	}
+	configPath := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(configPath, []byte("coap:\n  dtls:\n    port: \"5684\"\n    psk_file: "+pskPath+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	reloaded := make(chan *Config, 1)
+	watcher, err := NewConfigWatcher(configPath, &MockReloadable{
+		ReloadFunc: func(config *Config) {
+			reloaded <- config
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create config watcher: %v", err)
+	}
+	if err := watcher.Start(); err != nil {
+		t.Fatalf("Failed to start watcher: %v", err)
+	}
+	defer watcher.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+
+	if err := os.WriteFile(pskPath, []byte("- identity: sensor\n  key: \"0102\"\n  device_id: dev\n"), 0600); err != nil {
+		t.Fatalf("Failed to update PSK file: %v", err)
+	}
+
+	select {
+	case cfg := <-reloaded:
+		if cfg.Coap.DTLS.PSKFile != pskPath {
+			t.Errorf("Unexpected psk_file after reload: %q", cfg.Coap.DTLS.PSKFile)
+		}
+	case <-time.After(3 * time.Second):
+		t.Error("Expected PSK file change to trigger a reload, but it didn't")
+	}
+}
@@ -0,0 +1,284 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"servone/config"
+	"servone/metrics"
+
+	"github.com/lib/pq"
+)
+
+// Defaults used when the batch config leaves a field unset.
+const (
+	DefaultBatchMaxRows       = 500
+	DefaultBatchFlushInterval = 200 * time.Millisecond
+)
+
+// batchFlushTimeout bounds one COPY of a batch.
+const batchFlushTimeout = 30 * time.Second
+
+// dbBatch buffers inserts made through WriteInsert. It is nil unless
+// UseBatchWriter was called.
+var dbBatch *BatchWriter
+
+// UseBatchWriter makes WriteInsert queue rows on w instead of inserting them
+// one by one. Passing nil switches back to single inserts.
+func UseBatchWriter(w *BatchWriter) {
+	dbBatch = w
+}
+
+// BatchWriter buffers rows per table and writes each buffer with a single
+// COPY once it holds MaxRows rows or FlushInterval has passed. The outbox
+// events of the rows are copied in the same transaction. If a batch fails,
+// its rows are written one by one so a bad row only fails itself; rows that
+// still fail are spooled when the spool is enabled, or logged, counted in
+// servone_db_batch_failed_rows_total and dropped. Add returns before the row
+// is written, so without the spool batched rows are delivered at most once.
+type BatchWriter struct {
+	conn     *sql.DB
+	maxRows  int
+	interval time.Duration
+
+	// copy writes one batch; write writes a single row. They are fields so
+	// tests can replace the database.
+	copy  func(ctx context.Context, rows []Insert) error
+	write func(ins Insert) (bool, error)
+
+	mu      sync.Mutex
+	pending map[string][]Insert // keyed by table and column list
+	closed  bool
+
+	// flushMu serializes flushes so rows of a table keep their order
+	flushMu sync.Mutex
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewBatchWriter creates a batch writer on conn and starts its flush timer.
+func NewBatchWriter(conn *sql.DB, cfg config.BatchConfig) (*BatchWriter, error) {
+	if cfg.MaxRows < 0 || cfg.FlushIntervalMs < 0 {
+		return nil, errors.New("batch: settings must not be negative")
+	}
+
+	w := &BatchWriter{
+		conn:     conn,
+		maxRows:  cfg.MaxRows,
+		interval: time.Duration(cfg.FlushIntervalMs) * time.Millisecond,
+		pending:  make(map[string][]Insert),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if w.maxRows == 0 {
+		w.maxRows = DefaultBatchMaxRows
+	}
+	if w.interval == 0 {
+		w.interval = DefaultBatchFlushInterval
+	}
+	w.copy = func(ctx context.Context, rows []Insert) error { return copyInserts(ctx, conn, rows) }
+	w.write = func(ins Insert) (bool, error) { return writeInsert(conn, ins) }
+
+	go w.run()
+	return w, nil
+}
+
+// batchKey groups rows that can share one COPY.
+func batchKey(ins Insert) string {
+	return ins.Table + "(" + strings.Join(ins.Columns, ",") + ")"
+}
+
+// Add queues ins for the next batch of its table. When the batch is full it
+// is written before Add returns, which slows down producers that outpace the
+// database. After Close, ins is written right away.
+func (w *BatchWriter) Add(ins Insert) error {
+	if err := validateInsert(ins); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		_, err := w.write(ins)
+		return err
+	}
+	key := batchKey(ins)
+	w.pending[key] = append(w.pending[key], ins)
+	full := len(w.pending[key]) >= w.maxRows
+	w.mu.Unlock()
+
+	if full {
+		w.flush(key)
+	}
+	return nil
+}
+
+// Pending returns the number of buffered rows.
+func (w *BatchWriter) Pending() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	n := 0
+	for _, rows := range w.pending {
+		n += len(rows)
+	}
+	return n
+}
+
+func (w *BatchWriter) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.Flush()
+		}
+	}
+}
+
+// Flush writes every buffered row.
+func (w *BatchWriter) Flush() {
+	w.mu.Lock()
+	keys := make([]string, 0, len(w.pending))
+	for key, rows := range w.pending {
+		if len(rows) > 0 {
+			keys = append(keys, key)
+		}
+	}
+	w.mu.Unlock()
+
+	for _, key := range keys {
+		w.flush(key)
+	}
+}
+
+// flush writes the buffered rows of one key.
+func (w *BatchWriter) flush(key string) {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	w.mu.Lock()
+	rows := w.pending[key]
+	delete(w.pending, key)
+	w.mu.Unlock()
+	if len(rows) == 0 {
+		return
+	}
+
+	table := rows[0].Table
+	// rows must not overtake inserts waiting in the spool
+	if q := dbSpool; q != nil && q.Pending() > 0 {
+		w.writeEach(rows)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), batchFlushTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := w.copy(ctx, rows)
+	status := "success"
+	if err != nil {
+		status = "failed"
+	}
+	metrics.RecordDBOperation("copy", table, status, time.Since(start).Seconds())
+	metrics.RecordDBBatch(table, len(rows))
+
+	if err != nil {
+		log.Printf("Batch writer: COPY of %d rows into %s failed, writing rows one by one: %v", len(rows), table, err)
+		w.writeEach(rows)
+	}
+}
+
+// writeEach writes rows individually so only the bad ones fail. A row that
+// fails here was neither written nor spooled and is lost.
+func (w *BatchWriter) writeEach(rows []Insert) {
+	for _, ins := range rows {
+		if _, err := w.write(ins); err != nil {
+			metrics.RecordDBBatchFailedRow(ins.Table)
+			log.Printf("Batch writer: failed to insert row into %s, dropping it: %v", ins.Table, err)
+		}
+	}
+}
+
+// Close stops the flush timer and writes the remaining rows. Rows added
+// afterwards are written one by one.
+func (w *BatchWriter) Close() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+		<-w.done
+
+		w.mu.Lock()
+		w.closed = true
+		w.mu.Unlock()
+		w.Flush()
+	})
+}
+
+// copyInserts writes rows, which share a table and column list, and their
+// outbox events with COPY in one transaction.
+func copyInserts(ctx context.Context, conn *sql.DB, rows []Insert) error {
+	if conn == nil {
+		return errors.New("database is not initialized")
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	first := rows[0]
+	values := make([][]interface{}, len(rows))
+	var events [][]interface{}
+	for i, ins := range rows {
+		values[i] = ins.Values
+		if ins.Event != nil {
+			payload, err := json.Marshal(ins.Event.Data)
+			if err != nil {
+				return fmt.Errorf("failed to marshal outbox event: %w", err)
+			}
+			events = append(events, []interface{}{ins.Event.Topic, string(payload), time.Now().UnixNano()})
+		}
+	}
+
+	if err := copyIn(ctx, tx, first.Table, first.Columns, values); err != nil {
+		return err
+	}
+	if len(events) > 0 {
+		if err := copyIn(ctx, tx, "outbox", []string{"topic", "payload", "created_at"}, events); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// copyIn runs one COPY FROM STDIN inside tx.
+func copyIn(ctx context.Context, tx *sql.Tx, table string, columns []string, values [][]interface{}) error {
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(table, columns...))
+	if err != nil {
+		return fmt.Errorf("failed to start COPY into %s: %w", table, err)
+	}
+	defer stmt.Close()
+
+	for _, v := range values {
+		if _, err := stmt.ExecContext(ctx, v...); err != nil {
+			return fmt.Errorf("failed to COPY into %s: %w", table, err)
+		}
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return fmt.Errorf("failed to COPY into %s: %w", table, err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"servone/config"
+	"servone/metrics"
+	"servone/spool"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBatchDB records batches and single-row writes instead of using
+// PostgreSQL.
+type fakeBatchDB struct {
+	mu      sync.Mutex
+	down    bool
+	bad     string // payload the single-row path rejects
+	batches [][]Insert
+	single  []Insert
+}
+
+func (f *fakeBatchDB) copy(ctx context.Context, rows []Insert) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return errors.New("connection refused")
+	}
+	for _, ins := range rows {
+		if ins.Values[1] == f.bad {
+			return errors.New("invalid input syntax")
+		}
+	}
+	f.batches = append(f.batches, append([]Insert(nil), rows...))
+	return nil
+}
+
+func (f *fakeBatchDB) write(ins Insert) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if ins.Values[1] == f.bad {
+		return false, errors.New("invalid input syntax")
+	}
+	f.single = append(f.single, ins)
+	return false, nil
+}
+
+func (f *fakeBatchDB) batchSizes() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var sizes []int
+	for _, b := range f.batches {
+		sizes = append(sizes, len(b))
+	}
+	return sizes
+}
+
+func newTestBatchWriter(t *testing.T, fake *fakeBatchDB, cfg config.BatchConfig) *BatchWriter {
+	t.Helper()
+	w, err := NewBatchWriter(nil, cfg)
+	require.NoError(t, err)
+	w.copy = fake.copy
+	w.write = fake.write
+	t.Cleanup(w.Close)
+	return w
+}
+
+func mqttInsert(payload string) Insert {
+	return Insert{
+		Table:   "mqtt_messages",
+		Columns: []string{"topic", "payload", "created_at"},
+		Values:  []interface{}{"sensors/temp", payload, time.Now().UnixNano()},
+	}
+}
+
+func TestNewBatchWriter(t *testing.T) {
+	_, err := NewBatchWriter(nil, config.BatchConfig{MaxRows: -1})
+	assert.Error(t, err)
+
+	w, err := NewBatchWriter(nil, config.BatchConfig{})
+	require.NoError(t, err)
+	defer w.Close()
+	assert.Equal(t, DefaultBatchMaxRows, w.maxRows)
+	assert.Equal(t, DefaultBatchFlushInterval, w.interval)
+}
+
+func TestBatchWriter(t *testing.T) {
+	t.Run("flushes when a batch is full", func(t *testing.T) {
+		fake := &fakeBatchDB{}
+		w := newTestBatchWriter(t, fake, config.BatchConfig{MaxRows: 3, FlushIntervalMs: 60000})
+
+		for i := 0; i < 7; i++ {
+			require.NoError(t, w.Add(mqttInsert("21")))
+		}
+		assert.Equal(t, []int{3, 3}, fake.batchSizes())
+		assert.Equal(t, 1, w.Pending())
+	})
+
+	t.Run("flushes on the interval", func(t *testing.T) {
+		fake := &fakeBatchDB{}
+		w := newTestBatchWriter(t, fake, config.BatchConfig{MaxRows: 100, FlushIntervalMs: 10})
+
+		require.NoError(t, w.Add(mqttInsert("21")))
+		require.NoError(t, w.Add(mqttInsert("22")))
+		assert.Eventually(t, func() bool { return len(fake.batchSizes()) == 1 }, time.Second, 5*time.Millisecond)
+		assert.Equal(t, []int{2}, fake.batchSizes())
+	})
+
+	t.Run("keeps tables and column lists in separate batches", func(t *testing.T) {
+		fake := &fakeBatchDB{}
+		w := newTestBatchWriter(t, fake, config.BatchConfig{MaxRows: 100, FlushIntervalMs: 60000})
+
+		require.NoError(t, w.Add(mqttInsert("21")))
+		require.NoError(t, w.Add(Insert{Table: "coap_messages", Columns: []string{"path", "payload", "method", "created_at"}, Values: []interface{}{"/a", "1", "POST", int64(1)}}))
+		require.NoError(t, w.Add(Insert{Table: "mqtt_messages", Columns: []string{"topic", "payload"}, Values: []interface{}{"t", "2"}}))
+		w.Flush()
+		assert.ElementsMatch(t, []int{1, 1, 1}, fake.batchSizes())
+	})
+
+	t.Run("a failed batch is written row by row", func(t *testing.T) {
+		fake := &fakeBatchDB{bad: "not json"}
+		w := newTestBatchWriter(t, fake, config.BatchConfig{MaxRows: 100, FlushIntervalMs: 60000})
+		failed := testutil.ToFloat64(metrics.DBBatchFailedRowsTotal.WithLabelValues("mqtt_messages"))
+
+		require.NoError(t, w.Add(mqttInsert("21")))
+		require.NoError(t, w.Add(mqttInsert("not json")))
+		require.NoError(t, w.Add(mqttInsert("23")))
+		w.Flush()
+
+		assert.Empty(t, fake.batches)
+		require.Len(t, fake.single, 2, "only the bad row is lost")
+		assert.Equal(t, "21", fake.single[0].Values[1])
+		assert.Equal(t, "23", fake.single[1].Values[1])
+		assert.Equal(t, failed+1, testutil.ToFloat64(metrics.DBBatchFailedRowsTotal.WithLabelValues("mqtt_messages")), "the lost row is counted")
+	})
+
+	t.Run("close flushes and later rows are written directly", func(t *testing.T) {
+		fake := &fakeBatchDB{}
+		w := newTestBatchWriter(t, fake, config.BatchConfig{MaxRows: 100, FlushIntervalMs: 60000})
+
+		require.NoError(t, w.Add(mqttInsert("21")))
+		w.Close()
+		assert.Equal(t, []int{1}, fake.batchSizes())
+
+		require.NoError(t, w.Add(mqttInsert("22")))
+		assert.Len(t, fake.single, 1)
+		w.Close() // closing twice is safe
+	})
+
+	t.Run("rejects malformed rows", func(t *testing.T) {
+		w := newTestBatchWriter(t, &fakeBatchDB{}, config.BatchConfig{})
+
+		err := w.Add(Insert{Table: "mqtt_messages", Columns: []string{"topic"}, Values: []interface{}{"a", "b"}})
+		assert.True(t, spool.IsPermanent(err))
+		assert.Zero(t, w.Pending())
+	})
+}
+
+func TestWriteInsertBatched(t *testing.T) {
+	fake := &fakeBatchDB{}
+	w := newTestBatchWriter(t, fake, config.BatchConfig{MaxRows: 100, FlushIntervalMs: 60000})
+	UseBatchWriter(w)
+	defer UseBatchWriter(nil)
+
+	spooled, err := WriteInsert(nil, mqttInsert("21"))
+	require.NoError(t, err)
+	assert.False(t, spooled)
+	assert.Equal(t, 1, w.Pending(), "the row waits for the next batch")
+}
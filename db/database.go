@@ -11,7 +11,7 @@ package db
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"servone/kafka"
@@ -76,6 +76,7 @@ func InitDB(connStr string) error {
 		return err
 	}
 	DbPool = db.db
+	defaultStore = NewPostgresStore(DbPool)
 	return nil
 }
 
@@ -95,207 +96,45 @@ func CloseDB() error {
 	return nil
 }
 
-// SetupTables creates all necessary database tables
+// SetupTables brings the schema up to date by applying pending migrations
 func (d *Database) SetupTables() error {
-	if err := d.createClientDataTable(); err != nil {
-		return err
-	}
-	if err := d.createMQTTMessagesTable(); err != nil {
-		return err
-	}
-	if err := d.createCoapMessagesTable(); err != nil {
-		return err
-	}
-	if err := d.createSNMPDataTable(); err != nil {
-		return err
-	}
-	return nil
-}
-
-func (d *Database) createClientDataTable() error {
-	createSQL := `
-	CREATE TABLE IF NOT EXISTS client_data (
-		id SERIAL PRIMARY KEY,
-		url TEXT NOT NULL,
-		data JSONB,
-		parameters JSONB,
-		created_at BIGINT
-	);`
-
-	_, err := d.db.Exec(createSQL)
-	if err != nil {
-		return fmt.Errorf("failed to create 'client_data' table: %w", err)
-	}
-	fmt.Println("Table 'client_data' created successfully or already exists.")
-	return nil
-}
-
-func (d *Database) createMQTTMessagesTable() error {
-	createSQL := `
-	CREATE TABLE IF NOT EXISTS mqtt_messages (
-		id SERIAL PRIMARY KEY,
-		topic TEXT NOT NULL,
-		payload TEXT,
-		created_at BIGINT
-	);`
-
-	_, err := d.db.Exec(createSQL)
-	if err != nil {
-		return fmt.Errorf("failed to create 'mqtt_messages' table: %w", err)
-	}
-	fmt.Println("Table 'mqtt_messages' created successfully or already exists.")
-	return nil
-}
-
-func (d *Database) createCoapMessagesTable() error {
-	createSQL := `
-	CREATE TABLE IF NOT EXISTS coap_messages (
-		id SERIAL PRIMARY KEY,
-		path TEXT NOT NULL,
-		payload TEXT,
-		method TEXT,
-		created_at BIGINT
-	);`
-
-	_, err := d.db.Exec(createSQL)
-	if err != nil {
-		return fmt.Errorf("failed to create 'coap_messages' table: %w", err)
-	}
-	fmt.Println("Table 'coap_messages' created successfully or already exists.")
-	return nil
-}
-
-func (d *Database) createSNMPDataTable() error {
-	createSQL := `
-	CREATE TABLE IF NOT EXISTS snmp_data (
-		id SERIAL PRIMARY KEY,
-		host TEXT NOT NULL,
-		data JSONB,
-		created_at BIGINT
-	);`
-
-	_, err := d.db.Exec(createSQL)
+	m, err := NewMigrator(d.db)
 	if err != nil {
-		return fmt.Errorf("failed to create 'snmp_data' table: %w", err)
+		return err
 	}
-	fmt.Println("Table 'snmp_data' created successfully or already exists.")
-	return nil
+	_, err = m.Up(context.Background())
+	return err
 }
 
 // SetupDatabase is for backward compatibility
 func SetupDatabase() {
-	createClientDataTableSQL := `
-	CREATE TABLE IF NOT EXISTS client_data (
-		id SERIAL PRIMARY KEY,
-		url TEXT NOT NULL,
-		data JSONB,
-		parameters JSONB,
-		created_at BIGINT
-	);`
-
-	_, err := DbPool.Exec(createClientDataTableSQL)
+	m, err := NewMigrator(DbPool)
 	if err != nil {
-		log.Fatalf("Failed to create 'client_data' table: %v", err)
+		log.Fatalf("Failed to load migrations: %v", err)
 	}
-	fmt.Println("Table 'client_data' created successfully or already exists.")
-
-	createMQTTMessagesTableSQL := `
-	CREATE TABLE IF NOT EXISTS mqtt_messages (
-		id SERIAL PRIMARY KEY,
-		topic TEXT NOT NULL,
-		payload TEXT,
-		created_at BIGINT
-	);`
-
-	_, err = DbPool.Exec(createMQTTMessagesTableSQL)
-	if err != nil {
-		log.Fatalf("Failed to create 'mqtt_messages' table: %v", err)
-	}
-	fmt.Println("Table 'mqtt_messages' created successfully or already exists.")
-
-	// Create CoAP messages table
-	createCoapMessagesTableSQL := `
-	CREATE TABLE IF NOT EXISTS coap_messages (
-		id SERIAL PRIMARY KEY,
-		path TEXT NOT NULL,
-		payload TEXT,
-		method TEXT,
-		created_at BIGINT
-	);`
-
-	_, err = DbPool.Exec(createCoapMessagesTableSQL)
-	if err != nil {
-		log.Fatalf("Failed to create 'coap_messages' table: %v", err)
-	}
-	fmt.Println("Table 'coap_messages' created successfully or already exists.")
-
-	// Create SNMP data table
-	createSNMPDataTableSQL := `
-	CREATE TABLE IF NOT EXISTS snmp_data (
-		id SERIAL PRIMARY KEY,
-		host TEXT NOT NULL,
-		data JSONB,
-		created_at BIGINT
-	);`
-
-	_, err = DbPool.Exec(createSNMPDataTableSQL)
-	if err != nil {
-		log.Fatalf("Failed to create 'snmp_data' table: %v", err)
+	if _, err := m.Up(context.Background()); err != nil {
+		log.Fatalf("Failed to migrate database: %v", err)
 	}
-	fmt.Println("Table 'snmp_data' created successfully or already exists.")
+	fmt.Println("Database schema is up to date.")
 }
 
-func SaveToDB(url string, data map[string]interface{}, params map[string]string, publisher kafka.KafkaPublisherInterface) {
-	// Merge data and params, prioritizing existing keys in data
-	mergedData := make(map[string]interface{})
-	for k, v := range data {
-		mergedData[k] = v
-	}
-	for k, v := range params {
-		if _, ok := mergedData[k]; !ok {
-			mergedData[k] = v
-		}
-	}
-
-	mergeDataJSON, err := json.Marshal(mergedData)
-	if err != nil {
-		log.Printf("Failed to marshal data to JSON: %v", err)
-		return
-	}
-
-	paramsJSON, err := json.Marshal(params)
-	if err != nil {
-		log.Printf("Failed to marshal params to JSON: %v", err)
-		return
-	}
-
-	insertSQL := `
-	INSERT INTO client_data (url, data, parameters, created_at)
-	VALUES ($1, $2, $3, $4);`
-
-	kafkaData := map[string]interface{}{
-		"data":     mergedData,
-		"params":   params,
-		"url":      url,
-		"received": time.Now().UnixNano(),
+// SaveToDB is SaveToDBWithError for callers that only log failures
+func SaveToDB(url string, data map[string]interface{}, params map[string]string) {
+	if err := SaveToDBWithError(url, data, params); err != nil {
+		log.Printf("Failed to save data: %v", err)
 	}
+}
 
-	_, err = DbPool.Exec(insertSQL, url, mergeDataJSON, paramsJSON, time.Now().UnixNano())
-	if err != nil {
-		log.Printf("Failed to insert data into database: %v", err)
-	} else {
-		if publisher != nil {
-			// Sanitize the topic before publishing
-			topic := kafka.SanitizeTopic(url)
-			if err := publisher.Publish(topic, kafkaData); err != nil {
-				log.Printf("Failed to publish to Kafka: %v", err)
-			}
-		}
-	}
+// SaveToDBWithError stores a client_data row and, in the same transaction,
+// its Kafka event in the outbox
+func SaveToDBWithError(url string, data map[string]interface{}, params map[string]string) error {
+	return SaveToDBWithAuth(url, data, params, nil)
 }
 
-// SaveToDBWithError is a version of SaveToDB that returns errors
-func SaveToDBWithError(url string, data map[string]interface{}, params map[string]string, publisher kafka.KafkaPublisherInterface) error {
+// clientDataEvent builds the Kafka event of a client_data row: data merged
+// with params (data wins), and params plus the authenticated caller under
+// "auth" when auth is not nil.
+func clientDataEvent(url string, data map[string]interface{}, params map[string]string, auth interface{}) *Event {
 	// Merge data and params, prioritizing existing keys in data
 	mergedData := make(map[string]interface{})
 	for k, v := range data {
@@ -307,59 +146,40 @@ func SaveToDBWithError(url string, data map[string]interface{}, params map[strin
 		}
 	}
 
-	mergeDataJSON, err := json.Marshal(mergedData)
-	if err != nil {
-		return fmt.Errorf("failed to marshal data to JSON: %w", err)
-	}
-
-	paramsJSON, err := json.Marshal(params)
-	if err != nil {
-		return fmt.Errorf("failed to marshal params to JSON: %w", err)
+	storedParams := make(map[string]interface{}, len(params)+1)
+	for k, v := range params {
+		storedParams[k] = v
 	}
-
-	insertSQL := `
-	INSERT INTO client_data (url, data, parameters, created_at)
-	VALUES ($1, $2, $3, $4);`
-
-	kafkaData := map[string]interface{}{
-		"data":     mergedData,
-		"params":   params,
-		"url":      url,
-		"received": time.Now().UnixNano(),
+	if auth != nil {
+		storedParams["auth"] = auth
 	}
 
-	// Use context with timeout for database operations
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	_, err = DbPool.ExecContext(ctx, insertSQL, url, mergeDataJSON, paramsJSON, time.Now().UnixNano())
-	if err != nil {
-		return fmt.Errorf("failed to insert data into database: %w", err)
+	return &Event{
+		Topic: kafka.SanitizeTopic(url),
+		Data: map[string]interface{}{
+			"data":     mergedData,
+			"params":   storedParams,
+			"url":      url,
+			"received": time.Now().UnixNano(),
+		},
 	}
+}
 
-	if publisher != nil {
-		// Sanitize the topic before publishing
-		topic := kafka.SanitizeTopic(url)
-		if err := publisher.Publish(topic, kafkaData); err != nil {
-			log.Printf("Failed to publish to Kafka: %v", err)
-			// Don't return error as we've already saved to DB
-		}
+// SaveToDBWithAuth is SaveToDBWithError that also stores the authenticated
+// caller under the "auth" key of the parameters JSON and the Kafka params.
+// The identity is not merged into data.
+func SaveToDBWithAuth(url string, data map[string]interface{}, params map[string]string, auth interface{}) error {
+	if defaultStore == nil {
+		return errors.New("database is not initialized")
 	}
-
-	return nil
+	return defaultStore.SaveClientData(url, data, params, auth)
 }
 
-// SaveMQTTMessage saves MQTT message to database (method version)
-func (d *Database) SaveMQTTMessage(topic string, payload string, receivedTime int64) error {
+// SaveMQTTMessage saves MQTT message to database (method version).
+// event, if not nil, is written to the outbox in the same transaction.
+func (d *Database) SaveMQTTMessage(topic string, payload string, receivedTime int64, event *Event) error {
 	start := time.Now()
-	insertSQL := `
-	INSERT INTO mqtt_messages (topic, payload, created_at)
-	VALUES ($1, $2, $3);`
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	_, err := d.db.ExecContext(ctx, insertSQL, topic, payload, receivedTime)
+	err := execInsert(d.db, mqttRow(topic, payload, receivedTime, event))
 	if err != nil {
 		metrics.RecordDBOperation("insert", "mqtt_messages", "failed", time.Since(start).Seconds())
 		return fmt.Errorf("failed to insert MQTT message: %w", err)
@@ -369,15 +189,8 @@ func (d *Database) SaveMQTTMessage(topic string, payload string, receivedTime in
 }
 
 // SaveCoapMessage saves CoAP message to database (method version)
-func (d *Database) SaveCoapMessage(path string, payload string, method string, receivedTime int64) error {
-	insertSQL := `
-	INSERT INTO coap_messages (path, payload, method, created_at)
-	VALUES ($1, $2, $3, $4);`
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	_, err := d.db.ExecContext(ctx, insertSQL, path, payload, method, receivedTime)
+func (d *Database) SaveCoapMessage(path string, payload string, method string, deviceID string, params map[string]string, receivedTime int64, event *Event) error {
+	err := execInsert(d.db, coapRow(path, payload, method, deviceID, params, receivedTime, event))
 	if err != nil {
 		return fmt.Errorf("failed to insert CoAP message: %w", err)
 	}
@@ -385,131 +198,53 @@ func (d *Database) SaveCoapMessage(path string, payload string, method string, r
 }
 
 // SaveSNMPData saves SNMP data to database (method version)
-func (d *Database) SaveSNMPData(host string, data map[string]interface{}, receivedTime int64) error {
-	dataJSON, err := json.Marshal(data)
+func (d *Database) SaveSNMPData(host string, data map[string]interface{}, receivedTime int64, event *Event) error {
+	ins, err := snmpRow(host, data, receivedTime, event)
 	if err != nil {
-		return fmt.Errorf("failed to marshal SNMP data: %w", err)
+		return err
 	}
-
-	insertSQL := `
-	INSERT INTO snmp_data (host, data, created_at)
-	VALUES ($1, $2, $3);`
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	_, err = d.db.ExecContext(ctx, insertSQL, host, dataJSON, receivedTime)
-	if err != nil {
+	if err := execInsert(d.db, ins); err != nil {
 		return fmt.Errorf("failed to insert SNMP data: %w", err)
 	}
 	return nil
 }
 
 // SaveToDBWithError saves data to database with error handling (method version)
-func (d *Database) SaveToDBWithError(url string, data map[string]interface{}, params map[string]string, publisher kafka.KafkaPublisherInterface) error {
-	// Merge data and params, prioritizing existing keys in data
-	mergedData := make(map[string]interface{})
-	for k, v := range data {
-		mergedData[k] = v
-	}
-	for k, v := range params {
-		if _, ok := mergedData[k]; !ok {
-			mergedData[k] = v
-		}
-	}
-
-	mergeDataJSON, err := json.Marshal(mergedData)
-	if err != nil {
-		return fmt.Errorf("failed to marshal data to JSON: %w", err)
-	}
-
-	paramsJSON, err := json.Marshal(params)
+func (d *Database) SaveToDBWithError(url string, data map[string]interface{}, params map[string]string) error {
+	ins, err := clientDataRow(url, data, params, nil)
 	if err != nil {
-		return fmt.Errorf("failed to marshal params to JSON: %w", err)
-	}
-
-	insertSQL := `
-	INSERT INTO client_data (url, data, parameters, created_at)
-	VALUES ($1, $2, $3, $4);`
-
-	kafkaData := map[string]interface{}{
-		"data":     mergedData,
-		"params":   params,
-		"url":      url,
-		"received": time.Now().UnixNano(),
+		return err
 	}
-
-	// Use context with timeout for database operations
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	_, err = d.db.ExecContext(ctx, insertSQL, url, mergeDataJSON, paramsJSON, time.Now().UnixNano())
-	if err != nil {
+	if err := execInsert(d.db, ins); err != nil {
 		return fmt.Errorf("failed to insert data into database: %w", err)
 	}
-
-	if publisher != nil {
-		// Sanitize the topic before publishing
-		topic := kafka.SanitizeTopic(url)
-		if err := publisher.Publish(topic, kafkaData); err != nil {
-			log.Printf("Failed to publish to Kafka: %v", err)
-			// Don't return error as we've already saved to DB
-		}
-	}
-
 	return nil
 }
 
-// Global function versions for backward compatibility
-
-func SaveMQTTMessage(topic string, payload string, receivedTime int64) error {
-	insertSQL := `
-	INSERT INTO mqtt_messages (topic, payload, created_at)
-	VALUES ($1, $2, $3);`
+// Global function versions for backward compatibility. They write to the
+// store set by InitDB or UseStore.
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	_, err := DbPool.ExecContext(ctx, insertSQL, topic, payload, receivedTime)
-	if err != nil {
-		return fmt.Errorf("failed to insert MQTT message: %w", err)
+// SaveMQTTMessage saves MQTT message to database. event, if not nil, is
+// written to the outbox in the same transaction.
+func SaveMQTTMessage(topic string, payload string, receivedTime int64, event *Event) error {
+	if defaultStore == nil {
+		return errors.New("database is not initialized")
 	}
-	return nil
+	return defaultStore.SaveMQTTMessage(topic, payload, receivedTime, event)
 }
 
 // SaveCoapMessage saves CoAP message to database
-func SaveCoapMessage(path string, payload string, method string, receivedTime int64) error {
-	insertSQL := `
-	INSERT INTO coap_messages (path, payload, method, created_at)
-	VALUES ($1, $2, $3, $4);`
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	_, err := DbPool.ExecContext(ctx, insertSQL, path, payload, method, receivedTime)
-	if err != nil {
-		return fmt.Errorf("failed to insert CoAP message: %w", err)
+func SaveCoapMessage(path string, payload string, method string, deviceID string, params map[string]string, receivedTime int64, event *Event) error {
+	if defaultStore == nil {
+		return errors.New("database is not initialized")
 	}
-	return nil
+	return defaultStore.SaveCoapMessage(path, payload, method, deviceID, params, receivedTime, event)
 }
 
 // SaveSNMPData saves SNMP data to database
-func SaveSNMPData(host string, data map[string]interface{}, receivedTime int64) error {
-	dataJSON, err := json.Marshal(data)
-	if err != nil {
-		return fmt.Errorf("failed to marshal SNMP data: %w", err)
+func SaveSNMPData(host string, data map[string]interface{}, receivedTime int64, event *Event) error {
+	if defaultStore == nil {
+		return errors.New("database is not initialized")
 	}
-
-	insertSQL := `
-	INSERT INTO snmp_data (host, data, created_at)
-	VALUES ($1, $2, $3);`
-
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	_, err = DbPool.ExecContext(ctx, insertSQL, host, dataJSON, receivedTime)
-	if err != nil {
-		return fmt.Errorf("failed to insert SNMP data: %w", err)
-	}
-	return nil
+	return defaultStore.SaveSNMPData(host, data, receivedTime, event)
 }
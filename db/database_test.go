@@ -83,18 +83,24 @@ func TestTableNames(t *testing.T) {
 	}
 }
 
-// 실제 데이터베이스 연결이 필요한 테스트들은 별도로 분리
+// 저장 함수들을 각 저장소(SQLite, 파일, DB 초기화 시 PostgreSQL)에 대해 테스트
 func TestDatabaseIntegration(t *testing.T) {
-	if DbPool == nil {
-		t.Skip("Database not initialized - skipping integration tests")
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			UseStore(store)
+			defer UseStore(nil)
+			testSaveFunctions(t)
+		})
 	}
+}
 
+func testSaveFunctions(t *testing.T) {
 	t.Run("SaveMQTTMessage", func(t *testing.T) {
 		topic := "test/topic"
 		payload := "test message" // string으로 변경
 		receivedTime := time.Now().UnixNano()
 
-		err := SaveMQTTMessage(topic, payload, receivedTime)
+		err := SaveMQTTMessage(topic, payload, receivedTime, &Event{Topic: "mq.test.topic", Data: map[string]interface{}{"payload": payload}})
 		assert.NoError(t, err)
 	})
 
@@ -104,7 +110,7 @@ func TestDatabaseIntegration(t *testing.T) {
 		method := "POST"
 		receivedTime := time.Now().UnixNano()
 
-		err := SaveCoapMessage(path, payload, method, receivedTime)
+		err := SaveCoapMessage(path, payload, method, "", nil, receivedTime, nil)
 		assert.NoError(t, err)
 	})
 
@@ -116,7 +122,12 @@ func TestDatabaseIntegration(t *testing.T) {
 		}
 		timestamp := time.Now().UnixNano()
 
-		err := SaveSNMPData(host, data, timestamp)
+		err := SaveSNMPData(host, data, timestamp, nil)
+		assert.NoError(t, err)
+	})
+
+	t.Run("SaveToDBWithAuth", func(t *testing.T) {
+		err := SaveToDBWithAuth("/api/test", map[string]interface{}{"value": 1}, map[string]string{"id": "7"}, map[string]interface{}{"subject": "tester"})
 		assert.NoError(t, err)
 	})
 }
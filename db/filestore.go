@@ -0,0 +1,80 @@
+package db
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+// FileStore appends each message as one JSON line to a file, for
+// deployments that only need a local record. Every line holds the table
+// name and the row's columns; JSON columns are embedded as JSON. There is
+// no outbox, so Kafka events are published right after their line is
+// written and are lost if publishing fails.
+type FileStore struct {
+	rowStore
+	publisher Publisher
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenFileStore opens path for appending, creating it if needed. Events are
+// published through publisher; a nil publisher drops them.
+func OpenFileStore(path string, publisher Publisher) (*FileStore, error) {
+	if path == "" {
+		return nil, errors.New("file store needs a file path")
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open data file: %w", err)
+	}
+
+	s := &FileStore{publisher: publisher, file: f}
+	s.rowStore = rowStore{write: s.append}
+	return s, nil
+}
+
+// append writes ins as one line and then publishes its event.
+func (s *FileStore) append(ins Insert) error {
+	if err := validateInsert(ins); err != nil {
+		return err
+	}
+
+	line := map[string]interface{}{"table": ins.Table}
+	for i, col := range ins.Columns {
+		v := ins.Values[i]
+		if str, ok := v.(string); ok && jsonColumns[col] {
+			v = json.RawMessage(str)
+		}
+		line[col] = v
+	}
+	data, err := json.Marshal(line)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s row: %w", ins.Table, err)
+	}
+
+	s.mu.Lock()
+	_, err = s.file.Write(append(data, '\n'))
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if ins.Event != nil && s.publisher != nil {
+		if err := s.publisher.Publish(ins.Event.Topic, ins.Event.Data); err != nil {
+			log.Printf("File store: failed to publish event to %s: %v", ins.Event.Topic, err)
+		}
+	}
+	return nil
+}
+
+// Close closes the file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
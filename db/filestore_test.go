@@ -0,0 +1,85 @@
+package db
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingPublisher struct {
+	topics []string
+	err    error
+}
+
+func (p *recordingPublisher) Publish(topic string, data map[string]interface{}) error {
+	p.topics = append(p.topics, topic)
+	return p.err
+}
+
+func readLines(t *testing.T, path string) []map[string]interface{} {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var lines []map[string]interface{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal(sc.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	require.NoError(t, sc.Err())
+	return lines
+}
+
+func TestFileStore(t *testing.T) {
+	t.Run("appends one line per row", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "data.jsonl")
+		pub := &recordingPublisher{}
+		s, err := OpenFileStore(path, pub)
+		require.NoError(t, err)
+
+		require.NoError(t, s.SaveMQTTMessage("a/b", "hello", 42, &Event{Topic: "mq.a.b"}))
+		require.NoError(t, s.SaveSNMPData("10.0.0.1", map[string]interface{}{"x": 1}, 43, nil))
+		require.NoError(t, s.Close())
+
+		lines := readLines(t, path)
+		require.Len(t, lines, 2)
+		assert.Equal(t, map[string]interface{}{"table": "mqtt_messages", "topic": "a/b", "payload": "hello", "created_at": float64(42)}, lines[0])
+		// JSON columns are embedded, not quoted
+		assert.Equal(t, map[string]interface{}{"x": float64(1)}, lines[1]["data"])
+
+		assert.Equal(t, []string{"mq.a.b"}, pub.topics)
+	})
+
+	t.Run("keeps existing lines", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "data.jsonl")
+		for i := 0; i < 2; i++ {
+			s, err := OpenFileStore(path, nil)
+			require.NoError(t, err)
+			require.NoError(t, s.SaveCoapMessage("/c", "body", "POST", "", nil, int64(i), nil))
+			require.NoError(t, s.Close())
+		}
+		assert.Len(t, readLines(t, path), 2)
+	})
+
+	t.Run("publish failure does not fail the save", func(t *testing.T) {
+		s, err := OpenFileStore(filepath.Join(t.TempDir(), "data.jsonl"), &recordingPublisher{err: errors.New("broker down")})
+		require.NoError(t, err)
+		defer s.Close()
+		assert.NoError(t, s.SaveMQTTMessage("a", "p", 1, &Event{Topic: "t"}))
+	})
+
+	t.Run("rejects unknown tables", func(t *testing.T) {
+		s, err := OpenFileStore(filepath.Join(t.TempDir(), "data.jsonl"), nil)
+		require.NoError(t, err)
+		defer s.Close()
+		assert.Error(t, s.append(Insert{Table: "users", Columns: []string{"name"}, Values: []interface{}{"x"}}))
+	})
+}
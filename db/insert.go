@@ -0,0 +1,181 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"servone/spool"
+
+	"github.com/lib/pq"
+)
+
+// Insert is a single row insert in a form that can be written to the spool
+// and replayed later. JSON column values are passed as strings. Event, if
+// set, is written to the outbox in the same transaction as the row.
+type Insert struct {
+	Table   string        `json:"table"`
+	Columns []string      `json:"columns"`
+	Values  []interface{} `json:"values"`
+	Event   *Event        `json:"event,omitempty"`
+}
+
+// spoolTables lists the tables an Insert may target. Replayed records come
+// from disk, so the table and column names are never trusted blindly.
+var spoolTables = map[string][]string{
+	"client_data":   {"url", "data", "parameters", "created_at"},
+	"mqtt_messages": {"topic", "payload", "created_at"},
+	"coap_messages": {"path", "payload", "method", "created_at", "device_id", "parameters"},
+	"snmp_data":     {"host", "data", "created_at"},
+}
+
+// dbSpool holds inserts that failed while PostgreSQL was unavailable.
+// It is nil unless UseSpool was called.
+var dbSpool *spool.Queue
+
+// UseSpool makes failed inserts go to the "db" queue of s. The queue is
+// replayed through DbPool in the order the inserts were made.
+func UseSpool(s *spool.Spool) error {
+	q, err := s.Queue("db", replayInsert)
+	if err != nil {
+		return err
+	}
+	dbSpool = q
+	return nil
+}
+
+// WriteInsert executes ins on conn. When the spool is enabled and the insert
+// fails, or earlier inserts are still waiting for replay, ins is spooled
+// instead and spooled reports true. When a batch writer is in use, ins is
+// queued for the next batch instead.
+func WriteInsert(conn *sql.DB, ins Insert) (spooled bool, err error) {
+	if _, ok := spoolTables[ins.Table]; !ok {
+		return false, fmt.Errorf("table %q cannot be written through the spool", ins.Table)
+	}
+
+	if b := dbBatch; b != nil {
+		return false, b.Add(ins)
+	}
+	return writeInsert(conn, ins)
+}
+
+// writeInsert executes ins on conn right away, spooling it if the spool is
+// enabled and the insert fails or earlier inserts are waiting for replay.
+func writeInsert(conn *sql.DB, ins Insert) (spooled bool, err error) {
+	q := dbSpool
+	if q == nil {
+		return false, execInsert(conn, ins)
+	}
+
+	return q.Write(ins, func() error { return classify(execInsert(conn, ins)) })
+}
+
+// classify marks errors caused by the row itself (data exceptions, integrity
+// violations) as permanent so they are reported instead of spooled forever.
+// Everything else, connection failures included, is worth retrying.
+func classify(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "22", "23":
+			return spool.Permanent(err)
+		}
+	}
+	return err
+}
+
+// replayInsert is the spool delivery func for the "db" queue.
+func replayInsert(data json.RawMessage) error {
+	var ins Insert
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&ins); err != nil {
+		return spool.Permanent(fmt.Errorf("corrupt spooled insert: %w", err))
+	}
+	if _, ok := spoolTables[ins.Table]; !ok {
+		return spool.Permanent(fmt.Errorf("spooled insert targets unknown table %q", ins.Table))
+	}
+	if DbPool == nil {
+		return errors.New("database is not initialized")
+	}
+	for i, v := range ins.Values {
+		if n, ok := v.(json.Number); ok {
+			if iv, err := n.Int64(); err == nil {
+				ins.Values[i] = iv
+			}
+		}
+	}
+	return classify(execInsert(DbPool, ins))
+}
+
+// validateInsert checks the table, columns and values of ins. Malformed
+// inserts fail with a Permanent error.
+func validateInsert(ins Insert) error {
+	allowed, ok := spoolTables[ins.Table]
+	if !ok {
+		return spool.Permanent(fmt.Errorf("insert into unknown table %q", ins.Table))
+	}
+	if len(ins.Columns) == 0 || len(ins.Columns) != len(ins.Values) {
+		return spool.Permanent(fmt.Errorf("insert into %s: %d columns, %d values", ins.Table, len(ins.Columns), len(ins.Values)))
+	}
+	for _, col := range ins.Columns {
+		if !contains(allowed, col) {
+			return spool.Permanent(fmt.Errorf("insert into %s: unknown column %q", ins.Table, col))
+		}
+	}
+	return nil
+}
+
+// execInsert runs ins on conn. Malformed inserts fail with a Permanent error.
+func execInsert(conn *sql.DB, ins Insert) error {
+	if err := validateInsert(ins); err != nil {
+		return err
+	}
+	placeholders := make([]string, len(ins.Columns))
+	for i := range ins.Columns {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	if conn == nil {
+		return errors.New("database is not initialized")
+	}
+
+	insertSQL := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);",
+		ins.Table, strings.Join(ins.Columns, ", "), strings.Join(placeholders, ", "))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if ins.Event == nil {
+		_, err := conn.ExecContext(ctx, insertSQL, ins.Values...)
+		return err
+	}
+
+	// the row and its Kafka event are committed together or not at all
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, insertSQL, ins.Values...); err != nil {
+		return err
+	}
+	if err := writeOutbox(ctx, tx, ins.Event); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
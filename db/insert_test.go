@@ -0,0 +1,46 @@
+package db
+
+import (
+	"encoding/json"
+	"testing"
+
+	"servone/spool"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteInsertValidation(t *testing.T) {
+	t.Run("unknown table", func(t *testing.T) {
+		_, err := WriteInsert(nil, Insert{Table: "users", Columns: []string{"name"}, Values: []interface{}{"x"}})
+		assert.ErrorContains(t, err, "cannot be written through the spool")
+	})
+
+	t.Run("unknown column", func(t *testing.T) {
+		err := execInsert(nil, Insert{Table: "mqtt_messages", Columns: []string{"topic; DROP TABLE x"}, Values: []interface{}{"x"}})
+		assert.ErrorContains(t, err, "unknown column")
+		assert.True(t, spool.IsPermanent(err))
+	})
+
+	t.Run("column and value count mismatch", func(t *testing.T) {
+		err := execInsert(nil, Insert{Table: "mqtt_messages", Columns: []string{"topic", "payload"}, Values: []interface{}{"x"}})
+		assert.True(t, spool.IsPermanent(err))
+	})
+}
+
+func TestReplayInsert(t *testing.T) {
+	t.Run("corrupt records are discarded", func(t *testing.T) {
+		err := replayInsert(json.RawMessage(`{"table":`))
+		assert.True(t, spool.IsPermanent(err))
+
+		err = replayInsert(json.RawMessage(`{"table":"users","columns":["name"],"values":["x"]}`))
+		assert.True(t, spool.IsPermanent(err))
+	})
+}
+
+func TestClassify(t *testing.T) {
+	assert.True(t, spool.IsPermanent(classify(&pq.Error{Code: "23505"})), "unique violation")
+	assert.True(t, spool.IsPermanent(classify(&pq.Error{Code: "22P02"})), "invalid text representation")
+	assert.False(t, spool.IsPermanent(classify(&pq.Error{Code: "08006"})), "connection failure")
+	assert.Nil(t, classify(nil))
+}
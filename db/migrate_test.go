@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	t.Run("pairs up and down files in version order", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"0002_add_device.up.sql":   {Data: []byte("ALTER TABLE t ADD device_id TEXT;")},
+			"0002_add_device.down.sql": {Data: []byte("ALTER TABLE t DROP device_id;")},
+			"0001_create.up.sql":       {Data: []byte("CREATE TABLE t (id INT);")},
+			"0001_create.down.sql":     {Data: []byte("DROP TABLE t;")},
+			"README.md":                {Data: []byte("ignored")},
+		}
+		migrations, err := loadMigrations(fsys)
+		require.NoError(t, err)
+		require.Len(t, migrations, 2)
+		assert.Equal(t, int64(1), migrations[0].Version)
+		assert.Equal(t, "create", migrations[0].Name)
+		assert.Equal(t, "DROP TABLE t;", migrations[0].Down)
+		assert.Equal(t, int64(2), migrations[1].Version)
+		assert.Equal(t, "ALTER TABLE t ADD device_id TEXT;", migrations[1].Up)
+	})
+
+	t.Run("rejects invalid sets", func(t *testing.T) {
+		tests := map[string]fstest.MapFS{
+			"missing down": {
+				"0001_create.up.sql": {Data: []byte("CREATE TABLE t (id INT);")},
+			},
+			"two names for one version": {
+				"0001_create.up.sql":  {Data: []byte("CREATE TABLE t (id INT);")},
+				"0001_other.down.sql": {Data: []byte("DROP TABLE t;")},
+			},
+			"bad file name": {
+				"create_table.sql": {Data: []byte("CREATE TABLE t (id INT);")},
+			},
+			"version zero": {
+				"0000_create.up.sql":   {Data: []byte("CREATE TABLE t (id INT);")},
+				"0000_create.down.sql": {Data: []byte("DROP TABLE t;")},
+			},
+		}
+		for name, fsys := range tests {
+			t.Run(name, func(t *testing.T) {
+				_, err := loadMigrations(fsys)
+				assert.Error(t, err)
+			})
+		}
+	})
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	require.NoError(t, err)
+	require.NotEmpty(t, migrations)
+
+	for i, m := range migrations {
+		assert.Equal(t, int64(i+1), m.Version, "versions are contiguous from 1")
+		assert.NotEmpty(t, m.Up)
+		assert.NotEmpty(t, m.Down)
+	}
+}
+
+func TestMigratorIntegration(t *testing.T) {
+	if DbPool == nil {
+		t.Skip("Database not initialized - skipping integration tests")
+	}
+
+	m, err := NewMigrator(DbPool)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	for _, st := range statuses {
+		assert.NotNil(t, st.AppliedAt, "%d_%s", st.Version, st.Name)
+	}
+
+	// applying again is a no-op
+	applied, err := m.Up(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+}
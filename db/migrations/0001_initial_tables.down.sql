@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS snmp_data;
+DROP TABLE IF EXISTS coap_messages;
+DROP TABLE IF EXISTS mqtt_messages;
+DROP TABLE IF EXISTS client_data;
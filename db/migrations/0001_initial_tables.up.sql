@@ -0,0 +1,31 @@
+-- Tables that existed before versioned migrations. IF NOT EXISTS lets
+-- databases created by the old SetupDatabase adopt this version as is.
+CREATE TABLE IF NOT EXISTS client_data (
+	id SERIAL PRIMARY KEY,
+	url TEXT NOT NULL,
+	data JSONB,
+	parameters JSONB,
+	created_at BIGINT
+);
+
+CREATE TABLE IF NOT EXISTS mqtt_messages (
+	id SERIAL PRIMARY KEY,
+	topic TEXT NOT NULL,
+	payload TEXT,
+	created_at BIGINT
+);
+
+CREATE TABLE IF NOT EXISTS coap_messages (
+	id SERIAL PRIMARY KEY,
+	path TEXT NOT NULL,
+	payload TEXT,
+	method TEXT,
+	created_at BIGINT
+);
+
+CREATE TABLE IF NOT EXISTS snmp_data (
+	id SERIAL PRIMARY KEY,
+	host TEXT NOT NULL,
+	data JSONB,
+	created_at BIGINT
+);
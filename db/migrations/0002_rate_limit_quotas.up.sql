@@ -0,0 +1,8 @@
+-- One usage counter per scope, client key and UTC day
+CREATE TABLE IF NOT EXISTS rate_limit_quotas (
+	scope TEXT NOT NULL,
+	client_key TEXT NOT NULL,
+	day DATE NOT NULL,
+	count BIGINT NOT NULL,
+	PRIMARY KEY (scope, client_key, day)
+);
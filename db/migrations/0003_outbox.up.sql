@@ -0,0 +1,14 @@
+-- Kafka events waiting to be published. Rows whose sent_at is set are kept
+-- until the relay's retention expires.
+CREATE TABLE IF NOT EXISTS outbox (
+	id BIGSERIAL PRIMARY KEY,
+	topic TEXT NOT NULL,
+	payload JSONB NOT NULL,
+	created_at BIGINT NOT NULL,
+	attempts INT NOT NULL DEFAULT 0,
+	next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	last_error TEXT,
+	sent_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (next_attempt_at) WHERE sent_at IS NULL;
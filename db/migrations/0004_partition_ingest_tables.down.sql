@@ -0,0 +1,23 @@
+-- Copy the partitioned tables back into plain tables.
+CREATE FUNCTION pg_temp.servone_unpartition_table(t text) RETURNS void AS $$
+DECLARE
+	partitioned text := t || '_partitioned';
+	seq text;
+BEGIN
+	seq := pg_get_serial_sequence(t, 'id');
+	EXECUTE format('ALTER TABLE %I RENAME TO %I', t, partitioned);
+	EXECUTE format('CREATE TABLE %I (LIKE %I INCLUDING DEFAULTS)', t, partitioned);
+	EXECUTE format('INSERT INTO %I SELECT * FROM %I', t, partitioned);
+	EXECUTE format('ALTER SEQUENCE %s OWNED BY %I.id', seq, t);
+	EXECUTE format('DROP TABLE %I', partitioned);
+	EXECUTE format('ALTER TABLE %I ADD PRIMARY KEY (id)', t);
+	EXECUTE format('ALTER TABLE %I ALTER COLUMN created_at DROP NOT NULL', t);
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT pg_temp.servone_unpartition_table('client_data');
+SELECT pg_temp.servone_unpartition_table('mqtt_messages');
+SELECT pg_temp.servone_unpartition_table('coap_messages');
+SELECT pg_temp.servone_unpartition_table('snmp_data');
+
+DROP FUNCTION pg_temp.servone_unpartition_table(text);
@@ -0,0 +1,37 @@
+-- Convert the ingest tables to tables range-partitioned by created_at (Unix
+-- nanoseconds), one partition per UTC day. The existing table of each is
+-- kept as its first partition, "<table>_legacy", covering everything up to
+-- the end of the current day; the partition maintenance job creates the
+-- following days and drops partitions past their retention.
+CREATE FUNCTION pg_temp.servone_partition_table(t text) RETURNS void AS $$
+DECLARE
+	legacy text := t || '_legacy';
+	seq text;
+	upper_ns numeric;
+BEGIN
+	EXECUTE format('ALTER TABLE %I RENAME TO %I', t, legacy);
+	EXECUTE format('ALTER TABLE %I RENAME CONSTRAINT %I TO %I', legacy, t || '_pkey', legacy || '_pkey');
+
+	-- rows without a timestamp cannot be routed to a range partition
+	EXECUTE format('UPDATE %I SET created_at = 0 WHERE created_at IS NULL', legacy);
+	EXECUTE format('ALTER TABLE %I ALTER COLUMN created_at SET NOT NULL', legacy);
+
+	-- the primary key of a partitioned table has to include the partition key
+	EXECUTE format('CREATE TABLE %I (LIKE %I INCLUDING DEFAULTS, PRIMARY KEY (id, created_at)) PARTITION BY RANGE (created_at)', t, legacy);
+
+	-- keep the id sequence alive when the legacy partition is dropped
+	seq := pg_get_serial_sequence(legacy, 'id');
+	EXECUTE format('ALTER SEQUENCE %s OWNED BY %I.id', seq, t);
+
+	EXECUTE format('SELECT greatest(coalesce(max(created_at), 0), (extract(epoch FROM now()) * 1e9)::bigint) FROM %I', legacy) INTO upper_ns;
+	upper_ns := (floor(upper_ns / 86400e9) + 1) * 86400e9;
+	EXECUTE format('ALTER TABLE %I ATTACH PARTITION %I FOR VALUES FROM (MINVALUE) TO (%s)', t, legacy, upper_ns::bigint);
+END;
+$$ LANGUAGE plpgsql;
+
+SELECT pg_temp.servone_partition_table('client_data');
+SELECT pg_temp.servone_partition_table('mqtt_messages');
+SELECT pg_temp.servone_partition_table('coap_messages');
+SELECT pg_temp.servone_partition_table('snmp_data');
+
+DROP FUNCTION pg_temp.servone_partition_table(text);
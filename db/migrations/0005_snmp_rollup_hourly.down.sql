@@ -0,0 +1 @@
+DROP TABLE IF EXISTS snmp_rollup_hourly;
@@ -0,0 +1,12 @@
+-- Hourly aggregates of numeric SNMP values, kept after the detail rows in
+-- snmp_data are dropped by retention.
+CREATE TABLE IF NOT EXISTS snmp_rollup_hourly (
+	host TEXT NOT NULL,
+	oid TEXT NOT NULL,
+	hour TIMESTAMPTZ NOT NULL,
+	min_value DOUBLE PRECISION NOT NULL,
+	max_value DOUBLE PRECISION NOT NULL,
+	avg_value DOUBLE PRECISION NOT NULL,
+	samples BIGINT NOT NULL,
+	PRIMARY KEY (host, oid, hour)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS coap_psk;
+ALTER TABLE coap_messages DROP COLUMN IF EXISTS device_id;
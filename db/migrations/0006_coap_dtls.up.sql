@@ -0,0 +1,9 @@
+-- Device authenticated by DTLS for each CoAP message (NULL over plain CoAP).
+ALTER TABLE coap_messages ADD COLUMN IF NOT EXISTS device_id TEXT;
+
+-- Pre-shared keys of the coaps listener. psk is hex encoded.
+CREATE TABLE IF NOT EXISTS coap_psk (
+	identity TEXT PRIMARY KEY,
+	psk TEXT NOT NULL,
+	device_id TEXT NOT NULL
+);
@@ -0,0 +1 @@
+ALTER TABLE coap_messages DROP COLUMN IF EXISTS parameters;
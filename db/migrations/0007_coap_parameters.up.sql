@@ -0,0 +1,2 @@
+-- Variables captured from templated CoAP paths such as /sensors/{id}.
+ALTER TABLE coap_messages ADD COLUMN IF NOT EXISTS parameters JSONB;
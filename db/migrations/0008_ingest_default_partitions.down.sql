@@ -0,0 +1,19 @@
+-- Refuse to drop rows that have not been moved into a daily partition yet.
+DO $$
+DECLARE
+	t text;
+	n bigint;
+BEGIN
+	FOREACH t IN ARRAY ARRAY['client_data', 'mqtt_messages', 'coap_messages', 'snmp_data'] LOOP
+		EXECUTE format('SELECT count(*) FROM %I', t || '_default') INTO n;
+		IF n > 0 THEN
+			RAISE EXCEPTION '% still holds % rows; run partition maintenance first', t || '_default', n;
+		END IF;
+	END LOOP;
+END;
+$$;
+
+DROP TABLE IF EXISTS client_data_default;
+DROP TABLE IF EXISTS mqtt_messages_default;
+DROP TABLE IF EXISTS coap_messages_default;
+DROP TABLE IF EXISTS snmp_data_default;
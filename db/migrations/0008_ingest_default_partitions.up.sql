@@ -0,0 +1,8 @@
+-- Give each ingest table a DEFAULT partition so rows whose day has no
+-- partition yet (the maintenance job fell behind or servone was stopped
+-- longer than premake_days) are still stored. The maintenance job moves
+-- them into daily partitions on its next pass.
+CREATE TABLE IF NOT EXISTS client_data_default PARTITION OF client_data DEFAULT;
+CREATE TABLE IF NOT EXISTS mqtt_messages_default PARTITION OF mqtt_messages DEFAULT;
+CREATE TABLE IF NOT EXISTS coap_messages_default PARTITION OF coap_messages DEFAULT;
+CREATE TABLE IF NOT EXISTS snmp_data_default PARTITION OF snmp_data DEFAULT;
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Event is a Kafka message that is stored in the outbox table in the same
+// transaction as the row it describes. The outbox relay publishes it later.
+type Event struct {
+	Topic string                 `json:"topic"`
+	Data  map[string]interface{} `json:"data"`
+}
+
+// insertOutboxSQL adds an event to the outbox
+const insertOutboxSQL = `
+	INSERT INTO outbox (topic, payload, created_at)
+	VALUES ($1, $2, $3);`
+
+// writeOutbox stores ev inside tx.
+func writeOutbox(ctx context.Context, tx *sql.Tx, ev *Event) error {
+	payload, err := json.Marshal(ev.Data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal outbox event: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, insertOutboxSQL, ev.Topic, string(payload), time.Now().UnixNano()); err != nil {
+		return fmt.Errorf("failed to insert outbox event: %w", err)
+	}
+	return nil
+}
+
+// OutboxEntry is an outbox event claimed for publishing.
+type OutboxEntry struct {
+	ID       int64
+	Topic    string
+	Payload  json.RawMessage
+	Attempts int
+}
+
+// claimOutboxSQL leases due entries by pushing next_attempt_at past the
+// lease, so a relay that dies mid-batch only delays them. SKIP LOCKED lets
+// several servone instances share one outbox.
+const claimOutboxSQL = `
+	UPDATE outbox SET next_attempt_at = now() + make_interval(secs => $2)
+	WHERE id IN (
+		SELECT id FROM outbox
+		WHERE sent_at IS NULL AND next_attempt_at <= now()
+		ORDER BY id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING id, topic, payload, attempts;`
+
+// OutboxStore gives the outbox relay access to the outbox table.
+type OutboxStore struct {
+	conn *sql.DB
+}
+
+// NewOutboxStore creates an OutboxStore on conn.
+func NewOutboxStore(conn *sql.DB) *OutboxStore {
+	return &OutboxStore{conn: conn}
+}
+
+// Claim leases up to limit due entries for lease and returns them in id order.
+func (s *OutboxStore) Claim(ctx context.Context, limit int, lease time.Duration) ([]OutboxEntry, error) {
+	rows, err := s.conn.QueryContext(ctx, claimOutboxSQL, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []OutboxEntry
+	for rows.Next() {
+		var e OutboxEntry
+		var payload []byte
+		if err := rows.Scan(&e.ID, &e.Topic, &payload, &e.Attempts); err != nil {
+			return nil, fmt.Errorf("failed to read outbox entry: %w", err)
+		}
+		e.Payload = payload
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read outbox entries: %w", err)
+	}
+	sortOutboxEntries(entries)
+	return entries, nil
+}
+
+// sortOutboxEntries puts claimed entries in id order; RETURNING does not
+// guarantee one.
+func sortOutboxEntries(entries []OutboxEntry) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].ID < entries[j].ID })
+}
+
+// MarkSent records that the entries were published.
+func (s *OutboxStore) MarkSent(ctx context.Context, ids []int64) error {
+	_, err := s.conn.ExecContext(ctx, `UPDATE outbox SET sent_at = now(), last_error = NULL WHERE id = ANY($1);`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox entries sent: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed records a failed publish and schedules the next attempt.
+func (s *OutboxStore) MarkFailed(ctx context.Context, id int64, reason string, retryAt time.Time) error {
+	_, err := s.conn.ExecContext(ctx,
+		`UPDATE outbox SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3 WHERE id = $1;`,
+		id, reason, retryAt)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox entry failed: %w", err)
+	}
+	return nil
+}
+
+// DeleteSent removes entries published before the given time.
+func (s *OutboxStore) DeleteSent(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.conn.ExecContext(ctx, `DELETE FROM outbox WHERE sent_at IS NOT NULL AND sent_at < $1;`, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete sent outbox entries: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// Pending counts entries not yet published.
+func (s *OutboxStore) Pending(ctx context.Context) (int64, error) {
+	var n int64
+	err := s.conn.QueryRowContext(ctx, `SELECT count(*) FROM outbox WHERE sent_at IS NULL;`).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count pending outbox entries: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,265 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// PartitionedTables are the ingest tables range-partitioned by created_at
+// (Unix nanoseconds), one partition per UTC day.
+var PartitionedTables = []string{"client_data", "mqtt_messages", "coap_messages", "snmp_data"}
+
+// RollupTable is the partitioned table whose numeric values can be rolled
+// up into snmp_rollup_hourly before its partitions are dropped.
+const RollupTable = "snmp_data"
+
+// Partition is one partition of a partitioned table. From is inclusive and
+// To exclusive; a MINVALUE or MAXVALUE bound is math.MinInt64 or
+// math.MaxInt64. The DEFAULT partition has Default set and no range; it
+// holds the rows no other partition accepts.
+type Partition struct {
+	Name    string
+	From    int64
+	To      int64
+	Default bool
+}
+
+// PartitionName returns the name of the daily partition of table that
+// starts at day.
+func PartitionName(table string, day time.Time) string {
+	return table + "_p" + day.UTC().Format("20060102")
+}
+
+// listPartitionsSQL returns the partitions of a table with their bounds.
+const listPartitionsSQL = `
+	SELECT c.relname, pg_get_expr(c.relpartbound, c.oid)
+	FROM pg_inherits i JOIN pg_class c ON c.oid = i.inhrelid
+	WHERE i.inhparent = $1::regclass
+	ORDER BY c.relname;`
+
+// defaultPartitionSQL returns the DEFAULT partition of a table, if any.
+const defaultPartitionSQL = `
+	SELECT c.relname
+	FROM pg_partitioned_table pt JOIN pg_class c ON c.oid = pt.partdefid
+	WHERE pt.partrelid = $1::regclass;`
+
+// partitionBoundRe matches a range bound as printed by pg_get_expr, e.g.
+// "FOR VALUES FROM ('1700000000000000000') TO ('1700086400000000000')".
+var partitionBoundRe = regexp.MustCompile(`^FOR VALUES FROM \((.+)\) TO \((.+)\)$`)
+
+// parsePartitionBound returns the range of a partition bound expression.
+func parsePartitionBound(expr string) (from, to int64, err error) {
+	m := partitionBoundRe.FindStringSubmatch(expr)
+	if m == nil {
+		return 0, 0, fmt.Errorf("unsupported partition bound %q", expr)
+	}
+	if from, err = parseBoundValue(m[1]); err != nil {
+		return 0, 0, err
+	}
+	if to, err = parseBoundValue(m[2]); err != nil {
+		return 0, 0, err
+	}
+	return from, to, nil
+}
+
+func parseBoundValue(v string) (int64, error) {
+	switch v {
+	case "MINVALUE":
+		return math.MinInt64, nil
+	case "MAXVALUE":
+		return math.MaxInt64, nil
+	}
+	n, err := strconv.ParseInt(strings.Trim(v, "'"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid partition bound value %q", v)
+	}
+	return n, nil
+}
+
+// rollupSNMPSQL aggregates the numeric values of one snmp_data partition
+// into hourly rows, merging with rows already rolled up for the same hour.
+// The partition name is filled in with %s.
+const rollupSNMPSQL = `
+	INSERT INTO snmp_rollup_hourly (host, oid, hour, min_value, max_value, avg_value, samples)
+	SELECT host, r->>'oid', date_trunc('hour', to_timestamp(created_at / 1e9)),
+		min((r->>'value')::double precision), max((r->>'value')::double precision),
+		avg((r->>'value')::double precision), count(*)
+	FROM %s, jsonb_array_elements(CASE WHEN jsonb_typeof(data->'results') = 'array' THEN data->'results' ELSE '[]'::jsonb END) r
+	WHERE r->>'oid' IS NOT NULL AND r->>'value' ~ '^\s*[-+]?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?\s*$'
+	GROUP BY 1, 2, 3
+	ON CONFLICT (host, oid, hour) DO UPDATE SET
+		min_value = least(snmp_rollup_hourly.min_value, excluded.min_value),
+		max_value = greatest(snmp_rollup_hourly.max_value, excluded.max_value),
+		avg_value = (snmp_rollup_hourly.avg_value * snmp_rollup_hourly.samples + excluded.avg_value * excluded.samples)
+			/ (snmp_rollup_hourly.samples + excluded.samples),
+		samples = snmp_rollup_hourly.samples + excluded.samples;`
+
+// PartitionStore creates and drops partitions of the ingest tables.
+type PartitionStore struct {
+	conn *sql.DB
+}
+
+// NewPartitionStore creates a PartitionStore on conn.
+func NewPartitionStore(conn *sql.DB) *PartitionStore {
+	return &PartitionStore{conn: conn}
+}
+
+// Partitions lists the partitions of table.
+func (s *PartitionStore) Partitions(ctx context.Context, table string) ([]Partition, error) {
+	rows, err := s.conn.QueryContext(ctx, listPartitionsSQL, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions of '%s': %w", table, err)
+	}
+	defer rows.Close()
+
+	var parts []Partition
+	for rows.Next() {
+		var p Partition
+		var bound string
+		if err := rows.Scan(&p.Name, &bound); err != nil {
+			return nil, fmt.Errorf("failed to read partition of '%s': %w", table, err)
+		}
+		if bound == "DEFAULT" {
+			p.Default = true
+		} else if p.From, p.To, err = parsePartitionBound(bound); err != nil {
+			return nil, fmt.Errorf("partition %s: %w", p.Name, err)
+		}
+		parts = append(parts, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list partitions of '%s': %w", table, err)
+	}
+	return parts, nil
+}
+
+// CreatePartition creates the daily partition of table starting at day
+// (midnight UTC). It does nothing if the partition already exists. Rows of
+// that day stored in the DEFAULT partition are moved into the new partition
+// in the same transaction.
+func (s *PartitionStore) CreatePartition(ctx context.Context, table string, day time.Time) (Partition, error) {
+	day = day.UTC()
+	p := Partition{
+		Name: PartitionName(table, day),
+		From: day.UnixNano(),
+		To:   day.AddDate(0, 0, 1).UnixNano(),
+	}
+
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return Partition{}, err
+	}
+	defer tx.Rollback()
+
+	var def string
+	err = tx.QueryRowContext(ctx, defaultPartitionSQL, table).Scan(&def)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return Partition{}, fmt.Errorf("failed to find default partition of '%s': %w", table, err)
+	}
+
+	parent := pq.QuoteIdentifier(table)
+	if def != "" {
+		// PostgreSQL refuses a new partition while the default partition
+		// holds rows of its range, so they are set aside and re-inserted
+		// through the parent once the partition exists. The lock keeps new
+		// rows of that day from landing in the default partition meanwhile.
+		steps := []string{
+			`LOCK TABLE ` + pq.QuoteIdentifier(def) + ` IN ACCESS EXCLUSIVE MODE;`,
+			`CREATE TEMP TABLE servone_moved_rows (LIKE ` + parent + `) ON COMMIT DROP;`,
+			fmt.Sprintf(`WITH moved AS (DELETE FROM %s WHERE created_at >= %d AND created_at < %d RETURNING *)
+				INSERT INTO servone_moved_rows SELECT * FROM moved;`, pq.QuoteIdentifier(def), p.From, p.To),
+		}
+		for _, step := range steps {
+			if _, err := tx.ExecContext(ctx, step); err != nil {
+				return Partition{}, fmt.Errorf("failed to move default rows into %s: %w", p.Name, err)
+			}
+		}
+	}
+
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM (%d) TO (%d);`,
+		pq.QuoteIdentifier(p.Name), parent, p.From, p.To)
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return Partition{}, fmt.Errorf("failed to create partition %s: %w", p.Name, err)
+	}
+	if def != "" {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO `+parent+` SELECT * FROM servone_moved_rows;`); err != nil {
+			return Partition{}, fmt.Errorf("failed to move default rows into %s: %w", p.Name, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return Partition{}, fmt.Errorf("failed to create partition %s: %w", p.Name, err)
+	}
+	return p, nil
+}
+
+// DefaultDays returns the UTC days of the rows held by the DEFAULT
+// partition def, i.e. the days that still need a partition of their own.
+func (s *PartitionStore) DefaultDays(ctx context.Context, def Partition) ([]time.Time, error) {
+	query := fmt.Sprintf(`SELECT DISTINCT floor(created_at / %d::numeric)::bigint FROM %s ORDER BY 1;`,
+		int64(24*time.Hour), pq.QuoteIdentifier(def.Name))
+	rows, err := s.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read days of %s: %w", def.Name, err)
+	}
+	defer rows.Close()
+
+	var days []time.Time
+	for rows.Next() {
+		var n int64
+		if err := rows.Scan(&n); err != nil {
+			return nil, fmt.Errorf("failed to read days of %s: %w", def.Name, err)
+		}
+		days = append(days, time.Unix(0, n*int64(24*time.Hour)).UTC())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read days of %s: %w", def.Name, err)
+	}
+	return days, nil
+}
+
+// DropPartition drops a partition of table. With rollup, the numeric SNMP
+// values of the partition are first aggregated into snmp_rollup_hourly in
+// the same transaction; it returns the number of hourly rows written.
+func (s *PartitionStore) DropPartition(ctx context.Context, table string, p Partition, rollup bool) (int64, error) {
+	if rollup && table != RollupTable {
+		return 0, fmt.Errorf("rollup is only supported for '%s'", RollupTable)
+	}
+
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	name := pq.QuoteIdentifier(p.Name)
+	// another instance dropping the same partition makes this fail instead
+	// of rolling it up twice
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE `+name+` IN ACCESS EXCLUSIVE MODE;`); err != nil {
+		return 0, fmt.Errorf("failed to lock partition %s: %w", p.Name, err)
+	}
+
+	var rolledUp int64
+	if rollup {
+		res, err := tx.ExecContext(ctx, fmt.Sprintf(rollupSNMPSQL, name))
+		if err != nil {
+			return 0, fmt.Errorf("failed to roll up partition %s: %w", p.Name, err)
+		}
+		rolledUp, _ = res.RowsAffected()
+	}
+
+	if _, err := tx.ExecContext(ctx, `DROP TABLE `+name+`;`); err != nil {
+		return 0, fmt.Errorf("failed to drop partition %s: %w", p.Name, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to drop partition %s: %w", p.Name, err)
+	}
+	return rolledUp, nil
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartitionName(t *testing.T) {
+	day := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, "snmp_data_p20261016", PartitionName("snmp_data", day))
+	assert.Equal(t, "snmp_data_p20261016", PartitionName("snmp_data", day.In(time.FixedZone("KST", 9*3600))), "names use the UTC day")
+}
+
+func TestParsePartitionBound(t *testing.T) {
+	t.Run("parses range bounds", func(t *testing.T) {
+		tests := map[string][2]int64{
+			"FOR VALUES FROM ('1700000000000000000') TO ('1700086400000000000')": {1700000000000000000, 1700086400000000000},
+			"FOR VALUES FROM (MINVALUE) TO ('1700086400000000000')":              {math.MinInt64, 1700086400000000000},
+			"FOR VALUES FROM ('0') TO (MAXVALUE)":                                {0, math.MaxInt64},
+		}
+		for expr, want := range tests {
+			from, to, err := parsePartitionBound(expr)
+			require.NoError(t, err, expr)
+			assert.Equal(t, want, [2]int64{from, to}, expr)
+		}
+	})
+
+	t.Run("rejects other bounds", func(t *testing.T) {
+		for _, expr := range []string{"DEFAULT", "FOR VALUES IN ('a')", "FOR VALUES FROM ('x') TO ('1')"} {
+			_, _, err := parsePartitionBound(expr)
+			assert.Error(t, err, expr)
+		}
+	})
+}
+
+func TestPartitionStoreIntegration(t *testing.T) {
+	if DbPool == nil {
+		t.Skip("Database not initialized - skipping integration tests")
+	}
+
+	ctx := context.Background()
+	s := NewPartitionStore(DbPool)
+	day := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 30)
+
+	p, err := s.CreatePartition(ctx, "client_data", day)
+	require.NoError(t, err)
+	_, err = s.CreatePartition(ctx, "client_data", day)
+	require.NoError(t, err, "creating an existing partition is a no-op")
+
+	parts, err := s.Partitions(ctx, "client_data")
+	require.NoError(t, err)
+	assert.Contains(t, parts, p)
+
+	_, err = s.DropPartition(ctx, "client_data", p, false)
+	require.NoError(t, err)
+
+	t.Run("rows in the default partition move to a new partition", func(t *testing.T) {
+		day := day.AddDate(0, 0, 10)
+		_, err := DbPool.Exec(`INSERT INTO client_data (url, created_at) VALUES ('/default', $1)`, day.Add(time.Hour).UnixNano())
+		require.NoError(t, err)
+
+		parts, err := s.Partitions(ctx, "client_data")
+		require.NoError(t, err)
+		var def Partition
+		for _, p := range parts {
+			if p.Default {
+				def = p
+			}
+		}
+		require.Equal(t, "client_data_default", def.Name)
+		days, err := s.DefaultDays(ctx, def)
+		require.NoError(t, err)
+		assert.Contains(t, days, day)
+
+		p, err := s.CreatePartition(ctx, "client_data", day)
+		require.NoError(t, err)
+		var n int
+		require.NoError(t, DbPool.QueryRow(`SELECT count(*) FROM `+p.Name+` WHERE url = '/default'`).Scan(&n))
+		assert.Equal(t, 1, n)
+		days, err = s.DefaultDays(ctx, def)
+		require.NoError(t, err)
+		assert.NotContains(t, days, day)
+
+		_, err = s.DropPartition(ctx, "client_data", p, false)
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+)
+
+// PSK is a pre-shared key of the CoAP DTLS listener and the device it
+// authenticates.
+type PSK struct {
+	Identity string
+	Key      []byte
+	DeviceID string
+}
+
+// PSKSource is implemented by stores that keep pre-shared keys in the
+// coap_psk table.
+type PSKSource interface {
+	LoadPSKs(ctx context.Context) ([]PSK, error)
+}
+
+// LoadPSKs reads the coap_psk table.
+func (s *PostgresStore) LoadPSKs(ctx context.Context) ([]PSK, error) {
+	return loadPSKs(ctx, s.conn)
+}
+
+// LoadPSKs reads the coap_psk table.
+func (s *SQLiteStore) LoadPSKs(ctx context.Context) ([]PSK, error) {
+	return loadPSKs(ctx, s.conn)
+}
+
+func loadPSKs(ctx context.Context, conn *sql.DB) ([]PSK, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT identity, psk, device_id FROM coap_psk ORDER BY identity;`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query coap_psk: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []PSK
+	for rows.Next() {
+		var k PSK
+		var key string
+		if err := rows.Scan(&k.Identity, &key, &k.DeviceID); err != nil {
+			return nil, err
+		}
+		if k.Key, err = hex.DecodeString(key); err != nil {
+			return nil, fmt.Errorf("coap_psk %q: psk is not hex encoded: %w", k.Identity, err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
@@ -0,0 +1,35 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPSKs(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("reads keys in identity order", func(t *testing.T) {
+		s := openTestSQLite(t)
+		_, err := s.conn.Exec(`INSERT INTO coap_psk (identity, psk, device_id) VALUES ('sensor-b', '0102', 'dev-b'), ('sensor-a', 'abcd', 'dev-a');`)
+		require.NoError(t, err)
+
+		keys, err := s.LoadPSKs(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []PSK{
+			{Identity: "sensor-a", Key: []byte{0xab, 0xcd}, DeviceID: "dev-a"},
+			{Identity: "sensor-b", Key: []byte{0x01, 0x02}, DeviceID: "dev-b"},
+		}, keys)
+	})
+
+	t.Run("rejects keys that are not hex", func(t *testing.T) {
+		s := openTestSQLite(t)
+		_, err := s.conn.Exec(`INSERT INTO coap_psk (identity, psk, device_id) VALUES ('sensor', 'secret', 'dev');`)
+		require.NoError(t, err)
+
+		_, err = s.LoadPSKs(ctx)
+		assert.ErrorContains(t, err, "not hex encoded")
+	})
+}
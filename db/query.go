@@ -0,0 +1,280 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 데이터 조회가 반환하는 행 수 제한
+const (
+	DefaultQueryLimit = 100
+	MaxQueryLimit     = 1000
+)
+
+// DataSource는 조회할 수집 테이블 하나를 나타냅니다.
+type DataSource struct {
+	Name      string   // 조회 API 에서 쓰는 이름
+	Table     string   // 행을 읽는 테이블
+	KeyColumn string   // DataQuery.Key 로 거르는 열
+	Columns   []string // id, created_at 외에 반환하는 열
+	// JSONColumn은 필드 조건을 적용할 JSONB 열입니다 (원문 텍스트를 저장하는 소스는 없음).
+	JSONColumn string
+}
+
+// DataSources는 API 이름별 조회 가능한 수집 테이블입니다.
+var DataSources = map[string]DataSource{
+	"rest": {Name: "rest", Table: "client_data", KeyColumn: "url", Columns: []string{"url", "data", "parameters"}, JSONColumn: "data"},
+	"mqtt": {Name: "mqtt", Table: "mqtt_messages", KeyColumn: "topic", Columns: []string{"topic", "payload"}},
+	"coap": {Name: "coap", Table: "coap_messages", KeyColumn: "path", Columns: []string{"path", "payload", "method", "device_id", "parameters"}},
+	"snmp": {Name: "snmp", Table: "snmp_data", KeyColumn: "host", Columns: []string{"host", "data"}, JSONColumn: "data"},
+}
+
+// jsonColumns는 문자열이 아닌 JSON 원문으로 반환하는 열입니다.
+var jsonColumns = map[string]bool{"data": true, "parameters": true}
+
+// 필드 조건 연산자
+var fieldOps = map[string]string{
+	"eq":  "==",
+	"ne":  "!=",
+	"gt":  ">",
+	"gte": ">=",
+	"lt":  "<",
+	"lte": "<=",
+}
+
+// FieldFilter는 소스 JSON 열의 필드를 값과 비교합니다.
+// Path 는 필드까지의 객체 키 목록이고 Op 는 eq, ne, gt, gte, lt, lte 중 하나입니다.
+// JSON 숫자, 불리언, null, 따옴표로 감싼 문자열로 파싱되는 값은 해당 타입으로, 그 밖의
+// 값은 문자열로 비교합니다. 필드가 없거나 타입이 다른 행은 일치하지 않습니다.
+type FieldFilter struct {
+	Path  []string
+	Op    string
+	Value string
+}
+
+// DataCursor는 페이지 마지막 행 다음 위치입니다.
+type DataCursor struct {
+	CreatedAt int64
+	ID        int64
+}
+
+// String은 URL 에 쓸 수 있도록 커서를 인코딩합니다.
+func (c DataCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", c.CreatedAt, c.ID)))
+}
+
+// ParseDataCursor는 DataCursor.String 으로 만든 커서를 디코딩합니다.
+func ParseDataCursor(s string) (DataCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return DataCursor{}, errors.New("invalid cursor")
+	}
+	createdAt, id, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return DataCursor{}, errors.New("invalid cursor")
+	}
+	var c DataCursor
+	if c.CreatedAt, err = strconv.ParseInt(createdAt, 10, 64); err != nil {
+		return DataCursor{}, errors.New("invalid cursor")
+	}
+	if c.ID, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return DataCursor{}, errors.New("invalid cursor")
+	}
+	return c, nil
+}
+
+// DataQuery는 한 소스의 행을 선택합니다 (Ascending 이 아니면 최신순).
+type DataQuery struct {
+	Source DataSource
+	// Key는 소스의 키 열과 정확히 일치하는 값이며, "*" 로 끝나면 접두사로 비교합니다.
+	Key       string
+	From, To  time.Time // 시작(포함)과 끝(미포함), 0 값은 제한 없음
+	Fields    []FieldFilter
+	After     *DataCursor
+	Ascending bool
+	Limit     int
+}
+
+// DataRow는 저장된 메시지 하나입니다.
+type DataRow struct {
+	ID        int64
+	CreatedAt int64                  // Unix 나노초
+	Values    map[string]interface{} // 소스 열 (JSON 열은 json.RawMessage)
+}
+
+// Cursor는 r 다음부터 이어지는 커서를 반환합니다.
+func (r DataRow) Cursor() DataCursor {
+	return DataCursor{CreatedAt: r.CreatedAt, ID: r.ID}
+}
+
+// buildDataQuery는 q 의 SQL 과 인자를 반환합니다. q.Limit 보다 한 행 더 선택합니다.
+func buildDataQuery(q DataQuery) (string, []interface{}, error) {
+	src := q.Source
+	if src.Table == "" {
+		return "", nil, errors.New("unknown data source")
+	}
+	if q.Limit <= 0 || q.Limit > MaxQueryLimit {
+		return "", nil, fmt.Errorf("limit must be between 1 and %d", MaxQueryLimit)
+	}
+
+	var where []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if q.Key != "" {
+		if prefix, ok := strings.CutSuffix(q.Key, "*"); ok {
+			where = append(where, fmt.Sprintf("%s LIKE %s", src.KeyColumn, arg(escapeLike(prefix)+"%")))
+		} else {
+			where = append(where, fmt.Sprintf("%s = %s", src.KeyColumn, arg(q.Key)))
+		}
+	}
+	if !q.From.IsZero() {
+		where = append(where, "created_at >= "+arg(q.From.UnixNano()))
+	}
+	if !q.To.IsZero() {
+		where = append(where, "created_at < "+arg(q.To.UnixNano()))
+	}
+
+	for _, f := range q.Fields {
+		if src.JSONColumn == "" {
+			return "", nil, fmt.Errorf("source %s has no JSON fields to filter on", src.Name)
+		}
+		cond, err := fieldCondition(src.JSONColumn, f, arg)
+		if err != nil {
+			return "", nil, err
+		}
+		where = append(where, cond)
+	}
+
+	order, cmp := "DESC", "<"
+	if q.Ascending {
+		order, cmp = "ASC", ">"
+	}
+	if q.After != nil {
+		where = append(where, fmt.Sprintf("(created_at, id) %s (%s, %s)", cmp, arg(q.After.CreatedAt), arg(q.After.ID)))
+	}
+
+	query := fmt.Sprintf("SELECT id, created_at, %s FROM %s", strings.Join(src.Columns, ", "), src.Table)
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY created_at %s, id %s LIMIT %s;", order, order, arg(q.Limit+1))
+	return query, args, nil
+}
+
+// fieldCondition은 f 의 jsonb_path_exists 조건을 만듭니다. JSON 경로로 비교하므로
+// 필드 타입이 다른 행에서도 오류가 나지 않습니다.
+func fieldCondition(column string, f FieldFilter, arg func(interface{}) string) (string, error) {
+	if len(f.Path) == 0 {
+		return "", errors.New("field filter needs a field name")
+	}
+	op, ok := fieldOps[f.Op]
+	if !ok {
+		return "", fmt.Errorf("unknown field operator %q", f.Op)
+	}
+
+	var path strings.Builder
+	path.WriteString("$")
+	for _, key := range f.Path {
+		if key == "" {
+			return "", errors.New("field names must not be empty")
+		}
+		quoted, _ := json.Marshal(key) // JSON 문자열 이스케이프는 jsonpath 에서도 유효
+		path.WriteString(".")
+		path.Write(quoted)
+	}
+	fmt.Fprintf(&path, " ? (@ %s $v)", op)
+
+	// 스칼라는 JSON 타입을 유지하고 그 밖의 값은 문자열로 비교
+	value, _ := json.Marshal(f.Value)
+	var v interface{}
+	if json.Unmarshal([]byte(f.Value), &v) == nil {
+		switch v.(type) {
+		case float64, bool, string, nil:
+			value = []byte(f.Value)
+		}
+	}
+	vars, _ := json.Marshal(map[string]json.RawMessage{"v": value})
+
+	return fmt.Sprintf("jsonb_path_exists(%s, %s::jsonpath, %s::jsonb)",
+		column, arg(path.String()), arg(string(vars))), nil
+}
+
+// escapeLike는 s 의 LIKE 와일드카드를 이스케이프합니다.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// DataReader는 저장된 메시지를 조회합니다.
+type DataReader struct {
+	conn *sql.DB
+}
+
+// NewDataReader는 conn 으로 DataReader 를 생성합니다.
+func NewDataReader(conn *sql.DB) *DataReader {
+	return &DataReader{conn: conn}
+}
+
+// Query는 q 로 선택한 행의 한 페이지와, 다음 페이지가 있으면 그 커서를 반환합니다.
+func (r *DataReader) Query(ctx context.Context, q DataQuery) ([]DataRow, *DataCursor, error) {
+	query, args, err := buildDataQuery(q)
+	if err != nil {
+		return nil, nil, err
+	}
+	if r.conn == nil {
+		return nil, nil, errors.New("database is not initialized")
+	}
+
+	rows, err := r.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query %s: %w", q.Source.Table, err)
+	}
+	defer rows.Close()
+
+	var result []DataRow
+	for rows.Next() {
+		var row DataRow
+		cols := make([]sql.NullString, len(q.Source.Columns))
+		dest := []interface{}{&row.ID, &row.CreatedAt}
+		for i := range cols {
+			dest = append(dest, &cols[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s row: %w", q.Source.Table, err)
+		}
+
+		row.Values = make(map[string]interface{}, len(cols))
+		for i, name := range q.Source.Columns {
+			switch {
+			case !cols[i].Valid:
+				row.Values[name] = nil
+			case jsonColumns[name]:
+				row.Values[name] = json.RawMessage(cols[i].String)
+			default:
+				row.Values[name] = cols[i].String
+			}
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to query %s: %w", q.Source.Table, err)
+	}
+
+	// 다음 페이지가 있는지 알기 위해 한 행을 더 읽음
+	if len(result) <= q.Limit {
+		return result, nil, nil
+	}
+	result = result[:q.Limit]
+	next := result[q.Limit-1].Cursor()
+	return result, &next, nil
+}
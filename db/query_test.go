@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataCursor(t *testing.T) {
+	c := DataCursor{CreatedAt: 1700000000000000001, ID: 42}
+	parsed, err := ParseDataCursor(c.String())
+	require.NoError(t, err)
+	assert.Equal(t, c, parsed)
+
+	for _, s := range []string{"", "!!", "MTIz", "YTpi"} {
+		_, err := ParseDataCursor(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestBuildDataQuery(t *testing.T) {
+	t.Run("no filters", func(t *testing.T) {
+		query, args, err := buildDataQuery(DataQuery{Source: DataSources["mqtt"], Limit: 10})
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT id, created_at, topic, payload FROM mqtt_messages ORDER BY created_at DESC, id DESC LIMIT $1;", query)
+		assert.Equal(t, []interface{}{11}, args, "one extra row tells whether another page follows")
+	})
+
+	t.Run("key, time range and cursor", func(t *testing.T) {
+		from := time.Unix(100, 0)
+		to := time.Unix(200, 0)
+		query, args, err := buildDataQuery(DataQuery{
+			Source:    DataSources["rest"],
+			Key:       "/api/te_mp*",
+			From:      from,
+			To:        to,
+			After:     &DataCursor{CreatedAt: 150, ID: 3},
+			Ascending: true,
+			Limit:     5,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "SELECT id, created_at, url, data, parameters FROM client_data"+
+			" WHERE url LIKE $1 AND created_at >= $2 AND created_at < $3 AND (created_at, id) > ($4, $5)"+
+			" ORDER BY created_at ASC, id ASC LIMIT $6;", query)
+		assert.Equal(t, []interface{}{`/api/te\_mp%`, from.UnixNano(), to.UnixNano(), int64(150), int64(3), 6}, args)
+	})
+
+	t.Run("field filters", func(t *testing.T) {
+		query, args, err := buildDataQuery(DataQuery{
+			Source: DataSources["snmp"],
+			Fields: []FieldFilter{
+				{Path: []string{"sensor", `na"me`}, Op: "eq", Value: "lobby"},
+				{Path: []string{"temp"}, Op: "gte", Value: "21.5"},
+				{Path: []string{"id"}, Op: "ne", Value: `"42"`},
+			},
+			Limit: 1,
+		})
+		require.NoError(t, err)
+		assert.Contains(t, query, "WHERE jsonb_path_exists(data, $1::jsonpath, $2::jsonb) AND jsonb_path_exists(data, $3::jsonpath, $4::jsonb)")
+		assert.Equal(t, `$."sensor"."na\"me" ? (@ == $v)`, args[0])
+		assert.Equal(t, `{"v":"lobby"}`, args[1])
+		assert.Equal(t, `$."temp" ? (@ >= $v)`, args[2])
+		assert.Equal(t, `{"v":21.5}`, args[3])
+		assert.Equal(t, `$."id" ? (@ != $v)`, args[4])
+		assert.Equal(t, `{"v":"42"}`, args[5], "quoted values are compared as strings")
+	})
+
+	t.Run("rejects invalid queries", func(t *testing.T) {
+		tests := map[string]DataQuery{
+			"unknown source":       {Limit: 1},
+			"zero limit":           {Source: DataSources["rest"]},
+			"limit too large":      {Source: DataSources["rest"], Limit: MaxQueryLimit + 1},
+			"field on text source": {Source: DataSources["coap"], Limit: 1, Fields: []FieldFilter{{Path: []string{"a"}, Op: "eq"}}},
+			"unknown operator":     {Source: DataSources["rest"], Limit: 1, Fields: []FieldFilter{{Path: []string{"a"}, Op: "like"}}},
+			"empty field name":     {Source: DataSources["rest"], Limit: 1, Fields: []FieldFilter{{Path: []string{"a", ""}, Op: "eq"}}},
+		}
+		for name, q := range tests {
+			t.Run(name, func(t *testing.T) {
+				_, _, err := buildDataQuery(q)
+				assert.Error(t, err)
+			})
+		}
+	})
+}
+
+func TestDataReaderIntegration(t *testing.T) {
+	if DbPool == nil {
+		t.Skip("Database not initialized - skipping integration tests")
+	}
+
+	r := NewDataReader(DbPool)
+	rows, _, err := r.Query(context.Background(), DataQuery{
+		Source: DataSources["rest"],
+		Fields: []FieldFilter{{Path: []string{"temp"}, Op: "gt", Value: "0"}},
+		Limit:  10,
+	})
+	require.NoError(t, err)
+	assert.LessOrEqual(t, len(rows), 10)
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// quotaTimeout bounds one quota update so a slow database cannot stall
+// request handling for long.
+const quotaTimeout = 5 * time.Second
+
+// takeQuotaSQL increments the counter only while it is below the limit, so
+// rejected requests do not inflate it; no returned row means the quota is used up.
+const takeQuotaSQL = `
+	INSERT INTO rate_limit_quotas (scope, client_key, day, count)
+	VALUES ($1, $2, $3, 1)
+	ON CONFLICT (scope, client_key, day)
+	DO UPDATE SET count = rate_limit_quotas.count + 1
+	WHERE rate_limit_quotas.count < $4
+	RETURNING count;`
+
+// refundQuotaSQL gives back one request taken by takeQuotaSQL.
+const refundQuotaSQL = `
+	UPDATE rate_limit_quotas SET count = count - 1
+	WHERE scope = $1 AND client_key = $2 AND day = $3 AND count > 0;`
+
+// QuotaStore keeps the daily rate limit quotas of all servone instances in
+// rate_limit_quotas. It implements ratelimit.Counter.
+type QuotaStore struct {
+	conn *sql.DB
+}
+
+// NewQuotaStore creates a QuotaStore on conn.
+func NewQuotaStore(conn *sql.DB) *QuotaStore {
+	return &QuotaStore{conn: conn}
+}
+
+// Take consumes one request from a client's daily quota and reports whether
+// the quota still had room.
+func (s *QuotaStore) Take(ctx context.Context, scope, key string, day time.Time, limit int64) (bool, error) {
+	if limit <= 0 {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, quotaTimeout)
+	defer cancel()
+
+	var count int64
+	err := s.conn.QueryRowContext(ctx, takeQuotaSQL, scope, key, day.Format("2006-01-02"), limit).Scan(&count)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to update rate limit quota: %w", err)
+	}
+	return true, nil
+}
+
+// Refund gives back one request taken from a client's daily quota.
+func (s *QuotaStore) Refund(ctx context.Context, scope, key string, day time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, quotaTimeout)
+	defer cancel()
+
+	if _, err := s.conn.ExecContext(ctx, refundQuotaSQL, scope, key, day.Format("2006-01-02")); err != nil {
+		return fmt.Errorf("failed to refund rate limit quota: %w", err)
+	}
+	return nil
+}
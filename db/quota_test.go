@@ -0,0 +1,33 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotaStoreIntegration(t *testing.T) {
+	if DbPool == nil {
+		t.Skip("Database not initialized - skipping integration tests")
+	}
+
+	ctx := context.Background()
+	s := NewQuotaStore(DbPool)
+	day := time.Now().UTC().Truncate(24 * time.Hour)
+	key := "quota-test-" + time.Now().Format("150405.000000000")
+
+	ok, err := s.Take(ctx, "test", key, day, 1)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = s.Take(ctx, "test", key, day, 1)
+	require.NoError(t, err)
+	assert.False(t, ok, "the quota is used up")
+
+	require.NoError(t, s.Refund(ctx, "test", key, day))
+	ok, err = s.Take(ctx, "test", key, day, 1)
+	require.NoError(t, err)
+	assert.True(t, ok, "a refunded request can be taken again")
+}
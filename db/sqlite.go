@@ -0,0 +1,251 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema creates the ingest tables and the outbox in an SQLite file.
+// JSON columns hold JSON text; timestamps are Unix nanoseconds.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS client_data (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	url TEXT NOT NULL,
+	data TEXT,
+	parameters TEXT,
+	created_at INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS mqtt_messages (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	topic TEXT NOT NULL,
+	payload TEXT,
+	created_at INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS coap_messages (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	path TEXT NOT NULL,
+	payload TEXT,
+	method TEXT,
+	created_at INTEGER,
+	device_id TEXT,
+	parameters TEXT
+);
+
+CREATE TABLE IF NOT EXISTS snmp_data (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	host TEXT NOT NULL,
+	data TEXT,
+	created_at INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS outbox (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	topic TEXT NOT NULL,
+	payload TEXT NOT NULL,
+	created_at INTEGER NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	next_attempt_at INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT,
+	sent_at INTEGER
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_idx ON outbox (next_attempt_at) WHERE sent_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS coap_psk (
+	identity TEXT PRIMARY KEY,
+	psk TEXT NOT NULL,
+	device_id TEXT NOT NULL
+);`
+
+// sqliteColumns are columns added after the first release of the SQLite
+// schema. Files created earlier get them on open.
+var sqliteColumns = []struct{ table, column, decl string }{
+	{"coap_messages", "device_id", "TEXT"},
+	{"coap_messages", "parameters", "TEXT"},
+}
+
+// SQLiteStore stores messages in an embedded SQLite file, for edge
+// deployments without PostgreSQL. Kafka events go to an outbox table in the
+// same transaction as their row, so SQLiteStore also serves as the outbox
+// store of the relay.
+type SQLiteStore struct {
+	rowStore
+	conn *sql.DB
+}
+
+// OpenSQLiteStore opens or creates the SQLite file at path and creates the
+// tables it needs.
+func OpenSQLiteStore(path string) (*SQLiteStore, error) {
+	if path == "" {
+		return nil, errors.New("sqlite store needs a database path")
+	}
+	conn, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	// SQLite allows one writer at a time
+	conn.SetMaxOpenConns(1)
+
+	if _, err := conn.Exec(sqliteSchema); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to create sqlite tables: %w", err)
+	}
+	if err := addSQLiteColumns(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	s := &SQLiteStore{conn: conn}
+	s.rowStore = rowStore{write: s.insert}
+	return s, nil
+}
+
+// addSQLiteColumns adds the columns of sqliteColumns that conn lacks.
+func addSQLiteColumns(conn *sql.DB) error {
+	for _, c := range sqliteColumns {
+		var n int
+		err := conn.QueryRow(`SELECT count(*) FROM pragma_table_info(?) WHERE name = ?;`, c.table, c.column).Scan(&n)
+		if err != nil {
+			return fmt.Errorf("failed to inspect sqlite table %s: %w", c.table, err)
+		}
+		if n > 0 {
+			continue
+		}
+		if _, err := conn.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", c.table, c.column, c.decl)); err != nil {
+			return fmt.Errorf("failed to add %s.%s: %w", c.table, c.column, err)
+		}
+	}
+	return nil
+}
+
+// insert writes ins and its event, if any, in one transaction.
+func (s *SQLiteStore) insert(ins Insert) error {
+	if err := validateInsert(ins); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := s.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ins.Columns)), ", ")
+	insertSQL := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);", ins.Table, strings.Join(ins.Columns, ", "), placeholders)
+	if _, err := tx.ExecContext(ctx, insertSQL, ins.Values...); err != nil {
+		return err
+	}
+
+	if ins.Event != nil {
+		payload, err := json.Marshal(ins.Event.Data)
+		if err != nil {
+			return fmt.Errorf("failed to marshal outbox event: %w", err)
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO outbox (topic, payload, created_at) VALUES (?, ?, ?);`,
+			ins.Event.Topic, string(payload), time.Now().UnixNano())
+		if err != nil {
+			return fmt.Errorf("failed to insert outbox event: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Claim leases up to limit due entries for lease and returns them in id order.
+func (s *SQLiteStore) Claim(ctx context.Context, limit int, lease time.Duration) ([]OutboxEntry, error) {
+	now := time.Now()
+	rows, err := s.conn.QueryContext(ctx, `
+		UPDATE outbox SET next_attempt_at = ?
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE sent_at IS NULL AND next_attempt_at <= ?
+			ORDER BY id
+			LIMIT ?
+		)
+		RETURNING id, topic, payload, attempts;`,
+		now.Add(lease).UnixNano(), now.UnixNano(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []OutboxEntry
+	for rows.Next() {
+		var e OutboxEntry
+		var payload string
+		if err := rows.Scan(&e.ID, &e.Topic, &payload, &e.Attempts); err != nil {
+			return nil, fmt.Errorf("failed to read outbox entry: %w", err)
+		}
+		e.Payload = json.RawMessage(payload)
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read outbox entries: %w", err)
+	}
+	sortOutboxEntries(entries)
+	return entries, nil
+}
+
+// MarkSent records that the entries were published.
+func (s *SQLiteStore) MarkSent(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	args := []interface{}{time.Now().UnixNano()}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ")
+	_, err := s.conn.ExecContext(ctx,
+		`UPDATE outbox SET sent_at = ?, last_error = NULL WHERE id IN (`+placeholders+`);`, args...)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox entries sent: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed records a failed publish and schedules the next attempt.
+func (s *SQLiteStore) MarkFailed(ctx context.Context, id int64, reason string, retryAt time.Time) error {
+	_, err := s.conn.ExecContext(ctx,
+		`UPDATE outbox SET attempts = attempts + 1, last_error = ?, next_attempt_at = ? WHERE id = ?;`,
+		reason, retryAt.UnixNano(), id)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox entry failed: %w", err)
+	}
+	return nil
+}
+
+// DeleteSent removes entries published before the given time.
+func (s *SQLiteStore) DeleteSent(ctx context.Context, before time.Time) (int64, error) {
+	res, err := s.conn.ExecContext(ctx, `DELETE FROM outbox WHERE sent_at IS NOT NULL AND sent_at < ?;`, before.UnixNano())
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete sent outbox entries: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// Pending counts entries not yet published.
+func (s *SQLiteStore) Pending(ctx context.Context) (int64, error) {
+	var n int64
+	err := s.conn.QueryRowContext(ctx, `SELECT count(*) FROM outbox WHERE sent_at IS NULL;`).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count pending outbox entries: %w", err)
+	}
+	return n, nil
+}
+
+// Close closes the database file.
+func (s *SQLiteStore) Close() error {
+	return s.conn.Close()
+}
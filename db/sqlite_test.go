@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestSQLite(t *testing.T) *SQLiteStore {
+	s, err := OpenSQLiteStore(filepath.Join(t.TempDir(), "servone.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSQLiteStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("rows and events are stored together", func(t *testing.T) {
+		s := openTestSQLite(t)
+
+		require.NoError(t, s.SaveMQTTMessage("a/b", "hello", 42, &Event{Topic: "mq.a.b", Data: map[string]interface{}{"payload": "hello"}}))
+		require.NoError(t, s.SaveCoapMessage("/c", "body", "POST", "dev-1", map[string]string{"id": "7"}, 43, nil))
+		require.NoError(t, s.SaveSNMPData("10.0.0.1", map[string]interface{}{"x": 1}, 44, nil))
+		require.NoError(t, s.SaveClientData("/api/x", map[string]interface{}{"a": 1}, nil, nil))
+
+		var topic, payload string
+		var created int64
+		require.NoError(t, s.conn.QueryRow(`SELECT topic, payload, created_at FROM mqtt_messages;`).Scan(&topic, &payload, &created))
+		assert.Equal(t, "a/b", topic)
+		assert.Equal(t, "hello", payload)
+		assert.Equal(t, int64(42), created)
+
+		var device, params string
+		require.NoError(t, s.conn.QueryRow(`SELECT device_id, parameters FROM coap_messages;`).Scan(&device, &params))
+		assert.Equal(t, "dev-1", device)
+		assert.JSONEq(t, `{"id":"7"}`, params)
+
+		var data string
+		require.NoError(t, s.conn.QueryRow(`SELECT data FROM snmp_data;`).Scan(&data))
+		assert.JSONEq(t, `{"x":1}`, data)
+
+		// MQTT and REST rows carry events
+		n, err := s.Pending(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), n)
+	})
+
+	t.Run("failed insert leaves no event", func(t *testing.T) {
+		s := openTestSQLite(t)
+
+		err := s.insert(Insert{Table: "mqtt_messages", Columns: []string{"topic", "payload", "created_at"},
+			Values: []interface{}{nil, "p", 1}, Event: &Event{Topic: "t"}})
+		assert.Error(t, err)
+
+		n, err := s.Pending(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, n)
+	})
+
+	t.Run("rejects unknown tables", func(t *testing.T) {
+		s := openTestSQLite(t)
+		err := s.insert(Insert{Table: "users", Columns: []string{"name"}, Values: []interface{}{"x"}})
+		assert.Error(t, err)
+	})
+
+	t.Run("outbox lifecycle", func(t *testing.T) {
+		s := openTestSQLite(t)
+		for _, topic := range []string{"t1", "t2", "t3"} {
+			require.NoError(t, s.SaveMQTTMessage(topic, "p", 1, &Event{Topic: topic, Data: map[string]interface{}{"topic": topic}}))
+		}
+
+		entries, err := s.Claim(ctx, 2, time.Minute)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		assert.Equal(t, "t1", entries[0].Topic)
+		assert.Equal(t, "t2", entries[1].Topic)
+		assert.JSONEq(t, `{"topic":"t1"}`, string(entries[0].Payload))
+
+		// leased entries are not claimed again
+		rest, err := s.Claim(ctx, 10, time.Minute)
+		require.NoError(t, err)
+		require.Len(t, rest, 1)
+		assert.Equal(t, "t3", rest[0].Topic)
+
+		require.NoError(t, s.MarkSent(ctx, []int64{entries[0].ID, entries[1].ID}))
+		require.NoError(t, s.MarkFailed(ctx, rest[0].ID, "broker down", time.Now().Add(-time.Second)))
+
+		n, err := s.Pending(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), n)
+
+		retry, err := s.Claim(ctx, 10, time.Minute)
+		require.NoError(t, err)
+		require.Len(t, retry, 1)
+		assert.Equal(t, 1, retry[0].Attempts)
+
+		deleted, err := s.DeleteSent(ctx, time.Now().Add(time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), deleted)
+	})
+
+	t.Run("adds new columns to older files", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "servone.db")
+		conn, err := sql.Open("sqlite", "file:"+path)
+		require.NoError(t, err)
+		_, err = conn.Exec(`CREATE TABLE coap_messages (id INTEGER PRIMARY KEY AUTOINCREMENT, path TEXT NOT NULL, payload TEXT, method TEXT, created_at INTEGER);`)
+		require.NoError(t, err)
+		require.NoError(t, conn.Close())
+
+		s, err := OpenSQLiteStore(path)
+		require.NoError(t, err)
+		defer s.Close()
+		require.NoError(t, s.SaveCoapMessage("/c", "body", "POST", "dev-2", map[string]string{"id": "7"}, 1, nil))
+	})
+
+	t.Run("reopens existing file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "servone.db")
+		s, err := OpenSQLiteStore(path)
+		require.NoError(t, err)
+		require.NoError(t, s.SaveCoapMessage("/c", "body", "GET", "", nil, 1, nil))
+		require.NoError(t, s.Close())
+
+		s, err = OpenSQLiteStore(path)
+		require.NoError(t, err)
+		defer s.Close()
+		var n int
+		require.NoError(t, s.conn.QueryRow(`SELECT count(*) FROM coap_messages;`).Scan(&n))
+		assert.Equal(t, 1, n)
+	})
+}
@@ -0,0 +1,212 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"servone/config"
+)
+
+// Drivers selectable with DatabaseConfig.Driver.
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverFile     = "file"
+)
+
+// Store persists ingested messages. PostgresStore, SQLiteStore and
+// FileStore implement it. event, where accepted, is the Kafka event of the
+// message: stores with an outbox table commit it together with the row,
+// FileStore publishes it after appending the row.
+type Store interface {
+	SaveClientData(url string, data map[string]interface{}, params map[string]string, auth interface{}) error
+	SaveMQTTMessage(topic string, payload string, receivedTime int64, event *Event) error
+	SaveCoapMessage(path string, payload string, method string, deviceID string, params map[string]string, receivedTime int64, event *Event) error
+	SaveSNMPData(host string, data map[string]interface{}, receivedTime int64, event *Event) error
+	Close() error
+}
+
+// Publisher publishes Kafka events for stores without an outbox table.
+type Publisher interface {
+	Publish(topic string, data map[string]interface{}) error
+}
+
+// OpenStore opens the store selected by cfg.Driver: PostgreSQL (the
+// default, with pending migrations applied), an SQLite file or an
+// append-only JSONL file. publisher is used by the file store only.
+func OpenStore(cfg config.DatabaseConfig, publisher Publisher) (Store, error) {
+	switch cfg.Driver {
+	case "", DriverPostgres:
+		if err := InitDB(cfg.ConnectionString); err != nil {
+			return nil, err
+		}
+		m, err := NewMigrator(DbPool)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := m.Up(context.Background()); err != nil {
+			return nil, fmt.Errorf("failed to migrate database: %w", err)
+		}
+		return NewPostgresStore(DbPool), nil
+	case DriverSQLite:
+		return OpenSQLiteStore(cfg.Path)
+	case DriverFile:
+		return OpenFileStore(cfg.Path, publisher)
+	}
+	return nil, fmt.Errorf("unknown database driver %q (expected %s, %s or %s)", cfg.Driver, DriverPostgres, DriverSQLite, DriverFile)
+}
+
+// defaultStore is the store used by the package-level Save functions. It is
+// nil until InitDB or UseStore is called.
+var defaultStore Store
+
+// UseStore makes the package-level Save functions write to s.
+func UseStore(s Store) {
+	defaultStore = s
+}
+
+// DefaultStore returns the store used by the package-level Save functions,
+// or nil when no database is configured.
+func DefaultStore() Store {
+	return defaultStore
+}
+
+// rowStore implements the Save methods of Store on top of a function that
+// writes one Insert.
+type rowStore struct {
+	write func(ins Insert) error
+}
+
+func (s rowStore) SaveClientData(url string, data map[string]interface{}, params map[string]string, auth interface{}) error {
+	ins, err := clientDataRow(url, data, params, auth)
+	if err != nil {
+		return err
+	}
+	if err := s.write(ins); err != nil {
+		return fmt.Errorf("failed to insert data into database: %w", err)
+	}
+	return nil
+}
+
+func (s rowStore) SaveMQTTMessage(topic string, payload string, receivedTime int64, event *Event) error {
+	if err := s.write(mqttRow(topic, payload, receivedTime, event)); err != nil {
+		return fmt.Errorf("failed to insert MQTT message: %w", err)
+	}
+	return nil
+}
+
+func (s rowStore) SaveCoapMessage(path string, payload string, method string, deviceID string, params map[string]string, receivedTime int64, event *Event) error {
+	if err := s.write(coapRow(path, payload, method, deviceID, params, receivedTime, event)); err != nil {
+		return fmt.Errorf("failed to insert CoAP message: %w", err)
+	}
+	return nil
+}
+
+func (s rowStore) SaveSNMPData(host string, data map[string]interface{}, receivedTime int64, event *Event) error {
+	ins, err := snmpRow(host, data, receivedTime, event)
+	if err != nil {
+		return err
+	}
+	if err := s.write(ins); err != nil {
+		return fmt.Errorf("failed to insert SNMP data: %w", err)
+	}
+	return nil
+}
+
+// clientDataRow builds the client_data row of a REST request and its event.
+func clientDataRow(url string, data map[string]interface{}, params map[string]string, auth interface{}) (Insert, error) {
+	event := clientDataEvent(url, data, params, auth)
+
+	mergeDataJSON, err := json.Marshal(event.Data["data"])
+	if err != nil {
+		return Insert{}, fmt.Errorf("failed to marshal data to JSON: %w", err)
+	}
+
+	paramsJSON, err := json.Marshal(event.Data["params"])
+	if err != nil {
+		return Insert{}, fmt.Errorf("failed to marshal params to JSON: %w", err)
+	}
+
+	return Insert{
+		Table:   "client_data",
+		Columns: []string{"url", "data", "parameters", "created_at"},
+		Values:  []interface{}{url, string(mergeDataJSON), string(paramsJSON), time.Now().UnixNano()},
+		Event:   event,
+	}, nil
+}
+
+// mqttRow builds the mqtt_messages row of an MQTT message.
+func mqttRow(topic string, payload string, receivedTime int64, event *Event) Insert {
+	return Insert{
+		Table:   "mqtt_messages",
+		Columns: []string{"topic", "payload", "created_at"},
+		Values:  []interface{}{topic, payload, receivedTime},
+		Event:   event,
+	}
+}
+
+// coapRow builds the coap_messages row of a CoAP request. deviceID, the
+// device authenticated by DTLS or TLS, and params, the variables captured
+// from a templated path, are only stored when set.
+func coapRow(path string, payload string, method string, deviceID string, params map[string]string, receivedTime int64, event *Event) Insert {
+	ins := Insert{
+		Table:   "coap_messages",
+		Columns: []string{"path", "payload", "method", "created_at"},
+		Values:  []interface{}{path, payload, method, receivedTime},
+		Event:   event,
+	}
+	if deviceID != "" {
+		ins.Columns = append(ins.Columns, "device_id")
+		ins.Values = append(ins.Values, deviceID)
+	}
+	if len(params) > 0 {
+		// map[string]string always marshals
+		paramsJSON, _ := json.Marshal(params)
+		ins.Columns = append(ins.Columns, "parameters")
+		ins.Values = append(ins.Values, string(paramsJSON))
+	}
+	return ins
+}
+
+// snmpRow builds the snmp_data row of an SNMP result.
+func snmpRow(host string, data map[string]interface{}, receivedTime int64, event *Event) (Insert, error) {
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return Insert{}, fmt.Errorf("failed to marshal SNMP data: %w", err)
+	}
+	return Insert{
+		Table:   "snmp_data",
+		Columns: []string{"host", "data", "created_at"},
+		Values:  []interface{}{host, string(dataJSON), receivedTime},
+		Event:   event,
+	}, nil
+}
+
+// PostgresStore stores messages in PostgreSQL through WriteInsert, so the
+// spool and the batch writer apply when enabled. It also serves as the
+// outbox store of the relay.
+type PostgresStore struct {
+	rowStore
+	*OutboxStore
+	conn *sql.DB
+}
+
+// NewPostgresStore creates a PostgresStore on conn.
+func NewPostgresStore(conn *sql.DB) *PostgresStore {
+	return &PostgresStore{
+		rowStore: rowStore{write: func(ins Insert) error {
+			_, err := WriteInsert(conn, ins)
+			return err
+		}},
+		OutboxStore: NewOutboxStore(conn),
+		conn:        conn,
+	}
+}
+
+// Close closes the connection pool.
+func (s *PostgresStore) Close() error {
+	return s.conn.Close()
+}
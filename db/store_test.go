@@ -0,0 +1,128 @@
+package db
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"servone/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testStores returns an SQLite and a file store in a temporary directory,
+// plus a PostgreSQL store when a test database is initialized.
+func testStores(t *testing.T) map[string]Store {
+	dir := t.TempDir()
+
+	sqlite, err := OpenSQLiteStore(filepath.Join(dir, "servone.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlite.Close() })
+
+	file, err := OpenFileStore(filepath.Join(dir, "servone.jsonl"), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { file.Close() })
+
+	stores := map[string]Store{"sqlite": sqlite, "file": file}
+	if DbPool != nil {
+		// the pool belongs to the caller, so it is not closed here
+		stores["postgres"] = NewPostgresStore(DbPool)
+	}
+	return stores
+}
+
+func TestOpenStore(t *testing.T) {
+	t.Run("sqlite", func(t *testing.T) {
+		s, err := OpenStore(config.DatabaseConfig{Driver: DriverSQLite, Path: filepath.Join(t.TempDir(), "a.db")}, nil)
+		require.NoError(t, err)
+		defer s.Close()
+		assert.IsType(t, &SQLiteStore{}, s)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		s, err := OpenStore(config.DatabaseConfig{Driver: DriverFile, Path: filepath.Join(t.TempDir(), "a.jsonl")}, nil)
+		require.NoError(t, err)
+		defer s.Close()
+		assert.IsType(t, &FileStore{}, s)
+	})
+
+	t.Run("missing path", func(t *testing.T) {
+		_, err := OpenStore(config.DatabaseConfig{Driver: DriverSQLite}, nil)
+		assert.Error(t, err)
+		_, err = OpenStore(config.DatabaseConfig{Driver: DriverFile}, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("unknown driver", func(t *testing.T) {
+		_, err := OpenStore(config.DatabaseConfig{Driver: "mysql"}, nil)
+		assert.ErrorContains(t, err, "unknown database driver")
+	})
+}
+
+func TestSaveWithoutStore(t *testing.T) {
+	UseStore(nil)
+
+	assert.Nil(t, DefaultStore())
+	assert.ErrorContains(t, SaveMQTTMessage("t", "p", 1, nil), "not initialized")
+	assert.ErrorContains(t, SaveCoapMessage("/p", "p", "POST", "", nil, 1, nil), "not initialized")
+	assert.ErrorContains(t, SaveSNMPData("h", nil, 1, nil), "not initialized")
+	assert.ErrorContains(t, SaveToDBWithAuth("/u", nil, nil, nil), "not initialized")
+}
+
+func TestClientDataRow(t *testing.T) {
+	ins, err := clientDataRow("/api/x",
+		map[string]interface{}{"a": 1, "id": "body"},
+		map[string]string{"id": "path", "b": "2"},
+		map[string]interface{}{"subject": "alice"})
+	require.NoError(t, err)
+	require.NoError(t, validateInsert(ins))
+
+	assert.Equal(t, "client_data", ins.Table)
+	assert.JSONEq(t, `{"a":1,"id":"body","b":"2"}`, ins.Values[1].(string))
+	assert.JSONEq(t, `{"id":"path","b":"2","auth":{"subject":"alice"}}`, ins.Values[2].(string))
+
+	require.NotNil(t, ins.Event)
+	assert.Equal(t, "bz.api.x", ins.Event.Topic)
+	_, err = json.Marshal(ins.Event.Data)
+	assert.NoError(t, err)
+}
+
+func TestRowStoreWrapsErrors(t *testing.T) {
+	var written []Insert
+	fail := false
+	s := rowStore{write: func(ins Insert) error {
+		if fail {
+			return assert.AnError
+		}
+		written = append(written, ins)
+		return nil
+	}}
+
+	require.NoError(t, s.SaveMQTTMessage("t", "p", 1, nil))
+	require.NoError(t, s.SaveCoapMessage("/p", "p", "PUT", "", nil, 2, nil))
+	require.NoError(t, s.SaveSNMPData("h", map[string]interface{}{"x": 1}, 3, nil))
+	require.Len(t, written, 3)
+	assert.Equal(t, []string{"mqtt_messages", "coap_messages", "snmp_data"},
+		[]string{written[0].Table, written[1].Table, written[2].Table})
+
+	fail = true
+	err := s.SaveCoapMessage("/p", "p", "PUT", "", nil, 2, nil)
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.ErrorContains(t, err, "failed to insert CoAP message")
+}
+
+func TestCoapRow(t *testing.T) {
+	plain := coapRow("/p", "body", "POST", "", nil, 1, nil)
+	assert.Equal(t, []string{"path", "payload", "method", "created_at"}, plain.Columns)
+
+	secure := coapRow("/p", "body", "POST", "dev-1", nil, 1, nil)
+	require.NoError(t, validateInsert(secure))
+	assert.Equal(t, "device_id", secure.Columns[len(secure.Columns)-1])
+	assert.Equal(t, "dev-1", secure.Values[len(secure.Values)-1])
+
+	templated := coapRow("/sensors/{id}", "body", "POST", "", map[string]string{"id": "7"}, 1, nil)
+	require.NoError(t, validateInsert(templated))
+	assert.Equal(t, "parameters", templated.Columns[len(templated.Columns)-1])
+	assert.JSONEq(t, `{"id":"7"}`, templated.Values[len(templated.Values)-1].(string))
+}
@@ -0,0 +1,92 @@
+// Package fault decides which deliberate faults (latency, errors, dropped
+// connections) to inject into a request, shared by the REST and CoAP servers.
+package fault
+
+import (
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"servone/config"
+	"servone/metrics"
+)
+
+// Kind identifies an injected fault.
+type Kind string
+
+const (
+	None       Kind = ""            // no fault, handle the request normally
+	Error      Kind = "error"       // reply with the configured error status
+	Reset      Kind = "reset"       // HTTP: abort the connection with a TCP RST
+	EmptyReply Kind = "empty_reply" // HTTP: close the connection without a reply
+	Drop       Kind = "drop"        // CoAP: do not send a response
+)
+
+// Protocol names used for decisions and metric labels.
+const (
+	HTTP = "http"
+	CoAP = "coap"
+)
+
+// Decision is the fault plan for one request.
+type Decision struct {
+	Delay time.Duration
+	Kind  Kind
+}
+
+// Decide rolls the dice for one request. Only the faults that make sense for
+// the protocol are considered: reset/empty_reply for HTTP, drop for CoAP.
+func Decide(cfg *config.FaultConfig, protocol string) Decision {
+	if cfg == nil {
+		return Decision{}
+	}
+
+	d := Decision{Delay: delay(cfg)}
+
+	roll := rand.Float64()
+	var threshold float64
+	if protocol == HTTP {
+		if threshold += cfg.ResetRate; roll < threshold {
+			d.Kind = Reset
+			return d
+		}
+		if threshold += cfg.EmptyReplyRate; roll < threshold {
+			d.Kind = EmptyReply
+			return d
+		}
+	} else if protocol == CoAP {
+		if threshold += cfg.DropRate; roll < threshold {
+			d.Kind = Drop
+			return d
+		}
+	}
+	if threshold += cfg.ErrorRate; roll < threshold {
+		d.Kind = Error
+	}
+	return d
+}
+
+// delay computes the fixed or random delay configured for an endpoint.
+func delay(cfg *config.FaultConfig) time.Duration {
+	ms := cfg.DelayMs
+	if cfg.DelayMaxMs > cfg.DelayMs {
+		ms += rand.IntN(cfg.DelayMaxMs - cfg.DelayMs + 1)
+	}
+	if ms <= 0 {
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// Apply sleeps for the planned delay and records/logs the injected fault, if any.
+func (d Decision) Apply(protocol, method, endpoint string) {
+	if d.Delay > 0 {
+		metrics.RecordFaultInjected(protocol, endpoint, "delay")
+		log.Printf("[fault] %s %s %s: injecting %s delay", protocol, method, endpoint, d.Delay)
+		time.Sleep(d.Delay)
+	}
+	if d.Kind != None {
+		metrics.RecordFaultInjected(protocol, endpoint, string(d.Kind))
+		log.Printf("[fault] %s %s %s: injecting %s", protocol, method, endpoint, d.Kind)
+	}
+}
@@ -0,0 +1,54 @@
+package fault
+
+import (
+	"testing"
+	"time"
+
+	"servone/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecide(t *testing.T) {
+	t.Run("nil config injects nothing", func(t *testing.T) {
+		assert.Equal(t, Decision{}, Decide(nil, HTTP))
+	})
+
+	t.Run("certain faults", func(t *testing.T) {
+		assert.Equal(t, Reset, Decide(&config.FaultConfig{ResetRate: 1}, HTTP).Kind)
+		assert.Equal(t, EmptyReply, Decide(&config.FaultConfig{EmptyReplyRate: 1}, HTTP).Kind)
+		assert.Equal(t, Error, Decide(&config.FaultConfig{ErrorRate: 1}, HTTP).Kind)
+		assert.Equal(t, Drop, Decide(&config.FaultConfig{DropRate: 1}, CoAP).Kind)
+		assert.Equal(t, Error, Decide(&config.FaultConfig{ErrorRate: 1}, CoAP).Kind)
+	})
+
+	t.Run("protocol specific faults are ignored elsewhere", func(t *testing.T) {
+		assert.Equal(t, None, Decide(&config.FaultConfig{DropRate: 1}, HTTP).Kind)
+		assert.Equal(t, None, Decide(&config.FaultConfig{ResetRate: 1, EmptyReplyRate: 1}, CoAP).Kind)
+	})
+
+	t.Run("zero rates inject nothing", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			assert.Equal(t, None, Decide(&config.FaultConfig{}, HTTP).Kind)
+		}
+	})
+
+	t.Run("partial rate", func(t *testing.T) {
+		seen := make(map[Kind]int)
+		for i := 0; i < 1000; i++ {
+			seen[Decide(&config.FaultConfig{ErrorRate: 0.5}, HTTP).Kind]++
+		}
+		assert.Greater(t, seen[Error], 300)
+		assert.Greater(t, seen[None], 300)
+	})
+}
+
+func TestDelay(t *testing.T) {
+	assert.Equal(t, 20*time.Millisecond, Decide(&config.FaultConfig{DelayMs: 20}, HTTP).Delay)
+
+	for i := 0; i < 50; i++ {
+		d := Decide(&config.FaultConfig{DelayMs: 10, DelayMaxMs: 30}, CoAP).Delay
+		assert.GreaterOrEqual(t, d, 10*time.Millisecond)
+		assert.LessOrEqual(t, d, 30*time.Millisecond)
+	}
+}
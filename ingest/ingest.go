@@ -0,0 +1,180 @@
+// Package ingest runs database inserts and Kafka publishes on a bounded
+// worker pool so request handlers never spawn unbounded goroutines.
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"servone/config"
+	"servone/metrics"
+)
+
+// Defaults used when the ingest config leaves a field unset.
+const (
+	DefaultWorkers   = 8
+	DefaultQueueSize = 1000
+)
+
+// Overflow policies applied when the queue is full.
+const (
+	OverflowBlock      = "block"       // wait for room (bounded by the submitter's context)
+	OverflowReject     = "reject"      // fail the submission; handlers answer 503
+	OverflowDropOldest = "drop_oldest" // evict the oldest queued job to make room
+)
+
+var (
+	// ErrQueueFull is returned by Submit when the queue is full and the
+	// policy is reject (or a blocking submit gave up).
+	ErrQueueFull = errors.New("ingest queue full")
+	// ErrClosed is returned by Submit once the pipeline is draining.
+	ErrClosed = errors.New("ingest pipeline closed")
+)
+
+// Job is one unit of ingestion work.
+type Job struct {
+	Source string       // metric label, e.g. http or coap
+	Run    func() error // performs the inserts/publishes; errors are counted, callers log details
+
+	enqueued time.Time
+}
+
+// Pipeline is a bounded queue served by a fixed number of workers.
+type Pipeline struct {
+	overflow string
+	queue    chan Job
+	wg       sync.WaitGroup
+
+	mu     sync.RWMutex // held for reading while submitting, for writing while closing
+	closed bool
+}
+
+// New validates cfg and starts the workers.
+func New(cfg config.IngestConfig) (*Pipeline, error) {
+	workers, size := cfg.Workers, cfg.QueueSize
+	if workers == 0 {
+		workers = DefaultWorkers
+	}
+	if size == 0 {
+		size = DefaultQueueSize
+	}
+	if workers < 0 || size < 0 {
+		return nil, fmt.Errorf("ingest: workers and queue_size must not be negative")
+	}
+
+	overflow := strings.ToLower(cfg.Overflow)
+	switch overflow {
+	case "":
+		overflow = OverflowBlock
+	case OverflowBlock, OverflowReject, OverflowDropOldest:
+	default:
+		return nil, fmt.Errorf("ingest: unknown overflow policy %q (use block, reject or drop_oldest)", cfg.Overflow)
+	}
+
+	p := &Pipeline{overflow: overflow, queue: make(chan Job, size)}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.work()
+	}
+	return p, nil
+}
+
+// Submit queues a job according to the overflow policy. With the block
+// policy it waits until there is room or ctx is done.
+func (p *Pipeline) Submit(ctx context.Context, job Job) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		metrics.RecordIngestJob(job.Source, "rejected")
+		return ErrClosed
+	}
+	job.enqueued = time.Now()
+
+	select {
+	case p.queue <- job:
+		metrics.SetIngestQueueDepth(len(p.queue))
+		return nil
+	default:
+	}
+
+	switch p.overflow {
+	case OverflowReject:
+		metrics.RecordIngestJob(job.Source, "rejected")
+		return ErrQueueFull
+
+	case OverflowDropOldest:
+		for {
+			select {
+			case p.queue <- job:
+				metrics.SetIngestQueueDepth(len(p.queue))
+				return nil
+			default:
+			}
+			select {
+			case old := <-p.queue:
+				metrics.RecordIngestJob(old.Source, "dropped")
+				log.Printf("Ingest queue full, dropped oldest %s job queued %s ago", old.Source, time.Since(old.enqueued).Round(time.Millisecond))
+			default:
+			}
+		}
+
+	default:
+		select {
+		case p.queue <- job:
+			metrics.SetIngestQueueDepth(len(p.queue))
+			return nil
+		case <-ctx.Done():
+			metrics.RecordIngestJob(job.Source, "rejected")
+			return fmt.Errorf("%w: %v", ErrQueueFull, ctx.Err())
+		}
+	}
+}
+
+// Len returns the number of queued jobs.
+func (p *Pipeline) Len() int {
+	return len(p.queue)
+}
+
+func (p *Pipeline) work() {
+	defer p.wg.Done()
+	for job := range p.queue {
+		metrics.SetIngestQueueDepth(len(p.queue))
+		metrics.ObserveIngestWait(job.Source, time.Since(job.enqueued).Seconds())
+
+		status := "processed"
+		if err := job.Run(); err != nil {
+			status = "failed"
+		}
+		metrics.RecordIngestJob(job.Source, status)
+	}
+}
+
+// Close stops accepting jobs and waits until every queued job has run or
+// ctx is done. It is safe to call more than once.
+func (p *Pipeline) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("ingest: %d queued job(s) not processed: %w", len(p.queue), ctx.Err())
+	}
+}
@@ -0,0 +1,156 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"servone/config"
+	"servone/metrics"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockWorkers occupies every worker until the returned release func is called.
+func blockWorkers(t *testing.T, p *Pipeline, workers int) func() {
+	t.Helper()
+	release := make(chan struct{})
+	var started sync.WaitGroup
+	started.Add(workers)
+	for i := 0; i < workers; i++ {
+		require.NoError(t, p.Submit(context.Background(), Job{Source: "test", Run: func() error {
+			started.Done()
+			<-release
+			return nil
+		}}))
+	}
+	started.Wait()
+	return func() { close(release) }
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(config.IngestConfig{Overflow: "spill"})
+	assert.ErrorContains(t, err, "overflow")
+
+	_, err = New(config.IngestConfig{Workers: -1})
+	assert.Error(t, err)
+
+	p, err := New(config.IngestConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, OverflowBlock, p.overflow)
+	assert.Equal(t, DefaultQueueSize, cap(p.queue))
+	require.NoError(t, p.Close(context.Background()))
+}
+
+func TestOverflowPolicies(t *testing.T) {
+	t.Run("reject", func(t *testing.T) {
+		p, err := New(config.IngestConfig{Workers: 1, QueueSize: 1, Overflow: "reject"})
+		require.NoError(t, err)
+		release := blockWorkers(t, p, 1)
+
+		before := testutil.ToFloat64(metrics.IngestJobsTotal.WithLabelValues("reject-test", "rejected"))
+		require.NoError(t, p.Submit(context.Background(), Job{Source: "reject-test", Run: func() error { return nil }}))
+		err = p.Submit(context.Background(), Job{Source: "reject-test", Run: func() error { return nil }})
+		assert.ErrorIs(t, err, ErrQueueFull)
+		assert.Equal(t, before+1, testutil.ToFloat64(metrics.IngestJobsTotal.WithLabelValues("reject-test", "rejected")))
+
+		release()
+		require.NoError(t, p.Close(context.Background()))
+	})
+
+	t.Run("block until context is done", func(t *testing.T) {
+		p, err := New(config.IngestConfig{Workers: 1, QueueSize: 1, Overflow: "block"})
+		require.NoError(t, err)
+		release := blockWorkers(t, p, 1)
+		require.NoError(t, p.Submit(context.Background(), Job{Source: "test", Run: func() error { return nil }}))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err = p.Submit(ctx, Job{Source: "test", Run: func() error { return nil }})
+		assert.ErrorIs(t, err, ErrQueueFull)
+
+		// a blocked submit proceeds once a worker frees a slot
+		done := make(chan error, 1)
+		go func() { done <- p.Submit(context.Background(), Job{Source: "test", Run: func() error { return nil }}) }()
+		release()
+		assert.NoError(t, <-done)
+		require.NoError(t, p.Close(context.Background()))
+	})
+
+	t.Run("drop oldest", func(t *testing.T) {
+		p, err := New(config.IngestConfig{Workers: 1, QueueSize: 2, Overflow: "drop_oldest"})
+		require.NoError(t, err)
+		release := blockWorkers(t, p, 1)
+
+		var ran []int
+		var mu sync.Mutex
+		for i := 1; i <= 3; i++ {
+			i := i
+			require.NoError(t, p.Submit(context.Background(), Job{Source: "test", Run: func() error {
+				mu.Lock()
+				ran = append(ran, i)
+				mu.Unlock()
+				return nil
+			}}))
+		}
+
+		release()
+		require.NoError(t, p.Close(context.Background()))
+		assert.Equal(t, []int{2, 3}, ran)
+	})
+}
+
+func TestClose(t *testing.T) {
+	t.Run("drains queued jobs", func(t *testing.T) {
+		p, err := New(config.IngestConfig{Workers: 2, QueueSize: 100})
+		require.NoError(t, err)
+
+		var processed atomic.Int32
+		for i := 0; i < 50; i++ {
+			require.NoError(t, p.Submit(context.Background(), Job{Source: "test", Run: func() error {
+				time.Sleep(time.Millisecond)
+				processed.Add(1)
+				return nil
+			}}))
+		}
+
+		require.NoError(t, p.Close(context.Background()))
+		assert.Equal(t, int32(50), processed.Load())
+		assert.NoError(t, p.Close(context.Background()), "closing twice is safe")
+	})
+
+	t.Run("rejects after close", func(t *testing.T) {
+		p, err := New(config.IngestConfig{})
+		require.NoError(t, err)
+		require.NoError(t, p.Close(context.Background()))
+
+		err = p.Submit(context.Background(), Job{Source: "test", Run: func() error { return nil }})
+		assert.ErrorIs(t, err, ErrClosed)
+	})
+
+	t.Run("gives up when the context ends", func(t *testing.T) {
+		p, err := New(config.IngestConfig{Workers: 1})
+		require.NoError(t, err)
+		release := blockWorkers(t, p, 1)
+		defer release()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, p.Close(ctx), context.DeadlineExceeded)
+	})
+
+	t.Run("failed jobs are counted", func(t *testing.T) {
+		p, err := New(config.IngestConfig{Workers: 1})
+		require.NoError(t, err)
+
+		before := testutil.ToFloat64(metrics.IngestJobsTotal.WithLabelValues("fail-test", "failed"))
+		require.NoError(t, p.Submit(context.Background(), Job{Source: "fail-test", Run: func() error { return errors.New("boom") }}))
+		require.NoError(t, p.Close(context.Background()))
+		assert.Equal(t, before+1, testutil.ToFloat64(metrics.IngestJobsTotal.WithLabelValues("fail-test", "failed")))
+	})
+}
@@ -33,6 +33,60 @@ var (
 		[]string{"method", "endpoint", "status"},
 	)
 
+	// Schema validation metrics
+	SchemaValidationFailures = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "servone_schema_validation_failures_total",
+			Help: "Total number of inbound payloads rejected by JSON Schema validation",
+		},
+		[]string{"protocol", "endpoint"},
+	)
+
+	// Fault injection metrics
+	FaultsInjectedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "servone_faults_injected_total",
+			Help: "Total number of deliberately injected faults (delay, error, reset, empty_reply, drop)",
+		},
+		[]string{"protocol", "endpoint", "fault"},
+	)
+
+	// Authentication metrics
+	AuthFailuresTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "servone_auth_failures_total",
+			Help: "Total number of requests rejected by authentication",
+		},
+		[]string{"endpoint", "reason"},
+	)
+
+	// Rate limiting metrics
+	RateLimitedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "servone_rate_limited_total",
+			Help: "Total number of requests throttled by rate limits or daily quotas",
+		},
+		[]string{"protocol", "endpoint", "reason"},
+	)
+
+	// Proxy metrics
+	ProxyRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "servone_proxy_requests_total",
+			Help: "Total number of requests forwarded to upstream services",
+		},
+		[]string{"endpoint", "status"},
+	)
+
+	ProxyRequestDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "servone_proxy_request_duration_seconds",
+			Help:    "Upstream proxy request latencies in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"endpoint"},
+	)
+
 	// MQTT metrics
 	MQTTMessagesReceived = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -69,6 +123,23 @@ var (
 		[]string{"operation", "table"},
 	)
 
+	DBBatchSize = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "servone_db_batch_size",
+			Help:    "Number of rows written per batched COPY",
+			Buckets: prometheus.ExponentialBuckets(1, 4, 8),
+		},
+		[]string{"table"},
+	)
+
+	DBBatchFailedRowsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "servone_db_batch_failed_rows_total",
+			Help: "Total number of batched rows that could not be written or spooled",
+		},
+		[]string{"table"},
+	)
+
 	// Kafka metrics
 	KafkaPublishTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -87,6 +158,138 @@ var (
 		[]string{"topic"},
 	)
 
+	// Ingestion pipeline metrics
+	IngestQueueDepth = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "servone_ingest_queue_depth",
+			Help: "Number of ingestion jobs waiting for a worker",
+		},
+	)
+
+	IngestQueueWait = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "servone_ingest_queue_wait_seconds",
+			Help:    "Time ingestion jobs spent queued before a worker picked them up",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"source"},
+	)
+
+	IngestJobsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "servone_ingest_jobs_total",
+			Help: "Total number of ingestion jobs by outcome (processed, failed, rejected, dropped)",
+		},
+		[]string{"source", "status"},
+	)
+
+	// Spool metrics
+	SpoolBacklogRecords = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "servone_spool_backlog_records",
+			Help: "Number of spooled records waiting to be replayed to a sink",
+		},
+		[]string{"sink"},
+	)
+
+	SpoolBacklogBytes = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "servone_spool_backlog_bytes",
+			Help: "Size of the spooled backlog on disk",
+		},
+		[]string{"sink"},
+	)
+
+	SpoolOldestAge = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "servone_spool_oldest_age_seconds",
+			Help: "Age of the oldest spooled record, 0 when the backlog is empty",
+		},
+		[]string{"sink"},
+	)
+
+	SpoolRecordsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "servone_spool_records_total",
+			Help: "Total number of spool records by event (spooled, replayed, discarded)",
+		},
+		[]string{"sink", "event"},
+	)
+
+	// Outbox metrics
+	OutboxEventsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "servone_outbox_events_total",
+			Help: "Total number of outbox events by outcome (published, failed, deleted)",
+		},
+		[]string{"status"},
+	)
+
+	OutboxPending = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "servone_outbox_pending",
+			Help: "Number of outbox events not yet published to Kafka",
+		},
+	)
+
+	// Partition maintenance metrics
+	PartitionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "servone_partitions_total",
+			Help: "Total number of ingest table partitions by action (created, dropped)",
+		},
+		[]string{"table", "action"},
+	)
+
+	SNMPRollupRowsTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "servone_snmp_rollup_rows_total",
+			Help: "Total number of hourly SNMP rollup rows written before dropping partitions",
+		},
+	)
+
+	// CoAP Observe metrics
+	CoapObservers = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "servone_coap_observers",
+			Help: "Number of registered CoAP observers",
+		},
+		[]string{"path"},
+	)
+
+	CoapNotificationsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "servone_coap_notifications_total",
+			Help: "Total number of CoAP Observe notifications by outcome (sent, failed)",
+		},
+		[]string{"path", "status"},
+	)
+
+	CoapObserverEvictionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "servone_coap_observer_evictions_total",
+			Help: "Total number of CoAP observers removed by reason (deregistered, reset, timeout, closed, removed)",
+		},
+		[]string{"path", "reason"},
+	)
+
+	// CoAP transport metrics
+	CoapTransportRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "servone_coap_transport_requests_total",
+			Help: "Total number of CoAP messages received by transport (udp, dtls, tcp, tls)",
+		},
+		[]string{"transport"},
+	)
+
+	CoapTransportConnections = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "servone_coap_transport_connections",
+			Help: "Number of open CoAP connections by transport (tcp, tls)",
+		},
+		[]string{"transport"},
+	)
+
 	// Connection pool metrics
 	DBConnectionsActive = promauto.NewGauge(
 		prometheus.GaugeOpts{
@@ -121,6 +324,104 @@ func RecordCoapRequest(method, endpoint, status string) {
 	CoapRequestsTotal.WithLabelValues(method, endpoint, status).Inc()
 }
 
+// RecordSchemaValidationFailure records a payload rejected by schema validation
+func RecordSchemaValidationFailure(protocol, endpoint string) {
+	SchemaValidationFailures.WithLabelValues(protocol, endpoint).Inc()
+}
+
+// RecordFaultInjected records a deliberately injected fault
+func RecordFaultInjected(protocol, endpoint, fault string) {
+	FaultsInjectedTotal.WithLabelValues(protocol, endpoint, fault).Inc()
+}
+
+// RecordProxyRequest records an upstream proxy request
+func RecordProxyRequest(endpoint, status string, duration float64) {
+	ProxyRequestsTotal.WithLabelValues(endpoint, status).Inc()
+	ProxyRequestDuration.WithLabelValues(endpoint).Observe(duration)
+}
+
+// RecordAuthFailure records a request rejected by authentication
+func RecordAuthFailure(endpoint, reason string) {
+	AuthFailuresTotal.WithLabelValues(endpoint, reason).Inc()
+}
+
+// RecordRateLimited records a request throttled by a rate limit or daily quota
+func RecordRateLimited(protocol, endpoint, reason string) {
+	RateLimitedTotal.WithLabelValues(protocol, endpoint, reason).Inc()
+}
+
+// SetIngestQueueDepth updates the ingestion queue depth gauge
+func SetIngestQueueDepth(depth int) {
+	IngestQueueDepth.Set(float64(depth))
+}
+
+// ObserveIngestWait records how long an ingestion job waited in the queue
+func ObserveIngestWait(source string, seconds float64) {
+	IngestQueueWait.WithLabelValues(source).Observe(seconds)
+}
+
+// RecordIngestJob records the outcome of an ingestion job
+func RecordIngestJob(source, status string) {
+	IngestJobsTotal.WithLabelValues(source, status).Inc()
+}
+
+// SetSpoolBacklog updates the backlog gauges of a spool queue
+func SetSpoolBacklog(sink string, records int, bytes int64, oldestAgeSeconds float64) {
+	SpoolBacklogRecords.WithLabelValues(sink).Set(float64(records))
+	SpoolBacklogBytes.WithLabelValues(sink).Set(float64(bytes))
+	SpoolOldestAge.WithLabelValues(sink).Set(oldestAgeSeconds)
+}
+
+// RecordSpoolEvent records a record being spooled, replayed or discarded
+func RecordSpoolEvent(sink, event string) {
+	SpoolRecordsTotal.WithLabelValues(sink, event).Inc()
+}
+
+// RecordOutboxEvents records outbox events published, failed or deleted
+func RecordOutboxEvents(status string, n int) {
+	OutboxEventsTotal.WithLabelValues(status).Add(float64(n))
+}
+
+// SetOutboxPending updates the pending outbox events gauge
+func SetOutboxPending(n int64) {
+	OutboxPending.Set(float64(n))
+}
+
+// RecordPartition records a partition being created or dropped
+func RecordPartition(table, action string) {
+	PartitionsTotal.WithLabelValues(table, action).Inc()
+}
+
+// RecordSNMPRollupRows records hourly SNMP rollup rows written
+func RecordSNMPRollupRows(n int64) {
+	SNMPRollupRowsTotal.Add(float64(n))
+}
+
+// AddCoapObservers adjusts the number of observers of a CoAP endpoint
+func AddCoapObservers(path string, delta int) {
+	CoapObservers.WithLabelValues(path).Add(float64(delta))
+}
+
+// RecordCoapNotification records a CoAP Observe notification
+func RecordCoapNotification(path, status string) {
+	CoapNotificationsTotal.WithLabelValues(path, status).Inc()
+}
+
+// RecordCoapObserverEviction records a removed CoAP observer
+func RecordCoapObserverEviction(path, reason string) {
+	CoapObserverEvictionsTotal.WithLabelValues(path, reason).Inc()
+}
+
+// RecordCoapTransportRequest records a CoAP message received on a transport
+func RecordCoapTransportRequest(transport string) {
+	CoapTransportRequestsTotal.WithLabelValues(transport).Inc()
+}
+
+// AddCoapTransportConnections adjusts the number of open CoAP connections on a transport
+func AddCoapTransportConnections(transport string, delta int) {
+	CoapTransportConnections.WithLabelValues(transport).Add(float64(delta))
+}
+
 // RecordMQTTMessage records MQTT message received
 func RecordMQTTMessage(topic string) {
 	MQTTMessagesReceived.WithLabelValues(topic).Inc()
@@ -137,6 +438,16 @@ func RecordDBOperation(operation, table, status string, duration float64) {
 	DBOperationDuration.WithLabelValues(operation, table).Observe(duration)
 }
 
+// RecordDBBatch records the number of rows in a batched write
+func RecordDBBatch(table string, rows int) {
+	DBBatchSize.WithLabelValues(table).Observe(float64(rows))
+}
+
+// RecordDBBatchFailedRow records a batched row that was lost
+func RecordDBBatchFailedRow(table string) {
+	DBBatchFailedRowsTotal.WithLabelValues(table).Inc()
+}
+
 // RecordKafkaPublish records Kafka publish metrics
 func RecordKafkaPublish(topic, status string, duration float64) {
 	KafkaPublishTotal.WithLabelValues(topic, status).Inc()
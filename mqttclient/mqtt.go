@@ -4,6 +4,7 @@ import (
 	"log"
 	"servone/config"
 	"servone/db"
+	"sync"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
@@ -12,6 +13,29 @@ import (
 type MQTTClient struct {
 	client         mqtt.Client
 	kafkaPublisher KafkaPublisher
+
+	mu        sync.RWMutex
+	listeners []MessageListener
+}
+
+// MessageListener is called with every received MQTT message after it has
+// been stored or published.
+type MessageListener func(topic string, payload []byte)
+
+// AddListener registers l for all messages received from now on.
+func (c *MQTTClient) AddListener(l MessageListener) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listeners = append(c.listeners, l)
+}
+
+// notifyListeners passes a received message to the registered listeners.
+func (c *MQTTClient) notifyListeners(topic string, payload []byte) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, l := range c.listeners {
+		l(topic, payload)
+	}
 }
 
 type KafkaPublisher interface {
@@ -42,19 +66,25 @@ func (c *MQTTClient) Subscribe(topic string) error {
 		log.Printf("Received message on topic: %s and message : %s", msg.Topic(), string(msg.Payload()))
 		receivedTime := time.Now().UnixNano()
 		
-		// Save to DB
-		if err := db.SaveMQTTMessage(msg.Topic(), string(msg.Payload()), receivedTime); err != nil {
-			log.Printf("Failed to save MQTT message to database: %v", err)
-			// Continue to publish to Kafka even if DB save fails
-		}
-
-		// Publish to Kafka
 		kafkaTopic := "mq." + msg.Topic()
 		kafkaPayload := map[string]interface{}{
 			"topic":    msg.Topic(),
 			"payload":  string(msg.Payload()),
 			"received": receivedTime,
 		}
+
+		defer c.notifyListeners(msg.Topic(), msg.Payload())
+
+		// Save to DB together with the Kafka event; the outbox relay publishes it
+		if db.DefaultStore() != nil {
+			event := &db.Event{Topic: kafkaTopic, Data: kafkaPayload}
+			if err := db.SaveMQTTMessage(msg.Topic(), string(msg.Payload()), receivedTime, event); err != nil {
+				log.Printf("Failed to save MQTT message to database: %v", err)
+			}
+			return
+		}
+
+		// Without a database publish to Kafka directly
 		if err := c.kafkaPublisher.Publish(kafkaTopic, kafkaPayload); err != nil {
 			log.Printf("Failed to publish MQTT message to Kafka: %v", err)
 		}
@@ -0,0 +1,431 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"servone/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// operationMethods lists the OpenAPI path item keys that describe operations.
+var operationMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// maxSampleDepth bounds the $ref nesting followed while generating examples.
+const maxSampleDepth = 8
+
+// ImportFile reads an OpenAPI 3.x document (JSON or YAML) and converts it into
+// endpoint configs. See Import.
+func ImportFile(path string) ([]config.EndpointConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	endpoints, err := Import(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return endpoints, nil
+}
+
+// Import converts the operations of an OpenAPI 3.x document into endpoint
+// configs. Each operation answers with its first 2xx response (falling back to
+// "default"), using the media type example, the first named example, or a
+// sample generated from the response schema as the body. JSON request body
+// schemas become inline endpoint schemas. Local $refs are resolved.
+func Import(data []byte) ([]config.EndpointConfig, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+	root, ok := normalize(raw).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("OpenAPI document must be an object")
+	}
+	if version, _ := root["openapi"].(string); !strings.HasPrefix(version, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q (only 3.x is supported)", root["openapi"])
+	}
+
+	r := &refResolver{root: root}
+	paths, _ := root["paths"].(map[string]interface{})
+
+	names := make([]string, 0, len(paths))
+	for name := range paths {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return pathLess(names[i], names[j]) })
+
+	var endpoints []config.EndpointConfig
+	for _, path := range names {
+		item, _ := r.resolve(paths[path]).(map[string]interface{})
+		for _, method := range operationMethods {
+			op, ok := r.resolve(item[method]).(map[string]interface{})
+			if !ok {
+				continue
+			}
+			endpoint := config.EndpointConfig{
+				Path:     path,
+				Method:   strings.ToUpper(method),
+				Response: r.importResponse(op),
+			}
+			if schema := r.requestSchema(op); schema != nil {
+				endpoint.Schema = &config.SchemaConfig{Inline: schema}
+			}
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints, nil
+}
+
+// pathLess orders paths so that literal segments sort before templated ones,
+// e.g. /users/me before /users/{id}. gorilla/mux dispatches to the first
+// matching route, so this keeps literal routes reachable.
+func pathLess(a, b string) bool {
+	as, bs := strings.Split(a, "/"), strings.Split(b, "/")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		if as[i] == bs[i] {
+			continue
+		}
+		aVar, bVar := strings.HasPrefix(as[i], "{"), strings.HasPrefix(bs[i], "{")
+		if aVar != bVar {
+			return bVar
+		}
+		return as[i] < bs[i]
+	}
+	return len(as) < len(bs)
+}
+
+// importResponse builds the static response for an operation.
+func (r *refResolver) importResponse(op map[string]interface{}) config.ResponseConfig {
+	responses, _ := r.resolve(op["responses"]).(map[string]interface{})
+	code, status := successResponse(responses)
+	resp := config.ResponseConfig{Status: status}
+
+	spec, _ := r.resolve(responses[code]).(map[string]interface{})
+	if spec == nil {
+		return resp
+	}
+
+	headers, _ := r.resolve(spec["headers"]).(map[string]interface{})
+	for _, name := range sortedKeys(headers) {
+		header, _ := r.resolve(headers[name]).(map[string]interface{})
+		value := header["example"]
+		if value == nil && header["schema"] != nil {
+			value = r.sampleValue(header["schema"], nil)
+		}
+		if value != nil {
+			if resp.Headers == nil {
+				resp.Headers = make(map[string]string)
+			}
+			resp.Headers[name] = fmt.Sprint(value)
+		}
+	}
+
+	content, _ := r.resolve(spec["content"]).(map[string]interface{})
+	mediaType := preferredMediaType(content)
+	if mediaType == "" {
+		return resp
+	}
+	if resp.Headers == nil {
+		resp.Headers = make(map[string]string)
+	}
+	resp.Headers["Content-Type"] = mediaType
+
+	media, _ := r.resolve(content[mediaType]).(map[string]interface{})
+	if value, ok := r.mediaExample(media); ok {
+		resp.Body = encodeExample(value, mediaType)
+	}
+	return resp
+}
+
+// successResponse picks the response key to import and its HTTP status:
+// the lowest 2xx code, then 2XX, then default (200), then the lowest code.
+func successResponse(responses map[string]interface{}) (string, int) {
+	var codes []int
+	for key := range responses {
+		if n, err := strconv.Atoi(key); err == nil {
+			codes = append(codes, n)
+		}
+	}
+	sort.Ints(codes)
+
+	for _, n := range codes {
+		if n >= 200 && n < 300 {
+			return strconv.Itoa(n), n
+		}
+	}
+	for _, key := range []string{"2XX", "2xx", "default"} {
+		if _, ok := responses[key]; ok {
+			return key, 200
+		}
+	}
+	if len(codes) > 0 {
+		return strconv.Itoa(codes[0]), codes[0]
+	}
+	return "", 200
+}
+
+// preferredMediaType prefers application/json, then any +json type, then the
+// first media type in sorted order.
+func preferredMediaType(content map[string]interface{}) string {
+	if _, ok := content["application/json"]; ok {
+		return "application/json"
+	}
+	keys := sortedKeys(content)
+	for _, k := range keys {
+		if strings.HasSuffix(k, "+json") {
+			return k
+		}
+	}
+	if len(keys) > 0 {
+		return keys[0]
+	}
+	return ""
+}
+
+// mediaExample returns the example for a media type object.
+func (r *refResolver) mediaExample(media map[string]interface{}) (interface{}, bool) {
+	if media == nil {
+		return nil, false
+	}
+	if value, ok := media["example"]; ok {
+		return r.resolve(value), true
+	}
+	if examples, ok := r.resolve(media["examples"]).(map[string]interface{}); ok {
+		for _, name := range sortedKeys(examples) {
+			if ex, ok := r.resolve(examples[name]).(map[string]interface{}); ok {
+				if value, ok := ex["value"]; ok {
+					return r.resolve(value), true
+				}
+			}
+		}
+	}
+	if schema, ok := media["schema"]; ok {
+		return r.sampleValue(schema, nil), true
+	}
+	return nil, false
+}
+
+// encodeExample renders an example value as a response body.
+func encodeExample(value interface{}, mediaType string) string {
+	if s, ok := value.(string); ok && !strings.Contains(mediaType, "json") {
+		return s
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(raw)
+}
+
+// requestSchema returns the fully resolved JSON request body schema, if any.
+func (r *refResolver) requestSchema(op map[string]interface{}) map[string]interface{} {
+	body, _ := r.resolve(op["requestBody"]).(map[string]interface{})
+	content, _ := r.resolve(body["content"]).(map[string]interface{})
+	mediaType := preferredMediaType(content)
+	if !strings.Contains(mediaType, "json") {
+		return nil
+	}
+	media, _ := r.resolve(content[mediaType]).(map[string]interface{})
+	schema, _ := r.inline(media["schema"], nil).(map[string]interface{})
+	return schema
+}
+
+// sample generates an example value from a schema (which may be a $ref).
+// It reports false for recursive references, which callers omit.
+func (r *refResolver) sample(v interface{}, stack []string) (interface{}, bool) {
+	if len(stack) > maxSampleDepth {
+		return nil, false
+	}
+	if m, ok := v.(map[string]interface{}); ok {
+		if ref, ok := m["$ref"].(string); ok {
+			for _, seen := range stack {
+				if seen == ref {
+					return nil, false
+				}
+			}
+			return r.sample(r.lookup(ref), append(stack, ref))
+		}
+	}
+	schema, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	for _, key := range []string{"example", "default", "const"} {
+		if value, ok := schema[key]; ok {
+			return value, true
+		}
+	}
+	if examples, ok := schema["examples"].([]interface{}); ok && len(examples) > 0 {
+		return examples[0], true
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		return enum[0], true
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if alts, ok := schema[key].([]interface{}); ok && len(alts) > 0 {
+			return r.sample(alts[0], stack)
+		}
+	}
+	if all, ok := schema["allOf"].([]interface{}); ok {
+		merged := make(map[string]interface{})
+		for _, part := range all {
+			if obj, ok := r.sampleValue(part, stack).(map[string]interface{}); ok {
+				for k, v := range obj {
+					merged[k] = v
+				}
+			}
+		}
+		return merged, true
+	}
+
+	typ := schema["type"]
+	if list, ok := typ.([]interface{}); ok && len(list) > 0 { // OpenAPI 3.1 type arrays
+		typ = list[0]
+	}
+	if typ == nil {
+		if _, ok := schema["properties"]; ok {
+			typ = "object"
+		}
+	}
+	switch typ {
+	case "object":
+		obj := make(map[string]interface{})
+		props, _ := schema["properties"].(map[string]interface{})
+		for name, prop := range props {
+			if value, ok := r.sample(prop, stack); ok {
+				obj[name] = value
+			}
+		}
+		return obj, true
+	case "array":
+		if item, ok := r.sample(schema["items"], stack); ok {
+			return []interface{}{item}, true
+		}
+		return []interface{}{}, true
+	case "integer", "number":
+		return 0, true
+	case "boolean":
+		return true, true
+	case "string":
+		switch schema["format"] {
+		case "date-time":
+			return "2024-01-01T00:00:00Z", true
+		case "date":
+			return "2024-01-01", true
+		case "uuid":
+			return "3fa85f64-5717-4562-b3fc-2c963f66afa6", true
+		case "email":
+			return "user@example.com", true
+		case "uri", "url":
+			return "https://example.com", true
+		}
+		return "string", true
+	}
+	return nil, true
+}
+
+// sampleValue is sample without the recursion flag.
+func (r *refResolver) sampleValue(v interface{}, stack []string) interface{} {
+	value, _ := r.sample(v, stack)
+	return value
+}
+
+// refResolver resolves local JSON references ("#/components/...") against the
+// document root.
+type refResolver struct {
+	root map[string]interface{}
+}
+
+// resolve follows $ref chains on v (one level of the tree only).
+func (r *refResolver) resolve(v interface{}) interface{} {
+	for i := 0; i < 32; i++ {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok {
+			return v
+		}
+		v = r.lookup(ref)
+	}
+	return nil
+}
+
+// inline returns a copy of v with every local $ref replaced by its target.
+// Recursive references are replaced by an empty (accept anything) schema.
+func (r *refResolver) inline(v interface{}, stack []string) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if ref, ok := t["$ref"].(string); ok {
+			for _, seen := range stack {
+				if seen == ref {
+					return map[string]interface{}{}
+				}
+			}
+			return r.inline(r.lookup(ref), append(stack, ref))
+		}
+		out := make(map[string]interface{}, len(t))
+		for k, child := range t {
+			out[k] = r.inline(child, stack)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, child := range t {
+			out[i] = r.inline(child, stack)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// lookup evaluates a local JSON pointer reference. Unknown references yield nil.
+func (r *refResolver) lookup(ref string) interface{} {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil
+	}
+	var cur interface{} = r.root
+	for _, token := range strings.Split(ref[2:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		cur = m[token]
+	}
+	return cur
+}
+
+// normalize converts YAML-decoded maps with non-string keys (e.g. unquoted
+// status codes) into map[string]interface{} recursively.
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			t[k] = normalize(child)
+		}
+		return t
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, child := range t {
+			out[fmt.Sprint(k)] = normalize(child)
+		}
+		return out
+	case []interface{}:
+		for i, child := range t {
+			t[i] = normalize(child)
+		}
+		return t
+	default:
+		return v
+	}
+}
@@ -0,0 +1,128 @@
+package openapi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const petstore = `
+openapi: 3.0.3
+info: {title: pets, version: "1"}
+paths:
+  /pets/{petId}:
+    get:
+      responses:
+        200:
+          description: ok
+          headers:
+            X-Rate-Limit:
+              schema: {type: integer, example: 100}
+          content:
+            application/json:
+              schema: {$ref: '#/components/schemas/Pet'}
+        404: {description: missing}
+    delete:
+      responses:
+        default: {description: deleted}
+  /pets/mine:
+    get:
+      responses:
+        '200':
+          description: ok
+          content:
+            application/json:
+              examples:
+                b: {value: {second: true}}
+                a: {value: [{id: 1, name: rex}]}
+  /pets:
+    post:
+      requestBody:
+        content:
+          application/json:
+            schema: {$ref: '#/components/schemas/Pet'}
+      responses:
+        '201': {description: created}
+        '400': {description: bad}
+  /health:
+    get:
+      responses:
+        '200':
+          description: ok
+          content:
+            text/plain:
+              example: up
+components:
+  schemas:
+    Pet:
+      type: object
+      required: [id]
+      properties:
+        id: {type: integer, format: int64}
+        born: {type: string, format: date}
+        parent: {$ref: '#/components/schemas/Pet'}
+`
+
+func TestImport(t *testing.T) {
+	endpoints, err := Import([]byte(petstore))
+	require.NoError(t, err)
+
+	var keys []string
+	for _, e := range endpoints {
+		keys = append(keys, e.Method+" "+e.Path)
+	}
+	assert.Equal(t, []string{"GET /health", "POST /pets", "GET /pets/mine", "GET /pets/{petId}", "DELETE /pets/{petId}"}, keys)
+
+	t.Run("schema example with recursive ref", func(t *testing.T) {
+		get := endpoints[3].Response
+		assert.Equal(t, 200, get.Status)
+		assert.Equal(t, "application/json", get.Headers["Content-Type"])
+		assert.Equal(t, "100", get.Headers["X-Rate-Limit"])
+		assert.JSONEq(t, `{"id": 0, "born": "2024-01-01"}`, get.Body)
+	})
+
+	t.Run("named examples", func(t *testing.T) {
+		assert.JSONEq(t, `[{"id": 1, "name": "rex"}]`, endpoints[2].Response.Body)
+	})
+
+	t.Run("non JSON example", func(t *testing.T) {
+		assert.Equal(t, "up", endpoints[0].Response.Body)
+		assert.Equal(t, "text/plain", endpoints[0].Response.Headers["Content-Type"])
+	})
+
+	t.Run("default response", func(t *testing.T) {
+		del := endpoints[4].Response
+		assert.Equal(t, 200, del.Status)
+		assert.Empty(t, del.Body)
+	})
+
+	t.Run("request schema inlined", func(t *testing.T) {
+		post := endpoints[1]
+		assert.Equal(t, 201, post.Response.Status)
+		require.NotNil(t, post.Schema)
+		assert.Equal(t, "object", post.Schema.Inline["type"])
+		props := post.Schema.Inline["properties"].(map[string]interface{})
+		assert.Equal(t, map[string]interface{}{}, props["parent"]) // recursive ref replaced
+	})
+}
+
+func TestImportErrors(t *testing.T) {
+	_, err := Import([]byte(`swagger: "2.0"`))
+	assert.ErrorContains(t, err, "unsupported OpenAPI version")
+
+	_, err = Import([]byte(`[1, 2]`))
+	assert.Error(t, err)
+
+	_, err = ImportFile(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestPathLess(t *testing.T) {
+	assert.True(t, pathLess("/users/me", "/users/{id}"))
+	assert.False(t, pathLess("/users/{id}", "/users/me"))
+	assert.True(t, pathLess("/a", "/b"))
+	assert.True(t, pathLess("/users", "/users/{id}"))
+}
@@ -0,0 +1,372 @@
+// Package openapi generates an OpenAPI 3.1 document describing the REST
+// endpoints defined in the servone configuration.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"servone/config"
+)
+
+// Version is the OpenAPI specification version emitted by Generate.
+const Version = "3.1.0"
+
+// Document is the root OpenAPI object.
+type Document struct {
+	OpenAPI string               `json:"openapi"`
+	Info    Info                 `json:"info"`
+	Paths   map[string]*PathItem `json:"paths"`
+}
+
+// Info holds the API metadata.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps lower-case HTTP methods to operations.
+type PathItem map[string]*Operation
+
+// Operation describes a single endpoint.
+type Operation struct {
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	OperationID string               `json:"operationId"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter describes a path, query or header parameter.
+type Parameter struct {
+	Name     string                 `json:"name"`
+	In       string                 `json:"in"`
+	Required bool                   `json:"required,omitempty"`
+	Schema   map[string]interface{} `json:"schema"`
+}
+
+// RequestBody describes the expected request payload.
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response describes one response status.
+type Response struct {
+	Description string                `json:"description"`
+	Headers     map[string]*Header    `json:"headers,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// Header describes a response header.
+type Header struct {
+	Schema  map[string]interface{} `json:"schema"`
+	Example string                 `json:"example,omitempty"`
+}
+
+// MediaType holds the schema and examples for one content type.
+type MediaType struct {
+	Schema   interface{}         `json:"schema,omitempty"`
+	Example  interface{}         `json:"example,omitempty"`
+	Examples map[string]*Example `json:"examples,omitempty"`
+}
+
+// Example is a named example value.
+type Example struct {
+	Summary string      `json:"summary,omitempty"`
+	Value   interface{} `json:"value"`
+}
+
+// pathVarPattern matches gorilla/mux path variables, e.g. {id} or {id:[0-9]+}.
+var pathVarPattern = regexp.MustCompile(`\{([^{}:]+)(?::([^{}]+))?\}`)
+
+// nonIdentChars is used to derive operation IDs from paths.
+var nonIdentChars = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// Generate builds the OpenAPI document for the REST endpoints in rest.
+// Problems that only affect part of the document (e.g. an unreadable schema
+// file) are returned as errors while the rest of the document is still built.
+func Generate(rest config.RestConfig, info Info) (*Document, []error) {
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]*PathItem),
+	}
+
+	var errs []error
+	for _, endpoint := range rest.Endpoints {
+		path, params := convertPath(endpoint.Path)
+		method := strings.ToLower(endpoint.Method)
+
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = &PathItem{}
+			doc.Paths[path] = item
+		}
+		if _, exists := (*item)[method]; exists {
+			// gorilla/mux dispatches to the first registered route, so later duplicates are unreachable
+			errs = append(errs, fmt.Errorf("%s %s: duplicate endpoint ignored", endpoint.Method, endpoint.Path))
+			continue
+		}
+
+		op := &Operation{
+			Summary:     strings.ToUpper(endpoint.Method) + " " + endpoint.Path,
+			OperationID: operationID(method, endpoint.Path),
+			Parameters:  append(params, matchParameters(endpoint)...),
+			Responses:   buildResponses(endpoint),
+		}
+		if endpoint.Response.Proxy != nil {
+			op.Description = "Proxied to " + endpoint.Response.Proxy.URL
+		}
+
+		body, err := requestBody(endpoint.Schema)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", endpoint.Method, endpoint.Path, err))
+		}
+		op.RequestBody = body
+
+		(*item)[method] = op
+	}
+
+	return doc, errs
+}
+
+// convertPath turns a gorilla/mux path into an OpenAPI path template and its
+// path parameters. Regex constraints become the parameter pattern.
+func convertPath(path string) (string, []Parameter) {
+	var params []Parameter
+	for _, m := range pathVarPattern.FindAllStringSubmatch(path, -1) {
+		schema := map[string]interface{}{"type": "string"}
+		if m[2] != "" {
+			schema["pattern"] = "^" + m[2] + "$"
+		}
+		params = append(params, Parameter{Name: m[1], In: "path", Required: true, Schema: schema})
+	}
+	return pathVarPattern.ReplaceAllString(path, "{$1}"), params
+}
+
+// operationID derives a stable identifier such as post_api_devices_id.
+func operationID(method, path string) string {
+	id := strings.Trim(nonIdentChars.ReplaceAllString(path, "_"), "_")
+	if id == "" {
+		return method + "_root"
+	}
+	return method + "_" + id
+}
+
+// matchParameters lists the query parameters and headers referenced by
+// response variants and scenario transitions as optional parameters.
+func matchParameters(endpoint config.EndpointConfig) []Parameter {
+	matches := make([]config.MatchConfig, 0, len(endpoint.Responses))
+	for _, v := range endpoint.Responses {
+		matches = append(matches, v.Match)
+	}
+	if endpoint.Scenario != nil {
+		for _, tr := range endpoint.Scenario.Transitions {
+			matches = append(matches, tr.Match)
+		}
+	}
+
+	query := make(map[string]bool)
+	headers := make(map[string]bool)
+	for _, m := range matches {
+		for name := range m.Query {
+			query[name] = true
+		}
+		for name := range m.Headers {
+			headers[http.CanonicalHeaderKey(name)] = true
+		}
+	}
+
+	var params []Parameter
+	for _, name := range sortedKeys(query) {
+		params = append(params, Parameter{Name: name, In: "query", Schema: map[string]interface{}{"type": "string"}})
+	}
+	for _, name := range sortedKeys(headers) {
+		params = append(params, Parameter{Name: name, In: "header", Schema: map[string]interface{}{"type": "string"}})
+	}
+	return params
+}
+
+// labeledResponse is one possible response of an endpoint with a label
+// describing when it is returned.
+type labeledResponse struct {
+	label    string
+	response config.ResponseConfig
+}
+
+// buildResponses groups every response an endpoint can return by status code.
+func buildResponses(endpoint config.EndpointConfig) map[string]*Response {
+	candidates := []labeledResponse{{label: "default", response: endpoint.Response}}
+	for i, v := range endpoint.Responses {
+		label := v.Name
+		if label == "" {
+			label = fmt.Sprintf("variant %d", i+1)
+		}
+		candidates = append(candidates, labeledResponse{label: label, response: v.Response})
+	}
+	if endpoint.Sequence != nil {
+		for i, step := range endpoint.Sequence.Steps {
+			candidates = append(candidates, labeledResponse{label: fmt.Sprintf("sequence step %d", i+1), response: step.Response})
+		}
+	}
+	if endpoint.Scenario != nil {
+		for _, state := range sortedKeys(endpoint.Scenario.States) {
+			label := fmt.Sprintf("scenario %s: %s", endpoint.Scenario.Name, state)
+			candidates = append(candidates, labeledResponse{label: label, response: endpoint.Scenario.States[state]})
+		}
+	}
+
+	grouped := make(map[string][]labeledResponse)
+	var order []string
+	for _, c := range candidates {
+		status := c.response.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		key := strconv.Itoa(status)
+		if _, ok := grouped[key]; !ok {
+			order = append(order, key)
+		}
+		grouped[key] = append(grouped[key], c)
+	}
+
+	responses := make(map[string]*Response, len(grouped))
+	for _, key := range order {
+		responses[key] = buildResponse(key, grouped[key])
+	}
+	return responses
+}
+
+// buildResponse merges the candidates sharing one status code.
+func buildResponse(status string, candidates []labeledResponse) *Response {
+	code, _ := strconv.Atoi(status)
+	labels := make([]string, 0, len(candidates))
+	for _, c := range candidates {
+		labels = append(labels, c.label)
+	}
+	resp := &Response{Description: http.StatusText(code)}
+	if resp.Description == "" {
+		resp.Description = "Status " + status
+	}
+	resp.Description += " (" + strings.Join(labels, ", ") + ")"
+
+	examples := make(map[string]map[string]*Example)
+	var mediaOrder []string
+	for _, c := range candidates {
+		for name, value := range c.response.Headers {
+			if strings.EqualFold(name, "Content-Type") {
+				continue
+			}
+			if resp.Headers == nil {
+				resp.Headers = make(map[string]*Header)
+			}
+			if _, ok := resp.Headers[name]; !ok {
+				resp.Headers[name] = &Header{Schema: map[string]interface{}{"type": "string"}, Example: value}
+			}
+		}
+
+		if c.response.Body == "" {
+			continue
+		}
+		mediaType, value := exampleValue(c.response)
+		if _, ok := examples[mediaType]; !ok {
+			examples[mediaType] = make(map[string]*Example)
+			mediaOrder = append(mediaOrder, mediaType)
+		}
+		examples[mediaType][exampleKey(c.label)] = &Example{Summary: c.label, Value: value}
+	}
+
+	for _, mediaType := range mediaOrder {
+		if resp.Content == nil {
+			resp.Content = make(map[string]*MediaType)
+		}
+		mt := &MediaType{}
+		if named := examples[mediaType]; len(named) == 1 {
+			for _, ex := range named {
+				mt.Example = ex.Value
+			}
+		} else {
+			mt.Examples = named
+		}
+		resp.Content[mediaType] = mt
+	}
+	return resp
+}
+
+// exampleValue returns the media type and example value of a response body.
+// JSON bodies become structured examples; templated or non-JSON bodies are
+// kept verbatim as strings.
+func exampleValue(response config.ResponseConfig) (string, interface{}) {
+	mediaType := ""
+	for name, value := range response.Headers {
+		if strings.EqualFold(name, "Content-Type") {
+			mediaType = strings.TrimSpace(strings.Split(value, ";")[0])
+		}
+	}
+
+	var parsed interface{}
+	isJSON := json.Unmarshal([]byte(response.Body), &parsed) == nil
+	if mediaType == "" {
+		if isJSON {
+			mediaType = "application/json"
+		} else {
+			mediaType = "text/plain"
+		}
+	}
+	if isJSON {
+		return mediaType, parsed
+	}
+	return mediaType, response.Body
+}
+
+// exampleKey turns a label into an example map key.
+func exampleKey(label string) string {
+	return strings.Trim(nonIdentChars.ReplaceAllString(strings.ToLower(label), "_"), "_")
+}
+
+// requestBody describes the request payload using the endpoint JSON Schema.
+func requestBody(cfg *config.SchemaConfig) (*RequestBody, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	var schema interface{}
+	switch {
+	case cfg.File != "":
+		raw, err := os.ReadFile(cfg.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema %s: %w", cfg.File, err)
+		}
+		if err := json.Unmarshal(raw, &schema); err != nil {
+			return nil, fmt.Errorf("failed to parse schema %s: %w", cfg.File, err)
+		}
+	case len(cfg.Inline) > 0:
+		schema = cfg.Inline
+	default:
+		return nil, nil
+	}
+
+	return &RequestBody{
+		Required: true,
+		Content:  map[string]*MediaType{"application/json": {Schema: schema}},
+	}, nil
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,116 @@
+package openapi
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"servone/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertPath(t *testing.T) {
+	path, params := convertPath("/api/devices/{id:[0-9]+}/sensors/{sensor}")
+	assert.Equal(t, "/api/devices/{id}/sensors/{sensor}", path)
+	require.Len(t, params, 2)
+	assert.Equal(t, "id", params[0].Name)
+	assert.Equal(t, "^[0-9]+$", params[0].Schema["pattern"])
+	assert.True(t, params[1].Required)
+	assert.Equal(t, "path", params[1].In)
+}
+
+func TestGenerate(t *testing.T) {
+	schemaFile := filepath.Join(t.TempDir(), "tempo.json")
+	require.NoError(t, os.WriteFile(schemaFile, []byte(`{"type": "object", "required": ["temp"]}`), 0644))
+
+	rest := config.RestConfig{
+		Endpoints: []config.EndpointConfig{
+			{
+				Path:   "/api/users",
+				Method: "GET",
+				Response: config.ResponseConfig{
+					Status:  200,
+					Body:    `{"users": []}`,
+					Headers: map[string]string{"Content-Type": "application/json", "X-Total": "0"},
+				},
+				Responses: []config.ResponseVariant{
+					{Name: "missing", Match: config.MatchConfig{Query: map[string]string{"id": "404"}, Headers: map[string]string{"x-tenant": "*"}}, Response: config.ResponseConfig{Status: 404, Body: "not found"}},
+					{Name: "other", Response: config.ResponseConfig{Status: 200, Body: `{"users": [1]}`}},
+				},
+			},
+			{
+				Path:     "/api/tempo/{id}",
+				Method:   "POST",
+				Response: config.ResponseConfig{Status: 201, Body: `{"id": {{.id}}}`},
+				Schema:   &config.SchemaConfig{File: schemaFile},
+			},
+			{
+				Path:     "/api/inline",
+				Method:   "PUT",
+				Response: config.ResponseConfig{},
+				Schema:   &config.SchemaConfig{Inline: map[string]interface{}{"type": "object"}},
+			},
+			{
+				Path:     "/api/broken",
+				Method:   "POST",
+				Response: config.ResponseConfig{Status: 204},
+				Schema:   &config.SchemaConfig{File: filepath.Join(t.TempDir(), "missing.json")},
+			},
+		},
+	}
+
+	doc, errs := Generate(rest, Info{Title: "test", Version: "1"})
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "/api/broken")
+	assert.Equal(t, Version, doc.OpenAPI)
+
+	t.Run("responses grouped by status", func(t *testing.T) {
+		op := (*doc.Paths["/api/users"])["get"]
+		require.NotNil(t, op)
+		assert.Equal(t, "get_api_users", op.OperationID)
+
+		ok := op.Responses["200"]
+		require.NotNil(t, ok)
+		assert.Equal(t, "OK (default, other)", ok.Description)
+		assert.Equal(t, "0", ok.Headers["X-Total"].Example)
+		assert.NotContains(t, ok.Headers, "Content-Type")
+		require.Contains(t, ok.Content, "application/json")
+		assert.Len(t, ok.Content["application/json"].Examples, 2)
+
+		notFound := op.Responses["404"]
+		require.NotNil(t, notFound)
+		assert.Equal(t, "not found", notFound.Content["text/plain"].Example)
+	})
+
+	t.Run("match parameters", func(t *testing.T) {
+		op := (*doc.Paths["/api/users"])["get"]
+		require.Len(t, op.Parameters, 2)
+		assert.Equal(t, Parameter{Name: "id", In: "query", Schema: map[string]interface{}{"type": "string"}}, op.Parameters[0])
+		assert.Equal(t, "X-Tenant", op.Parameters[1].Name)
+		assert.Equal(t, "header", op.Parameters[1].In)
+	})
+
+	t.Run("request schemas", func(t *testing.T) {
+		op := (*doc.Paths["/api/tempo/{id}"])["post"]
+		require.NotNil(t, op.RequestBody)
+		schema := op.RequestBody.Content["application/json"].Schema.(map[string]interface{})
+		assert.Equal(t, "object", schema["type"])
+		assert.Equal(t, `{"id": {{.id}}}`, op.Responses["201"].Content["text/plain"].Example)
+
+		inline := (*doc.Paths["/api/inline"])["put"]
+		require.NotNil(t, inline.RequestBody)
+		assert.Contains(t, inline.Responses, "200")
+		assert.Nil(t, inline.Responses["200"].Content)
+
+		assert.Nil(t, (*doc.Paths["/api/broken"])["post"].RequestBody)
+	})
+
+	t.Run("marshals to JSON", func(t *testing.T) {
+		raw, err := json.Marshal(doc)
+		require.NoError(t, err)
+		assert.Contains(t, string(raw), `"openapi":"3.1.0"`)
+	})
+}
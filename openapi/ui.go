@@ -0,0 +1,9 @@
+package openapi
+
+import _ "embed"
+
+// UIPage is a self-contained HTML page that renders the document served at
+// openapi.json (relative to the page) and lets users try requests out.
+//
+//go:embed ui.html
+var UIPage []byte
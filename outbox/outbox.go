@@ -0,0 +1,239 @@
+// Package outbox relays Kafka events from the database outbox table to
+// Kafka. Ingestion writes each data row and its event in one transaction;
+// the relay publishes pending events with at-least-once delivery, marks
+// them sent, retries failures with exponential backoff and deletes sent
+// events after a retention period.
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"servone/config"
+	"servone/db"
+	"servone/kafka"
+	"servone/metrics"
+)
+
+// Defaults used when the outbox config leaves a field unset.
+const (
+	DefaultPollInterval = time.Second
+	DefaultBatchSize    = 100
+	DefaultMinBackoff   = time.Second
+	DefaultMaxBackoff   = time.Minute
+	DefaultRetention    = 24 * time.Hour
+)
+
+// Lease is how long claimed events are hidden from other relays. An event
+// whose relay dies before marking it sent is published again afterwards.
+const Lease = 30 * time.Second
+
+// cleanupInterval is how often sent events are deleted and the pending
+// gauge is refreshed.
+const cleanupInterval = time.Minute
+
+// Store is the outbox table. db.OutboxStore implements it.
+type Store interface {
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]db.OutboxEntry, error)
+	MarkSent(ctx context.Context, ids []int64) error
+	MarkFailed(ctx context.Context, id int64, reason string, retryAt time.Time) error
+	DeleteSent(ctx context.Context, before time.Time) (int64, error)
+	Pending(ctx context.Context) (int64, error)
+}
+
+// Relay publishes outbox events to Kafka.
+type Relay struct {
+	store     Store
+	publisher kafka.KafkaPublisherInterface
+
+	interval   time.Duration
+	batch      int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	retention  time.Duration
+	now        func() time.Time
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// New creates a relay. Call Start to begin publishing.
+func New(store Store, publisher kafka.KafkaPublisherInterface, cfg config.OutboxConfig) (*Relay, error) {
+	if cfg.PollIntervalMs < 0 || cfg.BatchSize < 0 || cfg.MinBackoffMs < 0 || cfg.MaxBackoffMs < 0 || cfg.RetentionHours < 0 {
+		return nil, errors.New("outbox: settings must not be negative")
+	}
+
+	r := &Relay{
+		store:      store,
+		publisher:  publisher,
+		interval:   durationOr(cfg.PollIntervalMs, time.Millisecond, DefaultPollInterval),
+		batch:      cfg.BatchSize,
+		minBackoff: durationOr(cfg.MinBackoffMs, time.Millisecond, DefaultMinBackoff),
+		maxBackoff: durationOr(cfg.MaxBackoffMs, time.Millisecond, DefaultMaxBackoff),
+		retention:  durationOr(cfg.RetentionHours, time.Hour, DefaultRetention),
+		now:        time.Now,
+		stop:       make(chan struct{}),
+	}
+	if r.batch == 0 {
+		r.batch = DefaultBatchSize
+	}
+	if r.maxBackoff < r.minBackoff {
+		return nil, fmt.Errorf("outbox: max_backoff_ms (%v) is below min_backoff_ms (%v)", r.maxBackoff, r.minBackoff)
+	}
+	return r, nil
+}
+
+func durationOr(n int, unit, def time.Duration) time.Duration {
+	if n == 0 {
+		return def
+	}
+	return time.Duration(n) * unit
+}
+
+// Backoff returns the delay before the next attempt after the given number
+// of failed attempts: min, 2*min, 4*min, ... capped at max.
+func (r *Relay) Backoff(attempts int) time.Duration {
+	d := r.minBackoff
+	for i := 1; i < attempts && d < r.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > r.maxBackoff {
+		d = r.maxBackoff
+	}
+	return d
+}
+
+// Start runs the relay in the background until Close.
+func (r *Relay) Start() {
+	r.done = make(chan struct{})
+	go r.run()
+}
+
+func (r *Relay) run() {
+	defer close(r.done)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-r.stop
+		cancel()
+	}()
+
+	lastCleanup := time.Time{}
+	for {
+		r.drain(ctx)
+		if time.Since(lastCleanup) >= cleanupInterval {
+			r.cleanup(ctx)
+			lastCleanup = time.Now()
+		}
+
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// drain publishes batches until the outbox has nothing due or a publish
+// fails (the broker is likely down; the next tick tries again).
+func (r *Relay) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		n, err := r.RunOnce(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Outbox relay: %v", err)
+			}
+			return
+		}
+		if n < r.batch {
+			return
+		}
+	}
+}
+
+// RunOnce claims one batch of due events and publishes it. It returns the
+// number of events published.
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	entries, err := r.store.Claim(ctx, r.batch, Lease)
+	if err != nil {
+		return 0, err
+	}
+
+	// record outcomes even if the relay is being stopped, so finished
+	// publishes are not repeated
+	markCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+
+	var sent []int64
+	failed := 0
+	for _, e := range entries {
+		if err := r.publish(e); err != nil {
+			failed++
+			retryAt := r.now().Add(r.Backoff(e.Attempts + 1))
+			log.Printf("Outbox relay: failed to publish event %d to %s (attempt %d, retry at %s): %v",
+				e.ID, e.Topic, e.Attempts+1, retryAt.Format(time.RFC3339), err)
+			if err := r.store.MarkFailed(markCtx, e.ID, err.Error(), retryAt); err != nil {
+				log.Printf("Outbox relay: %v", err)
+			}
+			continue
+		}
+		sent = append(sent, e.ID)
+	}
+	metrics.RecordOutboxEvents("failed", failed)
+
+	if len(sent) > 0 {
+		// if this fails the events are published again once the lease expires
+		if err := r.store.MarkSent(markCtx, sent); err != nil {
+			return 0, err
+		}
+		metrics.RecordOutboxEvents("published", len(sent))
+	}
+	return len(sent), nil
+}
+
+func (r *Relay) publish(e db.OutboxEntry) error {
+	var data map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(e.Payload))
+	dec.UseNumber() // keep integers such as "received" exact
+	if err := dec.Decode(&data); err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+	return r.publisher.Publish(e.Topic, data)
+}
+
+// cleanup deletes events sent before the retention period and refreshes the
+// pending gauge.
+func (r *Relay) cleanup(ctx context.Context) {
+	deleted, err := r.store.DeleteSent(ctx, r.now().Add(-r.retention))
+	if err != nil {
+		log.Printf("Outbox relay: %v", err)
+	} else if deleted > 0 {
+		metrics.RecordOutboxEvents("deleted", int(deleted))
+	}
+
+	pending, err := r.store.Pending(ctx)
+	if err != nil {
+		log.Printf("Outbox relay: %v", err)
+		return
+	}
+	metrics.SetOutboxPending(pending)
+}
+
+// Close stops the relay and waits for the current batch to finish.
+// Unpublished events stay in the outbox.
+func (r *Relay) Close() {
+	r.stopOnce.Do(func() { close(r.stop) })
+	if r.done != nil {
+		<-r.done
+	}
+}
@@ -0,0 +1,318 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"servone/config"
+	"servone/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryEntry is a row of memoryStore.
+type memoryEntry struct {
+	db.OutboxEntry
+	nextAttempt time.Time
+	sentAt      *time.Time
+	lastError   string
+}
+
+// memoryStore is an in-memory outbox table with the same claim semantics.
+type memoryStore struct {
+	mu      sync.Mutex
+	now     func() time.Time
+	entries map[int64]*memoryEntry
+	nextID  int64
+}
+
+func newMemoryStore(now func() time.Time) *memoryStore {
+	return &memoryStore{now: now, entries: make(map[int64]*memoryEntry)}
+}
+
+func (s *memoryStore) add(topic, payload string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	s.entries[s.nextID] = &memoryEntry{OutboxEntry: db.OutboxEntry{ID: s.nextID, Topic: topic, Payload: json.RawMessage(payload)}}
+	return s.nextID
+}
+
+func (s *memoryStore) get(id int64) memoryEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.entries[id]
+}
+
+func (s *memoryStore) Claim(ctx context.Context, limit int, lease time.Duration) ([]db.OutboxEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []db.OutboxEntry
+	for _, e := range s.entries {
+		if e.sentAt == nil && !e.nextAttempt.After(s.now()) {
+			due = append(due, e.OutboxEntry)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].ID < due[j].ID })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+	for _, e := range due {
+		s.entries[e.ID].nextAttempt = s.now().Add(lease)
+	}
+	return due, nil
+}
+
+func (s *memoryStore) MarkSent(ctx context.Context, ids []int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	for _, id := range ids {
+		s.entries[id].sentAt = &now
+	}
+	return nil
+}
+
+func (s *memoryStore) MarkFailed(ctx context.Context, id int64, reason string, retryAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.entries[id]
+	e.Attempts++
+	e.lastError = reason
+	e.nextAttempt = retryAt
+	return nil
+}
+
+func (s *memoryStore) DeleteSent(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for id, e := range s.entries {
+		if e.sentAt != nil && e.sentAt.Before(before) {
+			delete(s.entries, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (s *memoryStore) Pending(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var n int64
+	for _, e := range s.entries {
+		if e.sentAt == nil {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// fakeKafka records published messages and fails while down is set.
+type fakeKafka struct {
+	mu        sync.Mutex
+	down      bool
+	published []string
+	data      []map[string]interface{}
+}
+
+func (f *fakeKafka) Publish(topic string, data map[string]interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return errors.New("broker unavailable")
+	}
+	f.published = append(f.published, topic)
+	f.data = append(f.data, data)
+	return nil
+}
+
+func (f *fakeKafka) Close() {}
+
+func (f *fakeKafka) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+func (f *fakeKafka) topics() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.published...)
+}
+
+// clock is a settable time source.
+type clock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *clock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = c.t.Add(d)
+}
+
+func newRelay(t *testing.T, store Store, broker *fakeKafka, c *clock, cfg config.OutboxConfig) *Relay {
+	t.Helper()
+	r, err := New(store, broker, cfg)
+	require.NoError(t, err)
+	r.now = c.now
+	return r
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(nil, nil, config.OutboxConfig{BatchSize: -1})
+	assert.Error(t, err)
+
+	_, err = New(nil, nil, config.OutboxConfig{MinBackoffMs: 5000, MaxBackoffMs: 1000})
+	assert.ErrorContains(t, err, "max_backoff_ms")
+
+	r, err := New(nil, nil, config.OutboxConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultBatchSize, r.batch)
+	assert.Equal(t, DefaultPollInterval, r.interval)
+	assert.Equal(t, DefaultRetention, r.retention)
+}
+
+func TestBackoff(t *testing.T) {
+	r, err := New(nil, nil, config.OutboxConfig{MinBackoffMs: 100, MaxBackoffMs: 1000})
+	require.NoError(t, err)
+
+	assert.Equal(t, 100*time.Millisecond, r.Backoff(1))
+	assert.Equal(t, 200*time.Millisecond, r.Backoff(2))
+	assert.Equal(t, 400*time.Millisecond, r.Backoff(3))
+	assert.Equal(t, 800*time.Millisecond, r.Backoff(4))
+	assert.Equal(t, time.Second, r.Backoff(5))
+	assert.Equal(t, time.Second, r.Backoff(50))
+}
+
+func TestRunOnce(t *testing.T) {
+	t.Run("publishes due events in order and marks them sent", func(t *testing.T) {
+		c := &clock{t: time.Unix(1700000000, 0)}
+		store := newMemoryStore(c.now)
+		broker := &fakeKafka{}
+		r := newRelay(t, store, broker, c, config.OutboxConfig{})
+
+		first := store.add("api.tempo", `{"url": "/api/tempo", "received": 1700000000000000001}`)
+		store.add("mq.sensors", `{"topic": "sensors"}`)
+
+		n, err := r.RunOnce(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+		assert.Equal(t, []string{"api.tempo", "mq.sensors"}, broker.topics())
+		assert.NotNil(t, store.get(first).sentAt)
+		assert.Equal(t, json.Number("1700000000000000001"), broker.data[0]["received"], "large integers survive the round trip")
+
+		n, err = r.RunOnce(context.Background())
+		require.NoError(t, err)
+		assert.Zero(t, n, "sent events are not published again")
+	})
+
+	t.Run("retries failed events with backoff", func(t *testing.T) {
+		c := &clock{t: time.Unix(1700000000, 0)}
+		store := newMemoryStore(c.now)
+		broker := &fakeKafka{down: true}
+		r := newRelay(t, store, broker, c, config.OutboxConfig{MinBackoffMs: 1000, MaxBackoffMs: 10000})
+
+		id := store.add("api.tempo", `{"temp": 21}`)
+		n, err := r.RunOnce(context.Background())
+		require.NoError(t, err)
+		assert.Zero(t, n)
+
+		e := store.get(id)
+		assert.Equal(t, 1, e.Attempts)
+		assert.Equal(t, "broker unavailable", e.lastError)
+		assert.Equal(t, c.now().Add(time.Second), e.nextAttempt)
+
+		// still failing: the second attempt waits twice as long
+		c.advance(time.Second)
+		_, err = r.RunOnce(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, c.now().Add(2*time.Second), store.get(id).nextAttempt)
+
+		// not due yet
+		broker.setDown(false)
+		c.advance(time.Second)
+		n, err = r.RunOnce(context.Background())
+		require.NoError(t, err)
+		assert.Zero(t, n)
+
+		c.advance(time.Second)
+		n, err = r.RunOnce(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.Equal(t, []string{"api.tempo"}, broker.topics())
+	})
+
+	t.Run("claimed events are hidden until the lease expires", func(t *testing.T) {
+		c := &clock{t: time.Unix(1700000000, 0)}
+		store := newMemoryStore(c.now)
+		id := store.add("api.tempo", `{}`)
+
+		// a relay claimed the event and died before marking it
+		_, err := store.Claim(context.Background(), 10, Lease)
+		require.NoError(t, err)
+
+		broker := &fakeKafka{}
+		r := newRelay(t, store, broker, c, config.OutboxConfig{})
+		n, err := r.RunOnce(context.Background())
+		require.NoError(t, err)
+		assert.Zero(t, n)
+
+		c.advance(Lease)
+		n, err = r.RunOnce(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, n)
+		assert.NotNil(t, store.get(id).sentAt)
+	})
+}
+
+func TestCleanup(t *testing.T) {
+	c := &clock{t: time.Unix(1700000000, 0)}
+	store := newMemoryStore(c.now)
+	broker := &fakeKafka{}
+	r := newRelay(t, store, broker, c, config.OutboxConfig{RetentionHours: 1})
+
+	old := store.add("api.tempo", `{}`)
+	_, err := r.RunOnce(context.Background())
+	require.NoError(t, err)
+
+	c.advance(2 * time.Hour)
+	pending := store.add("api.tempo", `{}`)
+	r.cleanup(context.Background())
+
+	store.mu.Lock()
+	_, oldKept := store.entries[old]
+	_, pendingKept := store.entries[pending]
+	store.mu.Unlock()
+	assert.False(t, oldKept, "sent events past retention are deleted")
+	assert.True(t, pendingKept, "unsent events are never deleted")
+}
+
+func TestRelayLoop(t *testing.T) {
+	c := &clock{t: time.Unix(1700000000, 0)}
+	store := newMemoryStore(c.now)
+	broker := &fakeKafka{}
+	r := newRelay(t, store, broker, c, config.OutboxConfig{PollIntervalMs: 10, BatchSize: 2})
+
+	for i := 0; i < 5; i++ {
+		store.add("api.tempo", `{}`)
+	}
+	r.Start()
+	assert.Eventually(t, func() bool { return len(broker.topics()) == 5 }, time.Second, 10*time.Millisecond)
+	r.Close()
+	r.Close() // closing twice is safe
+}
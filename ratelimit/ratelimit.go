@@ -0,0 +1,305 @@
+// Package ratelimit 은 클라이언트별 토큰 버킷과 일일 할당량으로 수신 요청을 제한합니다.
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"servone/config"
+)
+
+// Decision.Reason 에 기록되는 거절 사유 (메트릭 레이블로도 사용)
+const (
+	ReasonRate  = "rate_limit"
+	ReasonQuota = "quota"
+)
+
+// RateLimitConfig.Key 에 쓸 수 있는 키 종류
+const (
+	KeyGlobal = "global"
+	KeyIP     = "ip"
+	KeyAPIKey = "api_key"
+	KeyHeader = "header"
+	KeyBody   = "body"
+)
+
+// sweepInterval은 가득 찬 유휴 버킷을 정리하는 주기입니다.
+const sweepInterval = time.Minute
+
+// Client는 제한 키로 쓸 수 있는 요청 속성을 담습니다.
+type Client struct {
+	IP      string
+	Subject string                   // 인증 미들웨어가 검증한 주체 (익명 요청은 "")
+	Header  func(name string) string // 헤더가 없는 프로토콜(CoAP)은 nil
+	Body    []byte
+}
+
+// Decision은 제한 검사 결과입니다.
+type Decision struct {
+	Allowed    bool
+	Reason     string        // 거절 시 ReasonRate 또는 ReasonQuota
+	RetryAfter time.Duration // 거절 시 클라이언트가 기다려야 하는 시간
+}
+
+// RetryAfterSeconds는 RetryAfter 를 초 단위로 올림한 값(최소 1)을 반환합니다.
+// HTTP Retry-After 헤더와 CoAP Max-Age 옵션에 사용합니다.
+func (d Decision) RetryAfterSeconds() int {
+	return int(math.Max(1, math.Ceil(d.RetryAfter.Seconds())))
+}
+
+// Counter는 일일 할당량 사용량을 기록합니다.
+type Counter interface {
+	// Take는 key 의 day 할당량에서 요청 하나를 차감하고, 할당량이 남아 있었는지 반환합니다.
+	Take(ctx context.Context, scope, key string, day time.Time, limit int64) (bool, error)
+	// Refund는 key 의 day 할당량에서 차감한 요청 하나를 되돌립니다.
+	Refund(ctx context.Context, scope, key string, day time.Time) error
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter는 하나의 범위(엔드포인트 또는 서버 전체)에 RateLimitConfig 하나를 적용합니다.
+type Limiter struct {
+	scope   string
+	cfg     config.RateLimitConfig
+	kind    string
+	arg     string // 헤더 이름 또는 본문 필드 경로
+	burst   float64
+	counter Counter
+	now     func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// New는 제한 설정을 컴파일합니다. counter 는 cfg.DailyQuota 가 있을 때만 사용합니다.
+func New(scope string, cfg config.RateLimitConfig, counter Counter) (*Limiter, error) {
+	if cfg.Rate < 0 || cfg.Burst < 0 || cfg.DailyQuota < 0 {
+		return nil, fmt.Errorf("rate limit %s: rate, burst and daily_quota must not be negative", scope)
+	}
+
+	kind, arg, _ := strings.Cut(cfg.Key, ":")
+	kind = strings.ToLower(strings.TrimSpace(kind))
+	arg = strings.TrimSpace(arg)
+	switch kind {
+	case "":
+		kind = KeyGlobal
+	case KeyGlobal, KeyIP, KeyAPIKey:
+	case KeyHeader, KeyBody:
+		if arg == "" {
+			return nil, fmt.Errorf("rate limit %s: key %q needs a name, e.g. %s:device_id", scope, cfg.Key, kind)
+		}
+	default:
+		return nil, fmt.Errorf("rate limit %s: unknown key %q (use global, ip, api_key, header:<name> or body:<field>)", scope, cfg.Key)
+	}
+
+	burst := float64(cfg.Burst)
+	if burst == 0 {
+		burst = math.Max(1, math.Ceil(cfg.Rate))
+	}
+
+	return &Limiter{
+		scope:   scope,
+		cfg:     cfg,
+		kind:    kind,
+		arg:     arg,
+		burst:   burst,
+		counter: counter,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}, nil
+}
+
+// Scope는 Limiter 를 생성한 범위를 반환합니다.
+func (l *Limiter) Scope() string {
+	return l.scope
+}
+
+// Key는 요청의 클라이언트 키를 결정합니다. 설정한 속성이 없는 요청(헤더 키를 쓰는
+// CoAP 요청 포함)은 속성을 빼서 제한을 피하지 못하도록 IP 로 대체합니다. api_key 는
+// 인증 수단이 설정된 헤더에서 검증한 신원만 믿습니다 (검증되지 않은 키는 바꿔 가며
+// 새 버킷을 얻을 수 있음).
+func (l *Limiter) Key(c Client) string {
+	switch l.kind {
+	case KeyGlobal:
+		return KeyGlobal
+	case KeyAPIKey:
+		if c.Subject != "" {
+			return "subject:" + c.Subject
+		}
+	case KeyHeader:
+		if c.Header != nil {
+			if v := c.Header(l.arg); v != "" {
+				return "header:" + v
+			}
+		}
+	case KeyBody:
+		if v, ok := bodyField(c.Body, l.arg); ok {
+			return "body:" + v
+		}
+	}
+	return "ip:" + c.IP
+}
+
+// Allow는 key 의 토큰(과 할당량 하나)을 차감합니다.
+func (l *Limiter) Allow(ctx context.Context, key string) Decision {
+	d, _ := allow(ctx, []*Limiter{l}, []string{key})
+	return d
+}
+
+// Check는 요청에 limiters 를 적용합니다. 거절되면 거절 결과와 원인이 된 Limiter,
+// 클라이언트 키를 반환하고, 아니면 허용 결과를 반환합니다. 거절된 요청은 차감하지
+// 않습니다 (다른 Limiter 에서 가져간 토큰과 할당량은 되돌림).
+func Check(ctx context.Context, limiters []*Limiter, c Client) (Decision, *Limiter, string) {
+	keys := make([]string, len(limiters))
+	for i, l := range limiters {
+		keys[i] = l.Key(c)
+	}
+	d, i := allow(ctx, limiters, keys)
+	if d.Allowed {
+		return d, nil, ""
+	}
+	return d, limiters[i], keys[i]
+}
+
+// charge는 다른 Limiter 에서 거절될 수도 있는 요청을 위해 가져간 토큰 또는 할당량입니다.
+type charge struct {
+	l     *Limiter
+	key   string
+	day   time.Time
+	quota bool
+}
+
+// allow는 모든 속도 제한에서 토큰을 가져온 뒤 모든 일일 할당량을 차감합니다
+// (할당량 저장소는 속도 제한을 모두 통과한 요청만 접근). 거절되면 그때까지 가져간
+// 것을 모두 되돌리고 거절한 Limiter 의 인덱스를 반환합니다.
+func allow(ctx context.Context, limiters []*Limiter, keys []string) (Decision, int) {
+	var taken []charge
+	deny := func(d Decision, i int) (Decision, int) {
+		for _, c := range taken {
+			c.refund(ctx)
+		}
+		return d, i
+	}
+
+	for i, l := range limiters {
+		if l.cfg.Rate <= 0 {
+			continue
+		}
+		if wait := l.take(keys[i]); wait > 0 {
+			return deny(Decision{Reason: ReasonRate, RetryAfter: wait}, i)
+		}
+		taken = append(taken, charge{l: l, key: keys[i]})
+	}
+
+	for i, l := range limiters {
+		if l.cfg.DailyQuota <= 0 || l.counter == nil {
+			continue
+		}
+		now := l.now().UTC()
+		day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		ok, err := l.counter.Take(ctx, l.scope, keys[i], day, l.cfg.DailyQuota)
+		if err != nil {
+			// fail open: DB 장애로 API 까지 멈추지 않도록 허용
+			log.Printf("Daily quota check failed for %s (%s), allowing request: %v", l.scope, keys[i], err)
+			continue
+		}
+		if !ok {
+			return deny(Decision{Reason: ReasonQuota, RetryAfter: day.Add(24 * time.Hour).Sub(now)}, i)
+		}
+		taken = append(taken, charge{l: l, key: keys[i], day: day, quota: true})
+	}
+	return Decision{Allowed: true}, -1
+}
+
+// refund는 가져간 토큰 또는 할당량을 되돌립니다.
+func (c charge) refund(ctx context.Context) {
+	if !c.quota {
+		c.l.untake(c.key)
+		return
+	}
+	if err := c.l.counter.Refund(ctx, c.l.scope, c.key, c.day); err != nil {
+		log.Printf("Daily quota refund failed for %s (%s): %v", c.l.scope, c.key, err)
+	}
+}
+
+// take는 key 버킷에서 토큰 하나를 꺼내고, 비어 있으면 기다려야 하는 시간을 반환합니다.
+func (l *Limiter) take(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) > sweepInterval {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.cfg.Rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.cfg.Rate * float64(time.Second))
+	}
+	b.tokens--
+	return 0
+}
+
+// untake는 take 로 꺼낸 토큰을 되돌립니다 (그 사이 정리된 버킷은 이미 가득 찬 상태).
+func (l *Limiter) untake(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if b, ok := l.buckets[key]; ok {
+		b.tokens = math.Min(l.burst, b.tokens+1)
+	}
+}
+
+// sweep은 가득 찬 버킷을 정리합니다 (새 버킷과 같음).
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.cfg.Rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// bodyField는 점으로 구분한 경로(예: meta.device_id)로 JSON 객체의 스칼라 값을 꺼냅니다.
+func bodyField(body []byte, path string) (string, bool) {
+	if len(body) == 0 {
+		return "", false
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return "", false
+	}
+	for _, part := range strings.Split(path, ".") {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if v, ok = obj[part]; !ok {
+			return "", false
+		}
+	}
+	switch val := v.(type) {
+	case string:
+		return val, val != ""
+	case float64, bool:
+		return fmt.Sprint(val), true
+	default:
+		return "", false
+	}
+}
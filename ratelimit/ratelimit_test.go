@@ -0,0 +1,244 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"servone/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingCounter는 DB 가 멈춘 할당량 카운터입니다.
+type failingCounter struct{}
+
+func (failingCounter) Take(context.Context, string, string, time.Time, int64) (bool, error) {
+	return false, errors.New("database down")
+}
+
+func (failingCounter) Refund(context.Context, string, string, time.Time) error {
+	return errors.New("database down")
+}
+
+// fakeClock은 테스트에서 대기 없이 시간을 앞당깁니다.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func newLimiter(t *testing.T, cfg config.RateLimitConfig, counter Counter) (*Limiter, *fakeClock) {
+	t.Helper()
+	l, err := New("test", cfg, counter)
+	require.NoError(t, err)
+	clock := &fakeClock{t: time.Date(2026, 3, 1, 23, 59, 0, 0, time.UTC)}
+	l.now = clock.now
+	return l, clock
+}
+
+func TestTokenBucket(t *testing.T) {
+	ctx := context.Background()
+	l, clock := newLimiter(t, config.RateLimitConfig{Rate: 2, Burst: 3}, nil)
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, l.Allow(ctx, "a").Allowed, "burst request %d", i)
+	}
+	d := l.Allow(ctx, "a")
+	assert.False(t, d.Allowed)
+	assert.Equal(t, ReasonRate, d.Reason)
+	assert.Equal(t, 500*time.Millisecond, d.RetryAfter)
+	assert.Equal(t, 1, d.RetryAfterSeconds())
+
+	assert.True(t, l.Allow(ctx, "b").Allowed, "other keys have their own bucket")
+
+	clock.t = clock.t.Add(500 * time.Millisecond)
+	assert.True(t, l.Allow(ctx, "a").Allowed, "one token refilled")
+	assert.False(t, l.Allow(ctx, "a").Allowed)
+
+	t.Run("full buckets are swept", func(t *testing.T) {
+		clock.t = clock.t.Add(2 * sweepInterval)
+		l.Allow(ctx, "c")
+		assert.Len(t, l.buckets, 1)
+	})
+}
+
+func TestDefaultBurst(t *testing.T) {
+	l, _ := newLimiter(t, config.RateLimitConfig{Rate: 0.5}, nil)
+	assert.Equal(t, 1.0, l.burst)
+
+	l, _ = newLimiter(t, config.RateLimitConfig{Rate: 10}, nil)
+	assert.Equal(t, 10.0, l.burst)
+}
+
+func TestDailyQuota(t *testing.T) {
+	ctx := context.Background()
+	l, clock := newLimiter(t, config.RateLimitConfig{DailyQuota: 2}, NewMemoryCounter())
+
+	assert.True(t, l.Allow(ctx, "dev-1").Allowed)
+	assert.True(t, l.Allow(ctx, "dev-1").Allowed)
+
+	d := l.Allow(ctx, "dev-1")
+	assert.False(t, d.Allowed)
+	assert.Equal(t, ReasonQuota, d.Reason)
+	assert.Equal(t, time.Minute, d.RetryAfter, "quota resets at UTC midnight")
+
+	assert.True(t, l.Allow(ctx, "dev-2").Allowed)
+
+	clock.t = clock.t.Add(2 * time.Minute)
+	assert.True(t, l.Allow(ctx, "dev-1").Allowed, "new day, new quota")
+
+	t.Run("counter errors fail open", func(t *testing.T) {
+		l, _ := newLimiter(t, config.RateLimitConfig{DailyQuota: 1}, failingCounter{})
+		assert.True(t, l.Allow(ctx, "dev-1").Allowed)
+	})
+}
+
+func TestKey(t *testing.T) {
+	header := http.Header{}
+	header.Set("X-API-Key", "secret")
+	header.Set("X-Device", "gw-7")
+	client := Client{IP: "10.0.0.1", Header: header.Get, Body: []byte(`{"device_id": "dev-1", "meta": {"serial": 42}}`)}
+
+	tests := []struct {
+		key    string
+		client Client
+		want   string
+	}{
+		{"", client, "global"},
+		{"ip", client, "ip:10.0.0.1"},
+		{"api_key", Client{Subject: "partners:partner-a"}, "subject:partners:partner-a"},
+		{"header:X-Device", client, "header:gw-7"},
+		{"body:device_id", client, "body:dev-1"},
+		{"body:meta.serial", client, "body:42"},
+		{"body:device_id", Client{IP: "10.0.0.2", Body: []byte(`not json`)}, "ip:10.0.0.2"},
+		{"header:X-Device", Client{IP: "10.0.0.3"}, "ip:10.0.0.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			l, err := New("test", config.RateLimitConfig{Rate: 1, Key: tt.key}, nil)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, l.Key(tt.client))
+		})
+	}
+
+	t.Run("unverified API keys fall back to IP", func(t *testing.T) {
+		l, err := New("test", config.RateLimitConfig{Rate: 1, Key: "api_key"}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "ip:10.0.0.1", l.Key(client))
+	})
+
+	t.Run("invalid keys", func(t *testing.T) {
+		for _, key := range []string{"cookie", "body:", "header"} {
+			_, err := New("test", config.RateLimitConfig{Rate: 1, Key: key}, nil)
+			assert.Error(t, err, key)
+		}
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(NewMemoryCounter())
+	cfg := &config.RateLimitConfig{Rate: 1}
+
+	t.Run("no limit configured", func(t *testing.T) {
+		l, err := r.Limiter("rest", nil)
+		assert.NoError(t, err)
+		assert.Nil(t, l)
+
+		l, err = r.Limiter("rest", &config.RateLimitConfig{Key: "ip"})
+		assert.NoError(t, err)
+		assert.Nil(t, l)
+	})
+
+	t.Run("unchanged limits are reused", func(t *testing.T) {
+		first, err := r.Limiter("rest", cfg)
+		require.NoError(t, err)
+		again, _ := r.Limiter("rest", &config.RateLimitConfig{Rate: 1})
+		assert.Same(t, first, again)
+
+		changed, _ := r.Limiter("rest", &config.RateLimitConfig{Rate: 2})
+		assert.NotSame(t, first, changed)
+	})
+
+	t.Run("endpoint limiters", func(t *testing.T) {
+		endpoint := config.EndpointConfig{Path: "/api/tempo", Method: "post", RateLimit: &config.RateLimitConfig{Rate: 5, Key: "ip"}}
+		limiters, errs := r.ForEndpoint("rest", cfg, endpoint)
+		assert.Empty(t, errs)
+		require.Len(t, limiters, 2)
+		assert.Equal(t, "rest", limiters[0].Scope())
+		assert.Equal(t, "rest POST /api/tempo", limiters[1].Scope())
+
+		endpoint.RateLimit = &config.RateLimitConfig{Rate: 5, Key: "bogus"}
+		limiters, errs = r.ForEndpoint("rest", nil, endpoint)
+		assert.Len(t, errs, 1)
+		assert.Empty(t, limiters)
+	})
+
+	t.Run("prune drops unused limiters", func(t *testing.T) {
+		r.Prune()
+		kept, _ := r.Limiter("rest", cfg)
+		r.Prune()
+		assert.Len(t, r.limiters, 1)
+		again, _ := r.Limiter("rest", cfg)
+		assert.Same(t, kept, again)
+	})
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	global, _ := newLimiter(t, config.RateLimitConfig{Rate: 100, Burst: 100}, nil)
+	endpoint, _ := newLimiter(t, config.RateLimitConfig{Rate: 1, Burst: 1, Key: "ip"}, nil)
+	limiters := []*Limiter{global, endpoint}
+	client := Client{IP: "10.0.0.1"}
+
+	d, _, _ := Check(ctx, limiters, client)
+	assert.True(t, d.Allowed)
+
+	d, l, key := Check(ctx, limiters, client)
+	assert.False(t, d.Allowed)
+	assert.Same(t, endpoint, l)
+	assert.Equal(t, "ip:10.0.0.1", key)
+
+	t.Run("a later rate limit denial refunds earlier tokens", func(t *testing.T) {
+		global, _ := newLimiter(t, config.RateLimitConfig{Rate: 1, Burst: 2}, nil)
+		endpoint, _ := newLimiter(t, config.RateLimitConfig{Rate: 1, Burst: 1}, nil)
+
+		d, _, _ := Check(ctx, []*Limiter{global, endpoint}, client)
+		assert.True(t, d.Allowed)
+		d, l, _ := Check(ctx, []*Limiter{global, endpoint}, client)
+		assert.False(t, d.Allowed)
+		assert.Same(t, endpoint, l)
+
+		assert.True(t, global.Allow(ctx, "global").Allowed, "the denied request did not use a global token")
+		assert.False(t, global.Allow(ctx, "global").Allowed)
+	})
+
+	t.Run("a quota denial refunds tokens and earlier quota units", func(t *testing.T) {
+		counter := NewMemoryCounter()
+		global, _ := newLimiter(t, config.RateLimitConfig{Rate: 1, Burst: 2, DailyQuota: 5}, counter)
+		endpoint, _ := newLimiter(t, config.RateLimitConfig{DailyQuota: 1, Key: "ip"}, counter)
+
+		d, _, _ := Check(ctx, []*Limiter{global, endpoint}, client)
+		assert.True(t, d.Allowed)
+		d, l, _ := Check(ctx, []*Limiter{global, endpoint}, client)
+		assert.False(t, d.Allowed)
+		assert.Equal(t, ReasonQuota, d.Reason)
+		assert.Same(t, endpoint, l)
+
+		assert.Equal(t, int64(1), counter.counts["test\x00global"], "only the allowed request is counted")
+		assert.True(t, global.Allow(ctx, "global").Allowed, "the denied request did not use a global token")
+	})
+
+	t.Run("rate limits are checked before any quota is taken", func(t *testing.T) {
+		counter := NewMemoryCounter()
+		global, _ := newLimiter(t, config.RateLimitConfig{DailyQuota: 5}, counter)
+		endpoint, _ := newLimiter(t, config.RateLimitConfig{Rate: 1, Burst: 1}, nil)
+
+		d, _, _ := Check(ctx, []*Limiter{global, endpoint}, client)
+		assert.True(t, d.Allowed)
+		d, _, _ = Check(ctx, []*Limiter{global, endpoint}, client)
+		assert.Equal(t, ReasonRate, d.Reason)
+		assert.Equal(t, int64(1), counter.counts["test\x00global"])
+	})
+}
@@ -0,0 +1,131 @@
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"servone/config"
+)
+
+// Registry는 설정 리로드 사이에 Limiter 를 유지해, 바뀌지 않은 제한의 버킷이 초기화되지 않게 합니다.
+type Registry struct {
+	counter Counter
+
+	mu       sync.Mutex
+	limiters map[string]*Limiter
+	used     map[string]bool // 마지막 Prune 이후 요청된 범위
+}
+
+// NewRegistry는 할당량을 counter 에 기록하는 Registry 를 생성합니다.
+func NewRegistry(counter Counter) *Registry {
+	return &Registry{
+		counter:  counter,
+		limiters: make(map[string]*Limiter),
+		used:     make(map[string]bool),
+	}
+}
+
+// Limiter는 scope 의 Limiter 를 반환합니다. 설정이 같으면 기존 것을 재사용하고,
+// cfg 가 nil 이거나 제한이 없으면 nil 을 반환합니다.
+func (r *Registry) Limiter(scope string, cfg *config.RateLimitConfig) (*Limiter, error) {
+	if cfg == nil || (cfg.Rate == 0 && cfg.DailyQuota == 0) {
+		return nil, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.used[scope] = true
+	if l, ok := r.limiters[scope]; ok && l.cfg == *cfg {
+		return l, nil
+	}
+	l, err := New(scope, *cfg, r.counter)
+	if err != nil {
+		delete(r.limiters, scope)
+		return nil, err
+	}
+	r.limiters[scope] = l
+	return l, nil
+}
+
+// ForEndpoint는 엔드포인트에 적용할 Limiter 목록(서버 전체 제한(scope = protocol),
+// 엔드포인트 자체 제한 순서)을 반환합니다.
+func (r *Registry) ForEndpoint(protocol string, global *config.RateLimitConfig, endpoint config.EndpointConfig) ([]*Limiter, []error) {
+	var limiters []*Limiter
+	var errs []error
+	scopes := []struct {
+		name string
+		cfg  *config.RateLimitConfig
+	}{
+		{protocol, global},
+		{protocol + " " + strings.ToUpper(endpoint.Method) + " " + endpoint.Path, endpoint.RateLimit},
+	}
+	for _, scope := range scopes {
+		l, err := r.Limiter(scope.name, scope.cfg)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if l != nil {
+			limiters = append(limiters, l)
+		}
+	}
+	return limiters, errs
+}
+
+// Prune은 이전 Prune 이후 요청되지 않은 Limiter(리로드로 삭제된 엔드포인트)를 정리합니다.
+func (r *Registry) Prune() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for scope := range r.limiters {
+		if !r.used[scope] {
+			delete(r.limiters, scope)
+		}
+	}
+	r.used = make(map[string]bool)
+}
+
+// MemoryCounter는 일일 할당량 사용량을 메모리에 보관합니다. DB 카운터를 넘기지 않으면
+// 서버가 이것을 사용하며, 재시작하면 초기화됩니다.
+type MemoryCounter struct {
+	mu     sync.Mutex
+	day    time.Time
+	counts map[string]int64
+}
+
+// NewMemoryCounter는 빈 메모리 카운터를 생성합니다.
+func NewMemoryCounter() *MemoryCounter {
+	return &MemoryCounter{counts: make(map[string]int64)}
+}
+
+// Take는 Counter 를 구현합니다. 당일 사용량만 보관합니다.
+func (m *MemoryCounter) Take(_ context.Context, scope, key string, day time.Time, limit int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !day.Equal(m.day) {
+		m.day = day
+		m.counts = make(map[string]int64)
+	}
+	id := scope + "\x00" + key
+	if m.counts[id] >= limit {
+		return false, nil
+	}
+	m.counts[id]++
+	return true, nil
+}
+
+// Refund는 Counter 를 구현합니다.
+func (m *MemoryCounter) Refund(_ context.Context, scope, key string, day time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id := scope + "\x00" + key
+	if day.Equal(m.day) && m.counts[id] > 0 {
+		m.counts[id]--
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+// Package recording captures HTTP traffic handled by the REST server into an
+// NDJSON file and replays it against a servone instance or any other target.
+package recording
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Message is one side (request or response) of a recorded exchange.
+type Message struct {
+	Method       string              `json:"method,omitempty"`
+	URI          string              `json:"uri,omitempty"`
+	Status       int                 `json:"status,omitempty"`
+	Headers      map[string][]string `json:"headers,omitempty"`
+	Body         string              `json:"body,omitempty"`
+	BodyEncoding string              `json:"body_encoding,omitempty"` // "base64" for non UTF-8 bodies
+}
+
+// Entry is one recorded request/response exchange.
+type Entry struct {
+	Time       time.Time `json:"time"`        // when the request was received
+	DurationMs float64   `json:"duration_ms"` // time spent handling the request
+	Endpoint   string    `json:"endpoint"`    // configured endpoint path, e.g. /api/devices/{id}
+	Request    Message   `json:"request"`
+	Response   Message   `json:"response"`
+}
+
+// SetBody stores body as text, or base64 when it is not valid UTF-8.
+func (m *Message) SetBody(body []byte) {
+	if utf8.Valid(body) {
+		m.Body = string(body)
+		m.BodyEncoding = ""
+		return
+	}
+	m.Body = base64.StdEncoding.EncodeToString(body)
+	m.BodyEncoding = "base64"
+}
+
+// BodyBytes returns the decoded body.
+func (m *Message) BodyBytes() ([]byte, error) {
+	if m.BodyEncoding == "base64" {
+		return base64.StdEncoding.DecodeString(m.Body)
+	}
+	return []byte(m.Body), nil
+}
+
+// Redacted replaces credential values in recorded headers and query strings.
+const Redacted = "[REDACTED]"
+
+// SensitiveHeaders are redacted from every recording unless credentials are
+// explicitly kept.
+var SensitiveHeaders = []string{"Authorization", "Proxy-Authorization", "X-Api-Key", "Cookie", "Set-Cookie"}
+
+// Redaction hides credentials before an exchange is written to disk. A nil
+// Redaction keeps everything.
+type Redaction struct {
+	headers map[string]bool
+	query   []string
+}
+
+// NewRedaction redacts SensitiveHeaders plus the given headers and query
+// parameters (e.g. the ones auth providers read credentials from).
+func NewRedaction(headers, queryParams []string) *Redaction {
+	r := &Redaction{headers: make(map[string]bool), query: queryParams}
+	for _, h := range append(append([]string{}, SensitiveHeaders...), headers...) {
+		r.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	return r
+}
+
+// Headers returns a copy of h with sensitive values replaced by Redacted.
+func (r *Redaction) Headers(h http.Header) map[string][]string {
+	out := h.Clone()
+	if r == nil {
+		return out
+	}
+	for k, values := range out {
+		if !r.headers[http.CanonicalHeaderKey(k)] {
+			continue
+		}
+		redacted := make([]string, len(values))
+		for i := range redacted {
+			redacted[i] = Redacted
+		}
+		out[k] = redacted
+	}
+	return out
+}
+
+// URI returns uri with the values of credential query parameters replaced by Redacted.
+func (r *Redaction) URI(uri string) string {
+	if r == nil || len(r.query) == 0 {
+		return uri
+	}
+	u, err := url.ParseRequestURI(uri)
+	if err != nil {
+		return uri
+	}
+	q := u.Query()
+	changed := false
+	for _, name := range r.query {
+		if values, ok := q[name]; ok {
+			for i := range values {
+				values[i] = Redacted
+			}
+			changed = true
+		}
+	}
+	if !changed {
+		return uri
+	}
+	u.RawQuery = q.Encode()
+	return u.RequestURI()
+}
+
+// Recorder appends entries to an NDJSON file. It is safe for concurrent use.
+type Recorder struct {
+	path string
+	mu   sync.Mutex
+	file *os.File
+	w    *bufio.Writer
+}
+
+// NewRecorder opens (or creates) the recording file in append mode. The file
+// is readable by its owner only since requests may carry personal data.
+func NewRecorder(path string) (*Recorder, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create recording directory: %w", err)
+		}
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording file: %w", err)
+	}
+	// files created by older versions were world readable
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to restrict recording file permissions: %w", err)
+	}
+	return &Recorder{path: path, file: f, w: bufio.NewWriter(f)}, nil
+}
+
+// Path returns the recording file path.
+func (r *Recorder) Path() string {
+	return r.path
+}
+
+// Record appends one entry and flushes it to disk.
+func (r *Recorder) Record(e Entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to marshal recording entry: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return fmt.Errorf("recorder is closed")
+	}
+	if _, err := r.w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to write recording entry: %w", err)
+	}
+	return r.w.Flush()
+}
+
+// Close flushes and closes the recording file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	flushErr := r.w.Flush()
+	closeErr := r.file.Close()
+	r.file = nil
+	if flushErr != nil {
+		return flushErr
+	}
+	return closeErr
+}
+
+// ReadFile loads all entries from a recording file.
+func ReadFile(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 32*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid recording entry: %w", path, line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
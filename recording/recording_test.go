@@ -0,0 +1,184 @@
+package recording
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "traffic.ndjson")
+
+	t.Run("round trip", func(t *testing.T) {
+		rec, err := NewRecorder(path)
+		require.NoError(t, err)
+		assert.Equal(t, path, rec.Path())
+
+		e := Entry{
+			Time:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			Endpoint: "/api/devices/{id}",
+			Request:  Message{Method: "POST", URI: "/api/devices/1?x=1", Headers: map[string][]string{"Content-Type": {"application/json"}}},
+			Response: Message{Status: 201},
+		}
+		e.Request.SetBody([]byte(`{"temp": 21}`))
+		e.Response.SetBody([]byte{0xff, 0x00, 0x01})
+		require.NoError(t, rec.Record(e))
+		require.NoError(t, rec.Close())
+
+		entries, err := ReadFile(path)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "/api/devices/1?x=1", entries[0].Request.URI)
+		assert.Equal(t, `{"temp": 21}`, entries[0].Request.Body)
+		assert.Equal(t, "base64", entries[0].Response.BodyEncoding)
+
+		body, err := entries[0].Response.BodyBytes()
+		require.NoError(t, err)
+		assert.Equal(t, []byte{0xff, 0x00, 0x01}, body)
+	})
+
+	t.Run("appends to existing file", func(t *testing.T) {
+		rec, err := NewRecorder(path)
+		require.NoError(t, err)
+		require.NoError(t, rec.Record(Entry{Request: Message{Method: "GET", URI: "/b"}}))
+		require.NoError(t, rec.Close())
+
+		entries, err := ReadFile(path)
+		require.NoError(t, err)
+		assert.Len(t, entries, 2)
+	})
+
+	t.Run("closed recorder", func(t *testing.T) {
+		rec, err := NewRecorder(path)
+		require.NoError(t, err)
+		require.NoError(t, rec.Close())
+		assert.NoError(t, rec.Close())
+		assert.Error(t, rec.Record(Entry{}))
+	})
+
+	t.Run("file is readable by owner only", func(t *testing.T) {
+		legacy := filepath.Join(t.TempDir(), "legacy.ndjson")
+		require.NoError(t, os.WriteFile(legacy, nil, 0644))
+		rec, err := NewRecorder(legacy)
+		require.NoError(t, err)
+		require.NoError(t, rec.Close())
+
+		info, err := os.Stat(legacy)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	})
+
+	t.Run("invalid line", func(t *testing.T) {
+		bad := filepath.Join(t.TempDir(), "bad.ndjson")
+		require.NoError(t, os.WriteFile(bad, []byte("{}\nnot json\n"), 0644))
+		_, err := ReadFile(bad)
+		assert.ErrorContains(t, err, "bad.ndjson:2")
+	})
+}
+
+func TestRedaction(t *testing.T) {
+	h := http.Header{
+		"Authorization": {"Bearer x"},
+		"Set-Cookie":    {"a=1", "b=2"},
+		"X-Token":       {"t"},
+		"Accept":        {"*/*"},
+	}
+
+	t.Run("sensitive and extra headers", func(t *testing.T) {
+		out := NewRedaction([]string{"x-token"}, nil).Headers(h)
+		assert.Equal(t, []string{Redacted}, out["Authorization"])
+		assert.Equal(t, []string{Redacted, Redacted}, out["Set-Cookie"])
+		assert.Equal(t, []string{Redacted}, out["X-Token"])
+		assert.Equal(t, []string{"*/*"}, out["Accept"])
+		assert.Equal(t, []string{"Bearer x"}, h["Authorization"]) // 원본은 그대로
+	})
+
+	t.Run("query parameters", func(t *testing.T) {
+		r := NewRedaction(nil, []string{"api_key"})
+		assert.Equal(t, "/a?api_key=%5BREDACTED%5D&x=1", r.URI("/a?x=1&api_key=k"))
+		assert.Equal(t, "/a?z=1&x=2", r.URI("/a?z=1&x=2"))
+	})
+
+	t.Run("nil keeps everything", func(t *testing.T) {
+		var r *Redaction
+		assert.Equal(t, []string{"Bearer x"}, r.Headers(h)["Authorization"])
+		assert.Equal(t, "/a?api_key=k", r.URI("/a?api_key=k"))
+	})
+}
+
+func TestReplay(t *testing.T) {
+	var gotBodies []string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		gotBodies = append(gotBodies, string(body))
+		assert.Equal(t, "abc", r.Header.Get("X-Token"))
+
+		switch r.URL.Path {
+		case "/ok":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"ok": true}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer target.Close()
+
+	base := time.Now()
+	entry := func(offset time.Duration, uri string, status int, body string) Entry {
+		return Entry{
+			Time:     base.Add(offset),
+			Request:  Message{Method: "POST", URI: uri, Headers: map[string][]string{"X-Token": {"abc"}}, Body: "req"},
+			Response: Message{Status: status, Body: body},
+		}
+	}
+
+	t.Run("compares status and body", func(t *testing.T) {
+		entries := []Entry{
+			entry(0, "/ok", 201, `{"ok": true}`),
+			entry(time.Hour, "/ok", 201, `{"ok": false}`),
+			entry(2*time.Hour, "/missing", 200, ""),
+		}
+
+		report, err := Replay(context.Background(), entries, ReplayOptions{Target: target.URL + "/", Speed: 0, CompareBody: true})
+		require.NoError(t, err)
+		assert.Equal(t, 3, report.Total)
+		assert.Equal(t, 1, report.Matched)
+		assert.Equal(t, 2, report.Mismatched)
+		assert.Equal(t, "status 404, recorded 200", report.Results[2].Mismatch)
+		assert.Contains(t, gotBodies, "req")
+	})
+
+	t.Run("scales original gaps by speed", func(t *testing.T) {
+		entries := []Entry{
+			entry(0, "/ok", 201, ""),
+			entry(200*time.Millisecond, "/ok", 201, ""),
+		}
+
+		start := time.Now()
+		report, err := Replay(context.Background(), entries, ReplayOptions{Target: target.URL, Speed: 2})
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.Matched)
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	})
+
+	t.Run("connection failure", func(t *testing.T) {
+		report, err := Replay(context.Background(), []Entry{entry(0, "/ok", 201, "")}, ReplayOptions{Target: "http://127.0.0.1:1"})
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Failed)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		_, err := Replay(context.Background(), nil, ReplayOptions{})
+		assert.Error(t, err)
+		_, err = Replay(context.Background(), nil, ReplayOptions{Target: target.URL, Speed: -1})
+		assert.Error(t, err)
+	})
+}
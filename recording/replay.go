@@ -0,0 +1,134 @@
+package recording
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ReplayOptions controls how recorded traffic is replayed.
+type ReplayOptions struct {
+	Target      string       // base URL, e.g. http://localhost:8090
+	Speed       float64      // 1 = original pacing, 2 = twice as fast, 0 = no delay between requests
+	CompareBody bool         // also compare response bodies, not only status codes
+	Client      *http.Client // optional, defaults to a client with a 30s timeout
+}
+
+// Result is the outcome of replaying one entry.
+type Result struct {
+	Entry      Entry
+	Status     int
+	Body       []byte
+	DurationMs float64
+	Err        error
+	Mismatch   string // non-empty when the response differs from the recording
+}
+
+// Report summarizes a replay run.
+type Report struct {
+	Total      int
+	Matched    int
+	Mismatched int
+	Failed     int
+	Results    []Result
+}
+
+// Replay sends the entries to the target in order, preserving the original
+// gaps between requests scaled by opts.Speed.
+func Replay(ctx context.Context, entries []Entry, opts ReplayOptions) (*Report, error) {
+	if opts.Target == "" {
+		return nil, fmt.Errorf("replay target is required")
+	}
+	if opts.Speed < 0 {
+		return nil, fmt.Errorf("replay speed must not be negative")
+	}
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	target := strings.TrimRight(opts.Target, "/")
+
+	report := &Report{Total: len(entries)}
+	start := time.Now()
+
+	for i, e := range entries {
+		if opts.Speed > 0 && i > 0 {
+			offset := time.Duration(float64(e.Time.Sub(entries[0].Time)) / opts.Speed)
+			if wait := time.Until(start.Add(offset)); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return report, ctx.Err()
+				}
+			}
+		}
+
+		res := replayOne(ctx, client, target, e)
+		switch {
+		case res.Err != nil:
+			report.Failed++
+		case res.Status != e.Response.Status:
+			res.Mismatch = fmt.Sprintf("status %d, recorded %d", res.Status, e.Response.Status)
+			report.Mismatched++
+		case opts.CompareBody && !bodiesEqual(res.Body, e.Response):
+			res.Mismatch = "body differs from recording"
+			report.Mismatched++
+		default:
+			report.Matched++
+		}
+		report.Results = append(report.Results, res)
+	}
+
+	return report, nil
+}
+
+// replayOne sends a single recorded request.
+func replayOne(ctx context.Context, client *http.Client, target string, e Entry) Result {
+	res := Result{Entry: e}
+
+	body, err := e.Request.BodyBytes()
+	if err != nil {
+		res.Err = fmt.Errorf("invalid recorded body: %w", err)
+		return res
+	}
+
+	req, err := http.NewRequestWithContext(ctx, e.Request.Method, target+e.Request.URI, bytes.NewReader(body))
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	for k, values := range e.Request.Headers {
+		for _, v := range values {
+			if v == Redacted {
+				continue // credentials were not recorded
+			}
+			req.Header.Add(k, v)
+		}
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	defer resp.Body.Close()
+
+	res.Body, res.Err = io.ReadAll(resp.Body)
+	res.Status = resp.StatusCode
+	res.DurationMs = float64(time.Since(start).Microseconds()) / 1000
+	return res
+}
+
+// bodiesEqual compares a replayed body with the recorded one.
+func bodiesEqual(got []byte, recorded Message) bool {
+	want, err := recorded.BodyBytes()
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(bytes.TrimSpace(got), bytes.TrimSpace(want))
+}
@@ -0,0 +1,228 @@
+// Package retention maintains the daily partitions of the ingest tables. It
+// creates partitions ahead of time, moves rows that landed in a table's
+// DEFAULT partition into daily partitions of their own, drops partitions past each table's retention and, optionally, rolls
+// up numeric SNMP values into hourly aggregates before their detail rows
+// are dropped.
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"servone/config"
+	"servone/db"
+	"servone/metrics"
+)
+
+// Defaults used when the retention config leaves a field unset.
+const (
+	DefaultPremakeDays   = 7
+	DefaultCheckInterval = time.Hour
+)
+
+const day = 24 * time.Hour
+
+// Store manages the partitions of the ingest tables. db.PartitionStore
+// implements it.
+type Store interface {
+	Partitions(ctx context.Context, table string) ([]db.Partition, error)
+	CreatePartition(ctx context.Context, table string, day time.Time) (db.Partition, error)
+	DefaultDays(ctx context.Context, def db.Partition) ([]time.Time, error)
+	DropPartition(ctx context.Context, table string, p db.Partition, rollup bool) (int64, error)
+}
+
+// Manager runs partition maintenance periodically.
+type Manager struct {
+	store Store
+
+	premake   int
+	interval  time.Duration
+	retention map[string]time.Duration
+	rollup    bool
+	now       func() time.Time
+
+	stopOnce sync.Once
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// New creates a manager. Call Start to begin maintenance.
+func New(store Store, cfg config.RetentionConfig) (*Manager, error) {
+	if cfg.PremakeDays < 0 || cfg.CheckIntervalMinutes < 0 {
+		return nil, errors.New("retention: settings must not be negative")
+	}
+
+	m := &Manager{
+		store:     store,
+		premake:   cfg.PremakeDays,
+		interval:  time.Duration(cfg.CheckIntervalMinutes) * time.Minute,
+		retention: make(map[string]time.Duration),
+		rollup:    cfg.SNMPRollup,
+		now:       time.Now,
+		stop:      make(chan struct{}),
+	}
+	if m.premake == 0 {
+		m.premake = DefaultPremakeDays
+	}
+	if m.interval == 0 {
+		m.interval = DefaultCheckInterval
+	}
+	for table, days := range cfg.Days {
+		if !partitioned(table) {
+			return nil, fmt.Errorf("retention: '%s' is not a partitioned table (expected one of %v)", table, db.PartitionedTables)
+		}
+		if days < 0 {
+			return nil, fmt.Errorf("retention: days for '%s' must not be negative", table)
+		}
+		if days > 0 {
+			m.retention[table] = time.Duration(days) * day
+		}
+	}
+	return m, nil
+}
+
+func partitioned(table string) bool {
+	for _, t := range db.PartitionedTables {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
+
+// Start runs maintenance now and then every check interval until Close.
+func (m *Manager) Start() {
+	m.done = make(chan struct{})
+	go m.run()
+}
+
+func (m *Manager) run() {
+	defer close(m.done)
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-m.stop
+		cancel()
+	}()
+
+	for {
+		// failures are logged by RunOnce and retried on the next tick
+		m.RunOnce(ctx)
+
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce creates missing partitions from today through the premake window
+// and for every day with rows in the DEFAULT partition, then drops
+// partitions whose rows are all older than the table's retention. It logs
+// each failure, keeps going and returns the first error.
+func (m *Manager) RunOnce(ctx context.Context) error {
+	now := m.now().UTC()
+	today := now.Truncate(day)
+
+	var firstErr error
+	fail := func(err error) {
+		log.Printf("Partition maintenance: %v", err)
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	for _, table := range db.PartitionedTables {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		parts, err := m.store.Partitions(ctx, table)
+		if err != nil {
+			fail(err)
+			continue
+		}
+
+		var days []time.Time
+		for d := 0; d <= m.premake; d++ {
+			days = append(days, today.Add(time.Duration(d)*day))
+		}
+		for _, p := range parts {
+			if !p.Default {
+				continue
+			}
+			// rows stored while no partition covered their day
+			stray, err := m.store.DefaultDays(ctx, p)
+			if err != nil {
+				fail(err)
+				continue
+			}
+			days = append(days, stray...)
+		}
+
+		for _, start := range days {
+			if covered(parts, start.UnixNano(), start.Add(day).UnixNano()) {
+				continue
+			}
+			p, err := m.store.CreatePartition(ctx, table, start)
+			if err != nil {
+				fail(err)
+				continue
+			}
+			parts = append(parts, p)
+			metrics.RecordPartition(table, "created")
+			log.Printf("Created partition %s", p.Name)
+		}
+
+		keep, ok := m.retention[table]
+		if !ok {
+			continue
+		}
+		cutoff := now.Add(-keep).UnixNano()
+		for _, p := range parts {
+			if p.Default || p.To > cutoff {
+				continue
+			}
+			rollup := m.rollup && table == db.RollupTable
+			rows, err := m.store.DropPartition(ctx, table, p, rollup)
+			if err != nil {
+				fail(err)
+				continue
+			}
+			metrics.RecordPartition(table, "dropped")
+			if rollup {
+				metrics.RecordSNMPRollupRows(rows)
+				log.Printf("Dropped partition %s (rolled up into %d hourly rows)", p.Name, rows)
+			} else {
+				log.Printf("Dropped partition %s", p.Name)
+			}
+		}
+	}
+	return firstErr
+}
+
+// covered reports whether any range partition overlaps [from, to). An
+// overlapping range cannot get a partition of its own.
+func covered(parts []db.Partition, from, to int64) bool {
+	for _, p := range parts {
+		if !p.Default && p.From < to && from < p.To {
+			return true
+		}
+	}
+	return false
+}
+
+// Close stops the manager and waits for a running maintenance pass.
+func (m *Manager) Close() {
+	m.stopOnce.Do(func() { close(m.stop) })
+	if m.done != nil {
+		<-m.done
+	}
+}
@@ -0,0 +1,216 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"servone/config"
+	"servone/db"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryStore keeps partitions in memory and records drops.
+type memoryStore struct {
+	mu       sync.Mutex
+	parts    map[string][]db.Partition
+	defaults map[string][]int64 // created_at of the rows in a default partition
+	dropped  map[string]bool    // partition name -> rolled up
+	failOn   string             // table whose CreatePartition fails
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{parts: make(map[string][]db.Partition), defaults: make(map[string][]int64), dropped: make(map[string]bool)}
+}
+
+func (s *memoryStore) Partitions(ctx context.Context, table string) ([]db.Partition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]db.Partition(nil), s.parts[table]...), nil
+}
+
+func (s *memoryStore) CreatePartition(ctx context.Context, table string, day time.Time) (db.Partition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if table == s.failOn {
+		return db.Partition{}, errors.New("disk full")
+	}
+	p := db.Partition{Name: db.PartitionName(table, day), From: day.UnixNano(), To: day.AddDate(0, 0, 1).UnixNano()}
+	s.parts[table] = append(s.parts[table], p)
+	for _, q := range s.parts[table] {
+		if q.Default {
+			var kept []int64
+			for _, ts := range s.defaults[q.Name] {
+				if ts < p.From || ts >= p.To {
+					kept = append(kept, ts)
+				}
+			}
+			s.defaults[q.Name] = kept
+		}
+	}
+	return p, nil
+}
+
+func (s *memoryStore) DefaultDays(ctx context.Context, def db.Partition) ([]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var days []time.Time
+	for _, ts := range s.defaults[def.Name] {
+		days = append(days, time.Unix(0, ts).UTC().Truncate(day))
+	}
+	return days, nil
+}
+
+func (s *memoryStore) DropPartition(ctx context.Context, table string, p db.Partition, rollup bool) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := s.parts[table][:0]
+	for _, q := range s.parts[table] {
+		if q.Name != p.Name {
+			kept = append(kept, q)
+		}
+	}
+	s.parts[table] = kept
+	s.dropped[p.Name] = rollup
+	return 24, nil
+}
+
+func (s *memoryStore) names(table string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var names []string
+	for _, p := range s.parts[table] {
+		names = append(names, p.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newManager(t *testing.T, store Store, now time.Time, cfg config.RetentionConfig) *Manager {
+	t.Helper()
+	m, err := New(store, cfg)
+	require.NoError(t, err)
+	m.now = func() time.Time { return now }
+	return m
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(nil, config.RetentionConfig{PremakeDays: -1})
+	assert.Error(t, err)
+
+	_, err = New(nil, config.RetentionConfig{Days: map[string]int{"outbox": 1}})
+	assert.ErrorContains(t, err, "not a partitioned table")
+
+	_, err = New(nil, config.RetentionConfig{Days: map[string]int{"snmp_data": -3}})
+	assert.Error(t, err)
+
+	m, err := New(nil, config.RetentionConfig{Days: map[string]int{"client_data": 0, "snmp_data": 30}})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultPremakeDays, m.premake)
+	assert.Equal(t, DefaultCheckInterval, m.interval)
+	assert.Equal(t, map[string]time.Duration{"snmp_data": 30 * day}, m.retention, "0 keeps data forever")
+}
+
+func TestRunOnce(t *testing.T) {
+	now := time.Date(2026, 10, 16, 13, 30, 0, 0, time.UTC)
+	today := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+
+	t.Run("creates partitions from today through the premake window", func(t *testing.T) {
+		store := newMemoryStore()
+		m := newManager(t, store, now, config.RetentionConfig{PremakeDays: 2})
+
+		require.NoError(t, m.RunOnce(context.Background()))
+		for _, table := range db.PartitionedTables {
+			assert.Equal(t, []string{table + "_p20261016", table + "_p20261017", table + "_p20261018"}, store.names(table))
+		}
+
+		// running again creates nothing new
+		require.NoError(t, m.RunOnce(context.Background()))
+		assert.Len(t, store.names("client_data"), 3)
+	})
+
+	t.Run("skips days covered by the legacy partition", func(t *testing.T) {
+		store := newMemoryStore()
+		store.parts["mqtt_messages"] = []db.Partition{{Name: "mqtt_messages_legacy", From: math.MinInt64, To: today.AddDate(0, 0, 1).UnixNano()}}
+		m := newManager(t, store, now, config.RetentionConfig{PremakeDays: 1})
+
+		require.NoError(t, m.RunOnce(context.Background()))
+		assert.Equal(t, []string{"mqtt_messages_legacy", "mqtt_messages_p20261017"}, store.names("mqtt_messages"))
+	})
+
+	t.Run("drops partitions past retention", func(t *testing.T) {
+		store := newMemoryStore()
+		for d := -5; d <= 0; d++ {
+			_, err := store.CreatePartition(context.Background(), "coap_messages", today.AddDate(0, 0, d))
+			require.NoError(t, err)
+			_, err = store.CreatePartition(context.Background(), "client_data", today.AddDate(0, 0, d))
+			require.NoError(t, err)
+		}
+		m := newManager(t, store, now, config.RetentionConfig{PremakeDays: 1, Days: map[string]int{"coap_messages": 3}})
+
+		require.NoError(t, m.RunOnce(context.Background()))
+		// the partition of 10-13 still holds rows newer than 3 days
+		assert.Equal(t, []string{
+			"coap_messages_p20261013", "coap_messages_p20261014", "coap_messages_p20261015",
+			"coap_messages_p20261016", "coap_messages_p20261017",
+		}, store.names("coap_messages"))
+		assert.Len(t, store.names("client_data"), 7, "tables without retention keep everything")
+		assert.Equal(t, map[string]bool{"coap_messages_p20261011": false, "coap_messages_p20261012": false}, store.dropped)
+	})
+
+	t.Run("rolls up snmp_data before dropping when enabled", func(t *testing.T) {
+		store := newMemoryStore()
+		store.parts["snmp_data"] = []db.Partition{{Name: "snmp_data_legacy", From: math.MinInt64, To: today.AddDate(0, 0, -10).UnixNano()}}
+		store.parts["mqtt_messages"] = []db.Partition{{Name: "mqtt_messages_legacy", From: math.MinInt64, To: today.AddDate(0, 0, -10).UnixNano()}}
+		m := newManager(t, store, now, config.RetentionConfig{
+			SNMPRollup: true,
+			Days:       map[string]int{"snmp_data": 7, "mqtt_messages": 7},
+		})
+
+		require.NoError(t, m.RunOnce(context.Background()))
+		assert.True(t, store.dropped["snmp_data_legacy"])
+		assert.False(t, store.dropped["mqtt_messages_legacy"], "only snmp_data is rolled up")
+	})
+
+	t.Run("moves rows out of the default partition", func(t *testing.T) {
+		store := newMemoryStore()
+		def := db.Partition{Name: "snmp_data_default", Default: true}
+		store.parts["snmp_data"] = []db.Partition{def}
+		store.defaults[def.Name] = []int64{
+			today.AddDate(0, 0, -20).Add(time.Hour).UnixNano(),
+			today.AddDate(0, 0, -2).Add(3 * time.Hour).UnixNano(),
+			today.AddDate(0, 0, -2).Add(5 * time.Hour).UnixNano(),
+		}
+		m := newManager(t, store, now, config.RetentionConfig{PremakeDays: 1, Days: map[string]int{"snmp_data": 7}})
+
+		require.NoError(t, m.RunOnce(context.Background()))
+		assert.Empty(t, store.defaults[def.Name])
+		assert.Equal(t, []string{"snmp_data_default", "snmp_data_p20261014", "snmp_data_p20261016", "snmp_data_p20261017"}, store.names("snmp_data"))
+		assert.Equal(t, map[string]bool{"snmp_data_p20260926": false}, store.dropped, "rows past retention are dropped with their partition")
+	})
+
+	t.Run("keeps going after a failure", func(t *testing.T) {
+		store := newMemoryStore()
+		store.failOn = "client_data"
+		m := newManager(t, store, now, config.RetentionConfig{PremakeDays: 1})
+
+		assert.EqualError(t, m.RunOnce(context.Background()), "disk full")
+		assert.Len(t, store.names("snmp_data"), 2)
+	})
+}
+
+func TestManagerLoop(t *testing.T) {
+	store := newMemoryStore()
+	m := newManager(t, store, time.Now(), config.RetentionConfig{PremakeDays: 1})
+
+	m.Start()
+	assert.Eventually(t, func() bool { return len(store.names("snmp_data")) == 2 }, time.Second, 10*time.Millisecond)
+	m.Close()
+	m.Close() // closing twice is safe
+}
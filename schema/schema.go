@@ -0,0 +1,120 @@
+// Package schema validates inbound JSON payloads against per-endpoint JSON Schemas.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"servone/config"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// inlineResource is the resource name used when compiling inline schemas.
+const inlineResource = "inline-schema.json"
+
+// Violation describes a single schema violation.
+type Violation struct {
+	Path    string `json:"path"`    // JSON pointer to the offending value ("" for the root)
+	Message string `json:"message"` // human readable description
+}
+
+// Validator validates payloads against a compiled schema.
+type Validator struct {
+	schema *jsonschema.Schema
+}
+
+// Compile builds a Validator from the endpoint schema config.
+// It returns nil without error when no schema is configured.
+func Compile(cfg *config.SchemaConfig) (*Validator, error) {
+	if cfg == nil || (cfg.File == "" && len(cfg.Inline) == 0) {
+		return nil, nil
+	}
+	if cfg.File != "" && len(cfg.Inline) > 0 {
+		return nil, fmt.Errorf("schema: only one of file or inline may be set")
+	}
+
+	c := jsonschema.NewCompiler()
+
+	if cfg.File != "" {
+		path, err := filepath.Abs(cfg.File)
+		if err != nil {
+			return nil, fmt.Errorf("schema: failed to resolve %s: %w", cfg.File, err)
+		}
+		sch, err := c.Compile(path)
+		if err != nil {
+			return nil, fmt.Errorf("schema: failed to compile %s: %w", cfg.File, err)
+		}
+		return &Validator{schema: sch}, nil
+	}
+
+	raw, err := json.Marshal(cfg.Inline)
+	if err != nil {
+		return nil, fmt.Errorf("schema: failed to marshal inline schema: %w", err)
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("schema: failed to parse inline schema: %w", err)
+	}
+	if err := c.AddResource(inlineResource, doc); err != nil {
+		return nil, fmt.Errorf("schema: failed to add inline schema: %w", err)
+	}
+	sch, err := c.Compile(inlineResource)
+	if err != nil {
+		return nil, fmt.Errorf("schema: failed to compile inline schema: %w", err)
+	}
+	return &Validator{schema: sch}, nil
+}
+
+// Validate checks body and returns the list of violations (empty when valid).
+func (v *Validator) Validate(body []byte) []Violation {
+	inst, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+	if err != nil {
+		return []Violation{{Path: "", Message: fmt.Sprintf("invalid JSON: %v", err)}}
+	}
+
+	err = v.schema.Validate(inst)
+	if err == nil {
+		return nil
+	}
+
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return []Violation{{Path: "", Message: err.Error()}}
+	}
+
+	var violations []Violation
+	for _, unit := range ve.BasicOutput().Errors {
+		if unit.Error == nil {
+			continue
+		}
+		violations = append(violations, Violation{
+			Path:    unit.InstanceLocation,
+			Message: unit.Error.String(),
+		})
+	}
+	if len(violations) == 0 {
+		violations = append(violations, Violation{Path: "", Message: ve.Error()})
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		return violations[i].Path < violations[j].Path
+	})
+	return violations
+}
+
+// ErrorBody renders the structured error payload returned to clients.
+func ErrorBody(violations []Violation) []byte {
+	body, err := json.Marshal(map[string]interface{}{
+		"error":      "schema validation failed",
+		"violations": violations,
+	})
+	if err != nil {
+		return []byte(`{"error": "schema validation failed"}`)
+	}
+	return body
+}
@@ -0,0 +1,97 @@
+package schema
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"servone/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func inlineSchema() *config.SchemaConfig {
+	return &config.SchemaConfig{
+		Inline: map[string]interface{}{
+			"type":     "object",
+			"required": []interface{}{"device_id", "value"},
+			"properties": map[string]interface{}{
+				"device_id": map[string]interface{}{"type": "string"},
+				"value":     map[string]interface{}{"type": "number", "minimum": -50, "maximum": 150},
+			},
+		},
+	}
+}
+
+func TestCompile(t *testing.T) {
+	t.Run("no schema configured", func(t *testing.T) {
+		v, err := Compile(nil)
+		assert.NoError(t, err)
+		assert.Nil(t, v)
+
+		v, err = Compile(&config.SchemaConfig{})
+		assert.NoError(t, err)
+		assert.Nil(t, v)
+	})
+
+	t.Run("file and inline are exclusive", func(t *testing.T) {
+		cfg := inlineSchema()
+		cfg.File = "schema.json"
+		_, err := Compile(cfg)
+		assert.Error(t, err)
+	})
+
+	t.Run("schema from file", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "tempo.json")
+		raw, err := json.Marshal(inlineSchema().Inline)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, raw, 0644))
+
+		v, err := Compile(&config.SchemaConfig{File: path})
+		require.NoError(t, err)
+		assert.Empty(t, v.Validate([]byte(`{"device_id": "d1", "value": 20}`)))
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := Compile(&config.SchemaConfig{File: filepath.Join(t.TempDir(), "missing.json")})
+		assert.Error(t, err)
+	})
+}
+
+func TestValidate(t *testing.T) {
+	v, err := Compile(inlineSchema())
+	require.NoError(t, err)
+
+	t.Run("valid payload", func(t *testing.T) {
+		assert.Empty(t, v.Validate([]byte(`{"device_id": "d1", "value": 21.5}`)))
+	})
+
+	t.Run("missing and out of range fields", func(t *testing.T) {
+		violations := v.Validate([]byte(`{"value": 500}`))
+		require.NotEmpty(t, violations)
+
+		paths := make(map[string]bool)
+		for _, vi := range violations {
+			paths[vi.Path] = true
+			assert.NotEmpty(t, vi.Message)
+		}
+		assert.True(t, paths[""], "missing property should be reported at the root")
+		assert.True(t, paths["/value"], "maximum violation should point at /value")
+	})
+
+	t.Run("malformed JSON", func(t *testing.T) {
+		violations := v.Validate([]byte(`{"device_id": `))
+		require.Len(t, violations, 1)
+		assert.Contains(t, violations[0].Message, "invalid JSON")
+	})
+}
+
+func TestErrorBody(t *testing.T) {
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(ErrorBody([]Violation{{Path: "/value", Message: "too big"}}), &body))
+	assert.Equal(t, "schema validation failed", body["error"])
+	assert.Len(t, body["violations"], 1)
+}
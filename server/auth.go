@@ -0,0 +1,69 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"servone/auth"
+	"servone/metrics"
+
+	"github.com/gorilla/mux"
+)
+
+// 현재 설정으로 인증 수단과 경로 규칙을 컴파일 (라우트 설정 및 Reload 시 수행)
+func (ds *DynamicServer) compileAuth() {
+	authorizer, errs := auth.Compile(ds.config.Rest.Auth)
+	for _, err := range errs {
+		log.Printf("Auth: %v (routes using it reject all requests)", err)
+	}
+	ds.auth = authorizer
+	ds.credentials = credentialFields{
+		headers: auth.CredentialHeaders(ds.config.Rest.Auth),
+		query:   auth.CredentialQueryParams(ds.config.Rest.Auth),
+	}
+}
+
+// 경로에 적용할 인증 정책 결정 (엔드포인트 설정 우선, 없으면 경로 접두사 규칙)
+func (ds *DynamicServer) authPolicy(endpointAuth []string, path string) *auth.Policy {
+	policy := ds.auth.Policy(endpointAuth, path)
+	if policy != nil {
+		for _, problem := range policy.Problems() {
+			log.Printf("Auth for %s: %s", path, problem)
+		}
+	}
+	return policy
+}
+
+// 요청을 인증하는 함수 (정책이 없으면 통과)
+// 실패 시 메트릭을 기록하고 401 응답을 보낸 뒤 false 를 반환한다
+func authenticate(w http.ResponseWriter, r *http.Request, policy *auth.Policy, path string) (*auth.Identity, bool) {
+	if policy == nil {
+		return nil, true
+	}
+
+	identity, reason, err := policy.Authenticate(r)
+	if err != nil {
+		metrics.RecordAuthFailure(path, reason)
+		log.Printf("%s %s - 401 | Authentication failed (%s): %v", r.Method, r.URL.Path, reason, err)
+		policy.Challenge(w.Header())
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return nil, false
+	}
+	return identity, true
+}
+
+// 내장 라우트(/metrics, /_admin, /openapi.json 등)를 경로 접두사 인증 규칙과 함께 등록
+func (ds *DynamicServer) handleBuiltin(path string, handler http.Handler) *mux.Route {
+	return ds.handleBuiltinAs(path, path, handler)
+}
+
+// 내장 라우트를 다른 경로(policyPath)의 접두사 인증 규칙으로 등록
+// (예: /api/_data 는 /_admin/ 규칙으로 보호)
+func (ds *DynamicServer) handleBuiltinAs(path, policyPath string, handler http.Handler) *mux.Route {
+	policy := ds.authPolicy(nil, policyPath)
+	return ds.router.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := authenticate(w, r, policy, path); !ok {
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"net/http"
+	"servone/config"
+	"servone/metrics"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthentication(t *testing.T) {
+	cfg := &config.Config{
+		Rest: config.RestConfig{
+			Auth: config.AuthConfig{
+				Providers: map[string]config.AuthProviderConfig{
+					"partners": {Type: "api_key", Keys: map[string]string{"partner-a": "key-a"}},
+				},
+				Rules: []config.AuthRule{
+					{Prefix: "/api/", Providers: []string{"partners"}},
+					{Prefix: "/metrics", Providers: []string{"partners"}},
+				},
+			},
+			Endpoints: []config.EndpointConfig{
+				{
+					Path:     "/api/whoami",
+					Method:   "POST",
+					Response: config.ResponseConfig{Status: 200, Body: `{"caller": "{{.claims.sub}}"}`},
+					Schema:   &config.SchemaConfig{Inline: map[string]interface{}{"type": "object", "required": []interface{}{"id"}}},
+				},
+				{
+					Path:     "/api/public",
+					Method:   "GET",
+					Response: config.ResponseConfig{Status: 200, Body: `ok`},
+					Auth:     []string{"none"},
+				},
+				{
+					Path:     "/api/misconfigured",
+					Method:   "GET",
+					Response: config.ResponseConfig{Status: 200},
+					Auth:     []string{"missing"},
+				},
+			},
+		},
+	}
+	ds := NewDynamicServer(cfg, &MockKafkaPublisher{})
+
+	t.Run("rejects before schema validation and storage", func(t *testing.T) {
+		before := testutil.ToFloat64(metrics.AuthFailuresTotal.WithLabelValues("/api/whoami", "missing_credentials"))
+
+		rr := serve(t, ds, "POST", "/api/whoami", `{}`)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+		assert.JSONEq(t, `{"error": "unauthorized"}`, rr.Body.String())
+
+		after := testutil.ToFloat64(metrics.AuthFailuresTotal.WithLabelValues("/api/whoami", "missing_credentials"))
+		assert.Equal(t, before+1, after)
+	})
+
+	t.Run("claims exposed to templates", func(t *testing.T) {
+		rr := serveWithHeaders(t, ds, "POST", "/api/whoami", `{"id": 1}`, map[string]string{"X-API-Key": "key-a"})
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"caller": "partner-a"}`, rr.Body.String())
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		rr := serveWithHeaders(t, ds, "POST", "/api/whoami", `{"id": 1}`, map[string]string{"X-API-Key": "wrong"})
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("endpoint opt-out", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, serve(t, ds, "GET", "/api/public", "").Code)
+	})
+
+	t.Run("unknown provider fails closed", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, serve(t, ds, "GET", "/api/misconfigured", "").Code)
+	})
+
+	t.Run("built-in routes follow prefix rules", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, serve(t, ds, "GET", "/metrics", "").Code)
+		assert.Equal(t, http.StatusOK, serveWithHeaders(t, ds, "GET", "/metrics", "", map[string]string{"X-API-Key": "key-a"}).Code)
+		assert.Equal(t, http.StatusOK, serve(t, ds, "GET", "/openapi.json", "").Code)
+	})
+}
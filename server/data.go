@@ -0,0 +1,237 @@
+package server
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"servone/db"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// 저장된 메시지 조회 인터페이스 (db.DataReader 가 구현)
+type DataReader interface {
+	Query(ctx context.Context, q db.DataQuery) ([]db.DataRow, *db.DataCursor, error)
+}
+
+// 저장된 메시지 조회 API 사용 (/api/_data/{source}, DB 사용 시에만)
+func WithDataReader(r DataReader) Option {
+	return func(ds *DynamicServer) {
+		ds.data = r
+	}
+}
+
+// 조회 API 경로와 인증 규칙에 사용하는 경로 (/_admin/ 규칙으로 보호)
+const (
+	dataRoutePath  = "/api/_data/{source}"
+	dataPolicyPath = "/_admin/data"
+)
+
+// 조회 API 라우트 등록 (조회기 설정 및 rest.data_api 사용 시에만)
+// 저장된 모든 수집 데이터를 노출하므로 인증 규칙이 없으면 등록하지 않는다
+func (ds *DynamicServer) setupDataRoutes() {
+	if ds.data == nil || !ds.config.Rest.DataAPI {
+		return
+	}
+	if ds.auth.Policy(nil, dataPolicyPath) == nil {
+		log.Printf("Data API disabled: no auth rule covers %s (add a rule for /_admin/ or %s)", dataPolicyPath, dataPolicyPath)
+		return
+	}
+	ds.handleBuiltinAs(dataRoutePath, dataPolicyPath, http.HandlerFunc(ds.handleDataQuery)).Methods("GET")
+	log.Printf("Added route: GET %s", dataRoutePath)
+}
+
+// 저장된 메시지 조회
+// 쿼리 파라미터: url/topic/path/host(정확히 일치, 끝이 * 이면 접두사), from/to(RFC3339),
+// field.<키>[.<키>...]=[eq|ne|gt|gte|lt|lte:]<값>, limit, cursor, order(asc|desc), format(json|ndjson|csv)
+func (ds *DynamicServer) handleDataQuery(w http.ResponseWriter, r *http.Request) {
+	src, ok := db.DataSources[mux.Vars(r)["source"]]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown data source (use rest, mqtt, coap or snmp)"})
+		return
+	}
+
+	q, format, err := parseDataQuery(src, r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	rows, next, err := ds.data.Query(r.Context(), q)
+	if err != nil {
+		log.Printf("GET %s - 500 | Data query failed: %v", r.URL.Path, err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "query failed"})
+		return
+	}
+
+	// 다음 페이지 커서는 모든 형식에서 헤더로, JSON 은 본문에도 포함
+	nextCursor := ""
+	if next != nil {
+		nextCursor = next.String()
+		w.Header().Set("X-Next-Cursor", nextCursor)
+	}
+
+	switch format {
+	case "csv":
+		writeDataCSV(w, src, rows)
+	case "ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		for _, row := range rows {
+			if err := enc.Encode(dataItem(src, row)); err != nil {
+				return
+			}
+		}
+	default:
+		items := make([]map[string]interface{}, 0, len(rows))
+		for _, row := range rows {
+			items = append(items, dataItem(src, row))
+		}
+		body := map[string]interface{}{"items": items}
+		if nextCursor != "" {
+			body["next_cursor"] = nextCursor
+		}
+		writeJSON(w, http.StatusOK, body)
+	}
+}
+
+// 쿼리 파라미터를 조회 조건과 출력 형식으로 변환
+func parseDataQuery(src db.DataSource, r *http.Request) (db.DataQuery, string, error) {
+	params := r.URL.Query()
+	q := db.DataQuery{Source: src, Key: params.Get(src.KeyColumn), Limit: db.DefaultQueryLimit}
+
+	for _, name := range []string{"from", "to"} {
+		v := params.Get(name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return q, "", fmt.Errorf("%s must be an RFC3339 time", name)
+		}
+		if name == "from" {
+			q.From = t
+		} else {
+			q.To = t
+		}
+	}
+
+	if v := params.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > db.MaxQueryLimit {
+			return q, "", fmt.Errorf("limit must be between 1 and %d", db.MaxQueryLimit)
+		}
+		q.Limit = n
+	}
+
+	if v := params.Get("cursor"); v != "" {
+		c, err := db.ParseDataCursor(v)
+		if err != nil {
+			return q, "", err
+		}
+		q.After = &c
+	}
+
+	switch params.Get("order") {
+	case "", "desc":
+	case "asc":
+		q.Ascending = true
+	default:
+		return q, "", fmt.Errorf("order must be asc or desc")
+	}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		field, ok := strings.CutPrefix(name, "field.")
+		if !ok {
+			continue
+		}
+		values := params[name]
+		if src.JSONColumn == "" {
+			return q, "", fmt.Errorf("source %s has no JSON fields to filter on", src.Name)
+		}
+		for _, v := range values {
+			f := db.FieldFilter{Path: strings.Split(field, "."), Op: "eq", Value: v}
+			if op, value, ok := strings.Cut(v, ":"); ok && isFieldOp(op) {
+				f.Op, f.Value = op, value
+			}
+			q.Fields = append(q.Fields, f)
+		}
+	}
+
+	format, err := dataFormat(r)
+	return q, format, err
+}
+
+func isFieldOp(op string) bool {
+	switch op {
+	case "eq", "ne", "gt", "gte", "lt", "lte":
+		return true
+	}
+	return false
+}
+
+// 출력 형식 결정 (format 파라미터 우선, 없으면 Accept 헤더)
+func dataFormat(r *http.Request) (string, error) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "json", "ndjson", "csv":
+		return format, nil
+	case "":
+	default:
+		return "", fmt.Errorf("format must be json, ndjson or csv")
+	}
+
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, "text/csv"):
+		return "csv", nil
+	case strings.Contains(accept, "application/x-ndjson"):
+		return "ndjson", nil
+	}
+	return "json", nil
+}
+
+// JSON/NDJSON 출력용 행 (created_at 은 RFC3339 UTC 시각)
+func dataItem(src db.DataSource, row db.DataRow) map[string]interface{} {
+	item := map[string]interface{}{
+		"id":         row.ID,
+		"created_at": time.Unix(0, row.CreatedAt).UTC().Format(time.RFC3339Nano),
+	}
+	for _, col := range src.Columns {
+		item[col] = row.Values[col]
+	}
+	return item
+}
+
+// CSV 출력 (JSON 컬럼은 JSON 문자열 그대로)
+func writeDataCSV(w http.ResponseWriter, src db.DataSource, rows []db.DataRow) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	cw := csv.NewWriter(w)
+	cw.Write(append([]string{"id", "created_at"}, src.Columns...))
+	for _, row := range rows {
+		record := []string{strconv.FormatInt(row.ID, 10), time.Unix(0, row.CreatedAt).UTC().Format(time.RFC3339Nano)}
+		for _, col := range src.Columns {
+			switch v := row.Values[col].(type) {
+			case nil:
+				record = append(record, "")
+			case json.RawMessage:
+				record = append(record, string(v))
+			default:
+				record = append(record, fmt.Sprint(v))
+			}
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+}
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"servone/config"
+	"servone/db"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 마지막 조회 조건을 기록하고 고정된 행을 돌려주는 조회기
+type fakeDataReader struct {
+	rows  []db.DataRow
+	next  *db.DataCursor
+	err   error
+	query db.DataQuery
+}
+
+func (f *fakeDataReader) Query(ctx context.Context, q db.DataQuery) ([]db.DataRow, *db.DataCursor, error) {
+	f.query = q
+	return f.rows, f.next, f.err
+}
+
+func restRows() []db.DataRow {
+	return []db.DataRow{
+		{ID: 2, CreatedAt: time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC).UnixNano(), Values: map[string]interface{}{
+			"url": "/api/tempo", "data": json.RawMessage(`{"temp": 22}`), "parameters": json.RawMessage(`{}`),
+		}},
+		{ID: 1, CreatedAt: time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC).UnixNano(), Values: map[string]interface{}{
+			"url": "/api/tempo", "data": json.RawMessage(`{"temp": 21}`), "parameters": nil,
+		}},
+	}
+}
+
+// 조회 API 를 켜고 /_admin/ 인증 규칙으로 보호하는 설정
+func dataConfig() *config.Config {
+	return &config.Config{Rest: config.RestConfig{
+		DataAPI: true,
+		Auth: config.AuthConfig{
+			Providers: map[string]config.AuthProviderConfig{
+				"ops": {Type: "api_key", Keys: map[string]string{"ops": "secret"}},
+			},
+			Rules: []config.AuthRule{{Prefix: "/_admin/", Providers: []string{"ops"}}},
+		},
+	}}
+}
+
+// 운영자 키로 조회 API 호출
+func serveData(t *testing.T, ds *DynamicServer, path string) *httptest.ResponseRecorder {
+	t.Helper()
+	return serveWithHeaders(t, ds, "GET", path, "", map[string]string{"X-API-Key": "secret"})
+}
+
+func TestDataQueryEndpoint(t *testing.T) {
+	t.Run("not registered without a reader", func(t *testing.T) {
+		ds := NewDynamicServer(dataConfig(), &MockKafkaPublisher{})
+		rr := serveData(t, ds, "/api/_data/rest")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("not registered unless enabled", func(t *testing.T) {
+		cfg := dataConfig()
+		cfg.Rest.DataAPI = false
+		ds := NewDynamicServer(cfg, &MockKafkaPublisher{}, WithDataReader(&fakeDataReader{}))
+		rr := serveData(t, ds, "/api/_data/rest")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("not registered without an auth rule", func(t *testing.T) {
+		ds := NewDynamicServer(&config.Config{Rest: config.RestConfig{DataAPI: true}}, &MockKafkaPublisher{}, WithDataReader(&fakeDataReader{}))
+		rr := serve(t, ds, "GET", "/api/_data/rest", "")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("parses filters into the query", func(t *testing.T) {
+		reader := &fakeDataReader{}
+		ds := NewDynamicServer(dataConfig(), &MockKafkaPublisher{}, WithDataReader(reader))
+		cursor := db.DataCursor{CreatedAt: 1700000000000000000, ID: 7}
+
+		rr := serveData(t, ds, "/api/_data/snmp?host=10.0.0.*&from=2026-10-16T00:00:00Z&to=2026-10-17T00:00:00%2B09:00"+
+			"&field.results.type=Integer&field.uptime=gt:100&limit=10&order=asc&cursor="+cursor.String())
+		require.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+
+		q := reader.query
+		assert.Equal(t, "snmp_data", q.Source.Table)
+		assert.Equal(t, "10.0.0.*", q.Key)
+		assert.True(t, q.From.Equal(time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)))
+		assert.True(t, q.To.Equal(time.Date(2026, 10, 16, 15, 0, 0, 0, time.UTC)))
+		assert.Equal(t, []db.FieldFilter{
+			{Path: []string{"results", "type"}, Op: "eq", Value: "Integer"},
+			{Path: []string{"uptime"}, Op: "gt", Value: "100"},
+		}, q.Fields)
+		assert.Equal(t, 10, q.Limit)
+		assert.True(t, q.Ascending)
+		assert.Equal(t, &cursor, q.After)
+	})
+
+	t.Run("rejects invalid parameters", func(t *testing.T) {
+		ds := NewDynamicServer(dataConfig(), &MockKafkaPublisher{}, WithDataReader(&fakeDataReader{}))
+		tests := map[string]string{
+			"bad time":             "/api/_data/rest?from=yesterday",
+			"limit too large":      "/api/_data/rest?limit=5000",
+			"bad cursor":           "/api/_data/rest?cursor=!!",
+			"bad order":            "/api/_data/rest?order=up",
+			"bad format":           "/api/_data/rest?format=xml",
+			"field on text source": "/api/_data/mqtt?field.temp=1",
+		}
+		for name, path := range tests {
+			t.Run(name, func(t *testing.T) {
+				rr := serveData(t, ds, path)
+				assert.Equal(t, http.StatusBadRequest, rr.Code)
+			})
+		}
+
+		rr := serveData(t, ds, "/api/_data/users")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("json output with the next cursor", func(t *testing.T) {
+		next := db.DataCursor{CreatedAt: 1, ID: 1}
+		ds := NewDynamicServer(dataConfig(), &MockKafkaPublisher{}, WithDataReader(&fakeDataReader{rows: restRows(), next: &next}))
+
+		rr := serveData(t, ds, "/api/_data/rest?url=/api/tempo")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, next.String(), rr.Header().Get("X-Next-Cursor"))
+		assert.JSONEq(t, `{
+			"items": [
+				{"id": 2, "created_at": "2026-10-16T09:00:00Z", "url": "/api/tempo", "data": {"temp": 22}, "parameters": {}},
+				{"id": 1, "created_at": "2026-10-16T08:00:00Z", "url": "/api/tempo", "data": {"temp": 21}, "parameters": null}
+			],
+			"next_cursor": "`+next.String()+`"
+		}`, rr.Body.String())
+	})
+
+	t.Run("ndjson and csv output", func(t *testing.T) {
+		ds := NewDynamicServer(dataConfig(), &MockKafkaPublisher{}, WithDataReader(&fakeDataReader{rows: restRows()}))
+
+		rr := serveData(t, ds, "/api/_data/rest?format=ndjson")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+		lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+		require.Len(t, lines, 2)
+		assert.JSONEq(t, `{"id": 2, "created_at": "2026-10-16T09:00:00Z", "url": "/api/tempo", "data": {"temp": 22}, "parameters": {}}`, lines[0])
+		assert.Empty(t, rr.Header().Get("X-Next-Cursor"))
+
+		req := httptest.NewRequest("GET", "/api/_data/rest", nil)
+		req.Header.Set("Accept", "text/csv")
+		req.Header.Set("X-API-Key", "secret")
+		rr = httptest.NewRecorder()
+		ds.router.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "id,created_at,url,data,parameters\n"+
+			"2,2026-10-16T09:00:00Z,/api/tempo,\"{\"\"temp\"\": 22}\",{}\n"+
+			"1,2026-10-16T08:00:00Z,/api/tempo,\"{\"\"temp\"\": 21}\",\n", rr.Body.String())
+	})
+
+	t.Run("query failures are 500", func(t *testing.T) {
+		ds := NewDynamicServer(dataConfig(), &MockKafkaPublisher{}, WithDataReader(&fakeDataReader{err: errors.New("connection refused")}))
+		rr := serveData(t, ds, "/api/_data/coap")
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		assert.NotContains(t, rr.Body.String(), "connection refused")
+	})
+
+	t.Run("protected by the admin auth rule", func(t *testing.T) {
+		cfg := &config.Config{Rest: config.RestConfig{
+			DataAPI: true,
+			Auth: config.AuthConfig{
+				Providers: map[string]config.AuthProviderConfig{
+					"ops": {Type: "api_key", Keys: map[string]string{"ops": "secret"}},
+				},
+				Rules: []config.AuthRule{{Prefix: "/_admin/", Providers: []string{"ops"}}},
+			},
+			Endpoints: []config.EndpointConfig{
+				{Path: "/api/{name}/{id}", Method: "GET", Response: config.ResponseConfig{Status: 200}},
+			},
+		}}
+		ds := NewDynamicServer(cfg, &MockKafkaPublisher{}, WithDataReader(&fakeDataReader{}))
+
+		rr := serve(t, ds, "GET", "/api/_data/mqtt", "")
+		assert.Equal(t, http.StatusUnauthorized, rr.Code, "the data route wins over /api/{name}/{id}")
+
+		req := httptest.NewRequest("GET", "/api/_data/mqtt", nil)
+		req.Header.Set("X-API-Key", "secret")
+		rr = httptest.NewRecorder()
+		ds.router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+}
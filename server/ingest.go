@@ -0,0 +1,46 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"servone/config"
+	"servone/ingest"
+)
+
+// 서버 생성 옵션
+type Option func(*DynamicServer)
+
+// 외부에서 생성한 수집 파이프라인을 사용 (종료 시 비우는 것은 생성한 쪽의 책임)
+func WithIngest(p *ingest.Pipeline) Option {
+	return func(ds *DynamicServer) {
+		ds.ingest = p
+	}
+}
+
+// 설정으로 수집 파이프라인 생성 (설정 오류 시 기본값 사용)
+func newIngestPipeline(cfg config.IngestConfig) *ingest.Pipeline {
+	p, err := ingest.New(cfg)
+	if err != nil {
+		log.Printf("Invalid ingest config, using defaults: %v", err)
+		p, _ = ingest.New(config.IngestConfig{})
+	}
+	return p
+}
+
+// 수집 작업 등록 (대기열이 가득 차 거절되거나 종료 중이면 503 응답 후 false 반환)
+func (ds *DynamicServer) submitIngest(w http.ResponseWriter, r *http.Request, job ingest.Job) bool {
+	err := ds.ingest.Submit(r.Context(), job)
+	if err == nil {
+		return true
+	}
+
+	message := "ingestion queue full"
+	if errors.Is(err, ingest.ErrClosed) {
+		message = "server is shutting down"
+	}
+	log.Printf("%s %s - 503 | Not accepted for ingestion: %v", r.Method, r.URL.Path, err)
+	w.Header().Set("Retry-After", "1")
+	writeJSON(w, http.StatusServiceUnavailable, map[string]string{"error": message})
+	return false
+}
@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"servone/config"
+	"servone/ingest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngestBackpressure(t *testing.T) {
+	cfg := &config.Config{
+		Rest: config.RestConfig{
+			Endpoints: []config.EndpointConfig{
+				{Path: "/api/tempo", Method: "POST", Response: config.ResponseConfig{Status: 201}},
+			},
+		},
+	}
+
+	t.Run("rejects with 503 when the shared queue is full", func(t *testing.T) {
+		pipeline, err := ingest.New(config.IngestConfig{Workers: 1, QueueSize: 1, Overflow: "reject"})
+		require.NoError(t, err)
+		ds := NewDynamicServer(cfg, &MockKafkaPublisher{}, WithIngest(pipeline))
+
+		// 작업자와 대기열을 모두 점유
+		release := make(chan struct{})
+		started := make(chan struct{})
+		require.NoError(t, pipeline.Submit(context.Background(), ingest.Job{Source: "test", Run: func() error {
+			close(started)
+			<-release
+			return nil
+		}}))
+		<-started
+
+		rr := serve(t, ds, "POST", "/api/tempo", `{"temp": 21}`)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+
+		rr = serve(t, ds, "POST", "/api/tempo", `{"temp": 22}`)
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+		assert.JSONEq(t, `{"error": "ingestion queue full"}`, rr.Body.String())
+
+		// 공유 파이프라인은 서버 종료 시 닫히지 않는다
+		close(release)
+		require.NoError(t, ds.Shutdown(context.Background()))
+		assert.Eventually(t, func() bool { return pipeline.Len() == 0 }, time.Second, 10*time.Millisecond)
+		require.NoError(t, pipeline.Submit(context.Background(), ingest.Job{Source: "test", Run: func() error { return nil }}))
+		require.NoError(t, pipeline.Close(context.Background()))
+	})
+
+	t.Run("owned pipeline is drained on shutdown", func(t *testing.T) {
+		ds := NewDynamicServer(cfg, &MockKafkaPublisher{})
+		rr := serve(t, ds, "POST", "/api/tempo", `{"temp": 21}`)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+
+		require.NoError(t, ds.Shutdown(context.Background()))
+		assert.Equal(t, 0, ds.ingest.Len())
+
+		rr = serve(t, ds, "POST", "/api/tempo", `{"temp": 22}`)
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		assert.JSONEq(t, `{"error": "server is shutting down"}`, rr.Body.String())
+	})
+}
@@ -0,0 +1,119 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JSONPath 표현식의 한 단계 (객체 키 또는 배열 인덱스)
+type jsonPathStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// 컴파일된 JSONPath 표현식
+// 지원 문법: $.a.b, $['a'], $.items[0].id
+type jsonPath struct {
+	expr  string
+	steps []jsonPathStep
+}
+
+// JSONPath 표현식을 파싱하는 함수
+func parseJSONPath(expr string) (*jsonPath, error) {
+	expr = strings.TrimSpace(expr)
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("jsonpath %q must start with '$'", expr)
+	}
+
+	jp := &jsonPath{expr: expr}
+	rest := expr[1:]
+
+	for len(rest) > 0 {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			key := rest[:end]
+			if key == "" {
+				return nil, fmt.Errorf("jsonpath %q has an empty key", expr)
+			}
+			jp.steps = append(jp.steps, jsonPathStep{key: key})
+			rest = rest[end:]
+
+		case '[':
+			end := strings.Index(rest, "]")
+			if end == -1 {
+				return nil, fmt.Errorf("jsonpath %q has an unclosed '['", expr)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				jp.steps = append(jp.steps, jsonPathStep{key: inner[1 : len(inner)-1]})
+				continue
+			}
+
+			idx, err := strconv.Atoi(inner)
+			if err != nil || idx < 0 {
+				return nil, fmt.Errorf("jsonpath %q has an invalid index %q", expr, inner)
+			}
+			jp.steps = append(jp.steps, jsonPathStep{index: idx, isIndex: true})
+
+		default:
+			return nil, fmt.Errorf("jsonpath %q has an unexpected character %q", expr, rest[0])
+		}
+	}
+
+	return jp, nil
+}
+
+// 파싱된 JSON 문서에서 표현식이 가리키는 값을 찾는 함수
+func (jp *jsonPath) lookup(doc interface{}) (interface{}, bool) {
+	current := doc
+	for _, step := range jp.steps {
+		if step.isIndex {
+			arr, ok := current.([]interface{})
+			if !ok || step.index >= len(arr) {
+				return nil, false
+			}
+			current = arr[step.index]
+			continue
+		}
+
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = obj[step.key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// JSON 값을 비교용 문자열로 변환하는 함수
+func jsonValueString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		b, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprintf("%v", val)
+		}
+		return string(b)
+	}
+}
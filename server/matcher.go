@@ -0,0 +1,210 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"servone/config"
+	"strings"
+)
+
+// 매칭 값의 종류
+const (
+	matchExact   = iota // 정확히 일치
+	matchPresent        // "*": 값이 존재하면 일치
+	matchAbsent         // "!": 값이 없으면 일치
+	matchRegex          // "regex:...": 정규표현식 일치
+)
+
+// 단일 조건 값에 대한 컴파일된 매처
+type valueMatcher struct {
+	kind  int
+	value string
+	re    *regexp.Regexp
+}
+
+// 설정 문자열을 매처로 변환하는 함수
+func compileValueMatcher(expected string) (valueMatcher, error) {
+	switch {
+	case expected == "*":
+		return valueMatcher{kind: matchPresent}, nil
+	case expected == "!":
+		return valueMatcher{kind: matchAbsent}, nil
+	case strings.HasPrefix(expected, "regex:"):
+		re, err := regexp.Compile(strings.TrimPrefix(expected, "regex:"))
+		if err != nil {
+			return valueMatcher{}, fmt.Errorf("invalid regex %q: %w", expected, err)
+		}
+		return valueMatcher{kind: matchRegex, re: re}, nil
+	default:
+		return valueMatcher{kind: matchExact, value: expected}, nil
+	}
+}
+
+// 실제 값이 조건과 일치하는지 확인
+func (m valueMatcher) match(actual string, present bool) bool {
+	switch m.kind {
+	case matchPresent:
+		return present
+	case matchAbsent:
+		return !present
+	case matchRegex:
+		return present && m.re.MatchString(actual)
+	default:
+		return present && actual == m.value
+	}
+}
+
+// JSONPath 기반 바디 조건
+type bodyMatcher struct {
+	path    *jsonPath
+	matcher valueMatcher
+}
+
+// 하나의 응답 변형에 대한 컴파일된 조건 묶음
+type requestMatcher struct {
+	name     string
+	query    map[string]valueMatcher
+	headers  map[string]valueMatcher
+	pathVars map[string]valueMatcher
+	body     []bodyMatcher
+	response config.ResponseConfig
+}
+
+// 엔드포인트의 응답 변형 목록과 기본 응답을 담는 선택기
+type responseSelector struct {
+	variants []*requestMatcher
+	fallback config.ResponseConfig
+}
+
+// 문자열 맵 조건을 컴파일하는 함수
+func compileValueMap(conds map[string]string) (map[string]valueMatcher, error) {
+	compiled := make(map[string]valueMatcher, len(conds))
+	for key, expected := range conds {
+		m, err := compileValueMatcher(expected)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+		compiled[key] = m
+	}
+	return compiled, nil
+}
+
+// 응답 변형 하나를 컴파일하는 함수
+func compileVariant(variant config.ResponseVariant) (*requestMatcher, error) {
+	rm, err := compileMatch(variant.Name, variant.Match)
+	if err != nil {
+		return nil, err
+	}
+	rm.response = withDefaultStatus(variant.Response)
+	return rm, nil
+}
+
+// 매칭 조건 묶음을 컴파일하는 함수
+func compileMatch(name string, match config.MatchConfig) (*requestMatcher, error) {
+	rm := &requestMatcher{name: name}
+
+	var err error
+	if rm.query, err = compileValueMap(match.Query); err != nil {
+		return nil, fmt.Errorf("query %w", err)
+	}
+	if rm.headers, err = compileValueMap(match.Headers); err != nil {
+		return nil, fmt.Errorf("header %w", err)
+	}
+	if rm.pathVars, err = compileValueMap(match.PathVars); err != nil {
+		return nil, fmt.Errorf("path var %w", err)
+	}
+
+	for expr, expected := range match.Body {
+		jp, err := parseJSONPath(expr)
+		if err != nil {
+			return nil, err
+		}
+		m, err := compileValueMatcher(expected)
+		if err != nil {
+			return nil, fmt.Errorf("body %s: %w", expr, err)
+		}
+		rm.body = append(rm.body, bodyMatcher{path: jp, matcher: m})
+	}
+
+	return rm, nil
+}
+
+// status 를 생략한 응답은 200 OK 로 처리 (WriteHeader(0) 은 패닉)
+func withDefaultStatus(response config.ResponseConfig) config.ResponseConfig {
+	if response.Status == 0 {
+		response.Status = http.StatusOK
+	}
+	return response
+}
+
+// 엔드포인트 설정으로부터 응답 선택기를 생성
+// 잘못된 조건을 가진 변형은 로그를 남기고 건너뛴다
+func newResponseSelector(endpoint config.EndpointConfig) (*responseSelector, []error) {
+	rs := &responseSelector{fallback: withDefaultStatus(endpoint.Response)}
+	var errs []error
+
+	for i, variant := range endpoint.Responses {
+		rm, err := compileVariant(variant)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("response variant %d (%s): %w", i, variant.Name, err))
+			continue
+		}
+		rs.variants = append(rs.variants, rm)
+	}
+
+	return rs, errs
+}
+
+// 요청이 변형의 모든 조건과 일치하는지 확인
+func (rm *requestMatcher) matches(r *http.Request, vars map[string]string, body interface{}) bool {
+	query := r.URL.Query()
+	for key, m := range rm.query {
+		_, present := query[key]
+		if !m.match(query.Get(key), present) {
+			return false
+		}
+	}
+
+	for key, m := range rm.headers {
+		values := r.Header.Values(key)
+		present := len(values) > 0
+		actual := ""
+		if present {
+			actual = values[0]
+		}
+		if !m.match(actual, present) {
+			return false
+		}
+	}
+
+	for key, m := range rm.pathVars {
+		actual, present := vars[key]
+		if !m.match(actual, present) {
+			return false
+		}
+	}
+
+	for _, bm := range rm.body {
+		value, present := bm.path.lookup(body)
+		actual := ""
+		if present {
+			actual = jsonValueString(value)
+		}
+		if !bm.matcher.match(actual, present) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// 첫 번째로 일치하는 변형의 응답을 반환하고, 없으면 기본 응답을 반환
+func (rs *responseSelector) selectResponse(r *http.Request, vars map[string]string, body interface{}) (config.ResponseConfig, string) {
+	for _, rm := range rs.variants {
+		if rm.matches(r, vars, body) {
+			return rm.response, rm.name
+		}
+	}
+	return rs.fallback, ""
+}
@@ -0,0 +1,192 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"servone/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	var doc interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"device": {"id": "dev-1", "temp": 21.5}, "items": [{"id": 7}], "ok": true}`), &doc))
+
+	tests := []struct {
+		expr  string
+		want  string
+		found bool
+	}{
+		{"$.device.id", "dev-1", true},
+		{"$['device']['temp']", "21.5", true},
+		{"$.items[0].id", "7", true},
+		{"$.items[1].id", "", false},
+		{"$.ok", "true", true},
+		{"$.missing", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			jp, err := parseJSONPath(tt.expr)
+			require.NoError(t, err)
+
+			value, found := jp.lookup(doc)
+			assert.Equal(t, tt.found, found)
+			if tt.found {
+				assert.Equal(t, tt.want, jsonValueString(value))
+			}
+		})
+	}
+
+	t.Run("invalid expressions", func(t *testing.T) {
+		for _, expr := range []string{"device.id", "$.", "$[abc]", "$[0"} {
+			_, err := parseJSONPath(expr)
+			assert.Error(t, err, expr)
+		}
+	})
+}
+
+func TestValueMatcher(t *testing.T) {
+	exact, err := compileValueMatcher("abc")
+	require.NoError(t, err)
+	assert.True(t, exact.match("abc", true))
+	assert.False(t, exact.match("abd", true))
+	assert.False(t, exact.match("", false))
+
+	present, err := compileValueMatcher("*")
+	require.NoError(t, err)
+	assert.True(t, present.match("", true))
+	assert.False(t, present.match("", false))
+
+	absent, err := compileValueMatcher("!")
+	require.NoError(t, err)
+	assert.True(t, absent.match("", false))
+	assert.False(t, absent.match("x", true))
+
+	re, err := compileValueMatcher("regex:^dev-[0-9]+$")
+	require.NoError(t, err)
+	assert.True(t, re.match("dev-12", true))
+	assert.False(t, re.match("dev-x", true))
+
+	_, err = compileValueMatcher("regex:[")
+	assert.Error(t, err)
+}
+
+func TestConditionalResponses(t *testing.T) {
+	cfg := &config.Config{
+		Rest: config.RestConfig{
+			Host: "localhost",
+			Port: "8080",
+			Endpoints: []config.EndpointConfig{
+				{
+					Path:   "/api/devices/{id}",
+					Method: "POST",
+					Response: config.ResponseConfig{
+						Status: 200,
+						Body:   `{"result": "default"}`,
+					},
+					Responses: []config.ResponseVariant{
+						{
+							Name:     "missing-temp",
+							Match:    config.MatchConfig{Body: map[string]string{"$.temp": "!"}},
+							Response: config.ResponseConfig{Status: 400, Body: `{"error": "temp required"}`},
+						},
+						{
+							Name:     "blocked-device",
+							Match:    config.MatchConfig{PathVars: map[string]string{"id": "999"}},
+							Response: config.ResponseConfig{Status: 403, Body: `{"error": "blocked"}`},
+						},
+						{
+							Name: "verbose",
+							Match: config.MatchConfig{
+								Query:   map[string]string{"verbose": "true"},
+								Headers: map[string]string{"X-Client": "regex:^gw-"},
+							},
+							Response: config.ResponseConfig{Status: 202, Body: `{"result": "verbose"}`},
+						},
+						{
+							Name:     "broken",
+							Match:    config.MatchConfig{Body: map[string]string{"temp": "1"}},
+							Response: config.ResponseConfig{Status: 500},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	server := NewDynamicServer(cfg, &MockKafkaPublisher{})
+
+	send := func(path, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", path, bytes.NewBufferString(body))
+		require.NoError(t, err)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("body matcher", func(t *testing.T) {
+		rr := send("/api/devices/1", `{"hum": 40}`, nil)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("path var matcher", func(t *testing.T) {
+		rr := send("/api/devices/999", `{"temp": 20}`, nil)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("query and header matchers", func(t *testing.T) {
+		rr := send("/api/devices/1?verbose=true", `{"temp": 20}`, map[string]string{"X-Client": "gw-01"})
+		assert.Equal(t, http.StatusAccepted, rr.Code)
+
+		rr = send("/api/devices/1?verbose=true", `{"temp": 20}`, map[string]string{"X-Client": "phone"})
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("fallback to default response", func(t *testing.T) {
+		rr := send("/api/devices/1", `{"temp": 20}`, nil)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `{"result": "default"}`, rr.Body.String())
+	})
+
+	t.Run("reload picks up new variants", func(t *testing.T) {
+		newCfg := *cfg
+		newCfg.Rest.Endpoints = []config.EndpointConfig{cfg.Rest.Endpoints[0]}
+		newCfg.Rest.Endpoints[0].Responses = []config.ResponseVariant{
+			{
+				Match:    config.MatchConfig{Body: map[string]string{"$.temp": "*"}},
+				Response: config.ResponseConfig{Status: 418},
+			},
+		}
+		server.Reload(&newCfg)
+
+		rr := send("/api/devices/1", `{"temp": 20}`, nil)
+		assert.Equal(t, http.StatusTeapot, rr.Code)
+	})
+
+	t.Run("omitted status defaults to 200", func(t *testing.T) {
+		ds := NewDynamicServer(&config.Config{Rest: config.RestConfig{Endpoints: []config.EndpointConfig{{
+			Path:     "/api/plain",
+			Method:   "GET",
+			Response: config.ResponseConfig{Body: "default"},
+			Responses: []config.ResponseVariant{
+				{Match: config.MatchConfig{Query: map[string]string{"v": "1"}}, Response: config.ResponseConfig{Body: "variant"}},
+			},
+		}}}}, &MockKafkaPublisher{})
+
+		rr := serve(t, ds, "GET", "/api/plain?v=1", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "variant", rr.Body.String())
+
+		rr = serve(t, ds, "GET", "/api/plain", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "default", rr.Body.String())
+	})
+}
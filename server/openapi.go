@@ -0,0 +1,76 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"servone/config"
+	"servone/openapi"
+)
+
+// OpenAPI 문서 메타데이터
+var openapiInfo = openapi.Info{
+	Title:       "servone REST API",
+	Version:     "1.0.0",
+	Description: "설정 파일(rest.endpoints)로부터 자동 생성된 문서입니다.",
+}
+
+// 현재 설정으로 OpenAPI 문서를 생성하고 문서/UI 라우트를 등록 (Reload 때마다 다시 생성)
+func (ds *DynamicServer) setupOpenAPIRoutes() {
+	doc, errs := openapi.Generate(ds.config.Rest, openapiInfo)
+	for _, err := range errs {
+		log.Printf("OpenAPI: %v", err)
+	}
+
+	spec, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		log.Printf("Failed to generate OpenAPI document: %v", err)
+		return
+	}
+
+	ds.handleBuiltin("/openapi.json", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	})).Methods("GET")
+	ds.handleBuiltin("/docs", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(openapi.UIPage)
+	})).Methods("GET")
+	log.Printf("Added route: GET /openapi.json, GET /docs")
+}
+
+// rest.openapi 에 지정된 명세의 엔드포인트를 병합한 설정 사본을 반환
+// 설정 파일에 직접 정의한 엔드포인트(메서드 + 경로)가 우선하며, 읽을 수 없는 명세는 건너뛴다
+func withImportedEndpoints(cfg *config.Config) *config.Config {
+	if len(cfg.Rest.OpenAPI) == 0 {
+		return cfg
+	}
+
+	merged := *cfg
+	merged.Rest.Endpoints = append([]config.EndpointConfig(nil), cfg.Rest.Endpoints...)
+
+	defined := make(map[string]bool, len(merged.Rest.Endpoints))
+	for _, endpoint := range merged.Rest.Endpoints {
+		defined[endpointKey(endpoint)] = true
+	}
+
+	for _, spec := range cfg.Rest.OpenAPI {
+		endpoints, err := openapi.ImportFile(spec)
+		if err != nil {
+			log.Printf("Skipping OpenAPI spec: %v", err)
+			continue
+		}
+		imported := 0
+		for _, endpoint := range endpoints {
+			key := endpointKey(endpoint)
+			if defined[key] {
+				continue
+			}
+			defined[key] = true
+			merged.Rest.Endpoints = append(merged.Rest.Endpoints, endpoint)
+			imported++
+		}
+		log.Printf("Imported %d endpoint(s) from OpenAPI spec %s", imported, spec)
+	}
+	return &merged
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"servone/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPIRoutes(t *testing.T) {
+	endpoints := []config.EndpointConfig{
+		{Path: "/api/users", Method: "GET", Response: config.ResponseConfig{Status: 200, Body: `{"users": []}`}},
+	}
+	ds := NewDynamicServer(&config.Config{Rest: config.RestConfig{Endpoints: endpoints}}, &MockKafkaPublisher{})
+
+	paths := func() map[string]interface{} {
+		rr := serve(t, ds, "GET", "/openapi.json", "")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+		var doc map[string]interface{}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &doc))
+		assert.Equal(t, "3.1.0", doc["openapi"])
+		return doc["paths"].(map[string]interface{})
+	}
+
+	assert.Contains(t, paths(), "/api/users")
+
+	t.Run("regenerated on reload", func(t *testing.T) {
+		endpoints = append(endpoints, config.EndpointConfig{Path: "/api/devices/{id}", Method: "POST", Response: config.ResponseConfig{Status: 201}})
+		ds.Reload(&config.Config{Rest: config.RestConfig{Endpoints: endpoints}})
+		assert.Contains(t, paths(), "/api/devices/{id}")
+	})
+
+	t.Run("docs page", func(t *testing.T) {
+		rr := serve(t, ds, "GET", "/docs", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Contains(t, rr.Header().Get("Content-Type"), "text/html")
+		assert.Contains(t, rr.Body.String(), "openapi.json")
+	})
+}
+
+func TestImportedOpenAPIEndpoints(t *testing.T) {
+	spec := filepath.Join(t.TempDir(), "partner.yaml")
+	writeSpec := func(body string) {
+		require.NoError(t, os.WriteFile(spec, []byte(`
+openapi: 3.1.0
+info: {title: partner, version: "1"}
+paths:
+  /partner/status:
+    get:
+      responses:
+        '200':
+          content:
+            application/json:
+              example: `+body+`
+  /api/users:
+    get:
+      responses:
+        '200':
+          content:
+            application/json:
+              example: {from: spec}
+`), 0644))
+	}
+	writeSpec(`{state: ready}`)
+
+	cfg := &config.Config{Rest: config.RestConfig{
+		OpenAPI: []string{spec, filepath.Join(t.TempDir(), "missing.yaml")},
+		Endpoints: []config.EndpointConfig{
+			{Path: "/api/users", Method: "GET", Response: config.ResponseConfig{Status: 200, Body: `{"from": "config"}`}},
+		},
+	}}
+	ds := NewDynamicServer(cfg, &MockKafkaPublisher{})
+	assert.Len(t, cfg.Rest.Endpoints, 1, "caller's config must not be modified")
+
+	rr := serve(t, ds, "GET", "/partner/status", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"state": "ready"}`, rr.Body.String())
+
+	// 설정 파일의 엔드포인트가 명세보다 우선
+	assert.JSONEq(t, `{"from": "config"}`, serve(t, ds, "GET", "/api/users", "").Body.String())
+
+	t.Run("spec re-read on reload", func(t *testing.T) {
+		writeSpec(`{state: busy}`)
+		ds.Reload(cfg)
+		assert.JSONEq(t, `{"state": "busy"}`, serve(t, ds, "GET", "/partner/status", "").Body.String())
+	})
+}
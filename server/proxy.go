@@ -0,0 +1,195 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"servone/config"
+	"servone/metrics"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 기본 업스트림 타임아웃
+const defaultProxyTimeout = 10 * time.Second
+
+// 요청 본문과 업스트림 응답 본문의 최대 크기 (10MB)
+const maxBodyBytes = 10 * 1024 * 1024
+
+// 프록시 시 전달하지 않는 hop-by-hop 헤더
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// 인증 수단이 자격 증명을 읽는 헤더와 쿼리 파라미터
+// servone 의 자격 증명이 업스트림(제3자 백엔드)으로 새지 않도록 proxy.forward_credentials 가 아니면 제거한다
+type credentialFields struct {
+	headers []string
+	query   []string
+}
+
+// 요청 헤더에서 자격 증명 헤더 제거
+func (c credentialFields) stripHeaders(h http.Header) {
+	for _, name := range c.headers {
+		h.Del(name)
+	}
+}
+
+// 원본 쿼리 문자열에서 자격 증명 파라미터 제거 (해당 파라미터가 없으면 원본 유지)
+func (c credentialFields) stripQuery(rawQuery string) string {
+	if rawQuery == "" || len(c.query) == 0 {
+		return rawQuery
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return rawQuery
+	}
+	changed := false
+	for _, name := range c.query {
+		if values.Has(name) {
+			values.Del(name)
+			changed = true
+		}
+	}
+	if !changed {
+		return rawQuery
+	}
+	return values.Encode()
+}
+
+// 업스트림 URL 생성 ({var} 경로 변수 치환 후 원본 쿼리 문자열 병합)
+func buildUpstreamURL(target string, vars map[string]string, rawQuery string) (string, error) {
+	for k, v := range vars {
+		target = strings.ReplaceAll(target, "{"+k+"}", url.PathEscape(v))
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("invalid upstream url %q: %w", target, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("upstream url %q must be absolute", target)
+	}
+
+	if rawQuery != "" {
+		if u.RawQuery == "" {
+			u.RawQuery = rawQuery
+		} else {
+			u.RawQuery = u.RawQuery + "&" + rawQuery
+		}
+	}
+	return u.String(), nil
+}
+
+// hop-by-hop 헤더 제거
+func removeHopByHop(h http.Header) {
+	for _, connHeader := range h.Values("Connection") {
+		for _, name := range strings.Split(connHeader, ",") {
+			h.Del(strings.TrimSpace(name))
+		}
+	}
+	for _, name := range hopByHopHeaders {
+		h.Del(name)
+	}
+}
+
+// 요청을 업스트림으로 전달하고 응답을 그대로 돌려주는 함수
+// 업스트림 호출에 실패(fallback_on_5xx 이면 5xx 응답 포함)하고 fallback 이 설정되어 있으면 false 를 반환하여 정적 응답을 사용하게 한다
+func (ds *DynamicServer) proxyRequest(w http.ResponseWriter, r *http.Request, endpoint config.EndpointConfig, proxy *config.ProxyConfig, credentials credentialFields, vars map[string]string, body []byte) bool {
+	start := time.Now()
+
+	fail := func(err error) bool {
+		if proxy.Fallback {
+			metrics.RecordProxyRequest(endpoint.Path, "fallback", time.Since(start).Seconds())
+			log.Printf("Proxy %s %s failed, using static fallback: %v", r.Method, r.URL.Path, err)
+			w.Header().Set("X-Servone-Proxy", "fallback")
+			return false
+		}
+		metrics.RecordProxyRequest(endpoint.Path, "error", time.Since(start).Seconds())
+		log.Printf("Proxy %s %s failed: %v", r.Method, r.URL.Path, err)
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": "upstream request failed"})
+		return true
+	}
+
+	rawQuery := r.URL.RawQuery
+	if !proxy.ForwardCredentials {
+		rawQuery = credentials.stripQuery(rawQuery)
+	}
+	target, err := buildUpstreamURL(proxy.URL, vars, rawQuery)
+	if err != nil {
+		return fail(err)
+	}
+
+	timeout := defaultProxyTimeout
+	if proxy.TimeoutMs > 0 {
+		timeout = time.Duration(proxy.TimeoutMs) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	upReq, err := http.NewRequestWithContext(ctx, r.Method, target, bytes.NewReader(body))
+	if err != nil {
+		return fail(err)
+	}
+	upReq.Header = r.Header.Clone()
+	removeHopByHop(upReq.Header)
+	if !proxy.ForwardCredentials {
+		credentials.stripHeaders(upReq.Header)
+	}
+	for k, v := range proxy.Headers {
+		upReq.Header.Set(k, v)
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		if prior := r.Header.Get("X-Forwarded-For"); prior != "" {
+			host = prior + ", " + host
+		}
+		upReq.Header.Set("X-Forwarded-For", host)
+	}
+	upReq.Header.Set("X-Forwarded-Host", r.Host)
+	upReq.ContentLength = int64(len(body))
+
+	resp, err := ds.proxyClient.Do(upReq)
+	if err != nil {
+		return fail(err)
+	}
+	defer resp.Body.Close()
+
+	if proxy.FallbackOn5xx && resp.StatusCode >= 500 {
+		return fail(fmt.Errorf("upstream responded %d", resp.StatusCode))
+	}
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyBytes+1))
+	if err != nil {
+		return fail(err)
+	}
+	if len(respBody) > maxBodyBytes {
+		return fail(fmt.Errorf("upstream response exceeds %d bytes", maxBodyBytes))
+	}
+
+	metrics.RecordProxyRequest(endpoint.Path, strconv.Itoa(resp.StatusCode), time.Since(start).Seconds())
+	log.Printf("Proxy %s %s -> %s - %d", r.Method, r.URL.Path, target, resp.StatusCode)
+
+	removeHopByHop(resp.Header)
+	for k, values := range resp.Header {
+		for _, v := range values {
+			w.Header().Add(k, v)
+		}
+	}
+	w.Header().Del("Content-Length")
+	w.WriteHeader(resp.StatusCode)
+	w.Write(respBody)
+	return true
+}
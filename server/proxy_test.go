@@ -0,0 +1,204 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"servone/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildUpstreamURL(t *testing.T) {
+	got, err := buildUpstreamURL("http://upstream:8080/v1/devices/{id}?src=servone", map[string]string{"id": "a b"}, "verbose=true")
+	require.NoError(t, err)
+	assert.Equal(t, "http://upstream:8080/v1/devices/a%20b?src=servone&verbose=true", got)
+
+	_, err = buildUpstreamURL("/relative/{id}", map[string]string{"id": "1"}, "")
+	assert.Error(t, err)
+}
+
+func TestProxyResponse(t *testing.T) {
+	var gotMethod, gotPath, gotBody, gotHeader string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod = r.Method
+		gotPath = r.URL.RequestURI()
+		gotHeader = r.Header.Get("X-Api-Key")
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Upstream", "yes")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"upstream": true}`))
+	}))
+	defer upstream.Close()
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/huge" {
+			w.Write(bytes.Repeat([]byte("x"), maxBodyBytes+1))
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"upstream": "down"}`))
+	}))
+	defer failing.Close()
+
+	cfg := &config.Config{
+		Rest: config.RestConfig{
+			Endpoints: []config.EndpointConfig{
+				{
+					Path:   "/api/devices/{id}",
+					Method: "POST",
+					Response: config.ResponseConfig{
+						Status: 201,
+						Body:   `{"static": true}`,
+						Proxy: &config.ProxyConfig{
+							URL:     upstream.URL + "/v1/devices/{id}",
+							Headers: map[string]string{"X-Api-Key": "secret"},
+						},
+					},
+				},
+				{
+					Path:   "/api/down",
+					Method: "GET",
+					Response: config.ResponseConfig{
+						Status: 200,
+						Body:   `{"static": true}`,
+						Proxy:  &config.ProxyConfig{URL: "http://127.0.0.1:1/unreachable", TimeoutMs: 500, Fallback: true},
+					},
+				},
+				{
+					Path:   "/api/down-strict",
+					Method: "GET",
+					Response: config.ResponseConfig{
+						Status: 200,
+						Proxy:  &config.ProxyConfig{URL: "http://127.0.0.1:1/unreachable", TimeoutMs: 500},
+					},
+				},
+				{
+					Path:   "/api/5xx",
+					Method: "GET",
+					Response: config.ResponseConfig{
+						Status: 200,
+						Body:   `{"static": true}`,
+						Proxy:  &config.ProxyConfig{URL: failing.URL + "/5xx", Fallback: true},
+					},
+				},
+				{
+					Path:   "/api/5xx-fallback",
+					Method: "GET",
+					Response: config.ResponseConfig{
+						Status: 200,
+						Body:   `{"static": true}`,
+						Proxy:  &config.ProxyConfig{URL: failing.URL + "/5xx", Fallback: true, FallbackOn5xx: true},
+					},
+				},
+				{
+					Path:   "/api/huge",
+					Method: "GET",
+					Response: config.ResponseConfig{
+						Status: 200,
+						Proxy:  &config.ProxyConfig{URL: failing.URL + "/huge"},
+					},
+				},
+			},
+		},
+	}
+
+	ds := NewDynamicServer(cfg, &MockKafkaPublisher{})
+
+	t.Run("forwards request and returns upstream response", func(t *testing.T) {
+		rr := serve(t, ds, "POST", "/api/devices/42?mode=fast", `{"temp": 21}`)
+
+		assert.Equal(t, http.StatusAccepted, rr.Code)
+		assert.Equal(t, "yes", rr.Header().Get("X-Upstream"))
+		assert.JSONEq(t, `{"upstream": true}`, rr.Body.String())
+
+		assert.Equal(t, "POST", gotMethod)
+		assert.Equal(t, "/v1/devices/42?mode=fast", gotPath)
+		assert.Equal(t, `{"temp": 21}`, gotBody)
+		assert.Equal(t, "secret", gotHeader)
+	})
+
+	t.Run("falls back to static response", func(t *testing.T) {
+		rr := serve(t, ds, "GET", "/api/down", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "fallback", rr.Header().Get("X-Servone-Proxy"))
+		assert.JSONEq(t, `{"static": true}`, rr.Body.String())
+	})
+
+	t.Run("returns bad gateway without fallback", func(t *testing.T) {
+		rr := serve(t, ds, "GET", "/api/down-strict", "")
+		assert.Equal(t, http.StatusBadGateway, rr.Code)
+	})
+
+	t.Run("passes upstream 5xx through unless fallback_on_5xx", func(t *testing.T) {
+		rr := serve(t, ds, "GET", "/api/5xx", "")
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		assert.JSONEq(t, `{"upstream": "down"}`, rr.Body.String())
+
+		rr = serve(t, ds, "GET", "/api/5xx-fallback", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "fallback", rr.Header().Get("X-Servone-Proxy"))
+		assert.JSONEq(t, `{"static": true}`, rr.Body.String())
+	})
+
+	t.Run("rejects oversized upstream responses", func(t *testing.T) {
+		rr := serve(t, ds, "GET", "/api/huge", "")
+		assert.Equal(t, http.StatusBadGateway, rr.Code)
+	})
+}
+
+func TestProxyCredentials(t *testing.T) {
+	var got *http.Request
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	endpoint := func(path string, forward bool) config.EndpointConfig {
+		return config.EndpointConfig{
+			Path:     path,
+			Method:   "GET",
+			Response: config.ResponseConfig{Proxy: &config.ProxyConfig{URL: upstream.URL + "/v1", ForwardCredentials: forward}},
+		}
+	}
+	cfg := &config.Config{
+		Rest: config.RestConfig{
+			Auth: config.AuthConfig{
+				Providers: map[string]config.AuthProviderConfig{
+					"keys":   {Type: "api_key", Query: "api_key", Header: "X-API-Key", Keys: map[string]string{"gw": "gateway-secret"}},
+					"tokens": {Type: "jwt", Secret: "jwt-secret"},
+				},
+				Rules: []config.AuthRule{{Prefix: "/api/", Providers: []string{"keys"}}},
+			},
+			Endpoints: []config.EndpointConfig{endpoint("/api/strip", false), endpoint("/api/forward", true)},
+		},
+	}
+	ds := NewDynamicServer(cfg, &MockKafkaPublisher{})
+	headers := map[string]string{"X-API-Key": "gateway-secret", "Authorization": "Bearer abc", "X-Trace": "t1"}
+
+	t.Run("credentials consumed by auth are not forwarded", func(t *testing.T) {
+		rr := serveWithHeaders(t, ds, "GET", "/api/strip?api_key=gateway-secret&mode=fast", "", headers)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		assert.Empty(t, got.Header.Get("X-API-Key"))
+		assert.Empty(t, got.Header.Get("Authorization"))
+		assert.Equal(t, "t1", got.Header.Get("X-Trace"))
+		assert.Equal(t, "/v1?mode=fast", got.URL.RequestURI())
+	})
+
+	t.Run("forward_credentials keeps them", func(t *testing.T) {
+		rr := serveWithHeaders(t, ds, "GET", "/api/forward?api_key=gateway-secret", "", headers)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		assert.Equal(t, "gateway-secret", got.Header.Get("X-API-Key"))
+		assert.Equal(t, "Bearer abc", got.Header.Get("Authorization"))
+		assert.Equal(t, "/v1?api_key=gateway-secret", got.URL.RequestURI())
+	})
+}
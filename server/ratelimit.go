@@ -0,0 +1,138 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"servone/auth"
+	"servone/config"
+	"servone/metrics"
+	"servone/ratelimit"
+	"strconv"
+	"strings"
+)
+
+// X-Forwarded-For 를 신뢰하는 프록시 주소 대역 (rest.trusted_proxies)
+type trustedProxies []*net.IPNet
+
+// 현재 설정으로 신뢰 프록시 목록을 컴파일 (라우트 설정 및 Reload 시 수행)
+func (ds *DynamicServer) compileTrustedProxies() {
+	proxies, errs := parseTrustedProxies(ds.config.Rest.TrustedProxies)
+	for _, err := range errs {
+		log.Printf("Ignoring trusted proxy: %v", err)
+	}
+	ds.proxies = proxies
+}
+
+// IP 또는 CIDR 목록을 파싱 (잘못된 항목은 오류로 보고하고 제외)
+func parseTrustedProxies(entries []string) (trustedProxies, []error) {
+	var proxies trustedProxies
+	var errs []error
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				errs = append(errs, fmt.Errorf("invalid address %q", entry))
+				continue
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid CIDR %q", entry))
+			continue
+		}
+		proxies = append(proxies, ipNet)
+	}
+	return proxies, errs
+}
+
+func (t trustedProxies) contains(addr string) bool {
+	ip := net.ParseIP(strings.TrimSpace(addr))
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range t {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// 요청 제한 키로 사용할 클라이언트 IP
+// 직접 연결한 피어(RemoteAddr)가 신뢰하는 프록시일 때만 X-Forwarded-For 를 오른쪽부터 따라가
+// 신뢰하지 않는 첫 주소를 사용한다 (클라이언트가 헤더를 꾸며 새 버킷을 얻지 못하도록)
+func (t trustedProxies) clientIP(r *http.Request) string {
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peer = r.RemoteAddr
+	}
+	if !t.contains(peer) {
+		return peer
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		if !t.contains(hop) {
+			return hop
+		}
+		peer = hop
+	}
+	return peer
+}
+
+// 일일 할당량 저장소 지정 (기본은 메모리 카운터, 재시작 시 초기화)
+func WithQuotaCounter(c ratelimit.Counter) Option {
+	return func(ds *DynamicServer) {
+		ds.limits = ratelimit.NewRegistry(c)
+	}
+}
+
+// 엔드포인트에 적용할 요청 제한 목록 (서버 전체 rest.rate_limit → 엔드포인트 rate_limit 순서)
+func (ds *DynamicServer) endpointLimiters(endpoint config.EndpointConfig) []*ratelimit.Limiter {
+	limiters, errs := ds.limits.ForEndpoint("rest", ds.config.Rest.RateLimit, endpoint)
+	for _, err := range errs {
+		log.Printf("Rate limit disabled for %s %s: %v", endpoint.Method, endpoint.Path, err)
+	}
+	return limiters
+}
+
+// 요청 제한 검사 (초과 시 메트릭을 기록하고 Retry-After 와 함께 429 응답 후 false 반환)
+func allowRequest(w http.ResponseWriter, r *http.Request, limiters []*ratelimit.Limiter, proxies trustedProxies, path string, identity *auth.Identity, body []byte) bool {
+	if len(limiters) == 0 {
+		return true
+	}
+
+	client := ratelimit.Client{IP: proxies.clientIP(r), Header: r.Header.Get, Body: body}
+	if identity != nil {
+		client.Subject = identity.Provider + ":" + identity.Subject
+	}
+
+	decision, limiter, key := ratelimit.Check(r.Context(), limiters, client)
+	if decision.Allowed {
+		return true
+	}
+
+	metrics.RecordRateLimited("http", path, decision.Reason)
+	log.Printf("%s %s - 429 | Throttled by %s (%s, key %s)", r.Method, r.URL.Path, limiter.Scope(), decision.Reason, key)
+
+	message := "rate limit exceeded"
+	if decision.Reason == ratelimit.ReasonQuota {
+		message = "daily quota exceeded"
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(decision.RetryAfterSeconds()))
+	writeJSON(w, http.StatusTooManyRequests, map[string]string{"error": message})
+	return false
+}
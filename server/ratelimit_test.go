@@ -0,0 +1,157 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"servone/config"
+	"servone/metrics"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	cfg := &config.Config{
+		Rest: config.RestConfig{
+			RateLimit: &config.RateLimitConfig{Rate: 0.01, Burst: 3, Key: "ip"},
+			Endpoints: []config.EndpointConfig{
+				{
+					Path:      "/api/tempo",
+					Method:    "POST",
+					Response:  config.ResponseConfig{Status: 201, Body: `{"ok": true}`},
+					RateLimit: &config.RateLimitConfig{Rate: 0.01, Burst: 1, Key: "body:device_id"},
+				},
+				{
+					Path:      "/api/report",
+					Method:    "GET",
+					Response:  config.ResponseConfig{Status: 200},
+					RateLimit: &config.RateLimitConfig{DailyQuota: 1, Key: "header:X-Device"},
+				},
+			},
+		},
+	}
+	ds := NewDynamicServer(cfg, &MockKafkaPublisher{})
+
+	t.Run("per device bucket", func(t *testing.T) {
+		before := testutil.ToFloat64(metrics.RateLimitedTotal.WithLabelValues("http", "/api/tempo", "rate_limit"))
+
+		rr := serve(t, ds, "POST", "/api/tempo", `{"device_id": "dev-1"}`)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+
+		rr = serve(t, ds, "POST", "/api/tempo", `{"device_id": "dev-1"}`)
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, "100", rr.Header().Get("Retry-After"))
+		assert.JSONEq(t, `{"error": "rate limit exceeded"}`, rr.Body.String())
+
+		after := testutil.ToFloat64(metrics.RateLimitedTotal.WithLabelValues("http", "/api/tempo", "rate_limit"))
+		assert.Equal(t, before+1, after)
+
+		rr = serve(t, ds, "POST", "/api/tempo", `{"device_id": "dev-2"}`)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+	})
+
+	t.Run("server-wide limit per client IP", func(t *testing.T) {
+		// the global bucket (burst 3) holds one more token: the throttled request above was refunded
+		rr := serve(t, ds, "POST", "/api/tempo", `{"device_id": "dev-3"}`)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+
+		rr = serve(t, ds, "POST", "/api/tempo", `{"device_id": "dev-4"}`)
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	})
+
+	t.Run("daily quota", func(t *testing.T) {
+		ds := NewDynamicServer(&config.Config{Rest: config.RestConfig{Endpoints: cfg.Rest.Endpoints}}, &MockKafkaPublisher{})
+
+		rr := serveWithHeaders(t, ds, "GET", "/api/report", "", map[string]string{"X-Device": "gw-1"})
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		rr = serveWithHeaders(t, ds, "GET", "/api/report", "", map[string]string{"X-Device": "gw-1"})
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.JSONEq(t, `{"error": "daily quota exceeded"}`, rr.Body.String())
+		assert.NotEmpty(t, rr.Header().Get("Retry-After"))
+	})
+
+	t.Run("buckets survive reload", func(t *testing.T) {
+		ds.Reload(cfg)
+		rr := serve(t, ds, "POST", "/api/tempo", `{"device_id": "dev-1"}`)
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	})
+}
+
+func TestRateLimitClientIP(t *testing.T) {
+	proxies, errs := parseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.5", "not-an-ip"})
+	assert.Len(t, errs, 1)
+
+	request := func(remote string, xff ...string) *http.Request {
+		r := httptest.NewRequest("GET", "/api/ping", nil)
+		r.RemoteAddr = remote
+		for _, v := range xff {
+			r.Header.Add("X-Forwarded-For", v)
+		}
+		return r
+	}
+
+	tests := []struct {
+		name string
+		req  *http.Request
+		want string
+	}{
+		{"direct client", request("203.0.113.7:5000"), "203.0.113.7"},
+		{"untrusted peer cannot spoof", request("203.0.113.7:5000", "198.51.100.1"), "203.0.113.7"},
+		{"trusted proxy", request("10.1.2.3:443", "198.51.100.1"), "198.51.100.1"},
+		{"spoofed left entries ignored", request("10.1.2.3:443", "1.1.1.1, 198.51.100.1"), "198.51.100.1"},
+		{"proxy chain", request("192.168.1.5:443", "198.51.100.1, 10.9.9.9"), "198.51.100.1"},
+		{"multiple headers", request("10.1.2.3:443", "1.1.1.1", "198.51.100.1"), "198.51.100.1"},
+		{"only proxies", request("10.1.2.3:443", "10.2.2.2"), "10.2.2.2"},
+		{"trusted proxy without header", request("10.1.2.3:443"), "10.1.2.3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, proxies.clientIP(tt.req))
+		})
+	}
+
+	t.Run("forged X-Forwarded-For does not get a fresh bucket", func(t *testing.T) {
+		ds := NewDynamicServer(&config.Config{Rest: config.RestConfig{
+			RateLimit: &config.RateLimitConfig{Rate: 0.01, Burst: 1, Key: "ip"},
+			Endpoints: []config.EndpointConfig{{Path: "/api/ping", Method: "GET", Response: config.ResponseConfig{Status: 200}}},
+		}}, &MockKafkaPublisher{})
+
+		for i, want := range []int{http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests} {
+			rr := httptest.NewRecorder()
+			ds.router.ServeHTTP(rr, request("203.0.113.7:5000", fmt.Sprintf("198.51.100.%d", i)))
+			assert.Equal(t, want, rr.Code, i)
+		}
+	})
+}
+
+func TestRateLimitAPIKey(t *testing.T) {
+	limit := &config.RateLimitConfig{Rate: 0.01, Burst: 1, Key: "api_key"}
+	ds := NewDynamicServer(&config.Config{Rest: config.RestConfig{
+		Auth: config.AuthConfig{Providers: map[string]config.AuthProviderConfig{
+			"devices": {Type: "api_key", Header: "X-Device-Key", Keys: map[string]string{"gw-1": "k1", "gw-2": "k2"}},
+		}},
+		Endpoints: []config.EndpointConfig{
+			{Path: "/api/keyed", Method: "GET", Response: config.ResponseConfig{Status: 200}, Auth: []string{"devices"}, RateLimit: limit},
+			{Path: "/api/open", Method: "GET", Response: config.ResponseConfig{Status: 200}, RateLimit: limit},
+		},
+	}}, &MockKafkaPublisher{})
+
+	t.Run("verified keys get their own bucket", func(t *testing.T) {
+		for _, key := range []string{"k1", "k2"} {
+			rr := serveWithHeaders(t, ds, "GET", "/api/keyed", "", map[string]string{"X-Device-Key": key})
+			assert.Equal(t, http.StatusOK, rr.Code, key)
+		}
+		rr := serveWithHeaders(t, ds, "GET", "/api/keyed", "", map[string]string{"X-Device-Key": "k1"})
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	})
+
+	t.Run("unverified keys share the client IP bucket", func(t *testing.T) {
+		for i, want := range []int{http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests} {
+			rr := serveWithHeaders(t, ds, "GET", "/api/open", "", map[string]string{"X-API-Key": fmt.Sprintf("fake-%d", i)})
+			assert.Equal(t, want, rr.Code, i)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"servone/auth"
+	"servone/config"
+	"servone/recording"
+	"time"
+)
+
+// 녹화 파일 기본 경로
+const defaultRecordingFile = "recordings/servone.ndjson"
+
+// 응답 상태/헤더/본문을 가로채 기록하는 ResponseWriter 래퍼
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
+
+// http.ResponseController 가 Hijack/Flush 를 위해 원본 writer 에 접근할 수 있도록 한다
+func (rw *recordingWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// 설정에 맞게 녹화기를 열거나 닫는 함수 (경로가 같으면 기존 파일 유지)
+func (ds *DynamicServer) syncRecorder(cfg *config.Config) {
+	enabled := false
+	for _, endpoint := range cfg.Rest.Endpoints {
+		if endpoint.Record {
+			enabled = true
+			break
+		}
+	}
+
+	path := cfg.Rest.Recording.File
+	if path == "" {
+		path = defaultRecordingFile
+	}
+
+	// 인증 헤더/쿠키와 인증 수단이 읽는 헤더/쿼리는 명시적으로 허용하지 않는 한 가림
+	ds.redaction = nil
+	if !cfg.Rest.Recording.KeepCredentials {
+		ds.redaction = recording.NewRedaction(auth.CredentialHeaders(cfg.Rest.Auth), auth.CredentialQueryParams(cfg.Rest.Auth))
+	}
+
+	if ds.recorder != nil && (!enabled || ds.recorder.Path() != path) {
+		if err := ds.recorder.Close(); err != nil {
+			log.Printf("Failed to close recording file: %v", err)
+		}
+		log.Printf("Stopped recording to %s", ds.recorder.Path())
+		ds.recorder = nil
+	}
+
+	if enabled && ds.recorder == nil {
+		recorder, err := recording.NewRecorder(path)
+		if err != nil {
+			log.Printf("Recording disabled: %v", err)
+			return
+		}
+		ds.recorder = recorder
+		log.Printf("Recording traffic to %s", path)
+	}
+}
+
+// 요청/응답 한 쌍을 녹화 파일에 기록
+func recordExchange(recorder *recording.Recorder, redaction *recording.Redaction, endpoint config.EndpointConfig, r *http.Request, reqBody []byte, rw *recordingWriter, start time.Time) {
+	entry := recording.Entry{
+		Time:       start.UTC(),
+		DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+		Endpoint:   endpoint.Path,
+		Request: recording.Message{
+			Method:  r.Method,
+			URI:     redaction.URI(r.URL.RequestURI()),
+			Headers: redaction.Headers(r.Header),
+		},
+		Response: recording.Message{
+			Status:  rw.status,
+			Headers: redaction.Headers(rw.Header()),
+		},
+	}
+	entry.Request.SetBody(reqBody)
+	entry.Response.SetBody(rw.body.Bytes())
+
+	if err := recorder.Record(entry); err != nil {
+		log.Printf("Failed to record %s %s: %v", r.Method, r.URL.Path, err)
+	}
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"servone/config"
+	"servone/recording"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrafficRecording(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "traffic.ndjson")
+	recordConfig := func(record bool) *config.Config {
+		return &config.Config{
+			Rest: config.RestConfig{
+				Recording: config.RecordingConfig{File: file},
+				Endpoints: []config.EndpointConfig{
+					{
+						Path:     "/api/devices/{id}",
+						Method:   "POST",
+						Response: config.ResponseConfig{Status: 201, Body: `{"id": "{{.id}}"}`, Headers: map[string]string{"Content-Type": "application/json"}},
+						Record:   record,
+					},
+					{
+						Path:     "/api/other",
+						Method:   "GET",
+						Response: config.ResponseConfig{Status: 200},
+					},
+				},
+			},
+		}
+	}
+
+	ds := NewDynamicServer(recordConfig(true), &MockKafkaPublisher{})
+	defer ds.recorder.Close()
+
+	serve(t, ds, "POST", "/api/devices/7?mode=fast", `{"temp": 21}`)
+	serve(t, ds, "GET", "/api/other", "") // 녹화 대상 아님
+
+	entries, err := recording.ReadFile(file)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	e := entries[0]
+	assert.Equal(t, "/api/devices/{id}", e.Endpoint)
+	assert.Equal(t, "POST", e.Request.Method)
+	assert.Equal(t, "/api/devices/7?mode=fast", e.Request.URI)
+	assert.Equal(t, `{"temp": 21}`, e.Request.Body)
+	assert.Equal(t, 201, e.Response.Status)
+	assert.JSONEq(t, `{"id": "7"}`, e.Response.Body)
+	assert.Equal(t, "application/json", e.Response.Headers["Content-Type"][0])
+
+	t.Run("recording file is private", func(t *testing.T) {
+		info, err := os.Stat(file)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	})
+
+	t.Run("reload disables recording", func(t *testing.T) {
+		ds.Reload(recordConfig(false))
+		assert.Nil(t, ds.recorder)
+
+		serve(t, ds, "POST", "/api/devices/8", `{}`)
+		entries, err := recording.ReadFile(file)
+		require.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+}
+
+func TestRecordingRedactsCredentials(t *testing.T) {
+	recordConfig := func(file string, keep bool) *config.Config {
+		return &config.Config{
+			Rest: config.RestConfig{
+				Recording: config.RecordingConfig{File: file, KeepCredentials: keep},
+				Auth: config.AuthConfig{Providers: map[string]config.AuthProviderConfig{
+					"device": {Type: "api_key", Header: "X-Device-Token", Query: "token", Keys: map[string]string{"d1": "secret-1"}},
+				}},
+				Endpoints: []config.EndpointConfig{
+					{
+						Path:     "/api/login",
+						Method:   "POST",
+						Response: config.ResponseConfig{Status: 200, Headers: map[string]string{"Set-Cookie": "session=abc"}},
+						Record:   true,
+					},
+				},
+			},
+		}
+	}
+	headers := map[string]string{
+		"Authorization":  "Bearer token-1",
+		"X-API-Key":      "key-1",
+		"Cookie":         "session=xyz",
+		"X-Device-Token": "secret-1",
+		"User-Agent":     "sensor/1.0",
+	}
+
+	t.Run("credentials are redacted by default", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "traffic.ndjson")
+		ds := NewDynamicServer(recordConfig(file, false), &MockKafkaPublisher{})
+		defer ds.recorder.Close()
+
+		serveWithHeaders(t, ds, "POST", "/api/login?token=secret-1&mode=fast", `{}`, headers)
+
+		entries, err := recording.ReadFile(file)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		req := entries[0].Request
+		for _, name := range []string{"Authorization", "X-Api-Key", "Cookie", "X-Device-Token"} {
+			assert.Equal(t, []string{recording.Redacted}, req.Headers[name], name)
+		}
+		assert.Equal(t, []string{"sensor/1.0"}, req.Headers["User-Agent"])
+		assert.NotContains(t, req.URI, "secret-1")
+		assert.Contains(t, req.URI, "mode=fast")
+		assert.Equal(t, []string{recording.Redacted}, entries[0].Response.Headers["Set-Cookie"])
+	})
+
+	t.Run("keep_credentials records them verbatim", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "traffic.ndjson")
+		ds := NewDynamicServer(recordConfig(file, true), &MockKafkaPublisher{})
+		defer ds.recorder.Close()
+
+		serveWithHeaders(t, ds, "POST", "/api/login?token=secret-1", `{}`, headers)
+
+		entries, err := recording.ReadFile(file)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, []string{"Bearer token-1"}, entries[0].Request.Headers["Authorization"])
+		assert.Equal(t, "/api/login?token=secret-1", entries[0].Request.URI)
+	})
+}
@@ -0,0 +1,360 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"reflect"
+	"servone/config"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// 시나리오가 rest.scenarios 에 선언되지 않았을 때 사용하는 초기 상태
+const defaultScenarioState = "started"
+
+// 시나리오의 현재 상태와 정의
+type scenarioState struct {
+	def       config.ScenarioConfig
+	endpoints map[string]config.EndpointScenario // 시나리오를 참조하는 엔드포인트별 상태/전이 정의
+	state     string
+}
+
+// 순차 응답의 진행 상태와 정의
+type sequenceState struct {
+	def  config.SequenceConfig
+	next int
+}
+
+// 시나리오 및 순차 응답 상태 저장소 (Reload 간 유지)
+type stateStore struct {
+	mu        sync.Mutex
+	scenarios map[string]*scenarioState
+	sequences map[string]*sequenceState
+}
+
+func newStateStore() *stateStore {
+	return &stateStore{
+		scenarios: make(map[string]*scenarioState),
+		sequences: make(map[string]*sequenceState),
+	}
+}
+
+// 엔드포인트 키 (메서드 + 경로)
+func endpointKey(endpoint config.EndpointConfig) string {
+	return strings.ToUpper(endpoint.Method) + " " + endpoint.Path
+}
+
+// 새 설정과 상태 저장소를 동기화
+// 정의(시나리오 선언과 이를 참조하는 엔드포인트의 상태/전이)가 바뀌지 않은 시나리오/시퀀스는
+// 현재 상태를 유지하고, 바뀐 것은 초기화한다 (사라진 상태를 가리키지 않도록)
+func (s *stateStore) sync(rest config.RestConfig) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	defs := make(map[string]config.ScenarioConfig)
+	for _, sc := range rest.Scenarios {
+		defs[sc.Name] = sc
+	}
+	uses := make(map[string]map[string]config.EndpointScenario)
+	for _, endpoint := range rest.Endpoints {
+		if endpoint.Scenario == nil || endpoint.Scenario.Name == "" {
+			continue
+		}
+		name := endpoint.Scenario.Name
+		// 선언 없이 엔드포인트에서만 참조된 시나리오는 기본 초기 상태로 등록
+		if _, ok := defs[name]; !ok {
+			defs[name] = config.ScenarioConfig{Name: name, InitialState: defaultScenarioState}
+		}
+		if uses[name] == nil {
+			uses[name] = make(map[string]config.EndpointScenario)
+		}
+		uses[name][endpointKey(endpoint)] = *endpoint.Scenario
+	}
+
+	scenarios := make(map[string]*scenarioState, len(defs))
+	for name, def := range defs {
+		if def.InitialState == "" {
+			def.InitialState = defaultScenarioState
+		}
+		if existing, ok := s.scenarios[name]; ok && reflect.DeepEqual(existing.def, def) && reflect.DeepEqual(existing.endpoints, uses[name]) {
+			scenarios[name] = existing
+			continue
+		}
+		scenarios[name] = &scenarioState{def: def, endpoints: uses[name], state: def.InitialState}
+	}
+	s.scenarios = scenarios
+
+	sequences := make(map[string]*sequenceState)
+	for _, endpoint := range rest.Endpoints {
+		if endpoint.Sequence == nil || len(endpoint.Sequence.Steps) == 0 {
+			continue
+		}
+		key := endpointKey(endpoint)
+		if existing, ok := s.sequences[key]; ok && reflect.DeepEqual(existing.def, *endpoint.Sequence) {
+			sequences[key] = existing
+			continue
+		}
+		sequences[key] = &sequenceState{def: *endpoint.Sequence}
+	}
+	s.sequences = sequences
+}
+
+// 시나리오 상태를 읽고, 전이 함수가 반환한 상태로 원자적으로 갱신
+// 전이 이전 상태를 반환한다
+func (s *stateStore) advanceScenario(name string, next func(current string) string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sc, ok := s.scenarios[name]
+	if !ok {
+		return "", false
+	}
+	before := sc.state
+	sc.state = next(before)
+	return before, true
+}
+
+// 시퀀스의 다음 응답 인덱스를 반환
+func (s *stateStore) nextSequenceIndex(key string) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seq, ok := s.sequences[key]
+	if !ok || len(seq.def.Steps) == 0 {
+		return 0, false
+	}
+
+	steps := seq.def.Steps
+	switch seq.def.Mode {
+	case "random":
+		return weightedIndex(steps), true
+	case "stop":
+		idx := seq.next
+		if seq.next < len(steps)-1 {
+			seq.next++
+		}
+		return idx, true
+	default: // cycle
+		idx := seq.next % len(steps)
+		seq.next = (idx + 1) % len(steps)
+		return idx, true
+	}
+}
+
+// 가중치에 따라 무작위 인덱스를 선택
+func weightedIndex(steps []config.SequenceStep) int {
+	total := 0
+	for _, step := range steps {
+		total += stepWeight(step)
+	}
+	n := rand.IntN(total)
+	for i, step := range steps {
+		n -= stepWeight(step)
+		if n < 0 {
+			return i
+		}
+	}
+	return len(steps) - 1
+}
+
+func stepWeight(step config.SequenceStep) int {
+	if step.Weight <= 0 {
+		return 1
+	}
+	return step.Weight
+}
+
+// 시나리오 상태 조회
+func (s *stateStore) scenarioSnapshot() map[string]map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := make(map[string]map[string]string, len(s.scenarios))
+	for name, sc := range s.scenarios {
+		snapshot[name] = map[string]string{
+			"state":         sc.state,
+			"initial_state": sc.def.InitialState,
+		}
+	}
+	return snapshot
+}
+
+// 시나리오 상태를 지정 값(빈 값이면 초기 상태)으로 설정
+func (s *stateStore) setScenarioState(name, state string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sc, ok := s.scenarios[name]
+	if !ok {
+		return fmt.Errorf("scenario %q not found", name)
+	}
+	if state == "" {
+		state = sc.def.InitialState
+	}
+	sc.state = state
+	return nil
+}
+
+// 모든 시나리오와 시퀀스를 초기 상태로 되돌림
+func (s *stateStore) resetAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sc := range s.scenarios {
+		sc.state = sc.def.InitialState
+	}
+	for _, seq := range s.sequences {
+		seq.next = 0
+	}
+}
+
+// 엔드포인트에 대한 컴파일된 시나리오 전이
+type compiledTransition struct {
+	from    string
+	to      string
+	matcher *requestMatcher
+}
+
+// 엔드포인트의 시나리오/시퀀스 기반 응답 결정기
+type statefulResponder struct {
+	key         string
+	scenario    *config.EndpointScenario
+	transitions []compiledTransition
+	sequence    *config.SequenceConfig
+}
+
+// 엔드포인트 설정으로부터 상태 기반 응답 결정기를 생성 (해당 설정이 없으면 nil)
+func newStatefulResponder(endpoint config.EndpointConfig) (*statefulResponder, []error) {
+	if endpoint.Scenario == nil && endpoint.Sequence == nil {
+		return nil, nil
+	}
+
+	sr := &statefulResponder{
+		key:      endpointKey(endpoint),
+		scenario: endpoint.Scenario,
+		sequence: endpoint.Sequence,
+	}
+
+	var errs []error
+	if sr.scenario != nil {
+		for i, tr := range sr.scenario.Transitions {
+			rm, err := compileMatch(fmt.Sprintf("transition %d", i), tr.Match)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("scenario %s transition %d: %w", sr.scenario.Name, i, err))
+				continue
+			}
+			sr.transitions = append(sr.transitions, compiledTransition{from: tr.From, to: tr.To, matcher: rm})
+		}
+	}
+
+	return sr, errs
+}
+
+// 시나리오 전이를 적용하고, 상태별 응답 또는 시퀀스 응답을 반환
+// 해당하는 응답이 없으면 false 를 반환한다
+func (sr *statefulResponder) respond(store *stateStore, r *http.Request, vars map[string]string, body interface{}) (config.ResponseConfig, bool) {
+	if sr.scenario != nil {
+		state, ok := store.advanceScenario(sr.scenario.Name, func(current string) string {
+			for _, tr := range sr.transitions {
+				if (tr.from == "" || tr.from == current) && tr.matcher.matches(r, vars, body) {
+					if tr.to != current {
+						log.Printf("Scenario %s: %s -> %s", sr.scenario.Name, current, tr.to)
+					}
+					return tr.to
+				}
+			}
+			return current
+		})
+		if ok {
+			if response, exists := sr.scenario.States[state]; exists {
+				return withDefaultStatus(response), true
+			}
+		}
+	}
+
+	if sr.sequence != nil {
+		if idx, ok := store.nextSequenceIndex(sr.key); ok {
+			return withDefaultStatus(sr.sequence.Steps[idx].Response), true
+		}
+	}
+
+	return config.ResponseConfig{}, false
+}
+
+// 시나리오 관리용 admin 라우트 등록
+func (ds *DynamicServer) setupScenarioRoutes() {
+	ds.handleBuiltin("/_admin/scenarios", http.HandlerFunc(ds.handleListScenarios)).Methods("GET")
+	ds.handleBuiltin("/_admin/scenarios/reset", http.HandlerFunc(ds.handleResetScenarios)).Methods("POST")
+	ds.handleBuiltin("/_admin/scenarios/{name}", http.HandlerFunc(ds.handleSetScenario)).Methods("PUT")
+	ds.handleBuiltin("/_admin/scenarios/{name}/reset", http.HandlerFunc(ds.handleResetScenario)).Methods("POST")
+	log.Printf("Added route: GET /_admin/scenarios")
+}
+
+// 전체 시나리오 상태 조회
+func (ds *DynamicServer) handleListScenarios(w http.ResponseWriter, r *http.Request) {
+	snapshot := ds.states.scenarioSnapshot()
+	names := make([]string, 0, len(snapshot))
+	for name := range snapshot {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]map[string]string, 0, len(names))
+	for _, name := range names {
+		entry := snapshot[name]
+		entry["name"] = name
+		list = append(list, entry)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"scenarios": list})
+}
+
+// 모든 시나리오와 시퀀스 초기화
+func (ds *DynamicServer) handleResetScenarios(w http.ResponseWriter, r *http.Request) {
+	ds.states.resetAll()
+	log.Println("All scenarios and sequences reset")
+	writeJSON(w, http.StatusOK, map[string]string{"result": "reset"})
+}
+
+// 단일 시나리오 초기화
+func (ds *DynamicServer) handleResetScenario(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if err := ds.states.setScenarioState(name, ""); err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	log.Printf("Scenario %s reset", name)
+	writeJSON(w, http.StatusOK, ds.states.scenarioSnapshot()[name])
+}
+
+// 시나리오 상태 지정 (요청 본문: {"state": "..."})
+func (ds *DynamicServer) handleSetScenario(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	var req struct {
+		State string `json:"state"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.State == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": `request body must be {"state": "<state>"}`})
+		return
+	}
+
+	if err := ds.states.setScenarioState(name, req.State); err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		return
+	}
+	log.Printf("Scenario %s set to %s", name, req.State)
+	writeJSON(w, http.StatusOK, ds.states.scenarioSnapshot()[name])
+}
+
+// JSON 응답 작성 헬퍼
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"servone/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sequenceEndpoint(mode string) config.EndpointConfig {
+	return config.EndpointConfig{
+		Path:     "/api/seq",
+		Method:   "GET",
+		Response: config.ResponseConfig{Status: 200},
+		Sequence: &config.SequenceConfig{
+			Mode: mode,
+			Steps: []config.SequenceStep{
+				{Response: config.ResponseConfig{Status: 202}},
+				{Response: config.ResponseConfig{Status: 503}},
+				{Response: config.ResponseConfig{Status: 200}},
+			},
+		},
+	}
+}
+
+func serve(t *testing.T, ds *DynamicServer, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	return serveWithHeaders(t, ds, method, path, body, nil)
+}
+
+func serveWithHeaders(t *testing.T, ds *DynamicServer, method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	req, err := http.NewRequest(method, path, bytes.NewBufferString(body))
+	require.NoError(t, err)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	rr := httptest.NewRecorder()
+	ds.router.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestSequenceResponses(t *testing.T) {
+	t.Run("cycle", func(t *testing.T) {
+		ds := NewDynamicServer(&config.Config{Rest: config.RestConfig{Endpoints: []config.EndpointConfig{sequenceEndpoint("cycle")}}}, &MockKafkaPublisher{})
+		var got []int
+		for i := 0; i < 5; i++ {
+			got = append(got, serve(t, ds, "GET", "/api/seq", "").Code)
+		}
+		assert.Equal(t, []int{202, 503, 200, 202, 503}, got)
+	})
+
+	t.Run("stop at last", func(t *testing.T) {
+		ds := NewDynamicServer(&config.Config{Rest: config.RestConfig{Endpoints: []config.EndpointConfig{sequenceEndpoint("stop")}}}, &MockKafkaPublisher{})
+		var got []int
+		for i := 0; i < 5; i++ {
+			got = append(got, serve(t, ds, "GET", "/api/seq", "").Code)
+		}
+		assert.Equal(t, []int{202, 503, 200, 200, 200}, got)
+	})
+
+	t.Run("random weighted", func(t *testing.T) {
+		endpoint := sequenceEndpoint("random")
+		endpoint.Sequence.Steps[0].Weight = 1
+		endpoint.Sequence.Steps[1].Weight = -1 // 0 이하는 1로 취급
+		endpoint.Sequence.Steps = endpoint.Sequence.Steps[:2]
+		ds := NewDynamicServer(&config.Config{Rest: config.RestConfig{Endpoints: []config.EndpointConfig{endpoint}}}, &MockKafkaPublisher{})
+
+		seen := make(map[int]bool)
+		for i := 0; i < 100; i++ {
+			seen[serve(t, ds, "GET", "/api/seq", "").Code] = true
+		}
+		assert.True(t, seen[202])
+		assert.True(t, seen[503])
+		assert.False(t, seen[200])
+	})
+
+	t.Run("state survives reload unless sequence changed", func(t *testing.T) {
+		cfg := &config.Config{Rest: config.RestConfig{Endpoints: []config.EndpointConfig{sequenceEndpoint("cycle")}}}
+		ds := NewDynamicServer(cfg, &MockKafkaPublisher{})
+		assert.Equal(t, 202, serve(t, ds, "GET", "/api/seq", "").Code)
+
+		ds.Reload(&config.Config{Rest: config.RestConfig{Endpoints: []config.EndpointConfig{sequenceEndpoint("cycle")}}})
+		assert.Equal(t, 503, serve(t, ds, "GET", "/api/seq", "").Code)
+
+		ds.Reload(&config.Config{Rest: config.RestConfig{Endpoints: []config.EndpointConfig{sequenceEndpoint("stop")}}})
+		assert.Equal(t, 202, serve(t, ds, "GET", "/api/seq", "").Code)
+	})
+
+	t.Run("step without status answers 200", func(t *testing.T) {
+		endpoint := sequenceEndpoint("cycle")
+		endpoint.Sequence.Steps[0].Response = config.ResponseConfig{Body: "first"}
+		ds := NewDynamicServer(&config.Config{Rest: config.RestConfig{Endpoints: []config.EndpointConfig{endpoint}}}, &MockKafkaPublisher{})
+
+		rr := serve(t, ds, "GET", "/api/seq", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "first", rr.Body.String())
+	})
+}
+
+func scenarioConfig(initial string) *config.Config {
+	return &config.Config{
+		Rest: config.RestConfig{
+			Scenarios: []config.ScenarioConfig{{Name: "firmware", InitialState: initial}},
+			Endpoints: []config.EndpointConfig{
+				{
+					Path:     "/api/fw/update",
+					Method:   "POST",
+					Response: config.ResponseConfig{Status: 202},
+					Scenario: &config.EndpointScenario{
+						Name: "firmware",
+						Transitions: []config.ScenarioTransition{
+							{From: "idle", To: "downloading", Match: config.MatchConfig{Body: map[string]string{"$.version": "*"}}},
+						},
+					},
+				},
+				{
+					Path:     "/api/fw/status",
+					Method:   "GET",
+					Response: config.ResponseConfig{Status: 500},
+					Scenario: &config.EndpointScenario{
+						Name: "firmware",
+						States: map[string]config.ResponseConfig{
+							"idle":        {Status: 200, Body: `{"state": "idle"}`},
+							"downloading": {Status: 200, Body: `{"state": "downloading"}`},
+							"done":        {Status: 200, Body: `{"state": "done"}`},
+						},
+						Transitions: []config.ScenarioTransition{
+							{From: "downloading", To: "done"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestScenarioStateMachine(t *testing.T) {
+	ds := NewDynamicServer(scenarioConfig("idle"), &MockKafkaPublisher{})
+
+	status := func() string {
+		var body map[string]string
+		rr := serve(t, ds, "GET", "/api/fw/status", "")
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+		return body["state"]
+	}
+
+	assert.Equal(t, "idle", status())
+
+	// 전이 조건에 맞지 않는 요청은 상태를 바꾸지 않음
+	serve(t, ds, "POST", "/api/fw/update", `{"other": 1}`)
+	assert.Equal(t, "idle", status())
+
+	serve(t, ds, "POST", "/api/fw/update", `{"version": "1.2.0"}`)
+	assert.Equal(t, "downloading", status()) // 이 요청으로 done 으로 전이
+	assert.Equal(t, "done", status())
+
+	t.Run("state survives reload", func(t *testing.T) {
+		ds.Reload(scenarioConfig("idle"))
+		assert.Equal(t, "done", status())
+	})
+
+	t.Run("changed definition resets state", func(t *testing.T) {
+		ds.Reload(scenarioConfig("downloading"))
+		assert.Equal(t, "downloading", status())
+	})
+
+	t.Run("changed endpoint states reset state", func(t *testing.T) {
+		serve(t, ds, "PUT", "/_admin/scenarios/firmware", `{"state": "done"}`)
+		assert.Equal(t, "done", status())
+
+		// done 상태를 없애면 사라진 상태에 머물지 않고 초기 상태로 돌아감
+		cfg := scenarioConfig("downloading")
+		delete(cfg.Rest.Endpoints[1].Scenario.States, "done")
+		ds.Reload(cfg)
+		assert.Equal(t, "downloading", status())
+
+		// 전이만 바뀌어도 초기화
+		serve(t, ds, "PUT", "/_admin/scenarios/firmware", `{"state": "idle"}`)
+		cfg = scenarioConfig("downloading")
+		cfg.Rest.Endpoints[0].Scenario.Transitions[0].To = "done"
+		ds.Reload(cfg)
+		assert.Equal(t, "downloading", status())
+
+		ds.Reload(scenarioConfig("downloading"))
+	})
+
+	t.Run("admin set and reset", func(t *testing.T) {
+		rr := serve(t, ds, "PUT", "/_admin/scenarios/firmware", `{"state": "idle"}`)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "idle", status())
+
+		serve(t, ds, "PUT", "/_admin/scenarios/firmware", `{"state": "done"}`)
+		rr = serve(t, ds, "POST", "/_admin/scenarios/firmware/reset", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "downloading", status())
+
+		rr = serve(t, ds, "PUT", "/_admin/scenarios/unknown", `{"state": "x"}`)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+
+		rr = serve(t, ds, "PUT", "/_admin/scenarios/firmware", `{}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("admin list", func(t *testing.T) {
+		rr := serve(t, ds, "GET", "/_admin/scenarios", "")
+		assert.Equal(t, http.StatusOK, rr.Code)
+
+		var body struct {
+			Scenarios []map[string]string `json:"scenarios"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+		require.Len(t, body.Scenarios, 1)
+		assert.Equal(t, "firmware", body.Scenarios[0]["name"])
+	})
+}
@@ -8,18 +8,27 @@ import (
 	"encoding/json" // JSON 파싱 및 인코딩
 	"io"            // 요청 바디 읽기
 	"log"           // 로그 출력
+	"net"           // 클라이언트 주소 파싱
 	"net/http"      // HTTP 서버
 	"regexp"        // 정규표현식
 	"servone/config"
 	"servone/db"
 	"servone/kafka" // Kafka 퍼블리셔 인터페이스
-	"strconv"       // 문자열-숫자 변환
 	"strings"       // 문자열 처리
 	"sync"          // 동기화
-	"text/template" // 템플릿 처리
 	"time"          // 시간 관련
 
+	"servone/auth"
+	"servone/certs"
+	"servone/fault"
+	"servone/ingest"
+	"servone/metrics"
+	"servone/ratelimit"
+	"servone/recording"
+	"servone/schema"
 	"servone/snmpclient"
+	"servone/spool"
+	"servone/tmpl" // 응답 템플릿 엔진
 
 	"github.com/gorilla/mux" // 라우터
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -32,23 +41,56 @@ type DynamicServer struct {
 	server      *http.Server              // HTTP 서버 인스턴스
 	pathVars    map[string]*regexp.Regexp // 경로 변수에 대한 정규표현식 매핑
 	publisher   kafka.KafkaPublisherInterface
-	templates   map[string]*template.Template // 템플릿 캐시
-	templateMux sync.RWMutex                  // 템플릿 캐시 동기화
-	configMux   sync.RWMutex                  // 설정 변경 동기화
+	renderer    *tmpl.Renderer       // 응답 템플릿 렌더러 (캐시 포함)
+	states      *stateStore          // 시나리오/순차 응답 상태 (Reload 간 유지)
+	proxyClient *http.Client         // 프록시 응답 유형에서 사용하는 업스트림 HTTP 클라이언트
+	recorder    *recording.Recorder  // 트래픽 녹화기 (record: true 엔드포인트가 있을 때만)
+	redaction   *recording.Redaction // 녹화 시 가릴 인증 정보 (keep_credentials 이면 nil)
+	certs       *certs.Store         // TLS 인증서 저장소 (rest.tls 설정 시, 파일 변경 시 자동 재로드)
+	limits      *ratelimit.Registry  // 요청 제한 버킷 (설정이 같으면 Reload 간 유지)
+	ingest      *ingest.Pipeline     // DB 저장/Kafka 발행 작업자 풀
+	ownsIngest  bool                 // 자체 생성한 파이프라인이면 Shutdown 시 비우고 종료
+	spool       *spool.Spool         // 싱크 장애 시 메시지를 보관하는 로컬 스풀 (선택)
+	data        DataReader           // 저장된 메시지 조회 (/api/_data, 선택)
+	auth        *auth.Authorizer     // 인증 수단 및 경로 규칙 (라우트 설정 시 컴파일)
+	credentials credentialFields     // 인증 수단이 읽는 헤더/쿼리 (프록시 시 제거)
+	proxies     trustedProxies       // X-Forwarded-For 를 신뢰하는 프록시 (요청 제한 ip 키)
+	configMux   sync.RWMutex         // 설정 변경 동기화
 }
 
 // DynamicServer 생성자 함수
 // config: 서버 설정 구조체
-func NewDynamicServer(cfg *config.Config, publisher kafka.KafkaPublisherInterface) *DynamicServer {
+// opts: 선택 옵션 (예: WithIngest 로 CoAP 서버와 수집 파이프라인 공유)
+func NewDynamicServer(cfg *config.Config, publisher kafka.KafkaPublisherInterface, opts ...Option) *DynamicServer {
+	cfg = withImportedEndpoints(cfg) // rest.openapi 명세의 엔드포인트 병합
+
 	ds := &DynamicServer{
 		config:    cfg,
 		router:    mux.NewRouter(), // 새로운 라우터 생성
 		pathVars:  make(map[string]*regexp.Regexp),
 		publisher: publisher,
-		templates: make(map[string]*template.Template),
+		renderer:  tmpl.NewRenderer(),
+		states:    newStateStore(),
+		limits:    ratelimit.NewRegistry(ratelimit.NewMemoryCounter()),
+		proxyClient: &http.Client{
+			// 업스트림 리다이렉트는 따라가지 않고 그대로 클라이언트에 전달
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
 	}
 
-	ds.setupRoutes() // 라우트 설정
+	for _, opt := range opts {
+		opt(ds)
+	}
+	if ds.ingest == nil {
+		ds.ingest = newIngestPipeline(cfg.Ingest) // 공유 파이프라인이 없으면 자체 생성
+		ds.ownsIngest = true
+	}
+
+	ds.states.sync(cfg.Rest) // 시나리오/시퀀스 상태 초기화
+	ds.syncRecorder(cfg)     // 트래픽 녹화 설정
+	ds.setupRoutes()         // 라우트 설정
 
 	ds.server = &http.Server{
 		Addr:         ds.config.Rest.Host + ":" + ds.config.Rest.Port, // 서버 주소 및 포트 지정
@@ -63,11 +105,18 @@ func NewDynamicServer(cfg *config.Config, publisher kafka.KafkaPublisherInterfac
 
 // 설정에 정의된 모든 엔드포인트를 라우터에 등록
 func (ds *DynamicServer) setupRoutes() {
+	ds.compileAuth()           // 인증 설정 컴파일 (엔드포인트 핸들러보다 먼저)
+	ds.compileTrustedProxies() // 요청 제한 ip 키에 사용할 신뢰 프록시
+	ds.setupDataRoutes()       // /api/_data 가 /api/{name} 같은 엔드포인트에 가려지지 않도록 먼저 등록
 	for _, endpoint := range ds.config.Rest.Endpoints {
 		ds.addRoute(endpoint)
 	}
-	ds.router.Handle("/metrics", promhttp.Handler()).Methods("GET")
+	ds.handleBuiltin("/metrics", promhttp.Handler()).Methods("GET")
 	log.Printf("Added route: GET /metrics")
+	ds.setupScenarioRoutes()
+	ds.setupOpenAPIRoutes()
+	ds.setupSpoolRoutes()
+	ds.limits.Prune() // 삭제된 엔드포인트의 제한 버킷 정리
 }
 
 // 단일 엔드포인트를 라우터에 등록하는 함수
@@ -75,6 +124,12 @@ func (ds *DynamicServer) addRoute(endpoint config.EndpointConfig) {
 	path := endpoint.Path                      // 엔드포인트 경로
 	method := strings.ToUpper(endpoint.Method) // HTTP 메서드 대문자화
 
+	// 템플릿 예약 키(.path, .body 등)와 겹치는 경로 변수는 등록하지 않음
+	if err := tmpl.CheckPathVars(path); err != nil {
+		log.Printf("Invalid route %s %s: %v", method, path, err)
+		return
+	}
+
 	handler := ds.createHandler(endpoint) // 핸들러 함수 생성
 
 	// 라우트 등록
@@ -85,6 +140,39 @@ func (ds *DynamicServer) addRoute(endpoint config.EndpointConfig) {
 
 // 엔드포인트별 요청을 처리하는 핸들러 함수 생성
 func (ds *DynamicServer) createHandler(endpoint config.EndpointConfig) http.HandlerFunc {
+	// 조건부 응답 변형 컴파일 (라우트 등록 및 Reload 시점에 한 번 수행)
+	selector, errs := newResponseSelector(endpoint)
+	for _, err := range errs {
+		log.Printf("Skipping invalid response variant on %s %s: %v", endpoint.Method, endpoint.Path, err)
+	}
+
+	// 시나리오/순차 응답 설정 컴파일
+	stateful, errs := newStatefulResponder(endpoint)
+	for _, err := range errs {
+		log.Printf("Skipping invalid scenario transition on %s %s: %v", endpoint.Method, endpoint.Path, err)
+	}
+
+	// 요청 페이로드 스키마 컴파일 (실패 시 검증 없이 처리하지 않고 500 응답)
+	validator, schemaErr := schema.Compile(endpoint.Schema)
+	if schemaErr != nil {
+		log.Printf("Invalid schema for %s %s, rejecting requests: %v", endpoint.Method, endpoint.Path, schemaErr)
+	}
+
+	// 인증 정책 (엔드포인트 auth 설정 또는 경로 접두사 규칙)
+	authPolicy := ds.authPolicy(endpoint.Auth, endpoint.Path)
+	credentials := ds.credentials
+
+	// 요청 제한 (서버 전체 rest.rate_limit 및 엔드포인트 rate_limit)
+	limiters := ds.endpointLimiters(endpoint)
+	proxies := ds.proxies
+
+	// 녹화 대상 엔드포인트면 현재 녹화기를 사용 (Reload 시 라우트와 함께 갱신)
+	var recorder *recording.Recorder
+	redaction := ds.redaction
+	if endpoint.Record {
+		recorder = ds.recorder
+	}
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		vars := mux.Vars(r) // URL 경로 변수 추출
 
@@ -92,8 +180,8 @@ func (ds *DynamicServer) createHandler(endpoint config.EndpointConfig) http.Hand
 		var err error
 		if r.Body != nil {
 			// 요청 크기 제한 (10MB)
-			r.Body = http.MaxBytesReader(w, r.Body, 10*1024*1024)
-			
+			r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+
 			bodyBytes, err = io.ReadAll(r.Body)
 			if err != nil {
 				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
@@ -102,6 +190,61 @@ func (ds *DynamicServer) createHandler(endpoint config.EndpointConfig) http.Hand
 			r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes)) // 바디 복원(다중 사용 가능하게)
 		}
 
+		// 녹화: 응답을 가로채 핸들러 종료 시 요청/응답을 함께 기록
+		if recorder != nil {
+			rw := &recordingWriter{ResponseWriter: w}
+			w = rw
+			start := time.Now()
+			defer recordExchange(recorder, redaction, endpoint, r, bodyBytes, rw, start)
+		}
+
+		// 인증 (실패 시 401, 이후 단계(DB/Kafka 포함)로 진행하지 않음)
+		identity, ok := authenticate(w, r, authPolicy, endpoint.Path)
+		if !ok {
+			return
+		}
+		var claims map[string]interface{}
+		var authInfo interface{}
+		if identity != nil {
+			claims = identity.Claims
+			authInfo = identity
+		}
+
+		// 요청 제한 (초과 시 429, 이후 단계(DB/Kafka 포함)로 진행하지 않음)
+		if !allowRequest(w, r, limiters, proxies, endpoint.Path, identity, bodyBytes) {
+			return
+		}
+
+		// mTLS 클라이언트 인증서 정보 (장치 식별용, DB 저장 파라미터 및 템플릿에 노출)
+		clientCert := clientCertInfo(r)
+
+		// 장애/지연 주입 (설정된 경우에만, 주입 시 저장/발행하지 않음)
+		decision := fault.Decide(endpoint.Fault, fault.HTTP)
+		decision.Apply(fault.HTTP, r.Method, endpoint.Path)
+		if decision.Kind != fault.None {
+			injectHTTPFault(w, endpoint.Fault, decision.Kind)
+			return
+		}
+
+		// 스키마를 컴파일하지 못한 엔드포인트는 검증 없이 받아들이지 않음
+		if schemaErr != nil {
+			log.Printf("%s %s - 500 | Schema unavailable: %v", r.Method, r.URL.Path, schemaErr)
+			http.Error(w, "schema unavailable", http.StatusInternalServerError)
+			return
+		}
+
+		// 스키마가 설정된 경우 페이로드 검증 (실패 시 저장/발행하지 않고 400 반환)
+		if validator != nil && (len(bodyBytes) > 0 || hasPayloadMethod(r.Method)) {
+			if violations := validator.Validate(bodyBytes); len(violations) > 0 {
+				metrics.RecordSchemaValidationFailure("http", endpoint.Path)
+				log.Printf("%s %s - 400 | Schema validation failed: %d violation(s)", r.Method, r.URL.Path, len(violations))
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write(schema.ErrorBody(violations))
+				return
+			}
+		}
+
 		// Check if this is an SNMP endpoint
 		if strings.HasPrefix(endpoint.Path, "/api/snmp/") && r.Method == "POST" {
 			// Handle SNMP operations
@@ -114,6 +257,25 @@ func (ds *DynamicServer) createHandler(endpoint config.EndpointConfig) http.Hand
 			}
 		}
 
+		// 매칭 조건 평가를 위해 바디를 JSON으로 파싱 (실패 시 nil)
+		var parsedBody interface{}
+		if len(bodyBytes) > 0 {
+			if err := json.Unmarshal(bodyBytes, &parsedBody); err != nil {
+				parsedBody = nil
+			}
+		}
+
+		// 조건에 맞는 응답 선택 (일치하는 변형이 없으면 기본 응답)
+		// 변형이 일치하면 그 응답이 우선하며, 그렇지 않을 때만 시나리오/시퀀스가 진행된다
+		response, variant := selector.selectResponse(r, vars, parsedBody)
+		if variant != "" {
+			log.Printf("%s %s matched response variant %q", r.Method, r.URL.Path, variant)
+		} else if stateful != nil {
+			if resp, ok := stateful.respond(ds.states, r, vars, parsedBody); ok {
+				response = resp
+			}
+		}
+
 		// POST 요청이면서 JSON 바디가 있을 때 구조화된 로그 출력
 		if r.Method == "POST" && len(bodyBytes) > 0 {
 			var jsonData map[string]interface{}
@@ -128,91 +290,161 @@ func (ds *DynamicServer) createHandler(endpoint config.EndpointConfig) http.Hand
 					log.Printf("Request Log: %s", string(logBytes)) // JSON 형태로 로그 출력
 				}
 
-				// 데이터베이스에 저장 (에러 로깅 추가)
-				go func() {
-					// Only save to database if DbPool is initialized
-					if db.DbPool != nil {
-						if err := db.SaveToDBWithError(endpoint.Path, jsonData, vars, ds.publisher); err != nil {
-							log.Printf("Failed to save to database: %v", err)
-						}
+				// 데이터베이스 저장을 수집 파이프라인에 등록 (거절 시 503)
+				// Kafka 이벤트는 같은 트랜잭션으로 outbox에 기록되고 outbox 릴레이가 발행
+				params := withClientCert(vars, clientCert)
+				job := ingest.Job{Source: "http", Run: func() error {
+					// Only save to database if a store is configured
+					if db.DefaultStore() == nil {
+						return nil
+					}
+					err := db.SaveToDBWithAuth(endpoint.Path, jsonData, params, authInfo)
+					if err != nil {
+						log.Printf("Failed to save to database: %v", err)
 					}
-				}()
+					return err
+				}}
+				if !ds.submitIngest(w, r, job) {
+					return
+				}
 
 			} else {
 				// JSON 파싱 실패 시 일반 텍스트로 로그
-				log.Printf("%s %s - %d | Request body: %s", r.Method, r.URL.Path, endpoint.Response.Status, string(bodyBytes))
+				log.Printf("%s %s - %d | Request body: %s", r.Method, r.URL.Path, response.Status, string(bodyBytes))
 			}
 		} else if len(bodyBytes) > 0 {
 			// POST 외의 요청에서 바디가 있을 때 로그
-			log.Printf("%s %s - %d | Request body: %s", r.Method, r.URL.Path, endpoint.Response.Status, string(bodyBytes))
+			log.Printf("%s %s - %d | Request body: %s", r.Method, r.URL.Path, response.Status, string(bodyBytes))
 		} else {
 			// 바디가 없는 요청 로그
-			log.Printf("%s %s - %d", r.Method, r.URL.Path, endpoint.Response.Status)
+			log.Printf("%s %s - %d", r.Method, r.URL.Path, response.Status)
+		}
+
+		// 프록시 응답 유형이면 업스트림 응답을 그대로 반환 (실패 + fallback 시 정적 응답 사용)
+		if response.Proxy != nil && ds.proxyRequest(w, r, endpoint, response.Proxy, credentials, vars, bodyBytes) {
+			return
 		}
 
 		// 설정에 정의된 헤더를 응답에 추가
-		for key, value := range endpoint.Response.Headers {
+		for key, value := range response.Headers {
 			w.Header().Set(key, value)
 		}
 
-		w.WriteHeader(endpoint.Response.Status) // 응답 상태 코드 설정
+		// 요청 ID 헤더 설정 (설정된 헤더가 우선)
+		requestID := requestIDFor(r)
+		if w.Header().Get("X-Request-ID") == "" {
+			w.Header().Set("X-Request-ID", requestID)
+		}
+
+		w.WriteHeader(response.Status) // 응답 상태 코드 설정
 
 		// 템플릿 처리 후 응답 본문 작성
-		body := ds.processTemplate(endpoint.Response.Body, vars)
+		reqCtx := &tmpl.RequestContext{
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			ClientIP:   clientIP(r),
+			RequestID:  requestID,
+			Vars:       vars,
+			Query:      r.URL.Query(),
+			Headers:    r.Header,
+			Body:       parsedBody,
+			Claims:     claims,
+			ClientCert: clientCert,
+		}
+		body := ds.processTemplate(response.Body, reqCtx.Data())
 		w.Write([]byte(body))
 	}
 }
 
-// 응답 본문에 템플릿({{변수}})이 있을 경우 변수 치환 처리
-func (ds *DynamicServer) processTemplate(body string, vars map[string]string) string {
-	if !strings.Contains(body, "{{") {
-		return body // 템플릿이 없으면 그대로 반환
-	}
-
-	// 템플릿 캐시 확인
-	ds.templateMux.RLock()
-	tmpl, exists := ds.templates[body]
-	ds.templateMux.RUnlock()
+// 응답 본문에 템플릿({{변수}})이 있을 경우 요청 컨텍스트로 치환 처리
+func (ds *DynamicServer) processTemplate(body string, data map[string]interface{}) string {
+	return ds.renderer.Render(body, data)
+}
 
-	if !exists {
-		// 캐시에 없으면 파싱하고 저장
-		var err error
-		tmpl, err = template.New("response").Parse(body)
-		if err != nil {
-			log.Printf("Template parse error: %v", err)
-			return body
+// 주입된 장애에 따라 에러 응답을 보내거나 연결을 끊는 함수
+func injectHTTPFault(w http.ResponseWriter, cfg *config.FaultConfig, kind fault.Kind) {
+	if kind == fault.Error {
+		status := cfg.ErrorStatus
+		if status == 0 {
+			status = http.StatusInternalServerError
 		}
-
-		ds.templateMux.Lock()
-		ds.templates[body] = tmpl
-		ds.templateMux.Unlock()
+		w.Header().Set("X-Servone-Fault", string(kind)) // 실제 장애와 구분하기 위한 표시
+		w.WriteHeader(status)
+		w.Write([]byte(cfg.ErrorBody))
+		return
 	}
 
-	data := make(map[string]interface{})
-	for k, v := range vars {
-		// 숫자형 변수는 int로 변환하여 템플릿에 전달
-		if id, err := strconv.Atoi(v); err == nil {
-			data[k] = id
-		} else {
-			data[k] = v
-		}
+	// reset / empty_reply: 연결을 가로채 응답 없이 종료
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler) // 가로챌 수 없으면 핸들러 중단으로 연결 종료
+	}
+	if tcpConn, ok := conn.(*net.TCPConn); ok && kind == fault.Reset {
+		tcpConn.SetLinger(0) // Close 시 FIN 대신 RST 전송
 	}
+	conn.Close()
+}
 
-	data["timestamp"] = time.Now().UTC().Format(time.RFC3339) // 현재 UTC 타임스탬프 추가
+// 바디를 가지는 HTTP 메서드인지 확인
+func hasPayloadMethod(method string) bool {
+	return method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch
+}
 
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil { // 템플릿 실행
-		log.Printf("Template execute error: %v", err)
-		return body
+// 요청 ID 결정 (클라이언트가 보낸 X-Request-ID가 있으면 재사용)
+func requestIDFor(r *http.Request) string {
+	if id := r.Header.Get("X-Request-ID"); id != "" {
+		return id
 	}
+	return tmpl.NewUUID()
+}
 
-	return buf.String() // 치환된 결과 반환
+// 클라이언트 IP 추출 (X-Forwarded-For 첫 번째 값 우선)
+func clientIP(r *http.Request) string {
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		return strings.TrimSpace(strings.Split(fwd, ",")[0])
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
 }
 
 // 서버를 시작하는 함수
 func (ds *DynamicServer) Start() error {
-	log.Printf("Starting server on %s", ds.server.Addr) // 서버 시작 로그
-	return ds.server.ListenAndServe()                   // HTTP 서버 실행
+	ln, err := net.Listen("tcp", ds.server.Addr)
+	if err != nil {
+		return err
+	}
+	return ds.Serve(ln)
+}
+
+// 주어진 리스너로 서버 실행 (rest.tls 설정 시 TLS/mTLS, HTTP/2 협상)
+func (ds *DynamicServer) Serve(ln net.Listener) error {
+	ds.configMux.Lock()
+	restCfg := ds.config.Rest
+	ds.server.Protocols = serverProtocols(restCfg)
+	if !restCfg.TLS.Enabled() {
+		ds.configMux.Unlock()
+		log.Printf("Starting server on %s", ln.Addr()) // 서버 시작 로그
+		return ds.server.Serve(ln)                     // HTTP 서버 실행
+	}
+
+	store, err := certs.NewStore(restCfg.TLS)
+	if err != nil {
+		ds.configMux.Unlock()
+		ln.Close()
+		return err
+	}
+	if err := store.Watch(); err != nil {
+		log.Printf("TLS certificate watch disabled: %v", err) // 감시 실패 시 재시작 전까지 기존 인증서 사용
+	}
+	ds.certs = store
+	ds.server.TLSConfig = store.ServerConfig(!restCfg.TLS.DisableHTTP2)
+	ds.configMux.Unlock()
+
+	log.Printf("Starting TLS server on %s", ln.Addr()) // 서버 시작 로그
+	return ds.server.ServeTLS(ln, "", "")              // 인증서는 TLSConfig에서 제공
 }
 
 // 설정 변경 시 서버 라우트 및 핸들러를 재설정하는 함수
@@ -222,7 +454,11 @@ func (ds *DynamicServer) Reload(newConfig *config.Config) {
 	ds.configMux.Lock()
 	defer ds.configMux.Unlock()
 
+	newConfig = withImportedEndpoints(newConfig)  // rest.openapi 명세를 다시 읽어 병합
 	ds.config = newConfig                         // 새로운 설정 반영
+	ds.states.sync(newConfig.Rest)                // 정의가 바뀐 시나리오/시퀀스만 초기화
+	ds.syncRecorder(newConfig)                    // 녹화 대상/파일 변경 반영
+	ds.syncCerts(newConfig.Rest.TLS)              // 인증서 경로 변경 반영
 	newRouter := mux.NewRouter()                  // 새 라우터 생성
 	ds.pathVars = make(map[string]*regexp.Regexp) // 경로 변수 맵 초기화
 
@@ -232,14 +468,33 @@ func (ds *DynamicServer) Reload(newConfig *config.Config) {
 	ds.server.Handler = ds.router // 서버 핸들러 갱신
 
 	// 템플릿 캐시 초기화
-	ds.templateMux.Lock()
-	ds.templates = make(map[string]*template.Template)
-	ds.templateMux.Unlock()
+	ds.renderer.Reset()
 
 	log.Println("Server configuration reloaded successfully") // 재로드 완료 로그
 }
 
 // Shutdown gracefully shuts down the server
 func (ds *DynamicServer) Shutdown(ctx context.Context) error {
-	return ds.server.Shutdown(ctx)
+	err := ds.server.Shutdown(ctx)
+	ds.configMux.RLock()
+	store := ds.certs
+	ds.configMux.RUnlock()
+	if store != nil {
+		store.Close()
+	}
+	if ds.ownsIngest {
+		// 처리 중이던 요청이 모두 끝난 뒤 대기열에 남은 작업까지 처리
+		if drainErr := ds.ingest.Close(ctx); drainErr != nil {
+			log.Printf("Failed to drain ingest queue: %v", drainErr)
+			if err == nil {
+				err = drainErr
+			}
+		}
+	}
+	if ds.recorder != nil {
+		if closeErr := ds.recorder.Close(); closeErr != nil {
+			log.Printf("Failed to close recording file: %v", closeErr)
+		}
+	}
+	return err
 }
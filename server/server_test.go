@@ -154,3 +154,187 @@ func TestDynamicServer(t *testing.T) {
 		assert.Equal(t, http.StatusNotFound, rr.Code)
 	})
 }
+
+func TestTemplateRequestContext(t *testing.T) {
+	cfg := &config.Config{
+		Rest: config.RestConfig{
+			Host: "localhost",
+			Port: "8080",
+			Endpoints: []config.EndpointConfig{
+				{
+					Path:   "/api/devices/{id}",
+					Method: "POST",
+					Response: config.ResponseConfig{
+						Status: 200,
+						Body:   `{"id": {{.id}}, "device": "{{.body.device_id}}", "mode": "{{.query.mode}}", "client": "{{index .headers "X-Client"}}", "method": "{{.method}}", "ip": "{{.client_ip}}", "request_id": "{{.request_id}}", "next": {{add .body.seq 1}}}`,
+						Headers: map[string]string{
+							"Content-Type": "application/json",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	server := NewDynamicServer(cfg, &MockKafkaPublisher{})
+
+	req, err := http.NewRequest("POST", "/api/devices/7?mode=fast", bytes.NewBufferString(`{"device_id": "dev-7", "seq": 41}`))
+	require.NoError(t, err)
+	req.Header.Set("X-Client", "gw-01")
+	req.Header.Set("X-Request-ID", "req-123")
+	req.RemoteAddr = "192.0.2.10:5555"
+
+	rr := httptest.NewRecorder()
+	server.router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "req-123", rr.Header().Get("X-Request-ID"))
+	assert.JSONEq(t, `{"id": 7, "device": "dev-7", "mode": "fast", "client": "gw-01", "method": "POST", "ip": "192.0.2.10", "request_id": "req-123", "next": 42}`, rr.Body.String())
+
+	t.Run("path variables may not shadow template keys", func(t *testing.T) {
+		ds := NewDynamicServer(&config.Config{Rest: config.RestConfig{Endpoints: []config.EndpointConfig{
+			{Path: "/api/files/{path}", Method: "GET", Response: config.ResponseConfig{Status: 200}},
+		}}}, &MockKafkaPublisher{})
+		assert.Equal(t, http.StatusNotFound, serve(t, ds, "GET", "/api/files/a", "").Code)
+	})
+}
+
+func TestSchemaValidation(t *testing.T) {
+	cfg := &config.Config{
+		Rest: config.RestConfig{
+			Host: "localhost",
+			Port: "8080",
+			Endpoints: []config.EndpointConfig{
+				{
+					Path:   "/api/tempo",
+					Method: "POST",
+					Response: config.ResponseConfig{
+						Status: 201,
+						Body:   `{"result": "success"}`,
+					},
+					Schema: &config.SchemaConfig{
+						Inline: map[string]interface{}{
+							"type":     "object",
+							"required": []interface{}{"device_id"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	mockPublisher := &MockKafkaPublisher{}
+	server := NewDynamicServer(cfg, mockPublisher)
+
+	t.Run("valid payload is accepted", func(t *testing.T) {
+		req, err := http.NewRequest("POST", "/api/tempo", bytes.NewBufferString(`{"device_id": "d1"}`))
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		server.router.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusCreated, rr.Code)
+	})
+
+	t.Run("invalid payload is rejected", func(t *testing.T) {
+		for _, body := range []string{`{"temp": 20}`, `not json`, ``} {
+			req, err := http.NewRequest("POST", "/api/tempo", bytes.NewBufferString(body))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			server.router.ServeHTTP(rr, req)
+
+			assert.Equal(t, http.StatusBadRequest, rr.Code, body)
+			assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+			var response map[string]interface{}
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+			assert.Equal(t, "schema validation failed", response["error"])
+			assert.NotEmpty(t, response["violations"])
+		}
+		assert.Empty(t, mockPublisher.PublishedTopic)
+	})
+
+	t.Run("invalid schema rejects requests", func(t *testing.T) {
+		broken := &config.Config{Rest: config.RestConfig{Endpoints: []config.EndpointConfig{{
+			Path:     "/api/broken",
+			Method:   "POST",
+			Response: config.ResponseConfig{Status: 201},
+			Schema:   &config.SchemaConfig{Inline: map[string]interface{}{"type": "objekt"}},
+		}}}}
+		publisher := &MockKafkaPublisher{}
+		srv := NewDynamicServer(broken, publisher)
+
+		req, err := http.NewRequest("POST", "/api/broken", bytes.NewBufferString(`{"device_id": "d1"}`))
+		require.NoError(t, err)
+		rr := httptest.NewRecorder()
+		srv.router.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusInternalServerError, rr.Code)
+		assert.Empty(t, publisher.PublishedTopic)
+	})
+}
+
+func TestFaultInjection(t *testing.T) {
+	cfg := &config.Config{
+		Rest: config.RestConfig{
+			Endpoints: []config.EndpointConfig{
+				{
+					Path:     "/api/error",
+					Method:   "POST",
+					Response: config.ResponseConfig{Status: 201},
+					Fault:    &config.FaultConfig{ErrorRate: 1, ErrorStatus: 503, ErrorBody: "injected"},
+				},
+				{
+					Path:     "/api/reset",
+					Method:   "GET",
+					Response: config.ResponseConfig{Status: 200},
+					Fault:    &config.FaultConfig{ResetRate: 1},
+				},
+				{
+					Path:     "/api/empty",
+					Method:   "GET",
+					Response: config.ResponseConfig{Status: 200},
+					Fault:    &config.FaultConfig{EmptyReplyRate: 1},
+				},
+				{
+					Path:     "/api/slow",
+					Method:   "GET",
+					Response: config.ResponseConfig{Status: 200},
+					Fault:    &config.FaultConfig{DelayMs: 50},
+				},
+			},
+		},
+	}
+
+	mockPublisher := &MockKafkaPublisher{}
+	ds := NewDynamicServer(cfg, mockPublisher)
+	ts := httptest.NewServer(ds.router)
+	defer ts.Close()
+
+	t.Run("error status", func(t *testing.T) {
+		resp, err := http.Post(ts.URL+"/api/error", "application/json", bytes.NewBufferString(`{"a": 1}`))
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+		assert.Equal(t, "error", resp.Header.Get("X-Servone-Fault"))
+		assert.Empty(t, mockPublisher.PublishedTopic)
+	})
+
+	t.Run("connection reset and empty reply", func(t *testing.T) {
+		for _, path := range []string{"/api/reset", "/api/empty"} {
+			_, err := http.Get(ts.URL + path)
+			assert.Error(t, err, path)
+		}
+	})
+
+	t.Run("delay", func(t *testing.T) {
+		start := time.Now()
+		resp, err := http.Get(ts.URL + "/api/slow")
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	})
+}
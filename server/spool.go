@@ -0,0 +1,28 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"servone/spool"
+)
+
+// 로컬 스풀 사용 (관리 엔드포인트로 적체 현황 노출, 닫는 것은 생성한 쪽의 책임)
+func WithSpool(s *spool.Spool) Option {
+	return func(ds *DynamicServer) {
+		ds.spool = s
+	}
+}
+
+// 스풀 현황 admin 라우트 등록 (스풀 사용 시에만)
+func (ds *DynamicServer) setupSpoolRoutes() {
+	if ds.spool == nil {
+		return
+	}
+	ds.handleBuiltin("/_admin/spool", http.HandlerFunc(ds.handleSpoolStatus)).Methods("GET")
+	log.Printf("Added route: GET /_admin/spool")
+}
+
+// 싱크별 스풀 적체 레코드 수/크기/가장 오래된 레코드 시각 조회
+func (ds *DynamicServer) handleSpoolStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"queues": ds.spool.Status()})
+}
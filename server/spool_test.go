@@ -0,0 +1,48 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"servone/config"
+	"servone/spool"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpoolAdminEndpoint(t *testing.T) {
+	cfg := &config.Config{}
+
+	t.Run("not registered without a spool", func(t *testing.T) {
+		ds := NewDynamicServer(cfg, &MockKafkaPublisher{})
+		rr := serve(t, ds, "GET", "/_admin/spool", "")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("reports the backlog of each queue", func(t *testing.T) {
+		s, err := spool.Open(config.SpoolConfig{Dir: t.TempDir(), RetryIntervalMs: 60000})
+		require.NoError(t, err)
+		defer s.Close()
+
+		q, err := s.Queue("db", func(json.RawMessage) error { return errors.New("database down") })
+		require.NoError(t, err)
+		_, err = q.Write(map[string]string{"url": "/api/tempo"}, func() error { return errors.New("database down") })
+		require.NoError(t, err)
+
+		ds := NewDynamicServer(cfg, &MockKafkaPublisher{}, WithSpool(s))
+		rr := serve(t, ds, "GET", "/_admin/spool", "")
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var body struct {
+			Queues []spool.Status `json:"queues"`
+		}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+		require.Len(t, body.Queues, 1)
+		assert.Equal(t, "db", body.Queues[0].Name)
+		assert.Equal(t, 1, body.Queues[0].Records)
+		assert.NotNil(t, body.Queues[0].Oldest)
+		assert.Equal(t, "database down", body.Queues[0].LastError)
+	})
+}
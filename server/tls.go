@@ -0,0 +1,68 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"servone/config"
+)
+
+// 클라이언트 인증서 정보가 DB 저장 파라미터에 추가될 때 사용하는 키
+const (
+	paramClientCertSubject = "client_cert_subject"
+	paramClientCertCN      = "client_cert_cn"
+	paramClientCertSerial  = "client_cert_serial"
+)
+
+// 설정에 맞는 HTTP 프로토콜 집합 (TLS 사용 시 HTTP/2 기본 활성화, h2c 는 명시적으로만)
+func serverProtocols(cfg config.RestConfig) *http.Protocols {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(cfg.TLS.Enabled() && !cfg.TLS.DisableHTTP2)
+	protocols.SetUnencryptedHTTP2(cfg.H2C && !cfg.TLS.Enabled())
+	return protocols
+}
+
+// 검증된 클라이언트 인증서의 subject/CN/serial 추출 (mTLS 가 아니면 nil)
+func clientCertInfo(r *http.Request) map[string]string {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+	cert := r.TLS.PeerCertificates[0]
+	return map[string]string{
+		"subject": cert.Subject.String(),
+		"cn":      cert.Subject.CommonName,
+		"serial":  cert.SerialNumber.String(),
+	}
+}
+
+// 경로 변수에 클라이언트 인증서 정보를 더한 DB 저장 파라미터 (원본 맵은 변경하지 않음)
+func withClientCert(vars map[string]string, cert map[string]string) map[string]string {
+	if cert == nil {
+		return vars
+	}
+	params := make(map[string]string, len(vars)+3)
+	for k, v := range vars {
+		params[k] = v
+	}
+	params[paramClientCertSubject] = cert["subject"]
+	params[paramClientCertCN] = cert["cn"]
+	params[paramClientCertSerial] = cert["serial"]
+	return params
+}
+
+// 설정 재로드 시 인증서 경로 변경 반영 (TLS 사용 여부 전환은 재시작 필요)
+func (ds *DynamicServer) syncCerts(cfg config.TLSConfig) {
+	if ds.certs == nil {
+		if cfg.Enabled() {
+			log.Printf("rest.tls was added; restart the server to enable TLS")
+		}
+		return
+	}
+	if !cfg.Enabled() {
+		log.Printf("rest.tls was removed; restart the server to disable TLS")
+		return
+	}
+	if err := ds.certs.Update(cfg); err != nil {
+		log.Printf("TLS certificate reload failed, keeping previous certificate: %v", err)
+	}
+}
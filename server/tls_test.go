@@ -0,0 +1,87 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"servone/config"
+	"servone/internal/testcert"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSServer(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey, _ := testcert.Issue(t, "servone-test-ca", nil, nil)
+	serverCert, serverKey, _ := testcert.Issue(t, "server", ca, caKey)
+	_, _, deviceCert := testcert.Issue(t, "device-42", ca, caKey)
+
+	certFile, keyFile := testcert.WriteKeyPair(t, dir, "tls", serverCert, serverKey)
+	caFile := filepath.Join(dir, "ca.pem")
+	testcert.Write(t, caFile, ca)
+
+	cfg := &config.Config{
+		Rest: config.RestConfig{
+			TLS: config.TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile},
+			Endpoints: []config.EndpointConfig{
+				{
+					Path:     "/device/hello",
+					Method:   "GET",
+					Response: config.ResponseConfig{Status: 200, Body: `{"device": "{{.client_cert.cn}}", "subject": "{{.client_cert.subject}}"}`},
+				},
+			},
+		},
+	}
+	ds := NewDynamicServer(cfg, &MockKafkaPublisher{})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go ds.Serve(ln)
+	defer ds.Shutdown(context.Background())
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	url := "https://" + ln.Addr().String() + "/device/hello"
+	client := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{
+			Timeout: 5 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig:   &tls.Config{RootCAs: roots, Certificates: certs},
+				ForceAttemptHTTP2: true,
+			},
+		}
+	}
+
+	t.Run("client certificate identity over HTTP/2", func(t *testing.T) {
+		resp, err := client(deviceCert).Get(url)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 2, resp.ProtoMajor)
+		assert.JSONEq(t, `{"device": "device-42", "subject": "CN=device-42,O=servone"}`, string(body))
+	})
+
+	t.Run("handshake fails without client certificate", func(t *testing.T) {
+		_, err := client().Get(url)
+		assert.Error(t, err)
+	})
+}
+
+func TestWithClientCert(t *testing.T) {
+	vars := map[string]string{"id": "7"}
+
+	assert.Equal(t, vars, withClientCert(vars, nil))
+
+	params := withClientCert(vars, map[string]string{"subject": "CN=dev", "cn": "dev", "serial": "9"})
+	assert.Equal(t, map[string]string{"id": "7", "client_cert_subject": "CN=dev", "client_cert_cn": "dev", "client_cert_serial": "9"}, params)
+	assert.Len(t, vars, 1, "원본 경로 변수 맵은 변경되지 않아야 함")
+}
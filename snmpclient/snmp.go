@@ -1,13 +1,11 @@
 package snmpclient
 
 import (
-	"context"
-	"database/sql"
-	"encoding/json"
 	"fmt"
 	"log"
 	"net"
 	"servone/config"
+	servone_db "servone/db"
 	"servone/kafka"
 	"sync"
 	"time"
@@ -18,7 +16,7 @@ import (
 // SNMPClient handles periodic SNMP WALK operations.
 type SNMPClient struct {
 	config         *config.SNMPConfig
-	db             *sql.DB
+	db             servone_db.Store
 	kafkaPublisher KafkaPublisherInterface
 	stop           chan struct{}
 	wg             sync.WaitGroup
@@ -27,7 +25,7 @@ type SNMPClient struct {
 // TrapServer handles incoming SNMP traps.
 type TrapServer struct {
 	config         *config.SNMPTrapConfig
-	db             *sql.DB
+	db             servone_db.Store
 	kafkaPublisher KafkaPublisherInterface
 	listener       *gosnmp.TrapListener
 }
@@ -37,7 +35,7 @@ type KafkaPublisherInterface interface {
 }
 
 // NewSNMPClient creates a new client for GET and periodic WALK operations.
-func NewSNMPClient(cfg *config.SNMPConfig, db *sql.DB, kafkaPublisher KafkaPublisherInterface) *SNMPClient {
+func NewSNMPClient(cfg *config.SNMPConfig, db servone_db.Store, kafkaPublisher KafkaPublisherInterface) *SNMPClient {
 	return &SNMPClient{
 		config:         cfg,
 		db:             db,
@@ -146,7 +144,7 @@ func (c *SNMPClient) WalkV3(target string, rootOid string) error {
 }
 
 // NewTrapServer creates a new server for receiving SNMP traps.
-func NewTrapServer(cfg *config.SNMPTrapConfig, kafkaPublisher KafkaPublisherInterface, db *sql.DB) *TrapServer {
+func NewTrapServer(cfg *config.SNMPTrapConfig, kafkaPublisher KafkaPublisherInterface, db servone_db.Store) *TrapServer {
 	return &TrapServer{
 		config:         cfg,
 		kafkaPublisher: kafkaPublisher,
@@ -241,7 +239,7 @@ func getSecurityParams(username, authProtocol, authPassphrase, privProtocol, pri
 }
 
 // processResults processes SNMP data and publishes it to Kafka.
-func processResults(operation string, source string, pdus []gosnmp.SnmpPDU, publisher KafkaPublisherInterface, db *sql.DB) {
+func processResults(operation string, source string, pdus []gosnmp.SnmpPDU, publisher KafkaPublisherInterface, db servone_db.Store) {
 	receivedTime := time.Now().UnixNano()
 
 	var results []map[string]interface{}
@@ -261,39 +259,21 @@ func processResults(operation string, source string, pdus []gosnmp.SnmpPDU, publ
 		"timestamp": receivedTime,
 	}
 
-	// Publish to Kafka
 	kafkaTopic := fmt.Sprintf("snmp.%s.%s", operation, kafka.SanitizeTopic(source))
-	if err := publisher.Publish(kafkaTopic, data); err != nil {
-		log.Printf("Failed to publish SNMP data to Kafka: %v", err)
-	}
 
-	// Save to database
+	// Save to database together with the Kafka event; the outbox relay publishes it
 	if db != nil {
-		if err := saveSNMPDataToDB(db, source, data, receivedTime); err != nil {
+		event := &servone_db.Event{Topic: kafkaTopic, Data: data}
+		if err := db.SaveSNMPData(source, data, receivedTime, event); err != nil {
 			log.Printf("Failed to save SNMP data to DB: %v", err)
 		}
+		return
 	}
-}
-
-// saveSNMPDataToDB saves SNMP data to the database.
-func saveSNMPDataToDB(db *sql.DB, host string, data map[string]interface{}, receivedTime int64) error {
-	dataJSON, err := json.Marshal(data)
-	if err != nil {
-		return fmt.Errorf("failed to marshal SNMP data: %w", err)
-	}
-
-	insertSQL := `
-	INSERT INTO snmp_data (host, data, created_at)
-	VALUES ($1, $2, $3);`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	_, err = db.ExecContext(ctx, insertSQL, host, dataJSON, receivedTime)
-	if err != nil {
-		return fmt.Errorf("failed to insert SNMP data: %w", err)
+	// Without a database publish to Kafka directly
+	if err := publisher.Publish(kafkaTopic, data); err != nil {
+		log.Printf("Failed to publish SNMP data to Kafka: %v", err)
 	}
-	return nil
 }
 
 // getValueString converts SNMP PDU value to a string representation.
@@ -0,0 +1,58 @@
+package spool
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"servone/kafka"
+)
+
+// kafkaRecord is a spooled Kafka message.
+type kafkaRecord struct {
+	Topic string                 `json:"topic"`
+	Data  map[string]interface{} `json:"data"`
+}
+
+// Publisher wraps a Kafka publisher so that messages which cannot be
+// published are kept in the "kafka" queue and published, in order, once the
+// broker is reachable again.
+type Publisher struct {
+	inner kafka.KafkaPublisherInterface
+	queue *Queue
+}
+
+// NewPublisher opens the "kafka" queue of s and replays it through inner.
+func NewPublisher(s *Spool, inner kafka.KafkaPublisherInterface) (*Publisher, error) {
+	p := &Publisher{inner: inner}
+	q, err := s.Queue("kafka", p.replay)
+	if err != nil {
+		return nil, err
+	}
+	p.queue = q
+	return p, nil
+}
+
+// Publish publishes data to topic, or spools it if the broker is unavailable.
+// The error is non-nil only when the message could not be spooled either.
+func (p *Publisher) Publish(topic string, data map[string]interface{}) error {
+	_, err := p.queue.Write(kafkaRecord{Topic: topic, Data: data}, func() error {
+		return p.inner.Publish(topic, data)
+	})
+	return err
+}
+
+// Close closes the wrapped publisher. The spool itself is closed with Spool.Close.
+func (p *Publisher) Close() {
+	p.inner.Close()
+}
+
+func (p *Publisher) replay(data json.RawMessage) error {
+	var rec kafkaRecord
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber() // keep integers such as "received" exact
+	if err := dec.Decode(&rec); err != nil {
+		return Permanent(fmt.Errorf("corrupt spooled Kafka message: %w", err))
+	}
+	return p.inner.Publish(rec.Topic, rec.Data)
+}
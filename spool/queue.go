@@ -0,0 +1,570 @@
+package spool
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"servone/metrics"
+)
+
+const (
+	segmentSuffix = ".seg"
+	cursorFile    = "cursor"
+)
+
+// Record is one spooled payload as stored on disk (one JSON line).
+type Record struct {
+	Seq  uint64          `json:"seq"`
+	Time time.Time       `json:"time"` // when the record was spooled
+	Data json.RawMessage `json:"data"`
+}
+
+// Status describes a queue's backlog.
+type Status struct {
+	Name             string     `json:"name"`
+	Records          int        `json:"records"`
+	Bytes            int64      `json:"bytes"`
+	Segments         int        `json:"segments"`
+	Oldest           *time.Time `json:"oldest,omitempty"`
+	OldestAgeSeconds float64    `json:"oldest_age_seconds"`
+	LastError        string     `json:"last_error,omitempty"`
+	LastErrorAt      *time.Time `json:"last_error_at,omitempty"`
+}
+
+// Queue is the spool of one sink. Records are appended to the newest segment
+// and consumed from a persisted cursor (segment number and byte offset);
+// fully consumed segments are deleted.
+type Queue struct {
+	name         string
+	dir          string
+	deliver      Deliver
+	segmentBytes int64
+
+	order sync.Mutex // serializes Write so a direct write cannot overtake a spooled one
+
+	mu      sync.Mutex
+	seq     uint64
+	records int
+	bytes   int64
+
+	w     *os.File // newest segment, opened for append
+	wSeg  uint64
+	wSize int64
+
+	r       *os.File // segment at the cursor
+	rr      *bufio.Reader
+	rSeg    uint64
+	rOff    int64
+	head    *Record // record at the cursor, cached until acknowledged
+	headLen int64
+	headAt  time.Time // spool time of the record at the cursor, refreshed whenever a record becomes the head
+
+	lastErr   string
+	lastErrAt time.Time
+
+	stop chan struct{}
+	done chan struct{} // closed when the replay loop exits; nil until start
+}
+
+func segmentPath(dir string, seg uint64) string {
+	return filepath.Join(dir, fmt.Sprintf("%016d%s", seg, segmentSuffix))
+}
+
+// listSegments returns the segment numbers in dir, ascending.
+func listSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var segs []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		n, err := strconv.ParseUint(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		segs = append(segs, n)
+	}
+	sort.Slice(segs, func(i, j int) bool { return segs[i] < segs[j] })
+	return segs, nil
+}
+
+func openQueue(name, dir string, deliver Deliver, segmentBytes int64) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("spool %s: %w", name, err)
+	}
+	q := &Queue{
+		name:         name,
+		dir:          dir,
+		deliver:      deliver,
+		segmentBytes: segmentBytes,
+		stop:         make(chan struct{}),
+	}
+	if err := q.recover(); err != nil {
+		q.closeFiles()
+		return nil, fmt.Errorf("spool %s: %w", name, err)
+	}
+	q.updateMetrics()
+	return q, nil
+}
+
+// recover restores the cursor, counts the backlog and reopens the segments.
+func (q *Queue) recover() error {
+	segs, err := listSegments(q.dir)
+	if err != nil {
+		return err
+	}
+
+	q.rSeg, q.rOff = q.readCursor()
+	var live []uint64
+	for _, seg := range segs {
+		if seg < q.rSeg {
+			os.Remove(segmentPath(q.dir, seg)) // consumed before the last shutdown
+			continue
+		}
+		live = append(live, seg)
+	}
+	if len(live) == 0 {
+		q.rSeg, q.rOff = 1, 0
+		if len(segs) > 0 {
+			q.rSeg = segs[len(segs)-1] + 1
+		}
+		live = []uint64{q.rSeg}
+	} else if live[0] != q.rSeg {
+		q.rSeg, q.rOff = live[0], 0
+	}
+
+	for i, seg := range live {
+		offset := int64(0)
+		if seg == q.rSeg {
+			offset = q.rOff
+		}
+		if err := q.scan(seg, offset, i == len(live)-1); err != nil {
+			return err
+		}
+	}
+
+	q.wSeg = live[len(live)-1]
+	q.w, err = os.OpenFile(segmentPath(q.dir, q.wSeg), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := q.w.Stat()
+	if err != nil {
+		return err
+	}
+	q.wSize = info.Size()
+	return q.openReader()
+}
+
+// scan counts the complete records of a segment from offset. A partial last
+// line (a crash in the middle of an append) is cut off the newest segment.
+func (q *Queue) scan(seg uint64, offset int64, newest bool) error {
+	f, err := os.Open(segmentPath(q.dir, seg))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var complete int64
+	reader := bufio.NewReader(f)
+	var pos int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		pos += int64(len(line))
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		complete = pos
+		if pos <= offset {
+			continue
+		}
+		var rec Record
+		decoded := json.Unmarshal(line, &rec) == nil
+		if decoded && q.records == 0 {
+			q.headAt = rec.Time
+		}
+		q.records++
+		q.bytes += int64(len(line))
+		if decoded && rec.Seq > q.seq {
+			q.seq = rec.Seq
+		}
+	}
+	if newest && pos > complete {
+		log.Printf("Spool %s: dropping %d byte(s) of a partially written record in segment %d", q.name, pos-complete, seg)
+		return os.Truncate(segmentPath(q.dir, seg), complete)
+	}
+	return nil
+}
+
+func (q *Queue) readCursor() (uint64, int64) {
+	raw, err := os.ReadFile(filepath.Join(q.dir, cursorFile))
+	if err != nil {
+		return 0, 0
+	}
+	var seg uint64
+	var off int64
+	if _, err := fmt.Sscanf(string(raw), "%d %d", &seg, &off); err != nil {
+		return 0, 0
+	}
+	return seg, off
+}
+
+// writeCursor persists the cursor atomically (write to a temp file, rename).
+func (q *Queue) writeCursor() error {
+	tmp := filepath.Join(q.dir, cursorFile+".tmp")
+	if err := os.WriteFile(tmp, []byte(fmt.Sprintf("%d %d\n", q.rSeg, q.rOff)), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(q.dir, cursorFile))
+}
+
+func (q *Queue) openReader() error {
+	if q.r != nil {
+		q.r.Close()
+	}
+	f, err := os.OpenFile(segmentPath(q.dir, q.rSeg), os.O_CREATE|os.O_RDONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(q.rOff, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	q.r, q.rr = f, bufio.NewReader(f)
+	return nil
+}
+
+// Write delivers v directly with direct when nothing is backlogged. If
+// records are already waiting (to keep order) or direct fails with an error
+// that is not Permanent, v is appended to the spool instead and spooled is
+// true. Otherwise the error is the Permanent one, or the failure to spool v.
+//
+// Writes are serialized: the backlog check, direct and the append of a failed
+// write all happen under the order lock, so a write never overtakes one that
+// started before it, whether that one was delivered directly or spooled.
+// The replay loop does not take the order lock and keeps delivering while a
+// Write waits on direct.
+func (q *Queue) Write(v interface{}, direct func() error) (spooled bool, err error) {
+	q.order.Lock()
+	defer q.order.Unlock()
+
+	if q.Pending() == 0 {
+		err := direct()
+		if err == nil {
+			return false, nil
+		}
+		if IsPermanent(err) {
+			return false, err
+		}
+		log.Printf("Spool %s: sink unavailable, spooling: %v", q.name, err)
+		q.setError(err)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return false, fmt.Errorf("spool %s: %w", q.name, err)
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.appendLocked(data); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Append adds v to the end of the queue and syncs it to disk.
+func (q *Queue) Append(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("spool %s: %w", q.name, err)
+	}
+
+	q.order.Lock()
+	defer q.order.Unlock()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.appendLocked(data)
+}
+
+// appendLocked writes one record holding data. Caller holds mu.
+func (q *Queue) appendLocked(data json.RawMessage) error {
+	rec := Record{Seq: q.seq + 1, Time: time.Now().UTC(), Data: data}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("spool %s: %w", q.name, err)
+	}
+	line = append(line, '\n')
+
+	if q.wSize > 0 && q.wSize+int64(len(line)) > q.segmentBytes {
+		if err := q.rotate(); err != nil {
+			return fmt.Errorf("spool %s: %w", q.name, err)
+		}
+	}
+	if _, err := q.w.Write(line); err != nil {
+		return fmt.Errorf("spool %s: %w", q.name, err)
+	}
+	if err := q.w.Sync(); err != nil {
+		return fmt.Errorf("spool %s: %w", q.name, err)
+	}
+
+	q.seq++
+	q.wSize += int64(len(line))
+	if q.records == 0 {
+		q.headAt = rec.Time
+	}
+	q.records++
+	q.bytes += int64(len(line))
+	metrics.RecordSpoolEvent(q.name, "spooled")
+	q.updateMetrics()
+	return nil
+}
+
+// rotate starts a new segment. Caller holds mu.
+func (q *Queue) rotate() error {
+	if err := q.w.Close(); err != nil {
+		return err
+	}
+	q.wSeg++
+	f, err := os.OpenFile(segmentPath(q.dir, q.wSeg), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	q.w, q.wSize = f, 0
+	return nil
+}
+
+// Pending returns the number of records waiting for replay.
+func (q *Queue) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.records
+}
+
+// peek returns the record at the cursor. Caller holds mu.
+func (q *Queue) peek() (*Record, error) {
+	for q.head == nil {
+		if q.records == 0 {
+			return nil, nil
+		}
+		line, err := q.rr.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) > 0 {
+				// partial record in an older segment; it can never complete
+				q.rOff += int64(len(line))
+			}
+			if q.rSeg >= q.wSeg {
+				return nil, nil
+			}
+			os.Remove(segmentPath(q.dir, q.rSeg))
+			q.rSeg, q.rOff = q.rSeg+1, 0
+			if err := q.openReader(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var rec Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			log.Printf("Spool %s: discarding corrupt record in segment %d at offset %d: %v", q.name, q.rSeg, q.rOff, err)
+			metrics.RecordSpoolEvent(q.name, "discarded")
+			q.consume(int64(len(line)))
+			continue
+		}
+		q.head, q.headLen, q.headAt = &rec, int64(len(line)), rec.Time
+	}
+	return q.head, nil
+}
+
+// ack consumes the record at the cursor after it was delivered. Caller holds mu.
+func (q *Queue) ack() {
+	q.head = nil
+	q.consume(q.headLen)
+	metrics.RecordSpoolEvent(q.name, "replayed")
+}
+
+// consume advances the cursor by n bytes. Once the backlog is empty the
+// segment is recycled so the spool does not grow without bound. Caller holds mu.
+func (q *Queue) consume(n int64) {
+	q.rOff += n
+	q.records--
+	q.bytes -= n
+
+	if q.records == 0 && q.rSeg == q.wSeg {
+		old := q.rSeg
+		if err := q.rotate(); err == nil {
+			q.rSeg, q.rOff = q.wSeg, 0
+			if err := q.openReader(); err == nil {
+				os.Remove(segmentPath(q.dir, old))
+			}
+		}
+	}
+	if err := q.writeCursor(); err != nil {
+		log.Printf("Spool %s: failed to persist cursor: %v", q.name, err)
+	}
+	q.updateMetrics()
+}
+
+// start launches the replay loop.
+func (q *Queue) start(interval time.Duration) {
+	q.done = make(chan struct{})
+	go q.run(interval)
+}
+
+// run replays the backlog every interval until Close.
+func (q *Queue) run(interval time.Duration) {
+	defer close(q.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-ticker.C:
+			q.replay()
+		}
+	}
+}
+
+// replay delivers records in order until the queue is empty or the sink fails.
+func (q *Queue) replay() {
+	replayed := 0
+	for {
+		select {
+		case <-q.stop:
+			return
+		default:
+		}
+
+		q.mu.Lock()
+		rec, err := q.peek()
+		q.mu.Unlock()
+		if err != nil {
+			log.Printf("Spool %s: failed to read backlog: %v", q.name, err)
+			return
+		}
+		if rec == nil {
+			if replayed > 0 {
+				log.Printf("Spool %s: replayed %d record(s), backlog empty", q.name, replayed)
+			}
+			return
+		}
+
+		if err := q.deliver(rec.Data); IsPermanent(err) {
+			log.Printf("Spool %s: discarding record %d that cannot be delivered: %v", q.name, rec.Seq, err)
+			q.mu.Lock()
+			q.head = nil
+			q.consume(q.headLen)
+			q.mu.Unlock()
+			metrics.RecordSpoolEvent(q.name, "discarded")
+			continue
+		} else if err != nil {
+			q.setError(err)
+			q.mu.Lock()
+			q.updateMetrics() // keep the age gauge moving while the sink is down
+			q.mu.Unlock()
+			return
+		}
+
+		q.mu.Lock()
+		q.ack()
+		q.mu.Unlock()
+		replayed++
+	}
+}
+
+func (q *Queue) setError(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.lastErr, q.lastErrAt = err.Error(), time.Now().UTC()
+}
+
+// oldest returns the spool time of the record at the cursor without reading
+// the segment. Caller holds mu.
+func (q *Queue) oldest() (time.Time, bool) {
+	if q.records == 0 || q.headAt.IsZero() {
+		return time.Time{}, false
+	}
+	return q.headAt, true
+}
+
+// updateMetrics publishes the backlog gauges. Caller holds mu.
+func (q *Queue) updateMetrics() {
+	age := 0.0
+	if t, ok := q.oldest(); ok {
+		age = time.Since(t).Seconds()
+	}
+	metrics.SetSpoolBacklog(q.name, q.records, q.bytes, age)
+}
+
+// Status reports the queue's backlog.
+func (q *Queue) Status() Status {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	st := Status{Name: q.name, Records: q.records, Bytes: q.bytes}
+	if segs, err := listSegments(q.dir); err == nil {
+		st.Segments = len(segs)
+	}
+	if t, ok := q.oldest(); ok {
+		st.Oldest = &t
+		st.OldestAgeSeconds = time.Since(t).Seconds()
+	}
+	if q.lastErr != "" {
+		at := q.lastErrAt
+		st.LastError, st.LastErrorAt = q.lastErr, &at
+	}
+	return st
+}
+
+// Close stops the replay loop and closes the segment files.
+func (q *Queue) Close() error {
+	select {
+	case <-q.stop:
+	default:
+		close(q.stop)
+		if q.done != nil {
+			<-q.done
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.closeFiles()
+}
+
+func (q *Queue) closeFiles() error {
+	var err error
+	if q.w != nil {
+		err = q.w.Close()
+		q.w = nil
+	}
+	if q.r != nil {
+		q.r.Close()
+		q.r = nil
+	}
+	return err
+}
@@ -0,0 +1,311 @@
+package spool
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sink is a Deliver target that can be switched between failing and healthy.
+type sink struct {
+	mu        sync.Mutex
+	down      bool
+	delivered []string
+}
+
+func (s *sink) setDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+func (s *sink) deliver(data json.RawMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errors.New("sink unavailable")
+	}
+	var v string
+	if err := json.Unmarshal(data, &v); err != nil {
+		return Permanent(err)
+	}
+	s.delivered = append(s.delivered, v)
+	return nil
+}
+
+func (s *sink) write(v string) func() error {
+	return func() error { return s.deliver(json.RawMessage(`"` + v + `"`)) }
+}
+
+func (s *sink) got() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.delivered...)
+}
+
+func TestQueueWrite(t *testing.T) {
+	t.Run("delivers directly while the sink is healthy", func(t *testing.T) {
+		out := &sink{}
+		q, err := openQueue("direct", t.TempDir(), out.deliver, DefaultSegmentBytes)
+		require.NoError(t, err)
+		defer q.Close()
+
+		spooled, err := q.Write("a", out.write("a"))
+		require.NoError(t, err)
+		assert.False(t, spooled)
+		assert.Equal(t, []string{"a"}, out.got())
+		assert.Equal(t, 0, q.Pending())
+	})
+
+	t.Run("spools while the sink is down and keeps order after recovery", func(t *testing.T) {
+		out := &sink{down: true}
+		q, err := openQueue("order", t.TempDir(), out.deliver, DefaultSegmentBytes)
+		require.NoError(t, err)
+		defer q.Close()
+
+		spooled, err := q.Write("a", out.write("a"))
+		require.NoError(t, err)
+		assert.True(t, spooled)
+		assert.Equal(t, 1, q.Pending())
+
+		// the sink is back, but b must not overtake the backlog
+		out.setDown(false)
+		spooled, err = q.Write("b", out.write("b"))
+		require.NoError(t, err)
+		assert.True(t, spooled)
+		assert.Empty(t, out.got())
+
+		q.replay()
+		assert.Equal(t, []string{"a", "b"}, out.got())
+		assert.Equal(t, 0, q.Pending())
+
+		// with the backlog gone writes go straight to the sink again
+		spooled, err = q.Write("c", out.write("c"))
+		require.NoError(t, err)
+		assert.False(t, spooled)
+		assert.Equal(t, []string{"a", "b", "c"}, out.got())
+	})
+
+	t.Run("a write cannot overtake a failing direct write", func(t *testing.T) {
+		out := &sink{}
+		q, err := openQueue("overtake", t.TempDir(), out.deliver, DefaultSegmentBytes)
+		require.NoError(t, err)
+		defer q.Close()
+
+		started, release := make(chan struct{}), make(chan struct{})
+		first := make(chan bool)
+		go func() {
+			spooled, _ := q.Write("a", func() error {
+				close(started)
+				<-release
+				return errors.New("sink unavailable")
+			})
+			first <- spooled
+		}()
+		<-started
+
+		second := make(chan bool)
+		go func() {
+			spooled, _ := q.Write("b", out.write("b"))
+			second <- spooled
+		}()
+		select {
+		case <-second:
+			t.Fatal("b was written while a was still in flight")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(release)
+		assert.True(t, <-first)
+		assert.True(t, <-second, "b waits behind the spooled a")
+		assert.Empty(t, out.got())
+
+		q.replay()
+		assert.Equal(t, []string{"a", "b"}, out.got())
+	})
+
+	t.Run("permanent errors are returned instead of spooled", func(t *testing.T) {
+		q, err := openQueue("permanent", t.TempDir(), (&sink{}).deliver, DefaultSegmentBytes)
+		require.NoError(t, err)
+		defer q.Close()
+
+		spooled, err := q.Write("a", func() error { return Permanent(errors.New("bad row")) })
+		assert.EqualError(t, err, "bad row")
+		assert.False(t, spooled)
+		assert.Equal(t, 0, q.Pending())
+	})
+}
+
+func TestQueueReplay(t *testing.T) {
+	t.Run("stops at the first failure and resumes later", func(t *testing.T) {
+		out := &sink{down: true}
+		q, err := openQueue("resume", t.TempDir(), out.deliver, DefaultSegmentBytes)
+		require.NoError(t, err)
+		defer q.Close()
+
+		for _, v := range []string{"a", "b", "c"} {
+			require.NoError(t, q.Append(v))
+		}
+		q.replay()
+		assert.Empty(t, out.got())
+		assert.Equal(t, "sink unavailable", q.Status().LastError)
+
+		out.setDown(false)
+		q.replay()
+		assert.Equal(t, []string{"a", "b", "c"}, out.got())
+	})
+
+	t.Run("discards records the sink rejects permanently", func(t *testing.T) {
+		out := &sink{}
+		q, err := openQueue("discard", t.TempDir(), out.deliver, DefaultSegmentBytes)
+		require.NoError(t, err)
+		defer q.Close()
+
+		require.NoError(t, q.Append(42)) // not a string: Permanent error
+		require.NoError(t, q.Append("a"))
+		q.replay()
+		assert.Equal(t, []string{"a"}, out.got())
+		assert.Equal(t, 0, q.Pending())
+	})
+
+	t.Run("rotates segments and removes consumed ones", func(t *testing.T) {
+		dir := t.TempDir()
+		out := &sink{}
+		q, err := openQueue("rotate", dir, out.deliver, 64)
+		require.NoError(t, err)
+		defer q.Close()
+
+		for i := 0; i < 5; i++ {
+			require.NoError(t, q.Append("record"))
+		}
+		segs, err := listSegments(dir)
+		require.NoError(t, err)
+		assert.Greater(t, len(segs), 1)
+
+		q.replay()
+		assert.Len(t, out.got(), 5)
+		segs, err = listSegments(dir)
+		require.NoError(t, err)
+		assert.Len(t, segs, 1)
+		assert.Equal(t, 0, q.Status().Records)
+	})
+
+	t.Run("replay loop drains in the background", func(t *testing.T) {
+		out := &sink{}
+		q, err := openQueue("loop", t.TempDir(), out.deliver, DefaultSegmentBytes)
+		require.NoError(t, err)
+		require.NoError(t, q.Append("a"))
+
+		q.start(10 * time.Millisecond)
+		assert.Eventually(t, func() bool { return q.Pending() == 0 }, time.Second, 10*time.Millisecond)
+		require.NoError(t, q.Close())
+		assert.Equal(t, []string{"a"}, out.got())
+	})
+}
+
+func TestQueueRestart(t *testing.T) {
+	t.Run("backlog and cursor survive a restart", func(t *testing.T) {
+		dir := t.TempDir()
+		out := &sink{}
+		q, err := openQueue("restart", dir, out.deliver, 64)
+		require.NoError(t, err)
+		for _, v := range []string{"a", "b", "c", "d"} {
+			require.NoError(t, q.Append(v))
+		}
+
+		// deliver only the first record before "crashing"
+		q.mu.Lock()
+		_, err = q.peek()
+		require.NoError(t, err)
+		q.ack()
+		q.mu.Unlock()
+		require.NoError(t, q.Close())
+
+		out = &sink{}
+		q, err = openQueue("restart", dir, out.deliver, 64)
+		require.NoError(t, err)
+		defer q.Close()
+		assert.Equal(t, 3, q.Pending())
+
+		q.replay()
+		assert.Equal(t, []string{"b", "c", "d"}, out.got())
+
+		// sequence numbers continue after the restart
+		require.NoError(t, q.Append("e"))
+		q.mu.Lock()
+		rec, err := q.peek()
+		q.mu.Unlock()
+		require.NoError(t, err)
+		assert.Equal(t, uint64(5), rec.Seq)
+	})
+
+	t.Run("a partially written record is dropped", func(t *testing.T) {
+		dir := t.TempDir()
+		out := &sink{}
+		q, err := openQueue("torn", dir, out.deliver, DefaultSegmentBytes)
+		require.NoError(t, err)
+		require.NoError(t, q.Append("a"))
+		seg := q.wSeg
+		require.NoError(t, q.Close())
+
+		f, err := os.OpenFile(segmentPath(dir, seg), os.O_WRONLY|os.O_APPEND, 0o644)
+		require.NoError(t, err)
+		_, err = f.WriteString(`{"seq":2,"data":"b`)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		q, err = openQueue("torn", dir, out.deliver, DefaultSegmentBytes)
+		require.NoError(t, err)
+		defer q.Close()
+		assert.Equal(t, 1, q.Pending())
+
+		require.NoError(t, q.Append("c"))
+		q.replay()
+		assert.Equal(t, []string{"a", "c"}, out.got())
+	})
+}
+
+func TestQueueStatus(t *testing.T) {
+	out := &sink{down: true}
+	dir := t.TempDir()
+	q, err := openQueue("status", dir, out.deliver, DefaultSegmentBytes)
+	require.NoError(t, err)
+	defer q.Close()
+
+	st := q.Status()
+	assert.Equal(t, "status", st.Name)
+	assert.Zero(t, st.Records)
+	assert.Nil(t, st.Oldest)
+
+	_, err = q.Write("a", out.write("a"))
+	require.NoError(t, err)
+	st = q.Status()
+	assert.Equal(t, 1, st.Records)
+	assert.Positive(t, st.Bytes)
+	assert.Equal(t, 1, st.Segments)
+	require.NotNil(t, st.Oldest)
+	assert.WithinDuration(t, time.Now(), *st.Oldest, time.Second)
+	assert.Equal(t, "sink unavailable", st.LastError)
+
+	_, err = os.Stat(filepath.Join(dir, cursorFile))
+	assert.True(t, os.IsNotExist(err), "the cursor is only written once records are consumed")
+
+	t.Run("the oldest record survives a restart", func(t *testing.T) {
+		oldest := *st.Oldest
+		require.NoError(t, q.Close())
+		q, err := openQueue("status", dir, out.deliver, DefaultSegmentBytes)
+		require.NoError(t, err)
+		defer q.Close()
+
+		st := q.Status()
+		require.NotNil(t, st.Oldest)
+		assert.True(t, oldest.Equal(*st.Oldest))
+	})
+}
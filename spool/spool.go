@@ -0,0 +1,137 @@
+// Package spool is a local write-ahead spool for messages that could not be
+// written to a sink (PostgreSQL, Kafka). Each sink has its own queue of
+// append-only segment files; a background loop replays the queue in order
+// once the sink accepts writes again. Queues survive restarts.
+package spool
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"servone/config"
+)
+
+// Defaults used when the spool config leaves a field unset.
+const (
+	DefaultSegmentBytes  = 16 << 20
+	DefaultRetryInterval = 5 * time.Second
+)
+
+// Deliver writes one spooled payload to its sink.
+type Deliver func(data json.RawMessage) error
+
+// permanentError marks a failure that retrying cannot fix.
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent wraps err to tell the spool that the payload itself is bad (it
+// would fail again on every retry). A direct write failing this way is not
+// spooled, and a replayed record failing this way is discarded.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// IsPermanent reports whether err was wrapped with Permanent.
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// Spool groups the queues of all sinks under one directory.
+type Spool struct {
+	dir          string
+	segmentBytes int64
+	retry        time.Duration
+
+	mu     sync.Mutex
+	queues map[string]*Queue
+}
+
+// Open prepares the spool directory. Queues are opened with Queue.
+func Open(cfg config.SpoolConfig) (*Spool, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("spool: dir is required")
+	}
+	if cfg.SegmentBytes < 0 || cfg.RetryIntervalMs < 0 {
+		return nil, errors.New("spool: segment_bytes and retry_interval_ms must not be negative")
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("spool: %w", err)
+	}
+
+	s := &Spool{
+		dir:          cfg.Dir,
+		segmentBytes: cfg.SegmentBytes,
+		retry:        time.Duration(cfg.RetryIntervalMs) * time.Millisecond,
+		queues:       make(map[string]*Queue),
+	}
+	if s.segmentBytes == 0 {
+		s.segmentBytes = DefaultSegmentBytes
+	}
+	if s.retry == 0 {
+		s.retry = DefaultRetryInterval
+	}
+	return s, nil
+}
+
+// Queue opens (or returns the already open) queue for a sink and starts
+// replaying its backlog through deliver.
+func (s *Spool) Queue(name string, deliver Deliver) (*Queue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if q, ok := s.queues[name]; ok {
+		return q, nil
+	}
+	q, err := openQueue(name, filepath.Join(s.dir, name), deliver, s.segmentBytes)
+	if err != nil {
+		return nil, err
+	}
+	if q.records > 0 {
+		log.Printf("Spool %s: %d record(s) pending replay from %s", name, q.records, q.dir)
+	}
+	s.queues[name] = q
+	q.start(s.retry)
+	return q, nil
+}
+
+// Status reports every open queue, sorted by name.
+func (s *Spool) Status() []Status {
+	s.mu.Lock()
+	queues := make([]*Queue, 0, len(s.queues))
+	for _, q := range s.queues {
+		queues = append(queues, q)
+	}
+	s.mu.Unlock()
+
+	statuses := make([]Status, 0, len(queues))
+	for _, q := range queues {
+		statuses = append(statuses, q.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// Close stops replaying and closes every queue. Pending records stay on disk.
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for _, q := range s.queues {
+		errs = append(errs, q.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,102 @@
+package spool
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"servone/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpen(t *testing.T) {
+	_, err := Open(config.SpoolConfig{})
+	assert.ErrorContains(t, err, "dir is required")
+
+	_, err = Open(config.SpoolConfig{Dir: t.TempDir(), SegmentBytes: -1})
+	assert.Error(t, err)
+
+	s, err := Open(config.SpoolConfig{Dir: t.TempDir()})
+	require.NoError(t, err)
+	assert.Equal(t, int64(DefaultSegmentBytes), s.segmentBytes)
+	assert.Equal(t, DefaultRetryInterval, s.retry)
+	require.NoError(t, s.Close())
+}
+
+func TestSpoolQueues(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(config.SpoolConfig{Dir: dir, RetryIntervalMs: 10})
+	require.NoError(t, err)
+
+	out := &sink{down: true}
+	db, err := s.Queue("db", out.deliver)
+	require.NoError(t, err)
+	again, err := s.Queue("db", out.deliver)
+	require.NoError(t, err)
+	assert.Same(t, db, again)
+
+	_, err = s.Queue("kafka", out.deliver)
+	require.NoError(t, err)
+	assert.DirExists(t, filepath.Join(dir, "db"))
+
+	require.NoError(t, db.Append("a"))
+	statuses := s.Status()
+	require.Len(t, statuses, 2)
+	assert.Equal(t, "db", statuses[0].Name)
+	assert.Equal(t, 1, statuses[0].Records)
+	assert.Equal(t, "kafka", statuses[1].Name)
+
+	out.setDown(false)
+	assert.Eventually(t, func() bool { return db.Pending() == 0 }, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"a"}, out.got())
+	require.NoError(t, s.Close())
+}
+
+// fakeKafka records published messages and fails while down is set.
+type fakeKafka struct {
+	mu        sync.Mutex
+	down      bool
+	published []map[string]interface{}
+}
+
+func (f *fakeKafka) Publish(topic string, data map[string]interface{}) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return errors.New("broker unavailable")
+	}
+	f.published = append(f.published, map[string]interface{}{"topic": topic, "data": data})
+	return nil
+}
+
+func (f *fakeKafka) Close() {}
+
+func TestPublisher(t *testing.T) {
+	s, err := Open(config.SpoolConfig{Dir: t.TempDir(), RetryIntervalMs: 10})
+	require.NoError(t, err)
+	defer s.Close()
+
+	broker := &fakeKafka{down: true}
+	p, err := NewPublisher(s, broker)
+	require.NoError(t, err)
+
+	require.NoError(t, p.Publish("tempo", map[string]interface{}{"received": int64(1700000000000000001)}))
+	assert.Equal(t, 1, p.queue.Pending())
+
+	broker.mu.Lock()
+	broker.down = false
+	broker.mu.Unlock()
+	assert.Eventually(t, func() bool { return p.queue.Pending() == 0 }, time.Second, 10*time.Millisecond)
+
+	broker.mu.Lock()
+	defer broker.mu.Unlock()
+	require.Len(t, broker.published, 1)
+	assert.Equal(t, "tempo", broker.published[0]["topic"])
+	data := broker.published[0]["data"].(map[string]interface{})
+	assert.Equal(t, json.Number("1700000000000000001"), data["received"], "large integers survive the round trip")
+}
@@ -0,0 +1,203 @@
+// Package tmpl 은 REST 와 CoAP 서버가 공유하는 응답 템플릿 엔진을 제공합니다.
+package tmpl
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"text/template"
+	"time"
+)
+
+// FuncMap은 모든 응답 템플릿에서 사용할 수 있는 헬퍼 함수를 반환합니다.
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"uuid":     NewUUID,
+		"now":      now,
+		"randInt":  randInt,
+		"b64enc":   b64enc,
+		"b64dec":   b64dec,
+		"toJSON":   toJSON,
+		"add":      add,
+		"sub":      sub,
+		"mul":      mul,
+		"div":      div,
+		"mod":      mod,
+		"default":  defaultValue,
+		"toString": toString,
+		"toNumber": toFloat,
+	}
+}
+
+// NewUUID는 랜덤(버전 4) UUID 문자열을 생성합니다.
+func NewUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// now는 현재 UTC 시각을 형식화합니다. 레이아웃이 없으면 RFC3339 이고,
+// 특수 레이아웃 "unix", "unixms" 는 epoch 초/밀리초를 반환합니다.
+func now(layout ...string) string {
+	t := time.Now().UTC()
+	if len(layout) == 0 || layout[0] == "" {
+		return t.Format(time.RFC3339)
+	}
+	switch layout[0] {
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	case "unixms":
+		return strconv.FormatInt(t.UnixMilli(), 10)
+	default:
+		return t.Format(layout[0])
+	}
+}
+
+// randInt는 [min, max) 범위의 랜덤 정수를 반환합니다.
+func randInt(min, max interface{}) (int64, error) {
+	lo, err := toFloat(min)
+	if err != nil {
+		return 0, err
+	}
+	hi, err := toFloat(max)
+	if err != nil {
+		return 0, err
+	}
+	if int64(hi) <= int64(lo) {
+		return 0, fmt.Errorf("randInt: max (%v) must be greater than min (%v)", max, min)
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(hi)-int64(lo)))
+	if err != nil {
+		return 0, err
+	}
+	return int64(lo) + n.Int64(), nil
+}
+
+func b64enc(v interface{}) string {
+	return base64.StdEncoding.EncodeToString([]byte(toString(v)))
+}
+
+func b64dec(v interface{}) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(toString(v))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func toJSON(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func defaultValue(def, v interface{}) interface{} {
+	if v == nil {
+		return def
+	}
+	if s, ok := v.(string); ok && s == "" {
+		return def
+	}
+	return v
+}
+
+func toString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+// toFloat는 산술 헬퍼용으로 숫자와 숫자 문자열을 float64 로 변환합니다.
+func toFloat(v interface{}) (float64, error) {
+	switch val := v.(type) {
+	case int:
+		return float64(val), nil
+	case int32:
+		return float64(val), nil
+	case int64:
+		return float64(val), nil
+	case uint:
+		return float64(val), nil
+	case uint32:
+		return float64(val), nil
+	case uint64:
+		return float64(val), nil
+	case float32:
+		return float64(val), nil
+	case float64:
+		return val, nil
+	case json.Number:
+		return val.Float64()
+	case string:
+		return strconv.ParseFloat(val, 64)
+	default:
+		return 0, fmt.Errorf("cannot convert %T to number", v)
+	}
+}
+
+func add(a, b interface{}) (float64, error) {
+	x, y, err := toFloats(a, b)
+	return x + y, err
+}
+
+func sub(a, b interface{}) (float64, error) {
+	x, y, err := toFloats(a, b)
+	return x - y, err
+}
+
+func mul(a, b interface{}) (float64, error) {
+	x, y, err := toFloats(a, b)
+	return x * y, err
+}
+
+func div(a, b interface{}) (float64, error) {
+	x, y, err := toFloats(a, b)
+	if err != nil {
+		return 0, err
+	}
+	if y == 0 {
+		return 0, fmt.Errorf("div: division by zero")
+	}
+	return x / y, nil
+}
+
+func mod(a, b interface{}) (int64, error) {
+	x, y, err := toFloats(a, b)
+	if err != nil {
+		return 0, err
+	}
+	if int64(y) == 0 {
+		return 0, fmt.Errorf("mod: division by zero")
+	}
+	return int64(x) % int64(y), nil
+}
+
+func toFloats(a, b interface{}) (float64, float64, error) {
+	x, err := toFloat(a)
+	if err != nil {
+		return 0, 0, err
+	}
+	y, err := toFloat(b)
+	if err != nil {
+		return 0, 0, err
+	}
+	return x, y, nil
+}
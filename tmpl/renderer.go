@@ -0,0 +1,176 @@
+package tmpl
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// RequestContext는 템플릿에서 참조할 수 있는 요청 정보를 담습니다.
+type RequestContext struct {
+	Method     string
+	Path       string
+	ClientIP   string
+	RequestID  string
+	Vars       map[string]string
+	Query      map[string][]string
+	Headers    map[string][]string
+	Body       interface{}            // 파싱된 JSON 본문 (JSON 이 아니면 nil)
+	Claims     map[string]interface{} // 인증된 호출자 클레임 (익명 요청은 nil)
+	ClientCert map[string]string      // 검증된 TLS 클라이언트 인증서 필드 (mTLS 가 아니면 nil)
+}
+
+// ReservedKeys는 템플릿 데이터의 최상위 키 중 요청 정보가 차지하는 이름입니다.
+// 경로 변수는 이 이름을 쓸 수 없습니다 (CheckPathVars 참고).
+var ReservedKeys = []string{
+	"vars", "body", "query", "headers", "method", "path",
+	"client_ip", "request_id", "claims", "client_cert", "timestamp",
+}
+
+// Data는 템플릿 데이터 맵을 만듭니다.
+//
+// 경로 변수는 .vars 아래(숫자는 int 로 변환)에 두고, 기존 템플릿({{.id}})과의 호환을 위해
+// 최상위에도 둡니다. 나머지 요청 정보는 ReservedKeys 의 이름으로 노출합니다.
+func (rc *RequestContext) Data() map[string]interface{} {
+	data := make(map[string]interface{})
+
+	vars := make(map[string]interface{}, len(rc.Vars))
+	for k, v := range rc.Vars {
+		if id, err := strconv.Atoi(v); err == nil {
+			vars[k] = id
+		} else {
+			vars[k] = v
+		}
+		data[k] = vars[k]
+	}
+
+	data["vars"] = vars
+	data["body"] = rc.Body
+	data["query"] = firstValues(rc.Query)
+	data["headers"] = firstValues(rc.Headers)
+	data["method"] = rc.Method
+	data["path"] = rc.Path
+	data["client_ip"] = rc.ClientIP
+	data["request_id"] = rc.RequestID
+	data["claims"] = rc.Claims
+	data["client_cert"] = rc.ClientCert
+	data["timestamp"] = time.Now().UTC().Format(time.RFC3339)
+
+	return data
+}
+
+// CheckPathVars는 라우트 경로의 {변수}({변수:패턴} 포함) 이름이 ReservedKeys 와
+// 겹치면 오류를 반환합니다. 겹치는 변수는 최상위에서 요청 정보에 가려지므로
+// 라우트 등록 시 거부합니다.
+func CheckPathVars(path string) error {
+	for _, name := range pathVarNames(path) {
+		for _, reserved := range ReservedKeys {
+			if name == reserved {
+				return fmt.Errorf("path variable {%s} collides with the template key .%s (use another name)", name, reserved)
+			}
+		}
+	}
+	return nil
+}
+
+// pathVarNames는 경로에서 {변수} 이름을 추출합니다 (패턴 안의 중괄호는 건너뜀).
+func pathVarNames(path string) []string {
+	var names []string
+	depth, start := 0, 0
+	for i, c := range path {
+		switch c {
+		case '{':
+			if depth == 0 {
+				start = i + 1
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth == 0 {
+				name, _, _ := strings.Cut(path[start:i], ":")
+				names = append(names, strings.TrimSpace(name))
+			}
+		}
+	}
+	return names
+}
+
+// firstValues는 값이 여러 개인 맵(쿼리 문자열, 헤더)을 첫 번째 값만 남겨 평탄화합니다.
+func firstValues(values map[string][]string) map[string]string {
+	flat := make(map[string]string, len(values))
+	for k, v := range values {
+		if len(v) > 0 {
+			flat[k] = v[0]
+		}
+	}
+	return flat
+}
+
+// Renderer는 응답 템플릿을 파싱하고 캐시합니다.
+type Renderer struct {
+	mu        sync.RWMutex
+	templates map[string]*template.Template
+}
+
+// NewRenderer는 빈 템플릿 캐시로 Renderer 를 생성합니다.
+func NewRenderer() *Renderer {
+	return &Renderer{templates: make(map[string]*template.Template)}
+}
+
+// Render는 body 를 data 로 실행한 결과를 반환합니다. "{{" 가 없는 본문은 그대로 반환하고,
+// 파싱/실행 오류는 로그를 남긴 뒤 원본 본문을 반환합니다.
+func (r *Renderer) Render(body string, data map[string]interface{}) string {
+	if !strings.Contains(body, "{{") {
+		return body
+	}
+
+	t, err := r.lookup(body)
+	if err != nil {
+		log.Printf("Template parse error: %v", err)
+		return body
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		log.Printf("Template execute error: %v", err)
+		return body
+	}
+
+	return buf.String()
+}
+
+// lookup은 body 의 캐시된 템플릿을 반환하고, 처음 사용할 때 파싱합니다.
+func (r *Renderer) lookup(body string) (*template.Template, error) {
+	r.mu.RLock()
+	t, ok := r.templates[body]
+	r.mu.RUnlock()
+	if ok {
+		return t, nil
+	}
+
+	t, err := template.New("response").Funcs(FuncMap()).Parse(body)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.templates[body] = t
+	r.mu.Unlock()
+
+	return t, nil
+}
+
+// Reset은 템플릿 캐시를 비웁니다 (예: 설정 리로드 후).
+func (r *Renderer) Reset() {
+	r.mu.Lock()
+	r.templates = make(map[string]*template.Template)
+	r.mu.Unlock()
+}
@@ -0,0 +1,101 @@
+package tmpl
+
+import (
+	"encoding/base64"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestContextData(t *testing.T) {
+	rc := &RequestContext{
+		Method:     "POST",
+		Path:       "/api/devices/42",
+		ClientIP:   "10.0.0.1",
+		RequestID:  "req-1",
+		Vars:       map[string]string{"id": "42", "name": "left"},
+		Query:      map[string][]string{"verbose": {"true", "false"}},
+		Headers:    map[string][]string{"X-Client": {"gw-01"}},
+		Body:       map[string]interface{}{"device_id": "dev-7"},
+		Claims:     map[string]interface{}{"sub": "partner-a"},
+		ClientCert: map[string]string{"cn": "device-42"},
+	}
+
+	data := rc.Data()
+	assert.Equal(t, 42, data["id"])
+	assert.Equal(t, "left", data["name"])
+	assert.Equal(t, "true", data["query"].(map[string]string)["verbose"])
+	assert.Equal(t, "gw-01", data["headers"].(map[string]string)["X-Client"])
+	assert.Equal(t, "dev-7", data["body"].(map[string]interface{})["device_id"])
+	assert.Equal(t, "POST", data["method"])
+	assert.Equal(t, "10.0.0.1", data["client_ip"])
+	assert.Equal(t, "req-1", data["request_id"])
+	assert.Equal(t, "partner-a", data["claims"].(map[string]interface{})["sub"])
+	assert.Equal(t, "device-42", data["client_cert"].(map[string]string)["cn"])
+	assert.NotEmpty(t, data["timestamp"])
+}
+
+func TestCheckPathVars(t *testing.T) {
+	assert.NoError(t, CheckPathVars("/api/devices/{id}/{name:[a-z]{2,}}"))
+	assert.NoError(t, CheckPathVars("/api/static"))
+
+	for _, path := range []string{"/api/{path}", "/api/{id}/{body:.+}", "/files/{query}"} {
+		assert.Error(t, CheckPathVars(path), path)
+	}
+	assert.Equal(t, []string{"id", "name"}, pathVarNames("/a/{id}/{name:[a-z]{2,}}"))
+}
+
+func TestRenderer(t *testing.T) {
+	r := NewRenderer()
+	data := (&RequestContext{
+		Vars: map[string]string{"id": "5"},
+		Body: map[string]interface{}{"device_id": "dev-7", "value": 20.5},
+	}).Data()
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"plain body", `{"ok": true}`, `{"ok": true}`},
+		{"path var", `{"id": {{.id}}}`, `{"id": 5}`},
+		{"body echo", `{{.body.device_id}}`, "dev-7"},
+		{"math", `{{add .body.value 1.5}}|{{mul .id 2}}|{{mod 7 3}}`, "22|10|1"},
+		{"base64", `{{b64enc "hi"}}`, base64.StdEncoding.EncodeToString([]byte("hi"))},
+		{"json", `{{toJSON .body}}`, `{"device_id":"dev-7","value":20.5}`},
+		{"default", `{{default "none" .body.missing}}`, "none"},
+		{"parse error keeps body", `{{.id`, `{{.id`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, r.Render(tt.body, data))
+		})
+	}
+
+	t.Run("uuid and now", func(t *testing.T) {
+		out := r.Render(`{{uuid}}`, data)
+		assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), out)
+
+		out = r.Render(`{{now "unix"}}`, data)
+		_, err := strconv.ParseInt(out, 10, 64)
+		assert.NoError(t, err)
+	})
+
+	t.Run("randInt range", func(t *testing.T) {
+		for i := 0; i < 20; i++ {
+			n, err := strconv.Atoi(r.Render(`{{randInt 3 5}}`, data))
+			require.NoError(t, err)
+			assert.True(t, n >= 3 && n < 5)
+		}
+	})
+
+	t.Run("reset clears cache", func(t *testing.T) {
+		r.Render(`{{.id}}`, data)
+		r.Reset()
+		assert.Empty(t, r.templates)
+	})
+}